@@ -20,6 +20,11 @@ var (
 	StandaloneMode = "standalone"
 )
 
+const (
+	defaultSetMaxIntsetEntries = 512
+	defaultHllSparseMaxBytes   = 3000
+)
+
 // ServerProperties defines global config properties
 type ServerProperties struct {
 	// for Public configuration
@@ -39,6 +44,12 @@ type ServerProperties struct {
 	SlaveAnnouncePort int    `cfg:"slave-announce-port"`
 	SlaveAnnounceIP   string `cfg:"slave-announce-ip"`
 	ReplTimeout       int    `cfg:"repl-timeout"`
+	// SetMaxIntsetEntries 整数集合编码最多保存的元素数量
+	SetMaxIntsetEntries int `cfg:"set-max-intset-entries"`
+	// HllSparseMaxBytes HyperLogLog稀疏编码的最大字节数, 超过后转换为稠密编码
+	HllSparseMaxBytes int `cfg:"hll-sparse-max-bytes"`
+	// NotifyKeyspaceEvents 需要发送的键空间通知类别, 使用与redis相同的标志字母, 为空时不发送
+	NotifyKeyspaceEvents string `cfg:"notify-keyspace-events"`
 
 	// for cluster mode configuration
 	ClusterEnabled string   `cfg:"cluster-enabled"` // Not used at present.
@@ -69,6 +80,9 @@ func init() {
 		Port:       6379,
 		AppendOnly: false,
 		RunID:      utils.RandString(40),
+
+		SetMaxIntsetEntries: defaultSetMaxIntsetEntries,
+		HllSparseMaxBytes:   defaultHllSparseMaxBytes,
 	}
 }
 
@@ -147,6 +161,12 @@ func SetupConfig(configFilename string) {
 	if Properties.Dir == "" {
 		Properties.Dir = "."
 	}
+	if Properties.SetMaxIntsetEntries <= 0 {
+		Properties.SetMaxIntsetEntries = defaultSetMaxIntsetEntries
+	}
+	if Properties.HllSparseMaxBytes <= 0 {
+		Properties.HllSparseMaxBytes = defaultHllSparseMaxBytes
+	}
 }
 
 func GetTmpDir() string {
@@ -0,0 +1,537 @@
+package database
+
+import (
+	"goredis/datastruct/bitmap"
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/redis/protocol"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxBitOffset 位图最多能容纳的位数
+const maxBitOffset = maxStringSize * 8
+
+func parseBitOffset(raw []byte) (int64, redis.Reply) {
+	offset, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || offset < 0 || offset >= maxBitOffset {
+		return 0, protocol.MakeErrReply("ERR bit offset is not an integer or out of range")
+	}
+	return offset, nil
+}
+
+// execSetBit SETBIT key offset value, 返回原来的位, 字符串长度不够时自动用0填充
+func execSetBit(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	offset, errReply := parseBitOffset(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	var val byte
+	switch string(args[2]) {
+	case "0":
+		val = 0
+	case "1":
+		val = 1
+	default:
+		return protocol.MakeErrReply("ERR bit is not an integer or out of range")
+	}
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	// 原地修改, 事务的undo日志和GET等命令的回复保存的都是值的副本
+	bm := bitmap.Grow(bytes, offset+1)
+	old := bm.GetBit(offset)
+	bm.SetBit(offset, val)
+	db.PutEntity(key, &database.DataEntity{Data: []byte(bm)})
+	db.notify(notifyString, "setbit", key)
+	return protocol.MakeIntReply(int64(old))
+}
+
+// execGetBit GETBIT key offset
+func execGetBit(db *DB, args [][]byte) redis.Reply {
+	offset, errReply := parseBitOffset(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	bytes, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return protocol.MakeIntReply(int64(bitmap.BitMap(bytes).GetBit(offset)))
+}
+
+// parseBitRange 解析start end [BYTE|BIT], 返回按位计算的闭区间, 区间为空时返回false.
+// 与GETRANGE一样支持负数下标, 越界时截断
+func parseBitRange(bm bitmap.BitMap, args [][]byte) (start, end int64, ok bool, errReply redis.Reply) {
+	start, err1 := strconv.ParseInt(string(args[0]), 10, 64)
+	end, err2 := strconv.ParseInt(string(args[1]), 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false, protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	isBit := false
+	if len(args) == 3 {
+		switch strings.ToUpper(string(args[2])) {
+		case "BYTE":
+		case "BIT":
+			isBit = true
+		default:
+			return 0, 0, false, protocol.MakeSyntaxErrReply()
+		}
+	}
+	total := int64(len(bm))
+	if isBit {
+		total = bm.BitSize()
+	}
+	if start < 0 && end < 0 && start > end {
+		return 0, 0, false, nil
+	}
+	if start < 0 {
+		start = max(total+start, 0)
+	}
+	if end < 0 {
+		end = max(total+end, 0)
+	}
+	end = min(end, total-1)
+	if start > end {
+		return 0, 0, false, nil
+	}
+	if !isBit {
+		start, end = start*8, end*8+7
+	}
+	return start, end, true, nil
+}
+
+// execBitCount BITCOUNT key [start end [BYTE|BIT]]
+func execBitCount(db *DB, args [][]byte) redis.Reply {
+	if len(args) == 2 || len(args) > 4 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	bytes, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	bm := bitmap.BitMap(bytes)
+	start, end := int64(0), bm.BitSize()-1
+	if len(args) > 1 {
+		var ok bool
+		var rangeErr redis.Reply
+		start, end, ok, rangeErr = parseBitRange(bm, args[1:])
+		if rangeErr != nil {
+			return rangeErr
+		}
+		if !ok {
+			return protocol.MakeIntReply(0)
+		}
+	}
+	return protocol.MakeIntReply(bm.BitCount(start, end))
+}
+
+// execBitPos BITPOS key bit [start [end [BYTE|BIT]]]
+func execBitPos(db *DB, args [][]byte) redis.Reply {
+	if len(args) > 5 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	var bit byte
+	switch string(args[1]) {
+	case "0":
+		bit = 0
+	case "1":
+		bit = 1
+	default:
+		return protocol.MakeErrReply("ERR The bit argument must be 1 or 0.")
+	}
+	bytes, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	bm := bitmap.BitMap(bytes)
+	rangeArgs := args[2:]
+	switch len(rangeArgs) {
+	case 0:
+		rangeArgs = [][]byte{[]byte("0"), []byte("-1")}
+	case 1:
+		rangeArgs = [][]byte{rangeArgs[0], []byte("-1")}
+	}
+	start, end, ok, rangeErr := parseBitRange(bm, rangeArgs)
+	if rangeErr != nil {
+		return rangeErr
+	}
+	if len(bm) == 0 {
+		// 不存在的key视为全是0的字符串
+		if bit == 0 {
+			return protocol.MakeIntReply(0)
+		}
+		return protocol.MakeIntReply(-1)
+	}
+	if !ok {
+		return protocol.MakeIntReply(-1)
+	}
+	pos := bm.BitPos(bit, start, end)
+	// 没有指定end时字符串右侧视为用0填充, 查找0时返回字符串之后的第一位
+	if pos < 0 && bit == 0 && len(args) < 4 {
+		return protocol.MakeIntReply(end + 1)
+	}
+	return protocol.MakeIntReply(pos)
+}
+
+// execBitOp BITOP AND|OR|XOR|NOT|DIFF|ONE destkey key [key ...]
+// 较短的字符串和不存在的key视为用0填充, 结果为空时删除destkey
+func execBitOp(db *DB, args [][]byte) redis.Reply {
+	op := strings.ToUpper(string(args[0]))
+	dst := string(args[1])
+	keys := args[2:]
+	switch op {
+	case "AND", "OR", "XOR", "ONE":
+	case "NOT":
+		if len(keys) != 1 {
+			return protocol.MakeErrReply("ERR BITOP NOT must be called with a single source key.")
+		}
+	case "DIFF":
+		if len(keys) < 2 {
+			return protocol.MakeErrReply("ERR BITOP DIFF must be called with at least two source keys.")
+		}
+	default:
+		return protocol.MakeSyntaxErrReply()
+	}
+	sources := make([][]byte, len(keys))
+	size := 0
+	for i, key := range keys {
+		bytes, errReply := db.getAsString(string(key))
+		if errReply != nil {
+			return errReply
+		}
+		sources[i] = bytes
+		size = max(size, len(bytes))
+	}
+	if size == 0 {
+		if db.Removes(dst) > 0 {
+			db.notify(notifyGeneric, "del", dst)
+		}
+		return protocol.MakeIntReply(0)
+	}
+	byteAt := func(src []byte, i int) byte {
+		if i < len(src) {
+			return src[i]
+		}
+		return 0
+	}
+	result := make([]byte, size)
+	for i := range result {
+		first := byteAt(sources[0], i)
+		switch op {
+		case "NOT":
+			result[i] = ^first
+		case "AND":
+			for _, src := range sources[1:] {
+				first &= byteAt(src, i)
+			}
+			result[i] = first
+		case "OR":
+			for _, src := range sources[1:] {
+				first |= byteAt(src, i)
+			}
+			result[i] = first
+		case "XOR":
+			for _, src := range sources[1:] {
+				first ^= byteAt(src, i)
+			}
+			result[i] = first
+		case "DIFF":
+			// 在第一个key中为1, 在其他key中都为0的位
+			var others byte
+			for _, src := range sources[1:] {
+				others |= byteAt(src, i)
+			}
+			result[i] = first &^ others
+		case "ONE":
+			// 只在一个key中为1的位
+			var once, twice byte
+			for _, src := range sources {
+				b := byteAt(src, i)
+				twice |= once & b
+				once |= b
+			}
+			result[i] = once &^ twice
+		}
+	}
+	db.PutEntity(dst, &database.DataEntity{Data: result})
+	db.Persist(dst)
+	db.notify(notifyString, "set", dst)
+	return protocol.MakeIntReply(int64(size))
+}
+
+func prepareBitOp(args [][]byte) ([]string, []string) {
+	keys := make([]string, len(args)-2)
+	for i, arg := range args[2:] {
+		keys[i] = string(arg)
+	}
+	return []string{string(args[1])}, keys
+}
+
+func undoBitOp(db *DB, args [][]byte) []CmdLine {
+	return rollbackGivenKeys(db, string(args[1]))
+}
+
+const (
+	overflowWrap = iota
+	overflowSat
+	overflowFail
+)
+
+// bitFieldOp BITFIELD的一个子命令
+type bitFieldOp struct {
+	name     string
+	signed   bool
+	width    uint
+	offset   int64
+	value    int64
+	overflow int
+}
+
+// parseBitFieldType 解析i1到i64, u1到u63
+func parseBitFieldType(raw []byte) (signed bool, width uint, errReply redis.Reply) {
+	errReply = protocol.MakeErrReply("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	s := strings.ToLower(string(raw))
+	if len(s) < 2 || (s[0] != 'i' && s[0] != 'u') {
+		return false, 0, errReply
+	}
+	signed = s[0] == 'i'
+	n, err := strconv.Atoi(s[1:])
+	if err != nil || n < 1 || (signed && n > 64) || (!signed && n > 63) {
+		return false, 0, errReply
+	}
+	return signed, uint(n), nil
+}
+
+// parseBitFieldOffset 解析位偏移, #N表示第N个width宽度的整数
+func parseBitFieldOffset(raw []byte, width uint) (int64, redis.Reply) {
+	errReply := protocol.MakeErrReply("ERR bit offset is not an integer or out of range")
+	s := string(raw)
+	multiply := false
+	if strings.HasPrefix(s, "#") {
+		multiply = true
+		s = s[1:]
+	}
+	offset, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, errReply
+	}
+	if multiply {
+		if offset > math.MaxInt64/int64(width) {
+			return 0, errReply
+		}
+		offset *= int64(width)
+	}
+	if offset+int64(width) > maxBitOffset {
+		return 0, errReply
+	}
+	return offset, nil
+}
+
+// parseBitField 解析BITFIELD的子命令, readOnly为true时只允许GET
+func parseBitField(args [][]byte, readOnly bool) ([]*bitFieldOp, redis.Reply) {
+	ops := make([]*bitFieldOp, 0)
+	overflow := overflowWrap
+	for i := 0; i < len(args); i++ {
+		name := strings.ToUpper(string(args[i]))
+		remain := len(args) - i - 1
+		if name == "OVERFLOW" && remain >= 1 {
+			if readOnly {
+				return nil, protocol.MakeErrReply("ERR BITFIELD_RO only supports the GET subcommand")
+			}
+			switch strings.ToUpper(string(args[i+1])) {
+			case "WRAP":
+				overflow = overflowWrap
+			case "SAT":
+				overflow = overflowSat
+			case "FAIL":
+				overflow = overflowFail
+			default:
+				return nil, protocol.MakeErrReply("ERR Invalid OVERFLOW type specified")
+			}
+			i++
+			continue
+		}
+		argCount := 3
+		if name == "GET" {
+			argCount = 2
+		} else if name != "SET" && name != "INCRBY" {
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+		if remain < argCount {
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+		if readOnly && name != "GET" {
+			return nil, protocol.MakeErrReply("ERR BITFIELD_RO only supports the GET subcommand")
+		}
+		signed, width, errReply := parseBitFieldType(args[i+1])
+		if errReply != nil {
+			return nil, errReply
+		}
+		offset, errReply := parseBitFieldOffset(args[i+2], width)
+		if errReply != nil {
+			return nil, errReply
+		}
+		op := &bitFieldOp{name: name, signed: signed, width: width, offset: offset, overflow: overflow}
+		if argCount == 3 {
+			value, err := strconv.ParseInt(string(args[i+3]), 10, 64)
+			if err != nil {
+				return nil, protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			op.value = value
+		}
+		ops = append(ops, op)
+		i += argCount
+	}
+	return ops, nil
+}
+
+// checkUnsignedOverflow 计算value+incr, 溢出时按照overflow处理, FAIL时返回false
+func checkUnsignedOverflow(value uint64, incr int64, width uint, overflow int) (uint64, bool) {
+	maxValue := uint64(1)<<width - 1
+	result := value + uint64(incr)
+	overflowed := value > maxValue ||
+		(incr > 0 && uint64(incr) > maxValue-value) ||
+		(incr < 0 && uint64(-incr) > value)
+	if !overflowed {
+		return result, true
+	}
+	switch overflow {
+	case overflowWrap:
+		return result & maxValue, true
+	case overflowSat:
+		if incr < 0 {
+			return 0, true
+		}
+		return maxValue, true
+	}
+	return 0, false
+}
+
+// checkSignedOverflow 计算value+incr, 溢出时按照overflow处理, FAIL时返回false
+func checkSignedOverflow(value int64, incr int64, width uint, overflow int) (int64, bool) {
+	maxValue := int64(math.MaxInt64)
+	if width < 64 {
+		maxValue = int64(1)<<(width-1) - 1
+	}
+	minValue := -maxValue - 1
+	result := int64(uint64(value) + uint64(incr))
+	// 宽度为64时maxValue-value和minValue-value本身可能溢出, 只在不会溢出时比较
+	tooLarge := value > maxValue || (incr > 0 && (width < 64 || value >= 0) && incr > maxValue-value)
+	tooSmall := value < minValue || (incr < 0 && (width < 64 || value < 0) && incr < minValue-value)
+	if !tooLarge && !tooSmall {
+		return result, true
+	}
+	switch overflow {
+	case overflowWrap:
+		if width < 64 {
+			// 截断到width位后做符号扩展
+			shift := 64 - width
+			result = result << shift >> shift
+		}
+		return result, true
+	case overflowSat:
+		if tooLarge {
+			return maxValue, true
+		}
+		return minValue, true
+	}
+	return 0, false
+}
+
+// execBitFieldGeneric 实现BITFIELD和BITFIELD_RO, 有写操作时才会创建或者扩展字符串
+func execBitFieldGeneric(db *DB, args [][]byte, readOnly bool) redis.Reply {
+	key := string(args[0])
+	ops, errReply := parseBitField(args[1:], readOnly)
+	if errReply != nil {
+		return errReply
+	}
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	bm := bitmap.BitMap(bytes)
+	bitSize := int64(0)
+	for _, op := range ops {
+		if op.name != "GET" {
+			bitSize = max(bitSize, op.offset+int64(op.width))
+		}
+	}
+	writable := bitSize > 0
+	if writable {
+		bm = bitmap.Grow(bytes, bitSize)
+	}
+
+	result := make([]redis.Reply, len(ops))
+	for i, op := range ops {
+		raw := bm.GetBits(op.offset, op.width)
+		if op.name == "GET" {
+			result[i] = protocol.MakeIntReply(bitFieldValue(raw, op))
+			continue
+		}
+		var old int64
+		var incr int64
+		if op.name == "SET" {
+			old, incr = op.value, 0
+		} else {
+			old, incr = bitFieldValue(raw, op), op.value
+		}
+		var updated uint64
+		var ok bool
+		if op.signed {
+			var value int64
+			value, ok = checkSignedOverflow(old, incr, op.width, op.overflow)
+			updated = uint64(value)
+		} else {
+			updated, ok = checkUnsignedOverflow(uint64(old), incr, op.width, op.overflow)
+		}
+		if !ok {
+			result[i] = protocol.MakeNullBulkReply()
+			continue
+		}
+		bm.SetBits(op.offset, op.width, updated)
+		if op.name == "SET" {
+			// SET返回原来的值
+			result[i] = protocol.MakeIntReply(bitFieldValue(raw, op))
+		} else {
+			result[i] = protocol.MakeIntReply(bitFieldValue(bm.GetBits(op.offset, op.width), op))
+		}
+	}
+	if writable {
+		db.PutEntity(key, &database.DataEntity{Data: []byte(bm)})
+		db.notify(notifyString, "setbit", key)
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+// bitFieldValue 按照子命令的类型解释读取到的位
+func bitFieldValue(raw uint64, op *bitFieldOp) int64 {
+	if op.signed && op.width < 64 {
+		shift := 64 - op.width
+		return int64(raw<<shift) >> shift
+	}
+	return int64(raw)
+}
+
+// execBitField BITFIELD key [GET type offset] [SET type offset value] [INCRBY type offset increment] [OVERFLOW WRAP|SAT|FAIL] ...
+func execBitField(db *DB, args [][]byte) redis.Reply {
+	return execBitFieldGeneric(db, args, false)
+}
+
+// execBitFieldRO BITFIELD_RO key [GET type offset ...]
+func execBitFieldRO(db *DB, args [][]byte) redis.Reply {
+	return execBitFieldGeneric(db, args, true)
+}
+
+func init() {
+	registerCommand("SetBit", execSetBit, writeFirstKey, rollbackFirstKey, 4, flagWrite)
+	registerCommand("GetBit", execGetBit, readFirstKey, nil, 3, flagReadOnly)
+	registerCommand("BitCount", execBitCount, readFirstKey, nil, -2, flagReadOnly)
+	registerCommand("BitPos", execBitPos, readFirstKey, nil, -3, flagReadOnly)
+	registerCommand("BitOp", execBitOp, prepareBitOp, undoBitOp, -4, flagWrite)
+	registerCommand("BitField", execBitField, writeFirstKey, rollbackFirstKey, -2, flagWrite)
+	registerCommand("BitField_RO", execBitFieldRO, readFirstKey, nil, -2, flagReadOnly)
+}
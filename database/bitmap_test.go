@@ -0,0 +1,124 @@
+package database
+
+import (
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"testing"
+)
+
+func TestBitmap(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	assertReply(t, db.Exec(c, utils.ToCmdLine("setbit", "k", "7", "1")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("setbit", "k", "7", "0")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("getbit", "k", "100")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("setbit", "k", "-1", "1")), "-ERR bit offset is not an integer or out of range\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("setbit", "k", "1", "2")), "-ERR bit is not an integer or out of range\r\n")
+
+	db.Exec(c, utils.ToCmdLine("set", "s", "foobar"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitcount", "s")), ":26\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitcount", "s", "1", "1")), ":6\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitcount", "s", "5", "30", "BIT")), ":17\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitcount", "s", "-1", "-2")), ":0\r\n")
+
+	db.Exec(c, utils.ToCmdLine("set", "p", "\xff\xf0\x00"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitpos", "p", "0")), ":12\r\n")
+	db.Exec(c, utils.ToCmdLine("set", "p", "\x00\xff\xf0"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitpos", "p", "1", "2")), ":16\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitpos", "p", "1", "7", "15", "BIT")), ":8\r\n")
+	db.Exec(c, utils.ToCmdLine("set", "p", "\xff\xff"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitpos", "p", "0")), ":16\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitpos", "p", "0", "0", "-1")), ":-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitpos", "none", "1")), ":-1\r\n")
+}
+
+func TestBitOp(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("set", "a", "foobar"))
+	db.Exec(c, utils.ToCmdLine("set", "b", "abcdef"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitop", "AND", "dst", "a", "b")), ":6\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("get", "dst")), "$6\r\n`bc`ab\r\n")
+
+	db.Exec(c, utils.ToCmdLine("set", "x", "\x0f"))
+	db.Exec(c, utils.ToCmdLine("set", "y", "\x3c"))
+	db.Exec(c, utils.ToCmdLine("set", "z", "\xf0\x01"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitop", "DIFF", "dst", "x", "y")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("get", "dst")), "$1\r\n\x03\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitop", "ONE", "dst", "x", "y", "z")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("get", "dst")), "$2\r\n\xc3\x01\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitop", "NOT", "dst", "x")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("get", "dst")), "$1\r\n\xf0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitop", "NOT", "dst", "x", "y")),
+		"-ERR BITOP NOT must be called with a single source key.\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitop", "OR", "dst", "none")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("exists", "dst")), ":0\r\n")
+}
+
+func TestBitField(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitfield", "k", "INCRBY", "i5", "100", "1", "GET", "u4", "0")), "*2\r\n:1\r\n:0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitfield", "k", "SET", "i8", "#1", "-100", "GET", "i8", "#1")), "*2\r\n:0\r\n:-100\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitfield", "k", "INCRBY", "i8", "8", "-100")), "*1\r\n:56\r\n")
+
+	for _, expected := range []string{"*2\r\n:1\r\n:1\r\n", "*2\r\n:2\r\n:2\r\n", "*2\r\n:3\r\n:3\r\n", "*2\r\n:0\r\n:3\r\n"} {
+		assertReply(t, db.Exec(c, utils.ToCmdLine("bitfield", "o", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1")), expected)
+	}
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitfield", "o", "OVERFLOW", "FAIL", "INCRBY", "u2", "102", "1")), "*1\r\n$-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitfield", "o", "SET", "i64", "0", "-1", "INCRBY", "i64", "0", "1")), "*2\r\n:0\r\n:0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitfield", "o", "GET", "u64", "0")),
+		"-ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitfield_ro", "o", "SET", "u8", "0", "1")),
+		"-ERR BITFIELD_RO only supports the GET subcommand\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bitfield_ro", "none", "GET", "u8", "0")), "*1\r\n:0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("exists", "none")), ":0\r\n")
+}
+
+func TestBitmapUndo(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("set", "k", "a"))
+	for _, cmdLine := range [][][]byte{
+		utils.ToCmdLine("setbit", "k", "100", "1"),
+		utils.ToCmdLine("bitfield", "k", "SET", "u8", "0", "255"),
+		utils.ToCmdLine("bitop", "NOT", "k", "k"),
+	} {
+		undoLogs := db.GetUndoLogs(cmdLine)
+		db.Exec(c, cmdLine)
+		for _, undo := range undoLogs {
+			db.Exec(c, undo)
+		}
+		assertReply(t, db.Exec(c, utils.ToCmdLine("get", "k")), "$1\r\na\r\n")
+	}
+}
+
+func TestSetBitInPlace(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	// 与解析请求得到的参数一样, 值的容量中还有CRLF
+	value := []byte("ab\r\n")[:2]
+	db.Exec(c, [][]byte{[]byte("set"), []byte("k"), value})
+	cmdLine := utils.ToCmdLine("setbit", "k", "0", "1")
+	undoLogs := db.GetUndoLogs(cmdLine)
+	assertReply(t, db.Exec(c, cmdLine), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("setbit", "k", "23", "1")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("get", "k")), "$3\r\n\xe1b\x01\r\n")
+	for _, undo := range undoLogs {
+		db.Exec(c, undo)
+	}
+	assertReply(t, db.Exec(c, utils.ToCmdLine("get", "k")), "$2\r\nab\r\n")
+}
+
+func TestBitmapReplyNotAliased(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("set", "k", "\x00\x00"))
+	get := db.Exec(c, utils.ToCmdLine("get", "k"))
+	getRange := db.Exec(c, utils.ToCmdLine("getrange", "k", "0", "0"))
+	db.Exec(c, utils.ToCmdLine("setbit", "k", "0", "1"))
+	db.Exec(c, utils.ToCmdLine("bitfield", "k", "SET", "u8", "8", "255"))
+	assertReply(t, get, "$2\r\n\x00\x00\r\n")
+	assertReply(t, getRange, "$1\r\n\x00\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("get", "k")), "$2\r\n\x80\xff\r\n")
+}
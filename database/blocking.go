@@ -0,0 +1,280 @@
+package database
+
+import (
+	"container/list"
+	"fmt"
+	"goredis/interface/redis"
+	"goredis/lib/timewheel"
+	"goredis/redis/protocol"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BlockingFunc 解析阻塞命令的参数, 返回需要等待的key以及超时时间, 超时时间为0表示一直等待.
+// 没有返回key时按照普通命令执行, 如没有BLOCK选项的XREAD
+type BlockingFunc func(args [][]byte) (keys []string, timeout time.Duration, errReply redis.Reply)
+
+// waiter 一个被阻塞的客户端
+type waiter struct {
+	conn redis.Conn
+	keys []string
+	// elems 记录waiter在每个key的等待队列中的位置, 用于离开时O(1)删除
+	elems []*list.Element
+
+	// wakeup 有数据写入时发送信号, 缓冲为1, 多次唤醒会合并
+	wakeup chan struct{}
+	// done 超时或者连接关闭时被关闭
+	done     chan struct{}
+	doneOnce sync.Once
+	// db 等待队列所在的DB, SWAPDB时随等待队列迁移到另一个DB
+	db atomic.Pointer[DB]
+}
+
+func (w *waiter) finish() {
+	w.doneOnce.Do(func() {
+		close(w.done)
+	})
+}
+
+// blockingManager 管理一个DB中阻塞在各个key上的客户端, 每个key的等待队列按照先进先出排列
+type blockingManager struct {
+	mu      sync.Mutex
+	waiters map[string]*list.List // key -> *waiter 队列
+	conns   map[redis.Conn]*waiter
+	// count 阻塞的客户端数量, 没有阻塞的客户端时写命令不需要加锁检查
+	count atomic.Int32
+}
+
+func newBlockingManager() *blockingManager {
+	return &blockingManager{
+		waiters: make(map[string]*list.List),
+		conns:   make(map[redis.Conn]*waiter),
+	}
+}
+
+// add 将waiter加入db的等待队列. db在连接选中之后被SWAPDB交换走时返回false, 调用方需要重新选择DB
+func (m *blockingManager) add(db *DB, w *waiter) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if int(db.index.Load()) != w.conn.GetDBIndex() {
+		return false
+	}
+	w.db.Store(db)
+	w.elems = make([]*list.Element, len(w.keys))
+	for i, key := range w.keys {
+		queue, ok := m.waiters[key]
+		if !ok {
+			queue = list.New()
+			m.waiters[key] = queue
+		}
+		w.elems[i] = queue.PushBack(w)
+	}
+	m.conns[w.conn] = w
+	m.count.Add(1)
+	return true
+}
+
+// remove 将waiter从所有等待队列中移除, waiter已经被迁移到其他DB时返回false
+func (m *blockingManager) remove(w *waiter) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conns[w.conn] != w {
+		return false
+	}
+	for i, key := range w.keys {
+		queue := m.waiters[key]
+		queue.Remove(w.elems[i])
+		if queue.Len() == 0 {
+			delete(m.waiters, key)
+		}
+	}
+	delete(m.conns, w.conn)
+	m.count.Add(-1)
+	return true
+}
+
+// leave 离开等待队列, 并把可能剩余的数据交给队列中的下一个客户端
+func (w *waiter) leave() {
+	for {
+		// remove失败说明SWAPDB刚刚把waiter迁移到了另一个DB, 重新读取
+		db := w.db.Load()
+		if db.blocking.remove(w) {
+			db.blocking.signal(w.keys...)
+			return
+		}
+	}
+}
+
+// signal 唤醒每个key等待队列中的第一个客户端
+// 被唤醒的客户端在离开时会再次调用signal, 所以剩余的数据会按顺序交给后面的客户端
+func (m *blockingManager) signal(keys ...string) {
+	if m.count.Load() == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		queue, ok := m.waiters[key]
+		if !ok {
+			continue
+		}
+		w := queue.Front().Value.(*waiter)
+		select {
+		case w.wakeup <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// swapBlocking 与SWAPDB一起交换两个DB的编号和等待队列, 阻塞的客户端继续等待它选中的编号上的DB.
+// 交换后唤醒所有客户端, 在新的DB中重试
+func swapBlocking(db1, db2 *DB) {
+	m1, m2 := db1.blocking, db2.blocking
+	// 只有持有swapMu时才会同时锁两个blockingManager, 不会死锁
+	m1.mu.Lock()
+	defer m1.mu.Unlock()
+	m2.mu.Lock()
+	defer m2.mu.Unlock()
+	index1 := db1.index.Load()
+	db1.index.Store(db2.index.Load())
+	db2.index.Store(index1)
+	m1.waiters, m2.waiters = m2.waiters, m1.waiters
+	m1.conns, m2.conns = m2.conns, m1.conns
+	count1 := m1.count.Load()
+	m1.count.Store(m2.count.Load())
+	m2.count.Store(count1)
+	m1.wakeAll(db1)
+	m2.wakeAll(db2)
+}
+
+// wakeAll 唤醒所有客户端, 调用方需要持有m.mu
+func (m *blockingManager) wakeAll(db *DB) {
+	for _, w := range m.conns {
+		w.db.Store(db)
+		select {
+		case w.wakeup <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// cancel 连接关闭时结束它的阻塞
+func (m *blockingManager) cancel(c redis.Conn) {
+	m.mu.Lock()
+	w, ok := m.conns[c]
+	m.mu.Unlock()
+	if ok {
+		w.finish()
+	}
+}
+
+// isEmptyReply 阻塞命令的执行函数在没有数据可以弹出时返回空回复
+func isEmptyReply(reply redis.Reply) bool {
+	switch reply.(type) {
+	case *protocol.NullBulkReply, *protocol.NullMultiBulkReply:
+		return true
+	}
+	return false
+}
+
+// errBlockingDBSwapped 阻塞命令开始等待前DB被SWAPDB交换, 需要在连接选中的DB中重新执行
+var errBlockingDBSwapped = protocol.MakeErrReply("ERR DB swapped while blocking")
+
+// execBlocking 执行阻塞命令: 先尝试一次, 没有数据时排队等待写入, 直到成功, 超时或者连接关闭
+func (db *DB) execBlocking(c redis.Conn, cmd *command, cmdLine [][]byte) redis.Reply {
+	keys, timeout, errReply := cmd.blocking(cmdLine[1:])
+	if errReply != nil {
+		return errReply
+	}
+	if len(keys) == 0 {
+		return db.execNormalCommand(c, cmd, cmdLine)
+	}
+	write, read := cmd.prepare(cmdLine[1:])
+
+	w := &waiter{
+		conn:   c,
+		keys:   keys,
+		wakeup: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	// 第一次尝试和加入等待队列都在持有key的锁时完成, 不会错过两者之间的写入
+	db.RWLocks(write, read)
+	reply := db.execute(cmd, cmdLine)
+	if !isEmptyReply(reply) {
+		db.addVersion(write...)
+		db.trackCommand(c, cmd, write, read, reply)
+		db.RWUnlock(write, read)
+		db.blocking.signal(write...)
+		return reply
+	}
+	if !db.blocking.add(db, w) {
+		db.RWUnlock(write, read)
+		return errBlockingDBSwapped
+	}
+	db.RWUnlock(write, read)
+	defer w.leave()
+
+	if timeout > 0 {
+		taskKey := fmt.Sprintf("blocking:%p", w)
+		timewheel.Delay(timeout, taskKey, w.finish)
+		defer timewheel.Cancel(taskKey)
+	}
+	for {
+		select {
+		case <-w.wakeup:
+			db = w.db.Load()
+			// 没有数据时不修改版本号, 避免阻塞中的客户端使其他客户端的WATCH失效
+			db.RWLocks(write, read)
+			reply = db.execute(cmd, cmdLine)
+			success := !isEmptyReply(reply)
+			if success {
+				db.addVersion(write...)
+				db.trackCommand(c, cmd, write, read, reply)
+			}
+			db.RWUnlock(write, read)
+			if success {
+				db.blocking.signal(write...)
+				return reply
+			}
+		case <-w.done:
+			return reply
+		}
+	}
+}
+
+// parseTimeout 解析以秒为单位的超时时间, 支持小数
+func parseTimeout(raw []byte) (time.Duration, redis.Reply) {
+	seconds, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, protocol.MakeErrReply("ERR timeout is not a float or out of range")
+	}
+	if seconds < 0 {
+		return 0, protocol.MakeErrReply("ERR timeout is negative")
+	}
+	if seconds > float64(math.MaxInt64/int64(time.Second)) {
+		return 0, protocol.MakeErrReply("ERR timeout is out of range")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// lastArgTimeout 适用于超时时间是最后一个参数的命令, 其余参数中前keyCount个是key, keyCount为-1时表示全部
+func lastArgTimeout(keyCount int) BlockingFunc {
+	return func(args [][]byte) ([]string, time.Duration, redis.Reply) {
+		timeout, errReply := parseTimeout(args[len(args)-1])
+		if errReply != nil {
+			return nil, 0, errReply
+		}
+		n := keyCount
+		if n < 0 {
+			n = len(args) - 1
+		}
+		keys := make([]string, n)
+		for i := range keys {
+			keys[i] = string(args[i])
+		}
+		return keys, timeout, nil
+	}
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"goredis/interface/redis"
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"testing"
+	"time"
+)
+
+// execAsync 在新的协程中执行命令, 并等待它进入阻塞状态
+func execAsync(db *DB, c redis.Conn, args ...string) <-chan redis.Reply {
+	ch := make(chan redis.Reply, 1)
+	before := db.blocking.count.Load()
+	go func() {
+		ch <- db.Exec(c, utils.ToCmdLine(args...))
+	}()
+	for db.blocking.count.Load() == before {
+		time.Sleep(time.Millisecond)
+	}
+	return ch
+}
+
+func TestBLPop(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("rpush", "b", "1"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("blpop", "a", "b", "0")), "*2\r\n$1\r\nb\r\n$1\r\n1\r\n")
+
+	ch := execAsync(db, conn.NewFakeConn(), "brpop", "a", "b", "0")
+	db.Exec(c, utils.ToCmdLine("rpush", "b", "2", "3"))
+	assertReply(t, <-ch, "*2\r\n$1\r\nb\r\n$1\r\n3\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lrange", "b", "0", "-1")), "*1\r\n$1\r\n2\r\n")
+
+	assertReply(t, db.Exec(c, utils.ToCmdLine("blpop", "a", "-1")), "-ERR timeout is negative\r\n")
+}
+
+func TestBlockingFIFO(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	first := execAsync(db, conn.NewFakeConn(), "blpop", "l", "0")
+	second := execAsync(db, conn.NewFakeConn(), "blpop", "l", "0")
+	db.Exec(c, utils.ToCmdLine("rpush", "l", "a", "b"))
+	assertReply(t, <-first, "*2\r\n$1\r\nl\r\n$1\r\na\r\n")
+	assertReply(t, <-second, "*2\r\n$1\r\nl\r\n$1\r\nb\r\n")
+}
+
+func TestBlockingTimeoutAndCancel(t *testing.T) {
+	db := newDB()
+	assertReply(t, db.Exec(conn.NewFakeConn(), utils.ToCmdLine("blmpop", "0.5", "1", "l", "LEFT")), "*-1\r\n")
+
+	blocked := conn.NewFakeConn()
+	ch := execAsync(db, blocked, "blmove", "src", "dst", "LEFT", "RIGHT", "0")
+	db.blocking.cancel(blocked)
+	assertReply(t, <-ch, "$-1\r\n")
+	if db.blocking.count.Load() != 0 {
+		t.Error("waiter should be removed after cancel")
+	}
+}
+
+func TestBlockingInMulti(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	c.SetMultiState(true)
+	assertReply(t, db.Exec(c, utils.ToCmdLine("blpop", "l", "0")), "*-1\r\n")
+}
+
+func TestBZPop(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("zadd", "z", "1", "a", "2", "b", "3", "c"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bzpopmax", "none", "z", "0")), "*3\r\n$1\r\nz\r\n$1\r\nc\r\n$1\r\n3\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zmpop", "2", "none", "z", "MIN", "COUNT", "5")),
+		"*2\r\n$1\r\nz\r\n*2\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n")
+	db.Exec(c, utils.ToCmdLine("zadd", "z", "1", "a"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zmpop", "1", "z", "MIN", "COUNT", "9223372036854775807")),
+		"*2\r\n$1\r\nz\r\n*1\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zmpop", "1", "z", "MIN")), "*-1\r\n")
+
+	first := execAsync(db, conn.NewFakeConn(), "bzpopmin", "z", "0")
+	second := execAsync(db, conn.NewFakeConn(), "bzmpop", "0", "1", "z", "MAX")
+	db.Exec(c, utils.ToCmdLine("zadd", "z", "1", "x", "2", "y"))
+	assertReply(t, <-first, "*3\r\n$1\r\nz\r\n$1\r\nx\r\n$1\r\n1\r\n")
+	assertReply(t, <-second, "*2\r\n$1\r\nz\r\n*1\r\n*2\r\n$1\r\ny\r\n$1\r\n2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("bzpopmin", "z", "0.2")), "*-1\r\n")
+}
@@ -6,6 +6,10 @@ import (
 	"goredis/interface/database"
 	"goredis/interface/redis"
 	"goredis/lib/timewheel"
+	"goredis/pubsub"
+	"goredis/redis/protocol"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,11 +18,24 @@ const (
 	ttlDictSize  = 1 << 10
 )
 
+// dbIDGenerator 为每个DB分配不变的id, 同时锁两个DB时按照id的顺序加锁
+var dbIDGenerator atomic.Uint64
+
 type DB struct {
-	index      int
+	id uint64
+	// index DB当前的编号, SWAPDB时与另一个DB交换
+	index      atomic.Int32
 	dict       *dict.ConcurrentDict
 	ttlMap     *dict.ConcurrentDict
 	versionMap *dict.ConcurrentDict
+	// epoch 清空或者交换DB时更新, 所有key的版本号都不小于它
+	epoch atomic.Uint32
+	// blocking 阻塞在这个DB的key上的客户端
+	blocking *blockingManager
+	// hub 用于发送键空间通知, 为nil时不发送
+	hub *pubsub.Hub
+	// tracking 用于客户端缓存的失效通知, 为nil时不追踪
+	tracking *trackingTable
 
 	addAof func(CmdLine)
 }
@@ -33,33 +50,97 @@ type UndoFunc func(db *DB, args [][]byte) []CmdLine
 
 func newDB() *DB {
 	return &DB{
+		id:         dbIDGenerator.Add(1),
 		dict:       dict.NewConcurrent(dataDictSize),
 		ttlMap:     dict.NewConcurrent(ttlDictSize),
 		versionMap: dict.NewConcurrent(dataDictSize),
+		blocking:   newBlockingManager(),
 		addAof:     func(line CmdLine) {},
 	}
 }
 
 func newBasicDB() *DB {
 	db := &DB{
+		id:         dbIDGenerator.Add(1),
 		dict:       dict.NewConcurrent(dataDictSize),
 		ttlMap:     dict.NewConcurrent(ttlDictSize),
 		versionMap: dict.NewConcurrent(dataDictSize),
+		blocking:   newBlockingManager(),
 		addAof:     func(line CmdLine) {},
 	}
 	return db
 }
 
+// Exec 在当前数据库中执行命令
 func (db *DB) Exec(c redis.Conn, cmdLine [][]byte) redis.Reply {
+	cmd, errReply := lookupCommand(cmdLine)
+	if errReply != nil {
+		return errReply
+	}
+	if cmd.blocking != nil && c != nil && !c.InMultiState() {
+		return db.execBlocking(c, cmd, cmdLine)
+	}
+	return db.execNormalCommand(c, cmd, cmdLine)
+}
 
+// execNormalCommand 对命令涉及的key加锁后执行
+func (db *DB) execNormalCommand(c redis.Conn, cmd *command, cmdLine [][]byte) redis.Reply {
+	write, read := cmd.prepare(cmdLine[1:])
+	db.RWLocks(write, read)
+	defer db.RWUnlock(write, read)
+	db.addVersion(cmd.versionKeys(write)...)
+	reply := db.execute(cmd, cmdLine)
+	db.trackCommand(c, cmd, write, read, reply)
+	db.blocking.signal(write...)
+	return reply
 }
 
-func (db *DB) execNormalCommand(cmdLine [][]byte) redis.Reply {
+// execWithLock 执行命令, 调用方需要持有命令涉及的key的锁
+func (db *DB) execWithLock(cmdLine [][]byte) redis.Reply {
+	cmd, errReply := lookupCommand(cmdLine)
+	if errReply != nil {
+		return errReply
+	}
+	reply := db.execute(cmd, cmdLine)
+	write, read := cmd.prepare(cmdLine[1:])
+	db.trackCommand(nil, cmd, write, read, reply)
+	db.blocking.signal(write...)
+	return reply
+}
 
+// execute 执行命令, 并将执行成功的写命令写入aof
+func (db *DB) execute(cmd *command, cmdLine [][]byte) redis.Reply {
+	db.notifyKeyMiss(cmd, cmdLine)
+	reply := cmd.executor(db, cmdLine[1:])
+	if cmd.flags&(flagReadOnly|flagCustomAof) == 0 {
+		if _, isErr := reply.(protocol.ErrorReply); !isErr {
+			db.addAof(cmdLine)
+		}
+	}
+	return reply
 }
 
-func (db *DB) execWithLock(cmdLine [][]byte) redis.Reply {
+// lookupCommand 查找命令并检查参数个数
+func lookupCommand(cmdLine [][]byte) (*command, redis.Reply) {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd, ok := cmdTable[cmdName]
+	if !ok {
+		return nil, protocol.MakeErrReply("ERR unknown command '" + cmdName + "'")
+	}
+	if !validateArity(cmd.arity, cmdLine) {
+		return nil, protocol.MakeArgNumErrReply(cmdName)
+	}
+	return cmd, nil
+}
 
+// GetUndoLogs 在执行命令前调用, 返回用于回滚cmdLine的命令
+func (db *DB) GetUndoLogs(cmdLine [][]byte) []CmdLine {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd, ok := cmdTable[cmdName]
+	if !ok || cmd.undo == nil {
+		return nil
+	}
+	return cmd.undo(db, cmdLine[1:])
 }
 
 func validateArity(arity int, cmdArgs [][]byte) bool {
@@ -85,7 +166,11 @@ func (db *DB) GetEntity(key string) (*database.DataEntity, bool) {
 
 // TODO where is lock?
 func (db *DB) PutEntity(key string, entity *database.DataEntity) int {
-	return db.dict.PutWithLock(key, entity)
+	result := db.dict.PutWithLock(key, entity)
+	if result > 0 {
+		db.notify(notifyNew, "new", key)
+	}
+	return result
 }
 
 // TODO where is lock?
@@ -95,14 +180,19 @@ func (db *DB) PutIfExists(key string, entity *database.DataEntity) int {
 
 // TODO where is lock?
 func (db *DB) PutIfAbsent(key string, entity *database.DataEntity) int {
-	return db.dict.PutIfAbsentWithLock(key, entity)
+	result := db.dict.PutIfAbsentWithLock(key, entity)
+	if result > 0 {
+		db.notify(notifyNew, "new", key)
+	}
+	return result
 }
 
 // Remove 删除Key 需要持锁
 func (db *DB) Remove(key string) {
 	db.dict.RemoveWithLock(key)
 	db.ttlMap.Remove(key)
-	taskKey :=
+	taskKey := db.genExpireTask(key)
+	timewheel.Cancel(taskKey)
 }
 
 // Removes 移除传入的keys，不需要持锁
@@ -121,6 +211,9 @@ func (db *DB) Removes(keys ...string) int {
 func (db *DB) Flush() {
 	db.dict.Clear()
 	db.ttlMap.Clear()
+	// epoch大于之前所有的版本号, 不再需要保存每个key的版本号
+	db.touchAll()
+	db.versionMap.Clear()
 }
 
 func (db *DB) RWLocks(writeKeys, readkeys []string) {
@@ -131,14 +224,15 @@ func (db *DB) RWUnlock(writeKeys, readkeys []string) {
 	db.dict.RWUnLocks(writeKeys, readkeys)
 }
 
-func genExpireTask(key string) string {
-	return fmt.Sprintf("expire:%s", key)
+// genExpireTask 生成时间轮中的任务名, 不同DB中的同名key需要对应不同的任务
+func (db *DB) genExpireTask(key string) string {
+	return fmt.Sprintf("expire:%p:%s", db, key)
 }
 
 // Expire 对key设置过期时间
 func (db *DB) Expire(key string, expireTime time.Time) {
 	db.ttlMap.Put(key, expireTime)
-	taskKey := genExpireTask(key)
+	taskKey := db.genExpireTask(key)
 	timewheel.At(expireTime, taskKey, func() {
 		keys := []string{key}
 		db.RWLocks(keys, nil)
@@ -151,6 +245,8 @@ func (db *DB) Expire(key string, expireTime time.Time) {
 		expired := time.Now().After(expire)
 		if expired {
 			db.Remove(key)
+			db.notify(notifyExpired, "expired", key)
+			db.invalidate(key)
 		}
 	})
 }
@@ -158,36 +254,46 @@ func (db *DB) Expire(key string, expireTime time.Time) {
 // Persister 取消key的过期时间
 func (db *DB) Persist(key string) {
 	db.ttlMap.Remove(key)
-	timewheel.Cancel(genExpireTask(key)
+	timewheel.Cancel(db.genExpireTask(key))
 }
 
 func (db *DB) IsExpired(key string) bool {
 	rawExpireTime, ok := db.ttlMap.Get(key)
 	if !ok {
-		// key如果过期了被删除了，那么ttlmap中就不存在了
-		return true
+		// 没有设置过期时间
+		return false
 	}
 	expireTime, _ := rawExpireTime.(time.Time)
 	expired := time.Now().After(expireTime)
 	if expired {
 		db.Remove(key)
+		db.notify(notifyExpired, "expired", key)
+		db.invalidate(key)
 	}
 	return expired
 }
 
+// versionCounter 所有DB共享的版本号, 只增不减. 交换DB后同一个编号下的key也能得到比WATCH时更大的版本号
+var versionCounter atomic.Uint32
+
 func (db *DB) addVersion(keys ...string) {
 	for _, k := range keys {
-		versionCode := db.GetVersion(k)
-		db.versionMap.Put(k, versionCode+1)
+		db.versionMap.Put(k, versionCounter.Add(1))
 	}
 }
 
+// touchAll 使所有key的版本号变大, 用于FLUSHDB、FLUSHALL和SWAPDB
+func (db *DB) touchAll() {
+	db.epoch.Store(versionCounter.Add(1))
+}
+
 func (db *DB) GetVersion(key string) uint32 {
+	epoch := db.epoch.Load()
 	entity, ok := db.versionMap.Get(key)
 	if !ok {
-		return 0
+		return epoch
 	}
-	return entity.(uint32)
+	return max(entity.(uint32), epoch)
 }
 
 func (db *DB) ForEach(consumer func(key string, data *database.DataEntity, expiration *time.Time) bool) {
@@ -196,6 +302,10 @@ func (db *DB) ForEach(consumer func(key string, data *database.DataEntity, expir
 
 		var expiration *time.Time
 		rawExpiration, ok := db.ttlMap.Get(key)
-
-	}
+		if ok {
+			expireTime, _ := rawExpiration.(time.Time)
+			expiration = &expireTime
+		}
+		return consumer(key, entity, expiration)
+	})
 }
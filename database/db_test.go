@@ -0,0 +1,57 @@
+package database
+
+import (
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"goredis/redis/protocol"
+	"testing"
+)
+
+func execTestWrite(db *DB, args [][]byte) redis.Reply {
+	if string(args[1]) == "fail" {
+		return protocol.MakeErrReply("ERR failed")
+	}
+	db.PutEntity(string(args[0]), &database.DataEntity{Data: args[1]})
+	return protocol.MakeOkReply()
+}
+
+func init() {
+	registerCommand("testwrite", execTestWrite, writeFirstKey, nil, 3, flagWrite)
+}
+
+func TestExecPipeline(t *testing.T) {
+	db := newDB()
+	var aofLines []CmdLine
+	db.addAof = func(line CmdLine) {
+		aofLines = append(aofLines, line)
+	}
+	c := conn.NewFakeConn()
+
+	result := db.Exec(c, utils.ToCmdLine("nosuchcmd"))
+	if !protocol.IsErrorReply(result) {
+		t.Errorf("expected unknown command error, actual %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("testwrite", "a"))
+	if string(result.ToBytes()) != string(protocol.MakeArgNumErrReply("testwrite").ToBytes()) {
+		t.Errorf("expected arity error, actual %s", result.ToBytes())
+	}
+
+	version := db.GetVersion("a")
+	result = db.Exec(c, utils.ToCmdLine("TestWrite", "a", "1"))
+	if !protocol.IsOKReply(result) {
+		t.Errorf("exec failed: %s", result.ToBytes())
+	}
+	// 版本号来自所有DB共享的计数器, 只保证写入后变大
+	if db.GetVersion("a") <= version {
+		t.Errorf("expected version greater than %d, actual %d", version, db.GetVersion("a"))
+	}
+	result = db.Exec(c, utils.ToCmdLine("testwrite", "a", "fail"))
+	if !protocol.IsErrorReply(result) {
+		t.Errorf("expected error, actual %s", result.ToBytes())
+	}
+	if len(aofLines) != 1 || string(aofLines[0][2]) != "1" {
+		t.Errorf("only successful write should be written to aof, actual %d lines", len(aofLines))
+	}
+}
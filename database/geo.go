@@ -0,0 +1,447 @@
+package database
+
+import (
+	"fmt"
+	"goredis/datastruct/sortedset"
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/lib/geohash"
+	"goredis/redis/protocol"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 与redis一样, 地理位置保存在有序集合中, 分数是经纬度的52位geohash编码
+
+func parseCoordinate(lngRaw, latRaw []byte) (latitude, longitude float64, errReply redis.Reply) {
+	longitude, err := strconv.ParseFloat(string(lngRaw), 64)
+	if err != nil {
+		return 0, 0, protocol.MakeErrReply("ERR value is not a valid float")
+	}
+	latitude, err = strconv.ParseFloat(string(latRaw), 64)
+	if err != nil {
+		return 0, 0, protocol.MakeErrReply("ERR value is not a valid float")
+	}
+	if !geohash.Valid(latitude, longitude) {
+		return 0, 0, protocol.MakeErrReply(fmt.Sprintf("ERR invalid longitude,latitude pair %f,%f", longitude, latitude))
+	}
+	return latitude, longitude, nil
+}
+
+// parseUnit 返回单位对应的米数
+func parseUnit(raw []byte) (float64, redis.Reply) {
+	switch strings.ToLower(string(raw)) {
+	case "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "ft":
+		return 0.3048, nil
+	case "mi":
+		return 1609.34, nil
+	}
+	return 0, protocol.MakeErrReply("ERR unsupported unit provided. please use M, KM, FT, MI")
+}
+
+func formatDistance(dist float64) []byte {
+	return []byte(strconv.FormatFloat(dist, 'f', 4, 64))
+}
+
+func formatCoordinate(score float64) redis.Reply {
+	latitude, longitude := geohash.Decode(uint64(score))
+	return protocol.MakeMultiBulkReply([][]byte{
+		[]byte(strconv.FormatFloat(longitude, 'f', -1, 64)),
+		[]byte(strconv.FormatFloat(latitude, 'f', -1, 64)),
+	})
+}
+
+// execGeoAdd GEOADD key [NX|XX] [CH] longitude latitude member [longitude latitude member ...]
+// 转换为ZADD key [NX|XX] [CH] score member ...执行
+func execGeoAdd(db *DB, args [][]byte) redis.Reply {
+	i := 1
+	zaddArgs := [][]byte{args[0]}
+loop:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX", "XX", "CH":
+			zaddArgs = append(zaddArgs, args[i])
+		default:
+			break loop
+		}
+	}
+	triples := args[i:]
+	if len(triples) == 0 || len(triples)%3 != 0 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	for j := 0; j < len(triples); j += 3 {
+		latitude, longitude, errReply := parseCoordinate(triples[j], triples[j+1])
+		if errReply != nil {
+			return errReply
+		}
+		score := geohash.Encode(latitude, longitude)
+		zaddArgs = append(zaddArgs, []byte(strconv.FormatUint(score, 10)), triples[j+2])
+	}
+	return execZAdd(db, zaddArgs)
+}
+
+// execGeoPos GEOPOS key [member ...]
+func execGeoPos(db *DB, args [][]byte) redis.Reply {
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	positions := make([]redis.Reply, len(args)-1)
+	for i, member := range args[1:] {
+		if zset == nil {
+			positions[i] = protocol.MakeNullMultiBulkReply()
+			continue
+		}
+		elem, ok := zset.Get(string(member))
+		if !ok {
+			positions[i] = protocol.MakeNullMultiBulkReply()
+			continue
+		}
+		positions[i] = formatCoordinate(elem.Score)
+	}
+	return protocol.MakeMultiRawReply(positions)
+}
+
+// execGeoDist GEODIST key member1 member2 [M|KM|FT|MI]
+func execGeoDist(db *DB, args [][]byte) redis.Reply {
+	if len(args) > 4 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	unit := 1.0
+	if len(args) == 4 {
+		var errReply redis.Reply
+		unit, errReply = parseUnit(args[3])
+		if errReply != nil {
+			return errReply
+		}
+	}
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	elem1, ok1 := zset.Get(string(args[1]))
+	elem2, ok2 := zset.Get(string(args[2]))
+	if !ok1 || !ok2 {
+		return protocol.MakeNullBulkReply()
+	}
+	lat1, lng1 := geohash.Decode(uint64(elem1.Score))
+	lat2, lng2 := geohash.Decode(uint64(elem2.Score))
+	return protocol.MakeBulkReply(formatDistance(geohash.Distance(lat1, lng1, lat2, lng2) / unit))
+}
+
+// execGeoHash GEOHASH key [member ...]
+func execGeoHash(db *DB, args [][]byte) redis.Reply {
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	hashes := make([][]byte, len(args)-1)
+	for i, member := range args[1:] {
+		if zset == nil {
+			continue
+		}
+		elem, ok := zset.Get(string(member))
+		if !ok {
+			continue
+		}
+		latitude, longitude := geohash.Decode(uint64(elem.Score))
+		hashes[i] = []byte(geohash.ToString(latitude, longitude))
+	}
+	return protocol.MakeMultiBulkReply(hashes)
+}
+
+// geoSearchOption GEOSEARCH的参数
+type geoSearchOption struct {
+	fromMember []byte
+	fromLonLat bool
+	latitude   float64
+	longitude  float64
+
+	byRadius bool
+	byBox    bool
+	radius   float64
+	width    float64
+	height   float64
+	// unit 距离单位对应的米数
+	unit float64
+
+	// sort 1为ASC, -1为DESC, 0为不排序
+	sort  int
+	count int
+	any   bool
+
+	withCoord bool
+	withDist  bool
+	withHash  bool
+	storeDist bool
+}
+
+// parseGeoSearch 解析FROMMEMBER member|FROMLONLAT longitude latitude BYRADIUS radius unit|BYBOX width height unit
+// [ASC|DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH], store为true时解析GEOSEARCHSTORE的[STOREDIST]
+func parseGeoSearch(args [][]byte, store bool) (*geoSearchOption, redis.Reply) {
+	option := &geoSearchOption{}
+	for i := 0; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		remain := len(args) - i - 1
+		switch {
+		case arg == "FROMMEMBER" && remain >= 1:
+			if option.fromMember != nil || option.fromLonLat {
+				return nil, protocol.MakeErrReply("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH")
+			}
+			option.fromMember = args[i+1]
+			i++
+		case arg == "FROMLONLAT" && remain >= 2:
+			if option.fromMember != nil || option.fromLonLat {
+				return nil, protocol.MakeErrReply("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH")
+			}
+			latitude, longitude, errReply := parseCoordinate(args[i+1], args[i+2])
+			if errReply != nil {
+				return nil, errReply
+			}
+			option.fromLonLat = true
+			option.latitude, option.longitude = latitude, longitude
+			i += 2
+		case arg == "BYRADIUS" && remain >= 2:
+			if option.byRadius || option.byBox {
+				return nil, protocol.MakeErrReply("ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH")
+			}
+			radius, err := strconv.ParseFloat(string(args[i+1]), 64)
+			if err != nil {
+				return nil, protocol.MakeErrReply("ERR need numeric radius")
+			}
+			if radius < 0 {
+				return nil, protocol.MakeErrReply("ERR radius cannot be negative")
+			}
+			unit, errReply := parseUnit(args[i+2])
+			if errReply != nil {
+				return nil, errReply
+			}
+			option.byRadius = true
+			option.radius, option.unit = radius*unit, unit
+			i += 2
+		case arg == "BYBOX" && remain >= 3:
+			if option.byRadius || option.byBox {
+				return nil, protocol.MakeErrReply("ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH")
+			}
+			width, err1 := strconv.ParseFloat(string(args[i+1]), 64)
+			height, err2 := strconv.ParseFloat(string(args[i+2]), 64)
+			if err1 != nil || err2 != nil {
+				return nil, protocol.MakeErrReply("ERR need numeric width and height")
+			}
+			if width < 0 || height < 0 {
+				return nil, protocol.MakeErrReply("ERR height or width cannot be negative")
+			}
+			unit, errReply := parseUnit(args[i+3])
+			if errReply != nil {
+				return nil, errReply
+			}
+			option.byBox = true
+			option.width, option.height, option.unit = width*unit, height*unit, unit
+			i += 3
+		case arg == "ASC":
+			option.sort = 1
+		case arg == "DESC":
+			option.sort = -1
+		case arg == "COUNT" && remain >= 1:
+			count, err := strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				return nil, protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if count <= 0 {
+				return nil, protocol.MakeErrReply("ERR COUNT must be > 0")
+			}
+			option.count = count
+			i++
+			if i+1 < len(args) && strings.ToUpper(string(args[i+1])) == "ANY" {
+				option.any = true
+				i++
+			}
+		case arg == "ANY":
+			return nil, protocol.MakeErrReply("ERR the ANY argument requires COUNT argument")
+		case arg == "WITHCOORD" && !store:
+			option.withCoord = true
+		case arg == "WITHDIST" && !store:
+			option.withDist = true
+		case arg == "WITHHASH" && !store:
+			option.withHash = true
+		case arg == "STOREDIST" && store:
+			option.storeDist = true
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	if option.fromMember == nil && !option.fromLonLat {
+		return nil, protocol.MakeErrReply("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH")
+	}
+	if !option.byRadius && !option.byBox {
+		return nil, protocol.MakeErrReply("ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH")
+	}
+	// 与redis一样, 指定COUNT而没有ANY时按距离从近到远返回最近的count个
+	if option.count > 0 && !option.any && option.sort == 0 {
+		option.sort = 1
+	}
+	return option, nil
+}
+
+// geoPoint 搜索结果
+type geoPoint struct {
+	member string
+	score  float64
+	// dist 到中心点的距离, 单位为米
+	dist float64
+}
+
+// geoSearch 在中心点所在的区域及其相邻区域中查找满足条件的成员
+func geoSearch(zset *sortedset.SortedSet, option *geoSearchOption) []*geoPoint {
+	radius := option.radius
+	if option.byBox {
+		radius = math.Sqrt(option.width*option.width+option.height*option.height) / 2
+	}
+	// 指定ANY时找到count个就停止, 否则需要找出所有满足条件的成员再排序
+	limit := 0
+	if option.any {
+		limit = option.count
+	}
+	points := make([]*geoPoint, 0)
+	for _, scoreRange := range geohash.GetNeighbours(option.latitude, option.longitude, radius) {
+		min := &sortedset.ScoreBorder{Value: float64(scoreRange[0])}
+		max := &sortedset.ScoreBorder{Value: float64(scoreRange[1]), Exclude: true}
+		zset.ForEachByBorder(min, max, 0, -1, false, func(elem *sortedset.Element) bool {
+			latitude, longitude := geohash.Decode(uint64(elem.Score))
+			var dist float64
+			if option.byBox {
+				var ok bool
+				dist, ok = geohash.DistanceInBox(option.width, option.height, option.latitude, option.longitude, latitude, longitude)
+				if !ok {
+					return true
+				}
+			} else {
+				dist = geohash.Distance(option.latitude, option.longitude, latitude, longitude)
+				if dist > option.radius {
+					return true
+				}
+			}
+			points = append(points, &geoPoint{member: elem.Member, score: elem.Score, dist: dist})
+			return limit == 0 || len(points) < limit
+		})
+		if limit > 0 && len(points) >= limit {
+			break
+		}
+	}
+	if option.sort != 0 {
+		sort.SliceStable(points, func(i, j int) bool {
+			if option.sort > 0 {
+				return points[i].dist < points[j].dist
+			}
+			return points[i].dist > points[j].dist
+		})
+	}
+	if option.count > 0 && len(points) > option.count {
+		points = points[:option.count]
+	}
+	return points
+}
+
+// searchGeneric 解析中心点并执行搜索, 中心成员不存在时返回错误
+func searchGeneric(db *DB, key string, option *geoSearchOption) ([]*geoPoint, redis.Reply) {
+	zset, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if zset == nil {
+		return nil, nil
+	}
+	if option.fromMember != nil {
+		elem, ok := zset.Get(string(option.fromMember))
+		if !ok {
+			return nil, protocol.MakeErrReply("ERR could not decode requested zset member")
+		}
+		option.latitude, option.longitude = geohash.Decode(uint64(elem.Score))
+	}
+	return geoSearch(zset, option), nil
+}
+
+// execGeoSearch GEOSEARCH key FROMMEMBER member|FROMLONLAT longitude latitude BYRADIUS radius unit|BYBOX width height unit
+// [ASC|DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]
+func execGeoSearch(db *DB, args [][]byte) redis.Reply {
+	option, errReply := parseGeoSearch(args[1:], false)
+	if errReply != nil {
+		return errReply
+	}
+	points, errReply := searchGeneric(db, string(args[0]), option)
+	if errReply != nil {
+		return errReply
+	}
+	if !option.withCoord && !option.withDist && !option.withHash {
+		members := make([][]byte, len(points))
+		for i, point := range points {
+			members[i] = []byte(point.member)
+		}
+		return protocol.MakeMultiBulkReply(members)
+	}
+	result := make([]redis.Reply, len(points))
+	for i, point := range points {
+		item := []redis.Reply{protocol.MakeBulkReply([]byte(point.member))}
+		if option.withDist {
+			item = append(item, protocol.MakeBulkReply(formatDistance(point.dist/option.unit)))
+		}
+		if option.withHash {
+			item = append(item, protocol.MakeIntReply(int64(point.score)))
+		}
+		if option.withCoord {
+			item = append(item, formatCoordinate(point.score))
+		}
+		result[i] = protocol.MakeMultiRawReply(item)
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+// execGeoSearchStore GEOSEARCHSTORE destination source FROMMEMBER member|FROMLONLAT longitude latitude
+// BYRADIUS radius unit|BYBOX width height unit [ASC|DESC] [COUNT count [ANY]] [STOREDIST]
+func execGeoSearchStore(db *DB, args [][]byte) redis.Reply {
+	dst := string(args[0])
+	option, errReply := parseGeoSearch(args[2:], true)
+	if errReply != nil {
+		return errReply
+	}
+	points, errReply := searchGeneric(db, string(args[1]), option)
+	if errReply != nil {
+		return errReply
+	}
+	if len(points) == 0 {
+		if db.Removes(dst) > 0 {
+			db.notify(notifyGeneric, "del", dst)
+		}
+		return protocol.MakeIntReply(0)
+	}
+	zset := sortedset.New()
+	for _, point := range points {
+		if option.storeDist {
+			zset.Add(point.member, point.dist/option.unit)
+		} else {
+			zset.Add(point.member, point.score)
+		}
+	}
+	db.PutEntity(dst, &database.DataEntity{Data: zset})
+	db.Persist(dst)
+	db.notify(notifyZSet, "geosearchstore", dst)
+	return protocol.MakeIntReply(zset.Len())
+}
+
+func init() {
+	registerCommand("GeoAdd", execGeoAdd, writeFirstKey, rollbackFirstKey, -5, flagWrite)
+	registerCommand("GeoPos", execGeoPos, readFirstKey, nil, -2, flagReadOnly)
+	registerCommand("GeoDist", execGeoDist, readFirstKey, nil, -4, flagReadOnly)
+	registerCommand("GeoHash", execGeoHash, readFirstKey, nil, -2, flagReadOnly)
+	registerCommand("GeoSearch", execGeoSearch, readFirstKey, nil, -7, flagReadOnly)
+	registerCommand("GeoSearchStore", execGeoSearchStore, prepareZRangeStore, rollbackFirstKey, -8, flagWrite)
+}
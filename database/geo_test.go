@@ -0,0 +1,38 @@
+package database
+
+import (
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"testing"
+)
+
+func TestGeo(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	assertReply(t, db.Exec(c, utils.ToCmdLine("geoadd", "Sicily", "13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("geoadd", "Sicily", "200", "100", "x")),
+		"-ERR invalid longitude,latitude pair 200.000000,100.000000\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("geodist", "Sicily", "Palermo", "Catania")), "$11\r\n166274.1516\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("geodist", "Sicily", "Palermo", "Catania", "km")), "$8\r\n166.2742\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("geodist", "Sicily", "Palermo", "none")), "$-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("geohash", "Sicily", "Palermo", "none")), "*2\r\n$11\r\nsqc8b49rny0\r\n$-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("geopos", "Sicily", "Palermo", "none")),
+		"*2\r\n*2\r\n$18\r\n13.361389338970184\r\n$17\r\n38.11555639549629\r\n*-1\r\n")
+
+	db.Exec(c, utils.ToCmdLine("geoadd", "Sicily", "12.758489", "38.788135", "edge1", "17.241510", "38.788135", "edge2"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("geosearch", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ASC")),
+		"*2\r\n$7\r\nCatania\r\n$7\r\nPalermo\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("geosearch", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "400", "400", "km", "DESC", "COUNT", "2", "WITHDIST")),
+		"*2\r\n*2\r\n$5\r\nedge1\r\n$8\r\n279.7405\r\n*2\r\n$5\r\nedge2\r\n$8\r\n279.7403\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("geosearch", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", "1", "m", "WITHHASH")),
+		"*1\r\n*2\r\n$7\r\nPalermo\r\n:3479099956230698\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("geosearch", "Sicily", "FROMMEMBER", "none", "BYRADIUS", "1", "m")),
+		"-ERR could not decode requested zset member\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("geosearch", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", "1", "m", "ANY")),
+		"-ERR the ANY argument requires COUNT argument\r\n")
+
+	assertReply(t, db.Exec(c, utils.ToCmdLine("geosearchstore", "dst", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "STOREDIST")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrange", "dst", "0", "0")), "*1\r\n$7\r\nCatania\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("geosearchstore", "dst", "Sicily", "FROMLONLAT", "0", "0", "BYRADIUS", "1", "km")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("exists", "dst")), ":0\r\n")
+}
@@ -0,0 +1,432 @@
+package database
+
+import (
+	"goredis/datastruct/dict"
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/redis/protocol"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// getAsHash 返回key对应的哈希表, key不存在时返回nil
+func (db *DB) getAsHash(key string) (dict.Dict, protocol.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return nil, nil
+	}
+	hash, ok := entity.Data.(dict.Dict)
+	if !ok {
+		return nil, &protocol.WrongTypeErrReply{}
+	}
+	return hash, nil
+}
+
+// getOrInitHash 返回key对应的哈希表, key不存在时创建一个空的哈希表
+func (db *DB) getOrInitHash(key string) (hash dict.Dict, isNew bool, errReply protocol.ErrorReply) {
+	hash, errReply = db.getAsHash(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	if hash == nil {
+		hash = dict.NewSimple()
+		db.PutEntity(key, &database.DataEntity{Data: hash})
+		isNew = true
+	}
+	return hash, isNew, nil
+}
+
+// removeEmptyHash 哈希表中没有未过期的字段时删除key
+func (db *DB) removeEmptyHash(key string, hash dict.Dict) {
+	if hashLen(hash) == 0 {
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+}
+
+// hashGet 读取字段的值, 已经过期的字段视为不存在
+func hashGet(hash dict.Dict, field string) ([]byte, bool) {
+	if fieldExpired(hash, field) {
+		return nil, false
+	}
+	raw, ok := hash.Get(field)
+	if !ok {
+		return nil, false
+	}
+	return raw.([]byte), true
+}
+
+// hashSet 写入字段并清除它的过期时间, 返回新增的字段数
+func hashSet(hash dict.Dict, field string, value []byte) int {
+	_, existed := hashGet(hash, field)
+	hash.Put(field, value)
+	clearFieldTTL(hash, field)
+	if existed {
+		return 0
+	}
+	return 1
+}
+
+// hashRemove 删除字段, 返回删除的未过期字段数
+func hashRemove(hash dict.Dict, field string) int {
+	_, existed := hashGet(hash, field)
+	hash.Remove(field)
+	clearFieldTTL(hash, field)
+	if existed {
+		return 1
+	}
+	return 0
+}
+
+// hashLen 返回未过期的字段数
+func hashLen(hash dict.Dict) int {
+	return hash.Len() - countExpiredFields(hash)
+}
+
+// hashForEach 遍历未过期的字段
+func hashForEach(hash dict.Dict, consumer func(field string, value []byte) bool) {
+	hash.ForEach(func(field string, raw any) bool {
+		if fieldExpired(hash, field) {
+			return true
+		}
+		return consumer(field, raw.([]byte))
+	})
+}
+
+// execHSet HSET key field value [field value ...]
+func execHSet(db *DB, args [][]byte) redis.Reply {
+	if len(args)%2 != 1 {
+		return protocol.MakeArgNumErrReply("hset")
+	}
+	key := string(args[0])
+	hash, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := 0
+	for i := 1; i < len(args); i += 2 {
+		result += hashSet(hash, string(args[i]), args[i+1])
+	}
+	db.notify(notifyHash, "hset", key)
+	return protocol.MakeIntReply(int64(result))
+}
+
+// execHMSet HMSET key field value [field value ...]
+func execHMSet(db *DB, args [][]byte) redis.Reply {
+	if len(args)%2 != 1 {
+		return protocol.MakeArgNumErrReply("hmset")
+	}
+	reply := execHSet(db, args)
+	if _, ok := reply.(*protocol.IntReply); !ok {
+		return reply
+	}
+	return protocol.MakeOkReply()
+}
+
+// execHSetNX HSETNX key field value
+func execHSetNX(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	hash, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if _, exists := hashGet(hash, field); exists {
+		return protocol.MakeIntReply(0)
+	}
+	hashSet(hash, field, args[2])
+	db.notify(notifyHash, "hset", key)
+	return protocol.MakeIntReply(1)
+}
+
+// execHGet HGET key field
+func execHGet(db *DB, args [][]byte) redis.Reply {
+	hash, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	value, exists := hashGet(hash, string(args[1]))
+	if !exists {
+		return protocol.MakeNullBulkReply()
+	}
+	return protocol.MakeBulkReply(value)
+}
+
+// execHMGet HMGET key field [field ...]
+func execHMGet(db *DB, args [][]byte) redis.Reply {
+	hash, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, len(args)-1)
+	if hash == nil {
+		return protocol.MakeMultiBulkReply(result)
+	}
+	for i, field := range args[1:] {
+		result[i], _ = hashGet(hash, string(field))
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// execHDel HDEL key field [field ...]
+func execHDel(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeIntReply(0)
+	}
+	deleted := 0
+	for _, field := range args[1:] {
+		deleted += hashRemove(hash, string(field))
+	}
+	if deleted > 0 {
+		db.notify(notifyHash, "hdel", key)
+	}
+	db.removeEmptyHash(key, hash)
+	return protocol.MakeIntReply(int64(deleted))
+}
+
+// execHExists HEXISTS key field
+func execHExists(db *DB, args [][]byte) redis.Reply {
+	hash, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeIntReply(0)
+	}
+	if _, exists := hashGet(hash, string(args[1])); exists {
+		return protocol.MakeIntReply(1)
+	}
+	return protocol.MakeIntReply(0)
+}
+
+// execHLen HLEN key
+func execHLen(db *DB, args [][]byte) redis.Reply {
+	hash, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(int64(hashLen(hash)))
+}
+
+// execHStrLen HSTRLEN key field
+func execHStrLen(db *DB, args [][]byte) redis.Reply {
+	hash, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeIntReply(0)
+	}
+	value, _ := hashGet(hash, string(args[1]))
+	return protocol.MakeIntReply(int64(len(value)))
+}
+
+// execHKeys HKEYS key
+func execHKeys(db *DB, args [][]byte) redis.Reply {
+	hash, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	fields := make([][]byte, 0, hash.Len())
+	hashForEach(hash, func(field string, value []byte) bool {
+		fields = append(fields, []byte(field))
+		return true
+	})
+	return protocol.MakeMultiBulkReply(fields)
+}
+
+// execHVals HVALS key
+func execHVals(db *DB, args [][]byte) redis.Reply {
+	hash, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	values := make([][]byte, 0, hash.Len())
+	hashForEach(hash, func(field string, value []byte) bool {
+		values = append(values, value)
+		return true
+	})
+	return protocol.MakeMultiBulkReply(values)
+}
+
+// execHGetAll HGETALL key, RESP3下返回map
+func execHGetAll(db *DB, args [][]byte) redis.Reply {
+	hash, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeMapReply(nil)
+	}
+	pairs := make([]redis.Reply, 0, hash.Len()*2)
+	hashForEach(hash, func(field string, value []byte) bool {
+		pairs = append(pairs, protocol.MakeBulkReply([]byte(field)), protocol.MakeBulkReply(value))
+		return true
+	})
+	return protocol.MakeMapReply(pairs)
+}
+
+// execHIncrBy HINCRBY key field increment
+func execHIncrBy(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	delta, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	hash, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	var value int64
+	if raw, exists := hashGet(hash, field); exists {
+		value, err = strconv.ParseInt(string(raw), 10, 64)
+		if err != nil {
+			db.removeEmptyHash(key, hash)
+			return protocol.MakeErrReply("ERR hash value is not an integer")
+		}
+	}
+	if (delta > 0 && value > math.MaxInt64-delta) || (delta < 0 && value < math.MinInt64-delta) {
+		db.removeEmptyHash(key, hash)
+		return protocol.MakeErrReply("ERR increment or decrement would overflow")
+	}
+	value += delta
+	hashSet(hash, field, []byte(strconv.FormatInt(value, 10)))
+	db.notify(notifyHash, "hincrby", key)
+	return protocol.MakeIntReply(value)
+}
+
+// execHIncrByFloat HINCRBYFLOAT key field increment
+func execHIncrByFloat(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	delta, err := strconv.ParseFloat(string(args[2]), 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return protocol.MakeErrReply("ERR value is not a valid float")
+	}
+	hash, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	var value float64
+	if raw, exists := hashGet(hash, field); exists {
+		value, err = strconv.ParseFloat(string(raw), 64)
+		if err != nil {
+			db.removeEmptyHash(key, hash)
+			return protocol.MakeErrReply("ERR hash value is not a float")
+		}
+	}
+	value += delta
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		db.removeEmptyHash(key, hash)
+		return protocol.MakeErrReply("ERR increment would produce NaN or Infinity")
+	}
+	result := []byte(strconv.FormatFloat(value, 'f', -1, 64))
+	hashSet(hash, field, result)
+	db.notify(notifyHash, "hincrbyfloat", key)
+	return protocol.MakeBulkReply(result)
+}
+
+// execHRandField HRANDFIELD key [count [WITHVALUES]], count为负数时允许重复
+func execHRandField(db *DB, args [][]byte) redis.Reply {
+	if len(args) > 3 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	hash, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if len(args) == 1 {
+		if hash == nil || hashLen(hash) == 0 {
+			return protocol.MakeNullBulkReply()
+		}
+		fields, _ := liveFields(hash)
+		return protocol.MakeBulkReply([]byte(fields[rand.Intn(len(fields))]))
+	}
+
+	count, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	withValues := false
+	if len(args) == 3 {
+		if strings.ToUpper(string(args[2])) != "WITHVALUES" {
+			return protocol.MakeSyntaxErrReply()
+		}
+		withValues = true
+	}
+	if hash == nil || count == 0 {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	fields, values := liveFields(hash)
+	var picked []int
+	if count > 0 {
+		// 不重复: 打乱下标后取前count个
+		picked = rand.Perm(len(fields))[:min(int(count), len(fields))]
+	} else {
+		if count < -math.MaxInt32 {
+			return protocol.MakeErrReply("ERR value is out of range")
+		}
+		picked = make([]int, -count)
+		for i := range picked {
+			picked[i] = rand.Intn(len(fields))
+		}
+	}
+	result := make([][]byte, 0, len(picked)*2)
+	for _, i := range picked {
+		result = append(result, []byte(fields[i]))
+		if withValues {
+			result = append(result, values[i])
+		}
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// liveFields 返回所有未过期的字段和值
+func liveFields(hash dict.Dict) ([]string, [][]byte) {
+	fields := make([]string, 0, hash.Len())
+	values := make([][]byte, 0, hash.Len())
+	hashForEach(hash, func(field string, value []byte) bool {
+		fields = append(fields, field)
+		values = append(values, value)
+		return true
+	})
+	return fields, values
+}
+
+func init() {
+	registerCommand("HSet", execHSet, writeFirstKey, rollbackFirstKey, -4, flagWrite)
+	registerCommand("HMSet", execHMSet, writeFirstKey, rollbackFirstKey, -4, flagWrite)
+	registerCommand("HSetNX", execHSetNX, writeFirstKey, rollbackFirstKey, 4, flagWrite)
+	registerCommand("HGet", execHGet, readFirstKey, nil, 3, flagReadOnly)
+	registerCommand("HMGet", execHMGet, readFirstKey, nil, -3, flagReadOnly)
+	registerCommand("HDel", execHDel, writeFirstKey, rollbackFirstKey, -3, flagWrite)
+	registerCommand("HExists", execHExists, readFirstKey, nil, 3, flagReadOnly)
+	registerCommand("HLen", execHLen, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("HStrLen", execHStrLen, readFirstKey, nil, 3, flagReadOnly)
+	registerCommand("HKeys", execHKeys, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("HVals", execHVals, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("HGetAll", execHGetAll, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("HIncrBy", execHIncrBy, writeFirstKey, rollbackFirstKey, 4, flagWrite)
+	registerCommand("HIncrByFloat", execHIncrByFloat, writeFirstKey, rollbackFirstKey, 4, flagWrite)
+	registerCommand("HRandField", execHRandField, readFirstKey, nil, -2, flagReadOnly)
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"goredis/redis/protocol"
+	"testing"
+	"time"
+)
+
+func TestHash(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hset", "h", "a", "1", "b", "2")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hset", "h", "a", "3", "c", "4")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hsetnx", "h", "a", "5")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hget", "h", "a")), "$1\r\n3\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hmget", "h", "b", "none")), "*2\r\n$1\r\n2\r\n$-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hlen", "h")), ":3\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hstrlen", "h", "c")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hincrby", "h", "a", "-5")), ":-2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hincrbyfloat", "h", "b", "0.5")), "$3\r\n2.5\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hincrby", "h", "b", "1")), "-ERR hash value is not an integer\r\n")
+	if reply, ok := db.Exec(c, utils.ToCmdLine("hrandfield", "h", "-5")).(*protocol.MultiBulkReply); !ok || len(reply.Args) != 5 {
+		t.Error("hrandfield with negative count should return repeated fields")
+	}
+	if reply, ok := db.Exec(c, utils.ToCmdLine("hrandfield", "h", "5", "WITHVALUES")).(*protocol.MultiBulkReply); !ok || len(reply.Args) != 6 {
+		t.Error("hrandfield with positive count should return distinct fields")
+	}
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hdel", "h", "a", "b", "c", "d")), ":3\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("exists", "h")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hgetall", "h")), "*0\r\n")
+}
+
+func TestHashFieldTTL(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("hset", "h", "a", "1", "b", "2", "c", "3"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hpexpire", "h", "100", "FIELDS", "2", "a", "none")), "*2\r\n:1\r\n:-2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hexpire", "h", "100", "NX", "FIELDS", "2", "a", "b")), "*2\r\n:0\r\n:1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("httl", "h", "FIELDS", "3", "b", "c", "none")), "*3\r\n:100\r\n:-1\r\n:-2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hpersist", "h", "FIELDS", "2", "b", "c")), "*2\r\n:1\r\n:-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("httl", "h", "FIELDS", "2", "a")),
+		"-ERR The `numfields` parameter must match the number of arguments\r\n")
+
+	time.Sleep(200 * time.Millisecond)
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hget", "h", "a")), "$-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hlen", "h")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hset", "h", "a", "x")), ":1\r\n")
+
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hgetex", "h", "PX", "100", "FIELDS", "2", "a", "none")), "*2\r\n$1\r\nx\r\n$-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hgetdel", "h", "FIELDS", "2", "b", "c")), "*2\r\n$1\r\n2\r\n$1\r\n3\r\n")
+	// 时间轮在过期后删除最后一个字段, 同时删除key
+	time.Sleep(2500 * time.Millisecond)
+	assertReply(t, db.Exec(c, utils.ToCmdLine("exists", "h")), ":0\r\n")
+}
+
+func TestHashUndo(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("hset", "h", "a", "1", "b", "2"))
+	db.Exec(c, utils.ToCmdLine("hexpire", "h", "100", "FIELDS", "1", "a"))
+	cmdLine := utils.ToCmdLine("hdel", "h", "a", "b")
+	undoLogs := db.GetUndoLogs(cmdLine)
+	db.Exec(c, cmdLine)
+	for _, undo := range undoLogs {
+		db.Exec(c, undo)
+	}
+	assertReply(t, db.Exec(c, utils.ToCmdLine("hlen", "h")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("httl", "h", "FIELDS", "2", "a", "b")), "*2\r\n:100\r\n:-1\r\n")
+}
+
+func TestHashEntityToCmds(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("hset", "h", "a", "1", "b", "2", "c", "3", "d", "4"))
+	db.Exec(c, utils.ToCmdLine("hpexpire", "h", "1", "FIELDS", "1", "a"))
+	db.Exec(c, utils.ToCmdLine("hexpire", "h", "100", "FIELDS", "2", "b", "d"))
+	time.Sleep(10 * time.Millisecond)
+	entity, _ := db.GetEntity("h")
+	cmdLines := EntityToCmds("h", entity)
+	// 一条HSET, 过期时间相同的b和d合并为一条HPEXPIREAT
+	if len(cmdLines) != 2 {
+		t.Fatalf("expected 2 commands, actual %d", len(cmdLines))
+	}
+
+	// 已经过期的字段不会被重写, 其余字段的过期时间精确到毫秒恢复
+	restored := newDB()
+	for _, cmdLine := range cmdLines {
+		restored.Exec(c, cmdLine)
+	}
+	assertReply(t, restored.Exec(c, utils.ToCmdLine("hlen", "h")), ":3\r\n")
+	assertReply(t, restored.Exec(c, utils.ToCmdLine("httl", "h", "FIELDS", "3", "a", "b", "c")), "*3\r\n:-2\r\n:100\r\n:-1\r\n")
+	expireTime := utils.ToCmdLine("hpexpiretime", "h", "FIELDS", "2", "b", "d")
+	expected := protocol.Marshal(db.Exec(c, expireTime), protocol.RESP2)
+	assertReply(t, restored.Exec(c, expireTime), string(expected))
+}
@@ -0,0 +1,507 @@
+package database
+
+import (
+	"fmt"
+	"goredis/datastruct/dict"
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/lib/timewheel"
+	"goredis/lib/utils"
+	"goredis/redis/protocol"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ttlHash 设置过字段过期时间的哈希表, 内嵌了dict.Dict, 只关心字段的代码可以把它当作普通的哈希表使用
+// 过期的字段在读取时被忽略, 由时间轮在过期后删除
+type ttlHash struct {
+	dict.Dict
+	expires map[string]time.Time
+}
+
+// fieldExpireTime 返回字段的过期时间
+func fieldExpireTime(hash dict.Dict, field string) (time.Time, bool) {
+	h, ok := hash.(*ttlHash)
+	if !ok {
+		return time.Time{}, false
+	}
+	expireAt, ok := h.expires[field]
+	return expireAt, ok
+}
+
+func fieldExpired(hash dict.Dict, field string) bool {
+	expireAt, ok := fieldExpireTime(hash, field)
+	return ok && time.Now().After(expireAt)
+}
+
+func clearFieldTTL(hash dict.Dict, field string) {
+	if h, ok := hash.(*ttlHash); ok {
+		delete(h.expires, field)
+	}
+}
+
+func countExpiredFields(hash dict.Dict) int {
+	h, ok := hash.(*ttlHash)
+	if !ok {
+		return 0
+	}
+	now := time.Now()
+	count := 0
+	for _, expireAt := range h.expires {
+		if now.After(expireAt) {
+			count++
+		}
+	}
+	return count
+}
+
+// asTTLHash 第一次设置字段过期时间时将key的值替换为ttlHash
+func asTTLHash(entity *database.DataEntity) *ttlHash {
+	if h, ok := entity.Data.(*ttlHash); ok {
+		return h
+	}
+	h := &ttlHash{
+		Dict:    entity.Data.(dict.Dict),
+		expires: make(map[string]time.Time),
+	}
+	entity.Data = h
+	return h
+}
+
+// genFieldExpireTask 生成字段过期任务的任务名, 加上key的长度避免key和字段拼接后产生歧义
+func (db *DB) genFieldExpireTask(key, field string) string {
+	return fmt.Sprintf("hexpire:%p:%d:%s:%s", db, len(key), key, field)
+}
+
+// expireField 设置字段的过期时间, 并在时间轮中添加删除字段的任务
+func (db *DB) expireField(key string, h *ttlHash, field string, expireAt time.Time) {
+	h.expires[field] = expireAt
+	timewheel.At(expireAt, db.genFieldExpireTask(key, field), func() {
+		keys := []string{key}
+		db.RWLocks(keys, nil)
+		defer db.RWUnlock(keys, nil)
+		entity, ok := db.GetEntity(key)
+		if !ok {
+			return
+		}
+		h, ok := entity.Data.(*ttlHash)
+		if !ok {
+			return
+		}
+		expireAt, ok := h.expires[field]
+		if !ok {
+			return
+		}
+		if !time.Now().After(expireAt) {
+			// 时间轮的精度是秒, 任务可能提前执行
+			db.expireField(key, h, field, expireAt)
+			return
+		}
+		h.Remove(field)
+		delete(h.expires, field)
+		db.notify(notifyHash, "hexpired", key)
+		db.removeEmptyHash(key, h)
+		db.invalidate(key)
+	})
+}
+
+// persistField 取消字段的过期时间
+func (db *DB) persistField(key string, hash dict.Dict, field string) {
+	clearFieldTTL(hash, field)
+	timewheel.Cancel(db.genFieldExpireTask(key, field))
+}
+
+// restoreFieldTTL key被重命名或者复制后, 为新的key重新添加字段过期任务
+func (db *DB) restoreFieldTTL(key string, entity *database.DataEntity) {
+	h, ok := entity.Data.(*ttlHash)
+	if !ok {
+		return
+	}
+	for field, expireAt := range h.expires {
+		db.expireField(key, h, field, expireAt)
+	}
+}
+
+// fieldTTLCmds 生成恢复字段过期时间的命令, 过期时间相同的字段合并到一条命令中, 已经过期的字段会被跳过
+func fieldTTLCmds(key string, entity *database.DataEntity) []CmdLine {
+	h, ok := entity.Data.(*ttlHash)
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	groups := make(map[int64][]string)
+	for field, expireAt := range h.expires {
+		if now.After(expireAt) {
+			continue
+		}
+		ms := expireAt.UnixMilli()
+		groups[ms] = append(groups[ms], field)
+	}
+	cmdLines := make([]CmdLine, 0, len(groups))
+	for ms, fields := range groups {
+		cmdLines = append(cmdLines, makeFieldExpireCmd(key, time.UnixMilli(ms), fields...))
+	}
+	return cmdLines
+}
+
+// makeFieldExpireCmd 生成绝对时间的HPEXPIREAT命令
+func makeFieldExpireCmd(key string, expireAt time.Time, fields ...string) CmdLine {
+	cmdLine := utils.ToCmdLine("HPEXPIREAT", key, strconv.FormatInt(expireAt.UnixMilli(), 10),
+		"FIELDS", strconv.Itoa(len(fields)))
+	for _, field := range fields {
+		cmdLine = append(cmdLine, []byte(field))
+	}
+	return cmdLine
+}
+
+// parseHashFields 解析 FIELDS numfields field [field ...]
+func parseHashFields(args [][]byte) ([]string, redis.Reply) {
+	if len(args) < 2 || strings.ToUpper(string(args[0])) != "FIELDS" {
+		return nil, protocol.MakeErrReply("ERR Mandatory argument FIELDS is missing or not at the right position")
+	}
+	numFields, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil || numFields <= 0 {
+		return nil, protocol.MakeErrReply("ERR Parameter `numFields` should be greater than 0")
+	}
+	if numFields != int64(len(args)-2) {
+		return nil, protocol.MakeErrReply("ERR The `numfields` parameter must match the number of arguments")
+	}
+	fields := make([]string, numFields)
+	for i := range fields {
+		fields[i] = string(args[2+i])
+	}
+	return fields, nil
+}
+
+// fieldsIndex 返回FIELDS参数的位置, 不存在时返回len(args)
+func fieldsIndex(args [][]byte) int {
+	for i, arg := range args {
+		if strings.ToUpper(string(arg)) == "FIELDS" {
+			return i
+		}
+	}
+	return len(args)
+}
+
+func makeIntArrayReply(codes []int64) redis.Reply {
+	replies := make([]redis.Reply, len(codes))
+	for i, code := range codes {
+		replies[i] = protocol.MakeIntReply(code)
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+// hexpireGeneric 实现HEXPIRE族命令, 每个字段的返回值:
+// -2 字段不存在, 0 不满足NX/XX/GT/LT条件, 1 设置成功, 2 过期时间已经过去, 字段被删除
+func hexpireGeneric(db *DB, cmdName string, args [][]byte, unit time.Duration, absolute bool) redis.Reply {
+	key := string(args[0])
+	raw, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	pos := fieldsIndex(args[2:]) + 2
+	option, errReply := parseExpireOption(args[2:pos])
+	if errReply != nil {
+		return errReply
+	}
+	fields, errReply := parseHashFields(args[pos:])
+	if errReply != nil {
+		return errReply
+	}
+	if raw < 0 || raw > math.MaxInt64/int64(unit) {
+		return protocol.MakeErrReply("ERR invalid expire time in '" + cmdName + "' command")
+	}
+	var expireAt time.Time
+	if absolute {
+		expireAt = time.Unix(0, 0).Add(time.Duration(raw) * unit)
+	} else {
+		expireAt = time.Now().Add(time.Duration(raw) * unit)
+	}
+
+	codes := make([]int64, len(fields))
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		for i := range codes {
+			codes[i] = -2
+		}
+		return makeIntArrayReply(codes)
+	}
+	if _, ok := entity.Data.(dict.Dict); !ok {
+		return &protocol.WrongTypeErrReply{}
+	}
+	h := asTTLHash(entity)
+	var updated, deleted []string
+	for i, field := range fields {
+		if _, exists := hashGet(h, field); !exists {
+			codes[i] = -2
+			continue
+		}
+		current, hasTTL := fieldExpireTime(h, field)
+		switch {
+		case option.nx && hasTTL,
+			option.xx && !hasTTL,
+			option.gt && (!hasTTL || !expireAt.After(current)),
+			option.lt && hasTTL && !expireAt.Before(current):
+			continue
+		}
+		if !expireAt.After(time.Now()) {
+			hashRemove(h, field)
+			deleted = append(deleted, field)
+			codes[i] = 2
+			continue
+		}
+		db.expireField(key, h, field, expireAt)
+		updated = append(updated, field)
+		codes[i] = 1
+	}
+	if len(updated) > 0 {
+		db.notify(notifyHash, "hexpire", key)
+		db.addAof(makeFieldExpireCmd(key, expireAt, updated...))
+	}
+	if len(deleted) > 0 {
+		db.notify(notifyHash, "hdel", key)
+		db.addAof(utils.ToCmdLine2("HDEL", append([]string{key}, deleted...)...))
+	}
+	db.removeEmptyHash(key, h)
+	return makeIntArrayReply(codes)
+}
+
+// execHExpire HEXPIRE key seconds [NX|XX|GT|LT] FIELDS numfields field [field ...]
+func execHExpire(db *DB, args [][]byte) redis.Reply {
+	return hexpireGeneric(db, "hexpire", args, time.Second, false)
+}
+
+// execHPExpire HPEXPIRE key milliseconds [NX|XX|GT|LT] FIELDS numfields field [field ...]
+func execHPExpire(db *DB, args [][]byte) redis.Reply {
+	return hexpireGeneric(db, "hpexpire", args, time.Millisecond, false)
+}
+
+// execHExpireAt HEXPIREAT key unix-time-seconds [NX|XX|GT|LT] FIELDS numfields field [field ...]
+func execHExpireAt(db *DB, args [][]byte) redis.Reply {
+	return hexpireGeneric(db, "hexpireat", args, time.Second, true)
+}
+
+// execHPExpireAt HPEXPIREAT key unix-time-milliseconds [NX|XX|GT|LT] FIELDS numfields field [field ...]
+func execHPExpireAt(db *DB, args [][]byte) redis.Reply {
+	return hexpireGeneric(db, "hpexpireat", args, time.Millisecond, true)
+}
+
+// fieldTTLGeneric 实现HTTL族命令, 字段不存在时返回-2, 没有过期时间时返回-1
+func fieldTTLGeneric(db *DB, args [][]byte, convert func(expireAt time.Time) int64) redis.Reply {
+	fields, errReply := parseHashFields(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	hash, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	codes := make([]int64, len(fields))
+	for i, field := range fields {
+		if hash == nil {
+			codes[i] = -2
+			continue
+		}
+		if _, exists := hashGet(hash, field); !exists {
+			codes[i] = -2
+			continue
+		}
+		expireAt, hasTTL := fieldExpireTime(hash, field)
+		if !hasTTL {
+			codes[i] = -1
+			continue
+		}
+		codes[i] = convert(expireAt)
+	}
+	return makeIntArrayReply(codes)
+}
+
+// execHTTL HTTL key FIELDS numfields field [field ...]
+func execHTTL(db *DB, args [][]byte) redis.Reply {
+	return fieldTTLGeneric(db, args, func(expireAt time.Time) int64 {
+		return max((time.Until(expireAt).Milliseconds()+500)/1000, 0)
+	})
+}
+
+// execHPTTL HPTTL key FIELDS numfields field [field ...]
+func execHPTTL(db *DB, args [][]byte) redis.Reply {
+	return fieldTTLGeneric(db, args, func(expireAt time.Time) int64 {
+		return max(time.Until(expireAt).Milliseconds(), 0)
+	})
+}
+
+// execHExpireTime HEXPIRETIME key FIELDS numfields field [field ...]
+func execHExpireTime(db *DB, args [][]byte) redis.Reply {
+	return fieldTTLGeneric(db, args, func(expireAt time.Time) int64 {
+		return expireAt.Unix()
+	})
+}
+
+// execHPExpireTime HPEXPIRETIME key FIELDS numfields field [field ...]
+func execHPExpireTime(db *DB, args [][]byte) redis.Reply {
+	return fieldTTLGeneric(db, args, func(expireAt time.Time) int64 {
+		return expireAt.UnixMilli()
+	})
+}
+
+// execHPersist HPERSIST key FIELDS numfields field [field ...]
+func execHPersist(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	fields, errReply := parseHashFields(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	codes := make([]int64, len(fields))
+	for i, field := range fields {
+		if hash == nil {
+			codes[i] = -2
+			continue
+		}
+		if _, exists := hashGet(hash, field); !exists {
+			codes[i] = -2
+			continue
+		}
+		if _, hasTTL := fieldExpireTime(hash, field); !hasTTL {
+			codes[i] = -1
+			continue
+		}
+		db.persistField(key, hash, field)
+		codes[i] = 1
+	}
+	for _, code := range codes {
+		if code == 1 {
+			db.notify(notifyHash, "hpersist", key)
+			break
+		}
+	}
+	return makeIntArrayReply(codes)
+}
+
+// execHGetDel HGETDEL key FIELDS numfields field [field ...]
+func execHGetDel(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	fields, errReply := parseHashFields(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, len(fields))
+	if hash == nil {
+		return protocol.MakeMultiBulkReply(result)
+	}
+	deleted := 0
+	for i, field := range fields {
+		result[i], _ = hashGet(hash, field)
+		deleted += hashRemove(hash, field)
+	}
+	if deleted > 0 {
+		db.notify(notifyHash, "hdel", key)
+	}
+	db.removeEmptyHash(key, hash)
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// execHGetEx HGETEX key [EX seconds|PX milliseconds|EXAT timestamp|PXAT timestamp|PERSIST] FIELDS numfields field [field ...]
+func execHGetEx(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	pos := fieldsIndex(args[1:]) + 1
+	var expireAt time.Time
+	hasTTL, persist := false, false
+	switch options := args[1:pos]; len(options) {
+	case 0:
+	case 1:
+		if strings.ToUpper(string(options[0])) != "PERSIST" {
+			return protocol.MakeSyntaxErrReply()
+		}
+		persist = true
+	case 2:
+		unit := strings.ToUpper(string(options[0]))
+		if unit != "EX" && unit != "PX" && unit != "EXAT" && unit != "PXAT" {
+			return protocol.MakeSyntaxErrReply()
+		}
+		var errReply redis.Reply
+		expireAt, errReply = parseExpireTime("hgetex", unit, options[1])
+		if errReply != nil {
+			return errReply
+		}
+		hasTTL = true
+	default:
+		return protocol.MakeSyntaxErrReply()
+	}
+	fields, errReply := parseHashFields(args[pos:])
+	if errReply != nil {
+		return errReply
+	}
+
+	result := make([][]byte, len(fields))
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return protocol.MakeMultiBulkReply(result)
+	}
+	hash, ok := entity.Data.(dict.Dict)
+	if !ok {
+		return &protocol.WrongTypeErrReply{}
+	}
+	var updated, deleted []string
+	for i, field := range fields {
+		value, exists := hashGet(hash, field)
+		if !exists {
+			continue
+		}
+		result[i] = value
+		switch {
+		case persist:
+			if _, ok := fieldExpireTime(hash, field); ok {
+				db.persistField(key, hash, field)
+				updated = append(updated, field)
+			}
+		case hasTTL && !expireAt.After(time.Now()):
+			hashRemove(hash, field)
+			deleted = append(deleted, field)
+		case hasTTL:
+			h := asTTLHash(entity)
+			db.expireField(key, h, field, expireAt)
+			hash = h
+			updated = append(updated, field)
+		}
+	}
+	if len(updated) > 0 {
+		if persist {
+			db.notify(notifyHash, "hpersist", key)
+			cmdLine := utils.ToCmdLine("HPERSIST", key, "FIELDS", strconv.Itoa(len(updated)))
+			db.addAof(append(cmdLine, utils.ToCmdLine(updated...)...))
+		} else {
+			db.notify(notifyHash, "hexpire", key)
+			db.addAof(makeFieldExpireCmd(key, expireAt, updated...))
+		}
+	}
+	if len(deleted) > 0 {
+		db.notify(notifyHash, "hdel", key)
+		db.addAof(utils.ToCmdLine2("HDEL", append([]string{key}, deleted...)...))
+	}
+	db.removeEmptyHash(key, hash)
+	return protocol.MakeMultiBulkReply(result)
+}
+
+func init() {
+	registerCommand("HExpire", execHExpire, writeFirstKey, rollbackFirstKey, -6, flagWrite|flagCustomAof)
+	registerCommand("HPExpire", execHPExpire, writeFirstKey, rollbackFirstKey, -6, flagWrite|flagCustomAof)
+	registerCommand("HExpireAt", execHExpireAt, writeFirstKey, rollbackFirstKey, -6, flagWrite|flagCustomAof)
+	registerCommand("HPExpireAt", execHPExpireAt, writeFirstKey, rollbackFirstKey, -6, flagWrite|flagCustomAof)
+	registerCommand("HTTL", execHTTL, readFirstKey, nil, -5, flagReadOnly)
+	registerCommand("HPTTL", execHPTTL, readFirstKey, nil, -5, flagReadOnly)
+	registerCommand("HExpireTime", execHExpireTime, readFirstKey, nil, -5, flagReadOnly)
+	registerCommand("HPExpireTime", execHPExpireTime, readFirstKey, nil, -5, flagReadOnly)
+	registerCommand("HPersist", execHPersist, writeFirstKey, rollbackFirstKey, -5, flagWrite)
+	registerCommand("HGetDel", execHGetDel, writeFirstKey, rollbackFirstKey, -5, flagWrite)
+	registerCommand("HGetEx", execHGetEx, writeFirstKey, rollbackFirstKey, -5, flagWrite|flagCustomAof)
+}
@@ -0,0 +1,209 @@
+package database
+
+import (
+	"goredis/config"
+	"goredis/datastruct/hll"
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/lib/utils"
+	"goredis/redis/protocol"
+	"strings"
+)
+
+// HyperLogLog与redis一样保存为字符串, GET/SET, DUMP/RESTORE, AOF和RDB都不需要特殊处理
+
+// getAsHLL 返回key对应的HyperLogLog, key不存在时返回nil, 返回的对象与字符串共享内存, 修改前需要复制
+func (db *DB) getAsHLL(key string) (*hll.HyperLogLog, redis.Reply) {
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if bytes == nil {
+		return nil, nil
+	}
+	h, err := hll.Parse(bytes)
+	if err != nil {
+		return nil, protocol.MakeErrReply(err.Error())
+	}
+	return h, nil
+}
+
+// execPFAdd PFADD key [element ...], 创建了key或者有寄存器被修改时返回1
+func execPFAdd(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	h, errReply := db.getAsHLL(key)
+	if errReply != nil {
+		return errReply
+	}
+	created := h == nil
+	if created {
+		h = hll.New()
+	} else {
+		h = h.Clone()
+	}
+	changed, err := h.Add(args[1:], config.Properties.HllSparseMaxBytes)
+	if err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	if !created && !changed {
+		return protocol.MakeIntReply(0)
+	}
+	db.PutEntity(key, &database.DataEntity{Data: h.Bytes()})
+	db.notify(notifyString, "pfadd", key)
+	return protocol.MakeIntReply(1)
+}
+
+// execPFCount PFCOUNT key [key ...], 只有一个key时会更新它的基数缓存, 多个key时合并后计算, 不修改任何key
+func execPFCount(db *DB, args [][]byte) redis.Reply {
+	if len(args) == 1 {
+		key := string(args[0])
+		h, errReply := db.getAsHLL(key)
+		if errReply != nil {
+			return errReply
+		}
+		if h == nil {
+			return protocol.MakeIntReply(0)
+		}
+		if h.CacheValid() {
+			count, _ := h.Count()
+			return protocol.MakeIntReply(int64(count))
+		}
+		h = h.Clone()
+		count, err := h.Count()
+		if err != nil {
+			return protocol.MakeErrReply(err.Error())
+		}
+		db.PutEntity(key, &database.DataEntity{Data: h.Bytes()})
+		db.addVersion(key)
+		db.addAof(utils.ToCmdLine("PFCOUNT", key))
+		return protocol.MakeIntReply(int64(count))
+	}
+
+	registers, errReply := db.mergeHLL(args)
+	if errReply != nil {
+		return errReply
+	}
+	return protocol.MakeIntReply(int64(hll.CountRegisters(registers)))
+}
+
+func preparePFCount(args [][]byte) ([]string, []string) {
+	if len(args) == 1 {
+		return writeFirstKey(args)
+	}
+	return readAllKeys(args)
+}
+
+// mergeHLL 合并多个key的寄存器, 不存在的key视为空的HyperLogLog
+func (db *DB) mergeHLL(keys [][]byte) ([]uint8, redis.Reply) {
+	registers, _ := hll.New().Registers()
+	for _, key := range keys {
+		h, errReply := db.getAsHLL(string(key))
+		if errReply != nil {
+			return nil, errReply
+		}
+		if h == nil {
+			continue
+		}
+		if err := h.Merge(registers); err != nil {
+			return nil, protocol.MakeErrReply(err.Error())
+		}
+	}
+	return registers, nil
+}
+
+// execPFMerge PFMERGE destkey [sourcekey ...], destkey已经存在时也参与合并, 任一个输入是稠密编码时结果使用稠密编码
+func execPFMerge(db *DB, args [][]byte) redis.Reply {
+	dst := string(args[0])
+	registers, errReply := db.mergeHLL(args)
+	if errReply != nil {
+		return errReply
+	}
+	dense := false
+	for _, key := range args {
+		h, _ := db.getAsHLL(string(key))
+		if h != nil && h.Encoding() == hll.EncodingDense {
+			dense = true
+		}
+	}
+	h := hll.FromRegisters(registers, dense, config.Properties.HllSparseMaxBytes)
+	db.PutEntity(dst, &database.DataEntity{Data: h.Bytes()})
+	db.notify(notifyString, "pfadd", dst)
+	return protocol.MakeOkReply()
+}
+
+// execPFDebug PFDEBUG GETREG|DECODE|ENCODING|TODENSE key
+func execPFDebug(db *DB, args [][]byte) redis.Reply {
+	subCmd := strings.ToUpper(string(args[0]))
+	key := string(args[1])
+	h, errReply := db.getAsHLL(key)
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return protocol.MakeErrReply("ERR The specified key does not exist")
+	}
+	switch subCmd {
+	case "GETREG":
+		// 与redis一样先转换为稠密编码
+		h = h.Clone()
+		converted, err := h.ToDense()
+		if err != nil {
+			return protocol.MakeErrReply(err.Error())
+		}
+		if converted {
+			db.putDenseHLL(key, h)
+		}
+		registers, _ := h.Registers()
+		result := make([]redis.Reply, len(registers))
+		for i, value := range registers {
+			result[i] = protocol.MakeIntReply(int64(value))
+		}
+		return protocol.MakeMultiRawReply(result)
+	case "DECODE":
+		if h.Encoding() != hll.EncodingSparse {
+			return protocol.MakeErrReply("ERR HLL encoding is not sparse")
+		}
+		desc, err := h.Describe()
+		if err != nil {
+			return protocol.MakeErrReply(err.Error())
+		}
+		return protocol.MakeStatusReply(desc)
+	case "ENCODING":
+		return protocol.MakeStatusReply(h.Encoding())
+	case "TODENSE":
+		h = h.Clone()
+		converted, err := h.ToDense()
+		if err != nil {
+			return protocol.MakeErrReply(err.Error())
+		}
+		if !converted {
+			return protocol.MakeIntReply(0)
+		}
+		db.putDenseHLL(key, h)
+		return protocol.MakeIntReply(1)
+	}
+	return protocol.MakeErrReply("ERR Unknown PFDEBUG subcommand '" + string(args[0]) + "'")
+}
+
+// putDenseHLL 保存转换为稠密编码的HyperLogLog, GETREG和TODENSE都以TODENSE的形式写入aof
+func (db *DB) putDenseHLL(key string, h *hll.HyperLogLog) {
+	db.PutEntity(key, &database.DataEntity{Data: h.Bytes()})
+	db.addVersion(key)
+	db.addAof(utils.ToCmdLine("PFDEBUG", "TODENSE", key))
+}
+
+func preparePFDebug(args [][]byte) ([]string, []string) {
+	return []string{string(args[1])}, nil
+}
+
+func undoPFDebug(db *DB, args [][]byte) []CmdLine {
+	return rollbackGivenKeys(db, string(args[1]))
+}
+
+func init() {
+	registerCommand("PFAdd", execPFAdd, writeFirstKey, rollbackFirstKey, -2, flagWrite)
+	// PFCOUNT和PFDEBUG大多数时候不修改key, 只有存储的内容改变时才更新版本号和写入aof
+	registerCommand("PFCount", execPFCount, preparePFCount, nil, -2, flagWrite|flagCustomAof|flagCustomVersion)
+	registerCommand("PFMerge", execPFMerge, prepareSetStore, rollbackFirstKey, -2, flagWrite)
+	registerCommand("PFDebug", execPFDebug, preparePFDebug, undoPFDebug, 3, flagWrite|flagCustomAof|flagCustomVersion)
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"goredis/redis/protocol"
+	"strconv"
+	"testing"
+)
+
+func TestPFAdd(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfadd", "empty")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfcount", "empty")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfadd", "h", "a", "b", "c", "d", "e", "f", "g")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfadd", "h", "a", "b")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfcount", "h")), ":7\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfdebug", "encoding", "h")), "+sparse\r\n")
+
+	// HyperLogLog保存为字符串, 可以通过GET/SET复制
+	value := db.Exec(c, utils.ToCmdLine("get", "h")).(*protocol.BulkReply).Arg
+	db.Exec(c, [][]byte{[]byte("set"), []byte("copy"), value})
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfcount", "copy")), ":7\r\n")
+
+	db.Exec(c, utils.ToCmdLine("set", "s", "foo"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfadd", "s", "a")), "-WRONGTYPE Key is not a valid HyperLogLog string value.\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfcount", "h", "s")), "-WRONGTYPE Key is not a valid HyperLogLog string value.\r\n")
+	db.Exec(c, utils.ToCmdLine("lpush", "l", "a"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfcount", "l")), "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfdebug", "todense", "h")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfdebug", "todense", "h")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfdebug", "encoding", "h")), "+dense\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfdebug", "decode", "h")), "-ERR HLL encoding is not sparse\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfcount", "h")), ":7\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfdebug", "getreg", "none")), "-ERR The specified key does not exist\r\n")
+}
+
+func TestPFMerge(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	for i := 0; i < 1000; i++ {
+		db.Exec(c, utils.ToCmdLine("pfadd", "a", "member:"+strconv.Itoa(i)))
+		db.Exec(c, utils.ToCmdLine("pfadd", "b", "member:"+strconv.Itoa(i+500)))
+	}
+	if count := db.Exec(c, utils.ToCmdLine("pfcount", "a")).(*protocol.IntReply).Code; count < 980 || count > 1020 {
+		t.Errorf("count %d is too far from 1000", count)
+	}
+	reply := db.Exec(c, utils.ToCmdLine("pfcount", "a", "b", "none"))
+	if count := reply.(*protocol.IntReply).Code; count < 1470 || count > 1530 {
+		t.Errorf("merged count %d is too far from 1500", count)
+	}
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfmerge", "dst", "a", "b")), "+OK\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfcount", "dst")), string(reply.ToBytes()))
+
+	undoLogs := db.GetUndoLogs(utils.ToCmdLine("pfmerge", "dst", "none"))
+	db.Exec(c, utils.ToCmdLine("set", "dst", "foo"))
+	for _, undo := range undoLogs {
+		db.Exec(c, undo)
+	}
+	assertReply(t, db.Exec(c, utils.ToCmdLine("pfcount", "dst")), string(reply.ToBytes()))
+}
+
+func TestPFDebugAof(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("pfadd", "h", "a", "b"))
+	var aofLines []CmdLine
+	db.addAof = func(line CmdLine) {
+		aofLines = append(aofLines, line)
+	}
+
+	// 缓存有效时PFCOUNT和只读的PFDEBUG子命令不修改key
+	db.Exec(c, utils.ToCmdLine("pfcount", "h"))
+	version := db.GetVersion("h")
+	db.Exec(c, utils.ToCmdLine("pfcount", "h"))
+	db.Exec(c, utils.ToCmdLine("pfdebug", "encoding", "h"))
+	db.Exec(c, utils.ToCmdLine("pfdebug", "decode", "h"))
+	if db.GetVersion("h") != version {
+		t.Error("version should not change when nothing is modified")
+	}
+	if len(aofLines) != 1 || string(aofLines[0][0]) != "PFCOUNT" {
+		t.Errorf("only the first pfcount should be written to aof, actual %d lines", len(aofLines))
+	}
+
+	db.Exec(c, utils.ToCmdLine("pfdebug", "getreg", "h"))
+	if db.GetVersion("h") == version {
+		t.Error("version should change after converting to dense encoding")
+	}
+	if len(aofLines) != 2 || string(aofLines[1][1]) != "TODENSE" {
+		t.Errorf("conversion should be written to aof, actual %d lines", len(aofLines))
+	}
+}
@@ -0,0 +1,533 @@
+package database
+
+import (
+	"goredis/datastruct/dict"
+	"goredis/datastruct/list"
+	"goredis/datastruct/set"
+	"goredis/datastruct/sortedset"
+	"goredis/datastruct/stream"
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/lib/utils"
+	"goredis/lib/wildcard"
+	"goredis/redis/protocol"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// execDel DEL key [key...]
+func execDel(db *DB, args [][]byte) redis.Reply {
+	deleted := 0
+	for _, arg := range args {
+		key := string(arg)
+		if db.Removes(key) > 0 {
+			db.notify(notifyGeneric, "del", key)
+			deleted++
+		}
+	}
+	return protocol.MakeIntReply(int64(deleted))
+}
+
+func undoDel(db *DB, args [][]byte) []CmdLine {
+	keys := make([]string, len(args))
+	for i, v := range args {
+		keys[i] = string(v)
+	}
+	return rollbackGivenKeys(db, keys...)
+}
+
+// execExists EXISTS key [key...], 重复的key会被重复计数
+func execExists(db *DB, args [][]byte) redis.Reply {
+	result := int64(0)
+	for _, arg := range args {
+		if _, exists := db.GetEntity(string(arg)); exists {
+			result++
+		}
+	}
+	return protocol.MakeIntReply(result)
+}
+
+// execType TYPE key
+func execType(db *DB, args [][]byte) redis.Reply {
+	entity, exists := db.GetEntity(string(args[0]))
+	if !exists {
+		return protocol.MakeStatusReply("none")
+	}
+	typeName := getTypeName(entity)
+	if typeName == "" {
+		return &protocol.UnknownErrReply{}
+	}
+	return protocol.MakeStatusReply(typeName)
+}
+
+// getTypeName 返回TYPE命令使用的类型名, 未知类型返回空字符串
+func getTypeName(entity *database.DataEntity) string {
+	switch entity.Data.(type) {
+	case []byte:
+		return "string"
+	case list.List:
+		return "list"
+	case dict.Dict:
+		return "hash"
+	case set.Set:
+		return "set"
+	case *sortedset.SortedSet:
+		return "zset"
+	case *stream.Stream:
+		return "stream"
+	}
+	return ""
+}
+
+// getEncoding 返回OBJECT ENCODING使用的编码名
+func getEncoding(entity *database.DataEntity) string {
+	switch val := entity.Data.(type) {
+	case []byte:
+		if len(val) <= 20 {
+			if _, err := strconv.ParseInt(string(val), 10, 64); err == nil {
+				return "int"
+			}
+		}
+		if len(val) <= 44 {
+			return "embstr"
+		}
+		return "raw"
+	case list.List:
+		return "quicklist"
+	case dict.Dict:
+		return "hashtable"
+	case set.Set:
+		return val.Encoding()
+	case *sortedset.SortedSet:
+		return "skiplist"
+	case *stream.Stream:
+		return "stream"
+	}
+	return ""
+}
+
+// execObject OBJECT ENCODING key
+func execObject(db *DB, args [][]byte) redis.Reply {
+	subCommand := strings.ToUpper(string(args[0]))
+	if subCommand != "ENCODING" {
+		return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try OBJECT HELP.")
+	}
+	if len(args) != 2 {
+		return protocol.MakeArgNumErrReply("object|encoding")
+	}
+	entity, exists := db.GetEntity(string(args[1]))
+	if !exists {
+		return protocol.MakeNullBulkReply()
+	}
+	return protocol.MakeBulkReply([]byte(getEncoding(entity)))
+}
+
+func prepareObject(args [][]byte) ([]string, []string) {
+	if len(args) < 2 {
+		return nil, nil
+	}
+	return nil, []string{string(args[1])}
+}
+
+func prepareRename(args [][]byte) ([]string, []string) {
+	return []string{string(args[0]), string(args[1])}, nil
+}
+
+func undoRename(db *DB, args [][]byte) []CmdLine {
+	return rollbackGivenKeys(db, string(args[0]), string(args[1]))
+}
+
+// execRename RENAME key newkey, 过期时间随key一起转移
+func execRename(db *DB, args [][]byte) redis.Reply {
+	src, dst := string(args[0]), string(args[1])
+	entity, exists := db.GetEntity(src)
+	if !exists {
+		return protocol.MakeErrReply("ERR no such key")
+	}
+	if src == dst {
+		return protocol.MakeOkReply()
+	}
+	db.renameKey(src, dst, entity)
+	return protocol.MakeOkReply()
+}
+
+// execRenameNX RENAMENX key newkey
+func execRenameNX(db *DB, args [][]byte) redis.Reply {
+	src, dst := string(args[0]), string(args[1])
+	entity, exists := db.GetEntity(src)
+	if !exists {
+		return protocol.MakeErrReply("ERR no such key")
+	}
+	if _, exists = db.GetEntity(dst); exists {
+		return protocol.MakeIntReply(0)
+	}
+	db.renameKey(src, dst, entity)
+	return protocol.MakeIntReply(1)
+}
+
+func (db *DB) renameKey(src, dst string, entity *database.DataEntity) {
+	rawExpireTime, hasTTL := db.ttlMap.Get(src)
+	db.Removes(src, dst)
+	db.PutEntity(dst, entity)
+	if hasTTL {
+		db.Expire(dst, rawExpireTime.(time.Time))
+	}
+	db.restoreFieldTTL(dst, entity)
+	db.notify(notifyGeneric, "rename_from", src)
+	db.notify(notifyGeneric, "rename_to", dst)
+}
+
+// execKeys KEYS pattern
+func execKeys(db *DB, args [][]byte) redis.Reply {
+	pattern := wildcard.CompilePattern(string(args[0]))
+	now := time.Now()
+	result := make([][]byte, 0)
+	db.ForEach(func(key string, data *database.DataEntity, expiration *time.Time) bool {
+		if expiration != nil && now.After(*expiration) {
+			return true
+		}
+		if pattern.IsMatch(key) {
+			result = append(result, []byte(key))
+		}
+		return true
+	})
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// randomKeyRetries RANDOMKEY 遇到已过期的key时重新抽取的次数
+const randomKeyRetries = 16
+
+// execRandomKey RANDOMKEY
+func execRandomKey(db *DB, args [][]byte) redis.Reply {
+	for i := 0; i < randomKeyRetries; i++ {
+		keys := db.dict.RandomKeys(1)
+		if len(keys) == 0 {
+			return protocol.MakeNullBulkReply()
+		}
+		rawExpireTime, hasTTL := db.ttlMap.Get(keys[0])
+		if hasTTL && time.Now().After(rawExpireTime.(time.Time)) {
+			continue
+		}
+		return protocol.MakeBulkReply([]byte(keys[0]))
+	}
+	return protocol.MakeNullBulkReply()
+}
+
+// execTouch TOUCH key [key...], 没有LRU信息需要更新, 只返回存在的key数量
+func execTouch(db *DB, args [][]byte) redis.Reply {
+	return execExists(db, args)
+}
+
+// deepCopy 复制key的值, 复制后两个key互不影响
+func deepCopy(entity *database.DataEntity) *database.DataEntity {
+	switch val := entity.Data.(type) {
+	case []byte:
+		bytes := make([]byte, len(val))
+		copy(bytes, val)
+		return &database.DataEntity{Data: bytes}
+	case list.List:
+		l := list.NewQuickList()
+		val.ForEach(func(i int, v any) bool {
+			l.Add(v)
+			return true
+		})
+		return &database.DataEntity{Data: l}
+	case *ttlHash:
+		h := &ttlHash{
+			Dict:    dict.NewSimple(),
+			expires: make(map[string]time.Time, len(val.expires)),
+		}
+		hashForEach(val, func(field string, value []byte) bool {
+			h.Put(field, value)
+			if expireAt, ok := val.expires[field]; ok {
+				h.expires[field] = expireAt
+			}
+			return true
+		})
+		return &database.DataEntity{Data: h}
+	case dict.Dict:
+		d := dict.NewSimple()
+		val.ForEach(func(field string, value any) bool {
+			d.Put(field, value)
+			return true
+		})
+		return &database.DataEntity{Data: d}
+	case set.Set:
+		return &database.DataEntity{Data: val.Copy()}
+	case *sortedset.SortedSet:
+		zset := sortedset.New()
+		val.ForEach(0, val.Len(), false, func(elem *sortedset.Element) bool {
+			zset.Add(elem.Member, elem.Score)
+			return true
+		})
+		return &database.DataEntity{Data: zset}
+	case *stream.Stream:
+		return &database.DataEntity{Data: val.Clone()}
+	}
+	return nil
+}
+
+// execCopy COPY source destination [DB destination-db] [REPLACE], 目标DB可能与当前DB不同, 所以在Server中处理
+func (server *Server) execCopy(dbIndex int, cmdLine [][]byte) redis.Reply {
+	copyCmd, errReply := server.parseCopy(dbIndex, cmdLine)
+	if errReply != nil {
+		return errReply
+	}
+	unlock := copyCmd.lock()
+	defer unlock()
+	return copyCmd.copyKey(cmdLine)
+}
+
+// parseCopy 解析COPY的参数并找到源DB和目标DB
+func (server *Server) parseCopy(dbIndex int, cmdLine [][]byte) (*crossDBCmd, redis.Reply) {
+	if len(cmdLine) < 3 {
+		return nil, protocol.MakeArgNumErrReply("copy")
+	}
+	src, dst := string(cmdLine[1]), string(cmdLine[2])
+	dstIndex := dbIndex
+	replace := false
+	for i := 3; i < len(cmdLine); i++ {
+		switch strings.ToUpper(string(cmdLine[i])) {
+		case "DB":
+			if i+1 >= len(cmdLine) {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			index, err := strconv.Atoi(string(cmdLine[i+1]))
+			if err != nil {
+				return nil, protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			dstIndex = index
+			i++
+		case "REPLACE":
+			replace = true
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	if src == dst && dstIndex == dbIndex {
+		return nil, protocol.MakeErrReply("ERR source and destination objects are the same")
+	}
+	srcDB, errReply := server.selectDB(dbIndex)
+	if errReply != nil {
+		return nil, errReply
+	}
+	dstDB, errReply := server.selectDB(dstIndex)
+	if errReply != nil {
+		return nil, errReply
+	}
+	return &crossDBCmd{srcDB: srcDB, dstDB: dstDB, src: src, dst: dst, replace: replace}, nil
+}
+
+// copyKey 执行COPY, 调用方需要持有两个key的锁
+func (x *crossDBCmd) copyKey(cmdLine [][]byte) redis.Reply {
+	srcDB, dstDB, src, dst := x.srcDB, x.dstDB, x.src, x.dst
+	entity, exists := srcDB.GetEntity(src)
+	if !exists {
+		return protocol.MakeIntReply(0)
+	}
+	if _, exists = dstDB.GetEntity(dst); exists {
+		if !x.replace {
+			return protocol.MakeIntReply(0)
+		}
+		dstDB.Remove(dst)
+	}
+	copied := deepCopy(entity)
+	if copied == nil {
+		return &protocol.UnknownErrReply{}
+	}
+	dstDB.PutEntity(dst, copied)
+	if rawExpireTime, hasTTL := srcDB.ttlMap.Get(src); hasTTL {
+		dstDB.Expire(dst, rawExpireTime.(time.Time))
+	}
+	dstDB.restoreFieldTTL(dst, copied)
+	dstDB.notify(notifyGeneric, "copy_to", dst)
+	dstDB.addVersion(dst)
+	dstDB.invalidate(dst)
+	dstDB.blocking.signal(dst)
+	srcDB.addAof(cmdLine)
+	return protocol.MakeIntReply(1)
+}
+
+// expireOption EXPIRE族命令的NX/XX/GT/LT选项, 没有过期时间的key在GT/LT比较时视为永不过期
+type expireOption struct {
+	nx, xx, gt, lt bool
+}
+
+func parseExpireOption(args [][]byte) (*expireOption, redis.Reply) {
+	option := &expireOption{}
+	for _, arg := range args {
+		switch strings.ToUpper(string(arg)) {
+		case "NX":
+			option.nx = true
+		case "XX":
+			option.xx = true
+		case "GT":
+			option.gt = true
+		case "LT":
+			option.lt = true
+		default:
+			return nil, protocol.MakeErrReply("ERR Unsupported option " + string(arg))
+		}
+	}
+	if option.nx && (option.xx || option.gt || option.lt) {
+		return nil, protocol.MakeErrReply("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if option.gt && option.lt {
+		return nil, protocol.MakeErrReply("ERR GT and LT options at the same time are not compatible")
+	}
+	return option, nil
+}
+
+// expireGeneric 实现EXPIRE族命令, unit是参数的单位, absolute表示参数是否为时间戳
+func expireGeneric(db *DB, cmdName string, args [][]byte, unit time.Duration, absolute bool) redis.Reply {
+	key := string(args[0])
+	raw, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	option, errReply := parseExpireOption(args[2:])
+	if errReply != nil {
+		return errReply
+	}
+	if raw > math.MaxInt64/int64(unit) || raw < math.MinInt64/int64(unit) {
+		return protocol.MakeErrReply("ERR invalid expire time in '" + cmdName + "' command")
+	}
+	var expireAt time.Time
+	if absolute {
+		expireAt = time.Unix(0, 0).Add(time.Duration(raw) * unit)
+	} else {
+		expireAt = time.Now().Add(time.Duration(raw) * unit)
+	}
+
+	if _, exists := db.GetEntity(key); !exists {
+		return protocol.MakeIntReply(0)
+	}
+	rawExpireTime, hasTTL := db.ttlMap.Get(key)
+	switch {
+	case option.nx && hasTTL,
+		option.xx && !hasTTL,
+		option.gt && (!hasTTL || !expireAt.After(rawExpireTime.(time.Time))),
+		option.lt && hasTTL && !expireAt.Before(rawExpireTime.(time.Time)):
+		return protocol.MakeIntReply(0)
+	}
+
+	if !expireAt.After(time.Now()) {
+		// 过期时间已经过去, 直接删除
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+		db.addAof(utils.ToCmdLine("DEL", key))
+		return protocol.MakeIntReply(1)
+	}
+	db.Expire(key, expireAt)
+	db.notify(notifyGeneric, "expire", key)
+	db.addAof(MakeExpireCmd(key, expireAt))
+	return protocol.MakeIntReply(1)
+}
+
+// execExpire EXPIRE key seconds [NX|XX|GT|LT]
+func execExpire(db *DB, args [][]byte) redis.Reply {
+	return expireGeneric(db, "expire", args, time.Second, false)
+}
+
+// execPExpire PEXPIRE key milliseconds [NX|XX|GT|LT]
+func execPExpire(db *DB, args [][]byte) redis.Reply {
+	return expireGeneric(db, "pexpire", args, time.Millisecond, false)
+}
+
+// execExpireAt EXPIREAT key unix-time-seconds [NX|XX|GT|LT]
+func execExpireAt(db *DB, args [][]byte) redis.Reply {
+	return expireGeneric(db, "expireat", args, time.Second, true)
+}
+
+// execPExpireAt PEXPIREAT key unix-time-milliseconds [NX|XX|GT|LT]
+func execPExpireAt(db *DB, args [][]byte) redis.Reply {
+	return expireGeneric(db, "pexpireat", args, time.Millisecond, true)
+}
+
+// getExpireTime 返回key的过期时间, key不存在时返回-2, 没有过期时间时返回-1
+func getExpireTime(db *DB, key string) (time.Time, int64) {
+	if _, exists := db.GetEntity(key); !exists {
+		return time.Time{}, -2
+	}
+	rawExpireTime, hasTTL := db.ttlMap.Get(key)
+	if !hasTTL {
+		return time.Time{}, -1
+	}
+	return rawExpireTime.(time.Time), 0
+}
+
+// execTTL TTL key
+func execTTL(db *DB, args [][]byte) redis.Reply {
+	expireAt, code := getExpireTime(db, string(args[0]))
+	if code < 0 {
+		return protocol.MakeIntReply(code)
+	}
+	// 与redis一样四舍五入到秒
+	ttl := time.Until(expireAt).Milliseconds()
+	return protocol.MakeIntReply(max((ttl+500)/1000, 0))
+}
+
+// execPTTL PTTL key
+func execPTTL(db *DB, args [][]byte) redis.Reply {
+	expireAt, code := getExpireTime(db, string(args[0]))
+	if code < 0 {
+		return protocol.MakeIntReply(code)
+	}
+	return protocol.MakeIntReply(max(time.Until(expireAt).Milliseconds(), 0))
+}
+
+// execExpireTime EXPIRETIME key
+func execExpireTime(db *DB, args [][]byte) redis.Reply {
+	expireAt, code := getExpireTime(db, string(args[0]))
+	if code < 0 {
+		return protocol.MakeIntReply(code)
+	}
+	return protocol.MakeIntReply(expireAt.Unix())
+}
+
+// execPExpireTime PEXPIRETIME key
+func execPExpireTime(db *DB, args [][]byte) redis.Reply {
+	expireAt, code := getExpireTime(db, string(args[0]))
+	if code < 0 {
+		return protocol.MakeIntReply(code)
+	}
+	return protocol.MakeIntReply(expireAt.UnixMilli())
+}
+
+// execPersist PERSIST key
+func execPersist(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	if _, exists := db.GetEntity(key); !exists {
+		return protocol.MakeIntReply(0)
+	}
+	if _, hasTTL := db.ttlMap.Get(key); !hasTTL {
+		return protocol.MakeIntReply(0)
+	}
+	db.Persist(key)
+	db.notify(notifyGeneric, "persist", key)
+	return protocol.MakeIntReply(1)
+}
+
+func init() {
+	registerCommand("Del", execDel, writeAllKeys, undoDel, -2, flagWrite)
+	registerCommand("Unlink", execDel, writeAllKeys, undoDel, -2, flagWrite)
+	registerCommand("Exists", execExists, readAllKeys, nil, -2, flagReadOnly)
+	registerCommand("Touch", execTouch, readAllKeys, nil, -2, flagReadOnly)
+	registerCommand("Type", execType, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("Object", execObject, prepareObject, nil, -2, flagReadOnly)
+	registerCommand("Rename", execRename, prepareRename, undoRename, 3, flagWrite)
+	registerCommand("RenameNX", execRenameNX, prepareRename, undoRename, 3, flagWrite)
+	registerCommand("Expire", execExpire, writeFirstKey, rollbackFirstKey, -3, flagWrite|flagCustomAof)
+	registerCommand("PExpire", execPExpire, writeFirstKey, rollbackFirstKey, -3, flagWrite|flagCustomAof)
+	registerCommand("ExpireAt", execExpireAt, writeFirstKey, rollbackFirstKey, -3, flagWrite|flagCustomAof)
+	registerCommand("PExpireAt", execPExpireAt, writeFirstKey, rollbackFirstKey, -3, flagWrite|flagCustomAof)
+	registerCommand("TTL", execTTL, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("PTTL", execPTTL, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("ExpireTime", execExpireTime, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("PExpireTime", execPExpireTime, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("Persist", execPersist, writeFirstKey, rollbackFirstKey, 2, flagWrite)
+	registerCommand("Keys", execKeys, noPrepare, nil, 2, flagReadOnly)
+	registerCommand("RandomKey", execRandomKey, noPrepare, nil, 1, flagReadOnly)
+}
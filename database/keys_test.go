@@ -0,0 +1,139 @@
+package database
+
+import (
+	"goredis/interface/database"
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"goredis/redis/protocol"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRename(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("set", "a", "1", "EX", "100"))
+	db.Exec(c, utils.ToCmdLine("set", "b", "2"))
+	result := db.Exec(c, utils.ToCmdLine("renamenx", "a", "b"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 0 {
+		t.Errorf("renamenx to existing key should return 0: %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("rename", "a", "b"))
+	if !protocol.IsOKReply(result) {
+		t.Fatalf("rename failed: %s", result.ToBytes())
+	}
+	if _, ok := db.ttlMap.Get("b"); !ok {
+		t.Error("ttl should be moved with key")
+	}
+	if _, ok := db.GetEntity("a"); ok {
+		t.Error("source key should be removed")
+	}
+	result = db.Exec(c, utils.ToCmdLine("rename", "a", "c"))
+	if !protocol.IsErrorReply(result) {
+		t.Errorf("rename missing key should fail: %s", result.ToBytes())
+	}
+}
+
+func TestKeys(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("mset", "user:1", "a", "user:2", "b", "item:1", "c"))
+	db.PutEntity("user:3", &database.DataEntity{Data: []byte("d")})
+	db.ttlMap.Put("user:3", time.Now().Add(-time.Second))
+	result := db.Exec(c, utils.ToCmdLine("keys", "user:*"))
+	keys := make([]string, 0)
+	for _, key := range result.(*protocol.MultiBulkReply).Args {
+		keys = append(keys, string(key))
+	}
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "user:1" || keys[1] != "user:2" {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+	result = db.Exec(c, utils.ToCmdLine("exists", "user:1", "user:1", "none"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 2 {
+		t.Errorf("exists failed: %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("del", "user:1", "none"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 1 {
+		t.Errorf("del failed: %s", result.ToBytes())
+	}
+}
+
+func TestCopy(t *testing.T) {
+	server := NewStandaloneServer()
+	c := conn.NewFakeConn()
+	server.Exec(c, utils.ToCmdLine("set", "a", "1"))
+	result := server.Exec(c, utils.ToCmdLine("copy", "a", "b"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 1 {
+		t.Errorf("copy failed: %s", result.ToBytes())
+	}
+	result = server.Exec(c, utils.ToCmdLine("copy", "a", "b"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 0 {
+		t.Errorf("copy to existing key without REPLACE should return 0: %s", result.ToBytes())
+	}
+	server.Exec(c, utils.ToCmdLine("append", "a", "2"))
+	result = server.Exec(c, utils.ToCmdLine("copy", "a", "b", "DB", "1", "REPLACE"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 1 {
+		t.Errorf("copy to another db failed: %s", result.ToBytes())
+	}
+	result = server.Exec(c, utils.ToCmdLine("get", "b"))
+	if string(result.ToBytes()) != "$1\r\n1\r\n" {
+		t.Errorf("copy should not share data: %s", result.ToBytes())
+	}
+	c.SelectDB(1)
+	result = server.Exec(c, utils.ToCmdLine("get", "b"))
+	if string(result.ToBytes()) != "$2\r\n12\r\n" {
+		t.Errorf("copy to db 1 failed: %s", result.ToBytes())
+	}
+}
+
+func TestExpire(t *testing.T) {
+	db := newDB()
+	var aofLines []CmdLine
+	db.addAof = func(line CmdLine) {
+		aofLines = append(aofLines, line)
+	}
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("set", "k", "v"))
+	result := db.Exec(c, utils.ToCmdLine("ttl", "k"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != -1 {
+		t.Errorf("expected ttl -1: %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("expire", "k", "100", "GT"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 0 {
+		t.Errorf("GT on key without ttl should return 0: %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("expire", "k", "100", "NX"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 1 {
+		t.Errorf("expire failed: %s", result.ToBytes())
+	}
+	last := aofLines[len(aofLines)-1]
+	if string(last[0]) != "PEXPIREAT" {
+		t.Errorf("expire should be written as PEXPIREAT, actual %s", last[0])
+	}
+	result = db.Exec(c, utils.ToCmdLine("ttl", "k"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 100 {
+		t.Errorf("expected ttl 100: %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("expire", "k", "200", "LT"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 0 {
+		t.Errorf("LT with later time should return 0: %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("expire", "k", "200", "NX", "GT"))
+	if !protocol.IsErrorReply(result) {
+		t.Errorf("NX and GT should be rejected: %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("persist", "k"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 1 {
+		t.Errorf("persist failed: %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("pexpireat", "k", "1000"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 1 {
+		t.Errorf("pexpireat failed: %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("pttl", "k"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != -2 {
+		t.Errorf("key expired in the past should be deleted: %s", result.ToBytes())
+	}
+}
@@ -0,0 +1,757 @@
+package database
+
+import (
+	"bytes"
+	"goredis/datastruct/list"
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/lib/utils"
+	"goredis/redis/protocol"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getAsList 返回key对应的列表, key不存在时返回nil
+func (db *DB) getAsList(key string) (list.List, protocol.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return nil, nil
+	}
+	l, ok := entity.Data.(list.List)
+	if !ok {
+		return nil, &protocol.WrongTypeErrReply{}
+	}
+	return l, nil
+}
+
+// getOrInitList 返回key对应的列表, key不存在时创建一个空列表, isNew表示是否新建
+func (db *DB) getOrInitList(key string) (l list.List, isNew bool, errReply protocol.ErrorReply) {
+	l, errReply = db.getAsList(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	if l == nil {
+		l = list.NewQuickList()
+		db.PutEntity(key, &database.DataEntity{Data: l})
+		isNew = true
+	}
+	return l, isNew, nil
+}
+
+// removeEmptyList 列表为空时删除key, 与redis一样不保留空列表
+func (db *DB) removeEmptyList(key string, l list.List) {
+	if l.Len() == 0 {
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+}
+
+// popEvent 返回弹出元素对应的事件名
+func popEvent(left bool) string {
+	if left {
+		return "lpop"
+	}
+	return "rpop"
+}
+
+func pushEvent(left bool) string {
+	if left {
+		return "lpush"
+	}
+	return "rpush"
+}
+
+func parseIndex(raw []byte) (int, redis.Reply) {
+	index, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	return int(index), nil
+}
+
+// normalizeIndex 将负数下标转换为正数下标
+func normalizeIndex(index, size int) int {
+	if index < 0 {
+		return size + index
+	}
+	return index
+}
+
+// normalizeRange 按照redis的规则将 [start, stop] 转换为 [start, end), 范围为空时返回 start >= end
+func normalizeRange(start, stop, size int) (int, int) {
+	start = max(normalizeIndex(start, size), 0)
+	stop = normalizeIndex(stop, size)
+	if start >= size || stop < start {
+		return 0, 0
+	}
+	return start, min(stop+1, size)
+}
+
+func pushGeneric(db *DB, args [][]byte, left, onlyExists bool) redis.Reply {
+	key := string(args[0])
+	var l list.List
+	var errReply protocol.ErrorReply
+	if onlyExists {
+		l, errReply = db.getAsList(key)
+		if errReply != nil {
+			return errReply
+		}
+		if l == nil {
+			return protocol.MakeIntReply(0)
+		}
+	} else {
+		l, _, errReply = db.getOrInitList(key)
+		if errReply != nil {
+			return errReply
+		}
+	}
+	for _, value := range args[1:] {
+		if left {
+			l.Insert(0, value)
+		} else {
+			l.Add(value)
+		}
+	}
+	db.notify(notifyList, pushEvent(left), key)
+	return protocol.MakeIntReply(int64(l.Len()))
+}
+
+// execLPush LPUSH key element [element...]
+func execLPush(db *DB, args [][]byte) redis.Reply {
+	return pushGeneric(db, args, true, false)
+}
+
+// execRPush RPUSH key element [element...]
+func execRPush(db *DB, args [][]byte) redis.Reply {
+	return pushGeneric(db, args, false, false)
+}
+
+// execLPushX LPUSHX key element [element...]
+func execLPushX(db *DB, args [][]byte) redis.Reply {
+	return pushGeneric(db, args, true, true)
+}
+
+// execRPushX RPUSHX key element [element...]
+func execRPushX(db *DB, args [][]byte) redis.Reply {
+	return pushGeneric(db, args, false, true)
+}
+
+func undoLPush(db *DB, args [][]byte) []CmdLine {
+	return []CmdLine{utils.ToCmdLine("LPOP", string(args[0]), strconv.Itoa(len(args)-1))}
+}
+
+func undoRPush(db *DB, args [][]byte) []CmdLine {
+	return []CmdLine{utils.ToCmdLine("RPOP", string(args[0]), strconv.Itoa(len(args)-1))}
+}
+
+func popGeneric(db *DB, args [][]byte, left bool) redis.Reply {
+	key := string(args[0])
+	count := -1 // 没有指定count时返回单个元素
+	if len(args) == 2 {
+		n, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return protocol.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if n < 0 {
+			return protocol.MakeErrReply("ERR value is out of range, must be positive")
+		}
+		count = int(n)
+	} else if len(args) > 2 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	l, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		if count < 0 {
+			return protocol.MakeNullBulkReply()
+		}
+		return protocol.MakeNullMultiBulkReply()
+	}
+	if count < 0 {
+		val := popOne(l, left)
+		db.notify(notifyList, popEvent(left), key)
+		db.removeEmptyList(key, l)
+		return protocol.MakeBulkReply(val)
+	}
+	result := make([][]byte, 0, min(count, l.Len()))
+	for len(result) < count && l.Len() > 0 {
+		result = append(result, popOne(l, left))
+	}
+	if len(result) > 0 {
+		db.notify(notifyList, popEvent(left), key)
+	}
+	db.removeEmptyList(key, l)
+	return protocol.MakeMultiBulkReply(result)
+}
+
+func popOne(l list.List, left bool) []byte {
+	if left {
+		return l.Remove(0).([]byte)
+	}
+	return l.RemoveLast().([]byte)
+}
+
+// execLPop LPOP key [count]
+func execLPop(db *DB, args [][]byte) redis.Reply {
+	return popGeneric(db, args, true)
+}
+
+// execRPop RPOP key [count]
+func execRPop(db *DB, args [][]byte) redis.Reply {
+	return popGeneric(db, args, false)
+}
+
+// execLLen LLEN key
+func execLLen(db *DB, args [][]byte) redis.Reply {
+	l, errReply := db.getAsList(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(int64(l.Len()))
+}
+
+// execLIndex LINDEX key index
+func execLIndex(db *DB, args [][]byte) redis.Reply {
+	index, errReply := parseIndex(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	l, err := db.getAsList(string(args[0]))
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	index = normalizeIndex(index, l.Len())
+	if index < 0 || index >= l.Len() {
+		return protocol.MakeNullBulkReply()
+	}
+	return protocol.MakeBulkReply(l.Get(index).([]byte))
+}
+
+// execLSet LSET key index element
+func execLSet(db *DB, args [][]byte) redis.Reply {
+	index, errReply := parseIndex(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	l, err := db.getAsList(string(args[0]))
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		return protocol.MakeErrReply("ERR no such key")
+	}
+	index = normalizeIndex(index, l.Len())
+	if index < 0 || index >= l.Len() {
+		return protocol.MakeErrReply("ERR index out of range")
+	}
+	l.Set(index, args[2])
+	db.notify(notifyList, "lset", string(args[0]))
+	return protocol.MakeOkReply()
+}
+
+func undoLSet(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	index, errReply := parseIndex(args[1])
+	if errReply != nil {
+		return nil
+	}
+	l, err := db.getAsList(key)
+	if err != nil || l == nil {
+		return nil
+	}
+	index = normalizeIndex(index, l.Len())
+	if index < 0 || index >= l.Len() {
+		return nil
+	}
+	return []CmdLine{utils.ToCmdLine3("LSET", args[0], args[1], l.Get(index).([]byte))}
+}
+
+// execLRange LRANGE key start stop
+func execLRange(db *DB, args [][]byte) redis.Reply {
+	start, errReply := parseIndex(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	stop, errReply := parseIndex(args[2])
+	if errReply != nil {
+		return errReply
+	}
+	l, err := db.getAsList(string(args[0]))
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	begin, end := normalizeRange(start, stop, l.Len())
+	if begin >= end {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	slice := l.Range(begin, end)
+	result := make([][]byte, len(slice))
+	for i, v := range slice {
+		result[i] = v.([]byte)
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// execLRem LREM key count element, count大于0时从表头开始删除, 小于0时从表尾开始删除, 等于0时全部删除
+func execLRem(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	count, errReply := parseIndex(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	l, err := db.getAsList(key)
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		return protocol.MakeIntReply(0)
+	}
+	expected := func(a any) bool {
+		return bytes.Equal(a.([]byte), args[2])
+	}
+	var removed int
+	switch {
+	case count == 0:
+		removed = l.RemoveAllByVal(expected)
+	case count > 0:
+		removed = l.RemoveByVal(expected, count)
+	default:
+		removed = l.ReverseRemoveByVal(expected, -count)
+	}
+	if removed > 0 {
+		db.notify(notifyList, "lrem", key)
+	}
+	db.removeEmptyList(key, l)
+	return protocol.MakeIntReply(int64(removed))
+}
+
+// execLTrim LTRIM key start stop
+func execLTrim(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	start, errReply := parseIndex(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	stop, errReply := parseIndex(args[2])
+	if errReply != nil {
+		return errReply
+	}
+	l, err := db.getAsList(key)
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		return protocol.MakeOkReply()
+	}
+	begin, end := normalizeRange(start, stop, l.Len())
+	if begin >= end {
+		db.Remove(key)
+		db.notify(notifyList, "ltrim", key)
+		db.notify(notifyGeneric, "del", key)
+		return protocol.MakeOkReply()
+	}
+	l.Trim(begin, end)
+	db.notify(notifyList, "ltrim", key)
+	return protocol.MakeOkReply()
+}
+
+// execLInsert LINSERT key BEFORE|AFTER pivot element
+func execLInsert(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	var before bool
+	switch strings.ToUpper(string(args[1])) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+		before = false
+	default:
+		return protocol.MakeSyntaxErrReply()
+	}
+	l, err := db.getAsList(key)
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		return protocol.MakeIntReply(0)
+	}
+	pivot := -1
+	l.ForEach(func(i int, v any) bool {
+		if bytes.Equal(v.([]byte), args[2]) {
+			pivot = i
+			return false
+		}
+		return true
+	})
+	if pivot < 0 {
+		return protocol.MakeIntReply(-1)
+	}
+	if !before {
+		pivot++
+	}
+	l.Insert(pivot, args[3])
+	db.notify(notifyList, "linsert", key)
+	return protocol.MakeIntReply(int64(l.Len()))
+}
+
+// execLPos LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+func execLPos(db *DB, args [][]byte) redis.Reply {
+	rank, count, maxLen := 1, -1, 0 // count为-1表示没有指定COUNT, 只返回一个下标
+	for i := 2; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		if (option != "RANK" && option != "COUNT" && option != "MAXLEN") || i+1 >= len(args) {
+			return protocol.MakeSyntaxErrReply()
+		}
+		value, errReply := parseIndex(args[i+1])
+		if errReply != nil {
+			return errReply
+		}
+		switch option {
+		case "RANK":
+			if value == 0 {
+				return protocol.MakeErrReply("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+			}
+			rank = value
+		case "COUNT":
+			if value < 0 {
+				return protocol.MakeErrReply("ERR COUNT can't be negative")
+			}
+			count = value
+		case "MAXLEN":
+			if value < 0 {
+				return protocol.MakeErrReply("ERR MAXLEN can't be negative")
+			}
+			maxLen = value
+		}
+		i++
+	}
+	l, err := db.getAsList(string(args[0]))
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		if count < 0 {
+			return protocol.MakeNullBulkReply()
+		}
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+
+	limit := count
+	if count <= 0 {
+		// COUNT 0 表示返回所有匹配的下标
+		limit = l.Len()
+		if count < 0 {
+			limit = 1
+		}
+	}
+	skip := rank
+	if rank < 0 {
+		skip = -rank
+	}
+	skip--
+	var positions []redis.Reply
+	compared := 0
+	consumer := func(i int, v any) bool {
+		if maxLen > 0 && compared >= maxLen {
+			return false
+		}
+		compared++
+		if !bytes.Equal(v.([]byte), args[1]) {
+			return true
+		}
+		if skip > 0 {
+			skip--
+			return true
+		}
+		positions = append(positions, protocol.MakeIntReply(int64(i)))
+		return len(positions) < limit
+	}
+	if rank > 0 {
+		l.ForEach(consumer)
+	} else {
+		l.ReverseForEach(consumer)
+	}
+
+	if count < 0 {
+		if len(positions) == 0 {
+			return protocol.MakeNullBulkReply()
+		}
+		return positions[0]
+	}
+	return protocol.MakeMultiRawReply(positions)
+}
+
+func parseDirection(raw []byte) (bool, redis.Reply) {
+	switch strings.ToUpper(string(raw)) {
+	case "LEFT":
+		return true, nil
+	case "RIGHT":
+		return false, nil
+	}
+	return false, protocol.MakeSyntaxErrReply()
+}
+
+// moveElement 从src的一端弹出元素并推入dst的一端, src不存在时返回nil
+func moveElement(db *DB, src, dst string, fromLeft, toLeft bool) ([]byte, protocol.ErrorReply) {
+	srcList, errReply := db.getAsList(src)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if srcList == nil {
+		return nil, nil
+	}
+	dstList, errReply := db.getAsList(dst)
+	if errReply != nil {
+		return nil, errReply
+	}
+	val := popOne(srcList, fromLeft)
+	if dstList == nil {
+		// src与dst相同时不会走到这里, 因为src一定存在
+		dstList, _, _ = db.getOrInitList(dst)
+	}
+	if toLeft {
+		dstList.Insert(0, val)
+	} else {
+		dstList.Add(val)
+	}
+	db.notify(notifyList, popEvent(fromLeft), src)
+	db.notify(notifyList, pushEvent(toLeft), dst)
+	db.removeEmptyList(src, srcList)
+	return val, nil
+}
+
+func prepareMove(args [][]byte) ([]string, []string) {
+	return []string{string(args[0]), string(args[1])}, nil
+}
+
+func undoMove(db *DB, args [][]byte) []CmdLine {
+	return rollbackGivenKeys(db, string(args[0]), string(args[1]))
+}
+
+// execLMove LMOVE source destination LEFT|RIGHT LEFT|RIGHT
+func execLMove(db *DB, args [][]byte) redis.Reply {
+	fromLeft, errReply := parseDirection(args[2])
+	if errReply != nil {
+		return errReply
+	}
+	toLeft, errReply := parseDirection(args[3])
+	if errReply != nil {
+		return errReply
+	}
+	val, err := moveElement(db, string(args[0]), string(args[1]), fromLeft, toLeft)
+	if err != nil {
+		return err
+	}
+	if val == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	return protocol.MakeBulkReply(val)
+}
+
+// execRPopLPush RPOPLPUSH source destination
+func execRPopLPush(db *DB, args [][]byte) redis.Reply {
+	val, err := moveElement(db, string(args[0]), string(args[1]), false, true)
+	if err != nil {
+		return err
+	}
+	if val == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	return protocol.MakeBulkReply(val)
+}
+
+// popFirstNonEmpty 从第一个非空的列表中弹出元素, 并把实际执行的弹出命令写入aof
+func popFirstNonEmpty(db *DB, keys []string, left bool, count int) (string, [][]byte, protocol.ErrorReply) {
+	for _, key := range keys {
+		l, errReply := db.getAsList(key)
+		if errReply != nil {
+			return "", nil, errReply
+		}
+		if l == nil {
+			continue
+		}
+		result := make([][]byte, 0, min(count, l.Len()))
+		for len(result) < count && l.Len() > 0 {
+			result = append(result, popOne(l, left))
+		}
+		db.notify(notifyList, popEvent(left), key)
+		db.removeEmptyList(key, l)
+		cmdName := "RPOP"
+		if left {
+			cmdName = "LPOP"
+		}
+		db.addAof(utils.ToCmdLine(cmdName, key, strconv.Itoa(len(result))))
+		return key, result, nil
+	}
+	return "", nil, nil
+}
+
+func blockingPop(db *DB, args [][]byte, left bool) redis.Reply {
+	keys := make([]string, len(args)-1)
+	for i := range keys {
+		keys[i] = string(args[i])
+	}
+	key, result, errReply := popFirstNonEmpty(db, keys, left, 1)
+	if errReply != nil {
+		return errReply
+	}
+	if result == nil {
+		return protocol.MakeNullMultiBulkReply()
+	}
+	return protocol.MakeMultiBulkReply([][]byte{[]byte(key), result[0]})
+}
+
+// execBLPop BLPOP key [key...] timeout
+func execBLPop(db *DB, args [][]byte) redis.Reply {
+	return blockingPop(db, args, true)
+}
+
+// execBRPop BRPOP key [key...] timeout
+func execBRPop(db *DB, args [][]byte) redis.Reply {
+	return blockingPop(db, args, false)
+}
+
+func prepareBlockingPop(args [][]byte) ([]string, []string) {
+	return writeAllKeys(args[:len(args)-1])
+}
+
+func undoBlockingPop(db *DB, args [][]byte) []CmdLine {
+	keys, _ := prepareBlockingPop(args)
+	return rollbackGivenKeys(db, keys...)
+}
+
+// execBLMove BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout
+func execBLMove(db *DB, args [][]byte) redis.Reply {
+	reply := execLMove(db, args[:4])
+	if _, ok := reply.(*protocol.BulkReply); ok {
+		db.addAof(utils.ToCmdLine3("LMOVE", args[:4]...))
+	}
+	return reply
+}
+
+// parseMPop 解析 numkeys key [key...] LEFT|RIGHT [COUNT count]
+func parseMPop(args [][]byte) (keys []string, left bool, count int, errReply redis.Reply) {
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil || numKeys <= 0 {
+		return nil, false, 0, protocol.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if len(args) < numKeys+2 {
+		return nil, false, 0, protocol.MakeSyntaxErrReply()
+	}
+	keys = make([]string, numKeys)
+	for i := range keys {
+		keys[i] = string(args[1+i])
+	}
+	left, errReply = parseDirection(args[numKeys+1])
+	if errReply != nil {
+		return nil, false, 0, errReply
+	}
+	count = 1
+	rest := args[numKeys+2:]
+	if len(rest) > 0 {
+		if len(rest) != 2 || strings.ToUpper(string(rest[0])) != "COUNT" {
+			return nil, false, 0, protocol.MakeSyntaxErrReply()
+		}
+		count, err = strconv.Atoi(string(rest[1]))
+		if err != nil || count <= 0 {
+			return nil, false, 0, protocol.MakeErrReply("ERR count should be greater than 0")
+		}
+	}
+	return keys, left, count, nil
+}
+
+func prepareMPop(args [][]byte) ([]string, []string) {
+	keys, _, _, errReply := parseMPop(args)
+	if errReply != nil {
+		return nil, nil
+	}
+	return keys, nil
+}
+
+func undoMPop(db *DB, args [][]byte) []CmdLine {
+	keys, _ := prepareMPop(args)
+	return rollbackGivenKeys(db, keys...)
+}
+
+// execLMPop LMPOP numkeys key [key...] LEFT|RIGHT [COUNT count]
+func execLMPop(db *DB, args [][]byte) redis.Reply {
+	keys, left, count, errReply := parseMPop(args)
+	if errReply != nil {
+		return errReply
+	}
+	key, result, err := popFirstNonEmpty(db, keys, left, count)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return protocol.MakeNullMultiBulkReply()
+	}
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte(key)),
+		protocol.MakeMultiBulkReply(result),
+	})
+}
+
+// execBLMPop BLMPOP timeout numkeys key [key...] LEFT|RIGHT [COUNT count]
+func execBLMPop(db *DB, args [][]byte) redis.Reply {
+	return execLMPop(db, args[1:])
+}
+
+func prepareBLMPop(args [][]byte) ([]string, []string) {
+	return prepareMPop(args[1:])
+}
+
+func undoBLMPop(db *DB, args [][]byte) []CmdLine {
+	return undoMPop(db, args[1:])
+}
+
+func blockingBLMPop(args [][]byte) ([]string, time.Duration, redis.Reply) {
+	timeout, errReply := parseTimeout(args[0])
+	if errReply != nil {
+		return nil, 0, errReply
+	}
+	keys, _, _, errReply := parseMPop(args[1:])
+	if errReply != nil {
+		return nil, 0, errReply
+	}
+	return keys, timeout, nil
+}
+
+func init() {
+	registerCommand("LPush", execLPush, writeFirstKey, undoLPush, -3, flagWrite)
+	registerCommand("RPush", execRPush, writeFirstKey, undoRPush, -3, flagWrite)
+	registerCommand("LPushX", execLPushX, writeFirstKey, undoLPush, -3, flagWrite)
+	registerCommand("RPushX", execRPushX, writeFirstKey, undoRPush, -3, flagWrite)
+	registerCommand("LPop", execLPop, writeFirstKey, rollbackFirstKey, -2, flagWrite)
+	registerCommand("RPop", execRPop, writeFirstKey, rollbackFirstKey, -2, flagWrite)
+	registerCommand("LLen", execLLen, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("LIndex", execLIndex, readFirstKey, nil, 3, flagReadOnly)
+	registerCommand("LSet", execLSet, writeFirstKey, undoLSet, 4, flagWrite)
+	registerCommand("LRange", execLRange, readFirstKey, nil, 4, flagReadOnly)
+	registerCommand("LRem", execLRem, writeFirstKey, rollbackFirstKey, 4, flagWrite)
+	registerCommand("LTrim", execLTrim, writeFirstKey, rollbackFirstKey, 4, flagWrite)
+	registerCommand("LInsert", execLInsert, writeFirstKey, rollbackFirstKey, 5, flagWrite)
+	registerCommand("LPos", execLPos, readFirstKey, nil, -3, flagReadOnly)
+	registerCommand("LMove", execLMove, prepareMove, undoMove, 5, flagWrite)
+	registerCommand("RPopLPush", execRPopLPush, prepareMove, undoMove, 3, flagWrite)
+	registerCommand("LMPop", execLMPop, prepareMPop, undoMPop, -4, flagWrite|flagCustomAof)
+	registerCommand("BLPop", execBLPop, prepareBlockingPop, undoBlockingPop, -3, flagWrite|flagCustomAof).
+		attachBlocking(lastArgTimeout(-1))
+	registerCommand("BRPop", execBRPop, prepareBlockingPop, undoBlockingPop, -3, flagWrite|flagCustomAof).
+		attachBlocking(lastArgTimeout(-1))
+	registerCommand("BLMove", execBLMove, prepareMove, undoMove, 6, flagWrite|flagCustomAof).
+		attachBlocking(lastArgTimeout(1))
+	registerCommand("BLMPop", execBLMPop, prepareBLMPop, undoBLMPop, -5, flagWrite|flagCustomAof).
+		attachBlocking(blockingBLMPop)
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"goredis/redis/protocol"
+	"testing"
+)
+
+func assertReply(t *testing.T, actual interface{ ToBytes() []byte }, expected string) {
+	t.Helper()
+	if string(actual.ToBytes()) != expected {
+		t.Errorf("expected %q, actual %q", expected, actual.ToBytes())
+	}
+}
+
+func TestListPushPop(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	assertReply(t, db.Exec(c, utils.ToCmdLine("rpush", "l", "b", "c")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lpush", "l", "a", "0")), ":4\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lrange", "l", "0", "-1")),
+		"*4\r\n$1\r\n0\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lpushx", "none", "a")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lpop", "l")), "$1\r\n0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("rpop", "l", "5")), "*3\r\n$1\r\nc\r\n$1\r\nb\r\n$1\r\na\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("exists", "l")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lpop", "l", "1")), "*-1\r\n")
+
+	db.Exec(c, utils.ToCmdLine("set", "s", "v"))
+	result := db.Exec(c, utils.ToCmdLine("lpush", "s", "a"))
+	if _, ok := result.(*protocol.WrongTypeErrReply); !ok {
+		t.Errorf("expected wrong type error, actual %s", result.ToBytes())
+	}
+}
+
+func TestListEdit(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("rpush", "l", "a", "b", "a", "c", "a"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lindex", "l", "-2")), "$1\r\nc\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lpos", "l", "a", "RANK", "-1", "COUNT", "0")), "*3\r\n:4\r\n:2\r\n:0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lpos", "l", "a", "RANK", "2")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lpos", "l", "c", "MAXLEN", "2")), "$-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lrem", "l", "-2", "a")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("linsert", "l", "BEFORE", "c", "x")), ":4\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("linsert", "l", "AFTER", "none", "x")), ":-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lset", "l", "0", "z")), "+OK\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lset", "l", "10", "z")), "-ERR index out of range\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lrange", "l", "0", "-1")),
+		"*4\r\n$1\r\nz\r\n$1\r\nb\r\n$1\r\nx\r\n$1\r\nc\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("ltrim", "l", "1", "-2")), "+OK\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lrange", "l", "0", "-1")), "*2\r\n$1\r\nb\r\n$1\r\nx\r\n")
+
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lmove", "l", "dst", "RIGHT", "LEFT")), "$1\r\nx\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("rpoplpush", "l", "dst")), "$1\r\nb\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lrange", "dst", "0", "-1")), "*2\r\n$1\r\nb\r\n$1\r\nx\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("rpoplpush", "l", "dst")), "$-1\r\n")
+}
+
+func TestListUndo(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("rpush", "l", "a", "b"))
+	cmdLine := utils.ToCmdLine("lrem", "l", "0", "a")
+	undoLogs := db.GetUndoLogs(cmdLine)
+	db.Exec(c, cmdLine)
+	for _, undo := range undoLogs {
+		db.Exec(c, undo)
+	}
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lrange", "l", "0", "-1")), "*2\r\n$1\r\na\r\n$1\r\nb\r\n")
+
+	cmdLine = utils.ToCmdLine("lpush", "l", "x", "y")
+	undoLogs = db.GetUndoLogs(cmdLine)
+	db.Exec(c, cmdLine)
+	for _, undo := range undoLogs {
+		db.Exec(c, undo)
+	}
+	assertReply(t, db.Exec(c, utils.ToCmdLine("llen", "l")), ":2\r\n")
+}
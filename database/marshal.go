@@ -0,0 +1,116 @@
+package database
+
+import (
+	"goredis/datastruct/dict"
+	"goredis/datastruct/list"
+	"goredis/datastruct/set"
+	"goredis/datastruct/sortedset"
+	"goredis/datastruct/stream"
+	"goredis/interface/database"
+	"goredis/lib/utils"
+	"goredis/redis/protocol"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// EntityToCmds 生成重建key的命令, 事务的undo日志和aof重写共用这一份实现.
+// stream需要多条命令才能重建消费者组, 哈希表的字段过期时间用HPEXPIREAT恢复, 已经过期的字段会被跳过
+func EntityToCmds(key string, entity *database.DataEntity) []CmdLine {
+	if entity == nil {
+		return nil
+	}
+	if s, ok := entity.Data.(*stream.Stream); ok {
+		return streamToCmds(key, s)
+	}
+	cmdLine := entityToCmd(key, entity)
+	if cmdLine == nil {
+		return nil
+	}
+	return append([]CmdLine{cmdLine}, fieldTTLCmds(key, entity)...)
+}
+
+// entityToCmd 生成重建key的命令
+func entityToCmd(key string, entity *database.DataEntity) CmdLine {
+	switch val := entity.Data.(type) {
+	case []byte:
+		// SETBIT, APPEND等命令会原地修改字符串, 需要保存一份副本
+		return utils.ToCmdLine3("SET", []byte(key), slices.Clone(val))
+	case list.List:
+		cmdLine := make(CmdLine, 2, 2+val.Len())
+		cmdLine[0], cmdLine[1] = []byte("RPUSH"), []byte(key)
+		val.ForEach(func(i int, v any) bool {
+			cmdLine = append(cmdLine, v.([]byte))
+			return true
+		})
+		return cmdLine
+	case dict.Dict:
+		if hashLen(val) == 0 {
+			return nil
+		}
+		cmdLine := make(CmdLine, 2, 2+val.Len()*2)
+		cmdLine[0], cmdLine[1] = []byte("HSET"), []byte(key)
+		hashForEach(val, func(field string, value []byte) bool {
+			cmdLine = append(cmdLine, []byte(field), value)
+			return true
+		})
+		return cmdLine
+	case *sortedset.SortedSet:
+		cmdLine := make(CmdLine, 2, 2+val.Len()*2)
+		cmdLine[0], cmdLine[1] = []byte("ZADD"), []byte(key)
+		val.ForEach(0, val.Len(), false, func(elem *sortedset.Element) bool {
+			cmdLine = append(cmdLine, []byte(protocol.FormatFloat(elem.Score)), []byte(elem.Member))
+			return true
+		})
+		return cmdLine
+	case set.Set:
+		cmdLine := make(CmdLine, 2, 2+val.Len())
+		cmdLine[0], cmdLine[1] = []byte("SADD"), []byte(key)
+		val.ForEach(func(member string) bool {
+			cmdLine = append(cmdLine, []byte(member))
+			return true
+		})
+		return cmdLine
+	}
+	return nil
+}
+
+// streamToCmds 生成重建stream以及消费者组的命令, 与redis重写aof的方式相同
+func streamToCmds(key string, s *stream.Stream) []CmdLine {
+	cmdLines := make([]CmdLine, 0, s.Len()+2)
+	if s.Len() == 0 {
+		// 先添加再裁剪, 得到一个空的stream
+		cmdLines = append(cmdLines, utils.ToCmdLine("XADD", key, "MAXLEN", "0", "0-1", "x", "y"))
+	}
+	for _, entry := range s.Range(stream.MinID, stream.MaxID, 0, false) {
+		cmdLine := make(CmdLine, 0, 3+len(entry.Fields))
+		cmdLine = append(cmdLine, []byte("XADD"), []byte(key), []byte(entry.ID.String()))
+		cmdLine = append(cmdLine, entry.Fields...)
+		cmdLines = append(cmdLines, cmdLine)
+	}
+	cmdLines = append(cmdLines, utils.ToCmdLine("XSETID", key, s.LastID().String(),
+		"ENTRIESADDED", strconv.FormatInt(s.EntriesAdded(), 10),
+		"MAXDELETEDID", s.MaxDeletedID().String()))
+	for _, group := range s.Groups() {
+		cmdLines = append(cmdLines, utils.ToCmdLine("XGROUP", "CREATE", key, group.Name, group.LastID.String(),
+			"ENTRIESREAD", strconv.FormatInt(group.EntriesRead, 10)))
+		group.RangePending(stream.MinID, func(pe *stream.PendingEntry) bool {
+			cmdLines = append(cmdLines, utils.ToCmdLine("XCLAIM", key, group.Name, pe.Consumer.Name, "0", pe.ID.String(),
+				"TIME", strconv.FormatInt(pe.DeliveryTime.UnixMilli(), 10),
+				"RETRYCOUNT", strconv.FormatInt(pe.DeliveryCount, 10),
+				"JUSTID", "FORCE"))
+			return true
+		})
+		for _, c := range group.Consumers() {
+			if c.PendingLen() == 0 {
+				cmdLines = append(cmdLines, utils.ToCmdLine("XGROUP", "CREATECONSUMER", key, group.Name, c.Name))
+			}
+		}
+	}
+	return cmdLines
+}
+
+// MakeExpireCmd 生成绝对时间的PEXPIREAT命令, 重放aof时不会延长key的生命周期
+func MakeExpireCmd(key string, expireAt time.Time) CmdLine {
+	return utils.ToCmdLine("PEXPIREAT", key, strconv.FormatInt(expireAt.UnixMilli(), 10))
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"goredis/config"
+	"strconv"
+	"sync/atomic"
+)
+
+// 键空间通知的类别, 与redis的notify-keyspace-events标志字母一一对应
+const (
+	notifyKeyspace = 1 << iota // K
+	notifyKeyevent             // E
+	notifyGeneric              // g
+	notifyString               // $
+	notifyList                 // l
+	notifySet                  // s
+	notifyHash                 // h
+	notifyZSet                 // z
+	notifyExpired              // x
+	notifyEvicted              // e, 没有实现maxmemory淘汰, 为了兼容配置只解析不发送
+	notifyStream               // t
+	notifyKeyMiss              // m
+	notifyNew                  // n
+	// notifyAll A是g$lshzxet的别名, 不包括m和n
+	notifyAll = notifyGeneric | notifyString | notifyList | notifySet | notifyHash |
+		notifyZSet | notifyExpired | notifyEvicted | notifyStream
+)
+
+// parseNotifyFlags 解析notify-keyspace-events, 忽略无法识别的字符.
+// 没有指定K或E时不会发送任何通知
+func parseNotifyFlags(s string) int {
+	flags := 0
+	for _, ch := range s {
+		switch ch {
+		case 'A':
+			flags |= notifyAll
+		case 'g':
+			flags |= notifyGeneric
+		case '$':
+			flags |= notifyString
+		case 'l':
+			flags |= notifyList
+		case 's':
+			flags |= notifySet
+		case 'h':
+			flags |= notifyHash
+		case 'z':
+			flags |= notifyZSet
+		case 'x':
+			flags |= notifyExpired
+		case 'e':
+			flags |= notifyEvicted
+		case 'K':
+			flags |= notifyKeyspace
+		case 'E':
+			flags |= notifyKeyevent
+		case 't':
+			flags |= notifyStream
+		case 'm':
+			flags |= notifyKeyMiss
+		case 'n':
+			flags |= notifyNew
+		}
+	}
+	if flags&(notifyKeyspace|notifyKeyevent) == 0 {
+		return 0
+	}
+	return flags
+}
+
+// notifyConfig 缓存最近一次解析的配置, 配置没有变化时不需要重复解析
+type notifyConfig struct {
+	raw   string
+	flags int
+}
+
+var cachedNotifyConfig atomic.Pointer[notifyConfig]
+
+func notifyFlags() int {
+	raw := config.Properties.NotifyKeyspaceEvents
+	if raw == "" {
+		return 0
+	}
+	if cached := cachedNotifyConfig.Load(); cached != nil && cached.raw == raw {
+		return cached.flags
+	}
+	cached := &notifyConfig{raw: raw, flags: parseNotifyFlags(raw)}
+	cachedNotifyConfig.Store(cached)
+	return cached.flags
+}
+
+// notify 发送键空间通知 __keyspace@<db>__:<key> 和键事件通知 __keyevent@<db>__:<event>
+func (db *DB) notify(class int, event string, key string) {
+	if db.hub == nil {
+		return
+	}
+	flags := notifyFlags()
+	if flags&class == 0 {
+		return
+	}
+	index := strconv.Itoa(int(db.index.Load()))
+	if flags&notifyKeyspace != 0 {
+		db.hub.Publish("__keyspace@"+index+"__:"+key, []byte(event))
+	}
+	if flags&notifyKeyevent != 0 {
+		db.hub.Publish("__keyevent@"+index+"__:"+event, []byte(key))
+	}
+}
+
+// notifyKeyMiss 命令要读取的key不存在时发送keymiss通知, 与redis一样只针对读取的key, 写入的key不会触发
+func (db *DB) notifyKeyMiss(cmd *command, cmdLine [][]byte) {
+	if db.hub == nil || notifyFlags()&notifyKeyMiss == 0 {
+		return
+	}
+	_, read := cmd.prepare(cmdLine[1:])
+	for _, key := range read {
+		if _, ok := db.GetEntity(key); !ok {
+			db.notify(notifyKeyMiss, "keymiss", key)
+		}
+	}
+}
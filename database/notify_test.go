@@ -0,0 +1,100 @@
+package database
+
+import (
+	"goredis/config"
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"goredis/redis/protocol"
+	"testing"
+	"time"
+)
+
+func makePMessage(pattern, channel, message string) string {
+	reply := protocol.MakeMultiBulkReply(utils.ToCmdLine("pmessage", pattern, channel, message))
+	return string(reply.ToBytes())
+}
+
+func TestParseNotifyFlags(t *testing.T) {
+	if flags := parseNotifyFlags("g$"); flags != 0 {
+		t.Errorf("flags without K or E should be ignored, actual %d", flags)
+	}
+	flags := parseNotifyFlags("KA")
+	if flags&notifyKeyspace == 0 || flags&notifyKeyevent != 0 {
+		t.Errorf("wrong flags %b", flags)
+	}
+	if flags&notifyStream == 0 || flags&notifyKeyMiss != 0 || flags&notifyNew != 0 {
+		t.Errorf("A should not include m and n, actual %b", flags)
+	}
+}
+
+func TestKeyspaceNotification(t *testing.T) {
+	old := config.Properties.NotifyKeyspaceEvents
+	defer func() { config.Properties.NotifyKeyspaceEvents = old }()
+	config.Properties.NotifyKeyspaceEvents = "KEg$x"
+
+	server := NewStandaloneServer()
+	subscriber := conn.NewFakeConn()
+	c := conn.NewFakeConn()
+	server.Exec(subscriber, utils.ToCmdLine("psubscribe", "__key*__:*"))
+	subscriber.Clean()
+
+	server.Exec(c, utils.ToCmdLine("set", "a", "1"))
+	expected := makePMessage("__key*__:*", "__keyspace@0__:a", "set") +
+		makePMessage("__key*__:*", "__keyevent@0__:set", "a")
+	if actual := string(subscriber.Bytes()); actual != expected {
+		t.Errorf("wrong set notification %q", actual)
+	}
+	subscriber.Clean()
+
+	// 没有订阅的类别不发送通知
+	server.Exec(c, utils.ToCmdLine("rpush", "l", "1"))
+	if actual := string(subscriber.Bytes()); actual != "" {
+		t.Errorf("list events should not be notified, actual %q", actual)
+	}
+
+	server.Exec(c, utils.ToCmdLine("del", "a", "none"))
+	expected = makePMessage("__key*__:*", "__keyspace@0__:a", "del") +
+		makePMessage("__key*__:*", "__keyevent@0__:del", "a")
+	if actual := string(subscriber.Bytes()); actual != expected {
+		t.Errorf("wrong del notification %q", actual)
+	}
+	subscriber.Clean()
+
+	server.Exec(c, utils.ToCmdLine("select", "1"))
+	server.Exec(c, utils.ToCmdLine("set", "b", "1", "px", "1"))
+	subscriber.Clean()
+	time.Sleep(10 * time.Millisecond)
+	server.Exec(c, utils.ToCmdLine("exists", "b"))
+	expected = makePMessage("__key*__:*", "__keyspace@1__:b", "expired") +
+		makePMessage("__key*__:*", "__keyevent@1__:expired", "b")
+	if actual := string(subscriber.Bytes()); actual != expected {
+		t.Errorf("wrong expired notification %q", actual)
+	}
+}
+
+func TestKeyMissNotification(t *testing.T) {
+	old := config.Properties.NotifyKeyspaceEvents
+	defer func() { config.Properties.NotifyKeyspaceEvents = old }()
+	config.Properties.NotifyKeyspaceEvents = "Em"
+
+	server := NewStandaloneServer()
+	subscriber := conn.NewFakeConn()
+	c := conn.NewFakeConn()
+	server.Exec(subscriber, utils.ToCmdLine("psubscribe", "__key*__:*"))
+	subscriber.Clean()
+
+	server.Exec(c, utils.ToCmdLine("mget", "a", "b"))
+	expected := makePMessage("__key*__:*", "__keyevent@0__:keymiss", "a") +
+		makePMessage("__key*__:*", "__keyevent@0__:keymiss", "b")
+	if actual := string(subscriber.Bytes()); actual != expected {
+		t.Errorf("wrong keymiss notification %q", actual)
+	}
+	subscriber.Clean()
+
+	// 写入的key不存在时不发送keymiss
+	server.Exec(c, utils.ToCmdLine("set", "a", "1"))
+	server.Exec(c, utils.ToCmdLine("get", "a"))
+	if actual := string(subscriber.Bytes()); actual != "" {
+		t.Errorf("unexpected notification %q", actual)
+	}
+}
@@ -1,6 +1,10 @@
 package database
 
-import "strings"
+import (
+	"math"
+	"strconv"
+	"strings"
+)
 
 var cmdTable = make(map[string]*command)
 
@@ -15,21 +19,68 @@ type command struct {
 	arity int
 	flags int
 	extra *commandExtra
+	// blocking 不为nil时命令在没有数据时会阻塞客户端, 在事务中则直接返回空结果
+	blocking BlockingFunc
 }
 
 type commandExtra struct {
-	signs    []string
+	signs []string
+	// firstKey 第一个key在参数中的下标(不含命令名)
 	firstKey int
-	lastKey  int
-	keyStep  int
+	// lastKey 最后一个key的下标, 负数表示从末尾倒数, lastKeyByNumKeys表示由firstKey前面的numkeys参数决定
+	lastKey int
+	// keyStep 相邻两个key的下标之差
+	keyStep int
+}
+
+// lastKeyByNumKeys 用于ZUNION numkeys key [key ...] 这类key的数量由参数指定的命令
+const lastKeyByNumKeys = math.MinInt
+
+// keys 取出位置信息描述的所有key, 参数不合法时返回nil, 由执行函数返回错误
+func (extra *commandExtra) keys(args [][]byte) []string {
+	first, last := extra.firstKey, extra.lastKey
+	switch {
+	case last == lastKeyByNumKeys:
+		if first < 1 || first > len(args) {
+			return nil
+		}
+		numKeys, err := strconv.Atoi(string(args[first-1]))
+		if err != nil || numKeys <= 0 {
+			return nil
+		}
+		last = first + (numKeys-1)*extra.keyStep
+	case last < 0:
+		last += len(args)
+	}
+	if first < 0 || last < first || last >= len(args) {
+		return nil
+	}
+	keys := make([]string, 0, (last-first)/extra.keyStep+1)
+	for i := first; i <= last; i += extra.keyStep {
+		keys = append(keys, string(args[i]))
+	}
+	return keys
 }
 
 const (
-	flagWrite = iota
-	flagReadOnly
+	flagWrite = 0
+	// flagReadOnly 只读命令不会修改key的版本, 也不会写入aof
+	flagReadOnly = 1 << iota
 	flagSpecial
+	// flagCustomAof 由执行函数自己写入aof, 例如需要把相对过期时间转换为绝对时间的命令
+	flagCustomAof
+	// flagCustomVersion 由执行函数在真正修改了key时自己更新版本号, 例如只在基数缓存失效时才写回的PFCOUNT
+	flagCustomVersion
 )
 
+// versionKeys 返回执行命令后需要更新版本号的key
+func (cmd *command) versionKeys(write []string) []string {
+	if cmd.flags&flagCustomVersion != 0 {
+		return nil
+	}
+	return write
+}
+
 func registerCommand(name string, executor ExecFunc, prepare PreFunc, undo UndoFunc, arity, flags int) *command {
 	name = strings.ToLower(name)
 	cmd := &command{
@@ -43,3 +94,56 @@ func registerCommand(name string, executor ExecFunc, prepare PreFunc, undo UndoF
 	cmdTable[name] = cmd
 	return cmd
 }
+
+// attachBlocking 将命令标记为阻塞命令
+func (cmd *command) attachBlocking(blocking BlockingFunc) *command {
+	cmd.blocking = blocking
+	return cmd
+}
+
+// attachKeys 描述key在参数中的位置, 并据此生成prepare函数:
+// 描述的key加读锁, 写命令的第一个参数是加写锁的目标key, 如ZUNIONSTORE的destination
+func (cmd *command) attachKeys(firstKey, lastKey, keyStep int) *command {
+	extra := &commandExtra{firstKey: firstKey, lastKey: lastKey, keyStep: keyStep}
+	cmd.extra = extra
+	cmd.prepare = func(args [][]byte) ([]string, []string) {
+		read := extra.keys(args)
+		if cmd.flags&flagReadOnly == 0 {
+			return []string{string(args[0])}, read
+		}
+		return nil, read
+	}
+	return cmd
+}
+
+// noPrepare 用于不涉及任何key的命令
+func noPrepare(args [][]byte) ([]string, []string) {
+	return nil, nil
+}
+
+func readFirstKey(args [][]byte) ([]string, []string) {
+	// assert len(args) > 0
+	key := string(args[0])
+	return nil, []string{key}
+}
+
+func writeFirstKey(args [][]byte) ([]string, []string) {
+	key := string(args[0])
+	return []string{key}, nil
+}
+
+func writeAllKeys(args [][]byte) ([]string, []string) {
+	keys := make([]string, len(args))
+	for i, v := range args {
+		keys[i] = string(v)
+	}
+	return keys, nil
+}
+
+func readAllKeys(args [][]byte) ([]string, []string) {
+	keys := make([]string, len(args))
+	for i, v := range args {
+		keys[i] = string(v)
+	}
+	return nil, keys
+}
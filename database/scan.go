@@ -0,0 +1,183 @@
+package database
+
+import (
+	"goredis/datastruct/dict"
+	"goredis/datastruct/set"
+	"goredis/datastruct/sortedset"
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/lib/wildcard"
+	"goredis/redis/protocol"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultScanCount 与redis一样, 不指定COUNT时每次大约处理10个元素
+const defaultScanCount = 10
+
+type scanOption struct {
+	cursor   uint64
+	count    int
+	pattern  *wildcard.Pattern
+	typeName string
+	noValues bool
+}
+
+// parseScanOption 解析 cursor [MATCH pattern] [COUNT count] 以及 allowed 中允许的额外选项(TYPE, NOVALUES, NOSCORES)
+func parseScanOption(args [][]byte, allowed ...string) (*scanOption, redis.Reply) {
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, protocol.MakeErrReply("ERR invalid cursor")
+	}
+	option := &scanOption{cursor: cursor, count: defaultScanCount}
+	for i := 1; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch {
+		case arg == "MATCH" && i+1 < len(args):
+			option.pattern = wildcard.CompilePattern(string(args[i+1]))
+			i++
+		case arg == "COUNT" && i+1 < len(args):
+			count, err := strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				return nil, protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if count < 1 {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			option.count = count
+			i++
+		case arg == "TYPE" && i+1 < len(args) && slices.Contains(allowed, "TYPE"):
+			option.typeName = strings.ToLower(string(args[i+1]))
+			i++
+		case arg == "NOVALUES" && slices.Contains(allowed, "NOVALUES"),
+			arg == "NOSCORES" && slices.Contains(allowed, "NOSCORES"):
+			option.noValues = true
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	return option, nil
+}
+
+func (option *scanOption) match(key string) bool {
+	return option.pattern == nil || option.pattern.IsMatch(key)
+}
+
+func makeScanReply(cursor uint64, result [][]byte) redis.Reply {
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte(strconv.FormatUint(cursor, 10))),
+		protocol.MakeMultiBulkReply(result),
+	})
+}
+
+// execScan SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+func execScan(db *DB, args [][]byte) redis.Reply {
+	option, errReply := parseScanOption(args, "TYPE")
+	if errReply != nil {
+		return errReply
+	}
+	now := time.Now()
+	result := make([][]byte, 0)
+	cursor := db.dict.Scan(option.cursor, option.count, func(key string, val any) {
+		if !option.match(key) {
+			return
+		}
+		if rawExpireTime, hasTTL := db.ttlMap.Get(key); hasTTL && now.After(rawExpireTime.(time.Time)) {
+			return
+		}
+		if option.typeName != "" {
+			entity, _ := val.(*database.DataEntity)
+			if entity == nil || getTypeName(entity) != option.typeName {
+				return
+			}
+		}
+		result = append(result, []byte(key))
+	})
+	return makeScanReply(cursor, result)
+}
+
+// execHScan HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES]
+func execHScan(db *DB, args [][]byte) redis.Reply {
+	option, errReply := parseScanOption(args[1:], "NOVALUES")
+	if errReply != nil {
+		return errReply
+	}
+	entity, exists := db.GetEntity(string(args[0]))
+	if !exists {
+		return makeScanReply(0, [][]byte{})
+	}
+	hash, ok := entity.Data.(dict.Dict)
+	if !ok {
+		return &protocol.WrongTypeErrReply{}
+	}
+	result := make([][]byte, 0)
+	cursor := hash.Scan(option.cursor, option.count, func(field string, val any) {
+		if fieldExpired(hash, field) || !option.match(field) {
+			return
+		}
+		result = append(result, []byte(field))
+		if !option.noValues {
+			value, _ := val.([]byte)
+			result = append(result, value)
+		}
+	})
+	return makeScanReply(cursor, result)
+}
+
+// execZScan ZSCAN key cursor [MATCH pattern] [COUNT count] [NOSCORES]
+func execZScan(db *DB, args [][]byte) redis.Reply {
+	option, errReply := parseScanOption(args[1:], "NOSCORES")
+	if errReply != nil {
+		return errReply
+	}
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return makeScanReply(0, [][]byte{})
+	}
+	result := make([][]byte, 0)
+	cursor := zset.Scan(option.cursor, option.count, func(elem *sortedset.Element) {
+		if !option.match(elem.Member) {
+			return
+		}
+		result = append(result, []byte(elem.Member))
+		if !option.noValues {
+			result = append(result, []byte(protocol.FormatFloat(elem.Score)))
+		}
+	})
+	return makeScanReply(cursor, result)
+}
+
+// execSScan SSCAN key cursor [MATCH pattern] [COUNT count]
+func execSScan(db *DB, args [][]byte) redis.Reply {
+	option, errReply := parseScanOption(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	entity, exists := db.GetEntity(string(args[0]))
+	if !exists {
+		return makeScanReply(0, [][]byte{})
+	}
+	members, ok := entity.Data.(set.Set)
+	if !ok {
+		return &protocol.WrongTypeErrReply{}
+	}
+	result := make([][]byte, 0)
+	cursor := members.Scan(option.cursor, option.count, func(member string) {
+		if option.match(member) {
+			result = append(result, []byte(member))
+		}
+	})
+	return makeScanReply(cursor, result)
+}
+
+func init() {
+	registerCommand("Scan", execScan, noPrepare, nil, -2, flagReadOnly)
+	registerCommand("HScan", execHScan, readFirstKey, nil, -3, flagReadOnly)
+	registerCommand("SScan", execSScan, readFirstKey, nil, -3, flagReadOnly)
+	registerCommand("ZScan", execZScan, readFirstKey, nil, -3, flagReadOnly)
+}
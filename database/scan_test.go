@@ -0,0 +1,43 @@
+package database
+
+import (
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"goredis/redis/protocol"
+	"strconv"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	for i := 0; i < 100; i++ {
+		db.Exec(c, utils.ToCmdLine("set", "str:"+strconv.Itoa(i), "v"))
+	}
+	seen := make(map[string]struct{})
+	cursor := "0"
+	for {
+		result := db.Exec(c, utils.ToCmdLine("scan", cursor, "MATCH", "str:1*", "COUNT", "20", "TYPE", "string"))
+		replies := result.(*protocol.MultiRawReply).Replies
+		cursor = string(replies[0].(*protocol.BulkReply).Arg)
+		for _, key := range replies[1].(*protocol.MultiBulkReply).Args {
+			seen[string(key)] = struct{}{}
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+	// str:1 以及 str:10 到 str:19
+	if len(seen) != 11 {
+		t.Errorf("expected 11 keys, actual %d", len(seen))
+	}
+
+	result := db.Exec(c, utils.ToCmdLine("scan", "abc"))
+	if !protocol.IsErrorReply(result) {
+		t.Errorf("expected invalid cursor error, actual %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("sscan", "str:1", "0"))
+	if !protocol.IsErrorReply(result) {
+		t.Errorf("expected wrong type error, actual %s", result.ToBytes())
+	}
+}
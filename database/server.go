@@ -0,0 +1,625 @@
+package database
+
+import (
+	"fmt"
+	"goredis/config"
+	"goredis/datastruct/dict"
+	"goredis/datastruct/list"
+	"goredis/datastruct/sortedset"
+	"goredis/datastruct/stream"
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/lib/logger"
+	"goredis/pubsub"
+	"goredis/redis/protocol"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hdt3213/rdb/core"
+	rdb "github.com/hdt3213/rdb/model"
+)
+
+const defaultDatabases = 16
+
+// Server 是单机模式的存储引擎, 包含多个相互独立的DB
+type Server struct {
+	dbSet []*atomic.Pointer[DB]
+	// swapMu 保证并发的SWAPDB不会交错执行, 事务持有读锁, 执行期间DB的编号不会被交换
+	swapMu sync.RWMutex
+	// hub 发布订阅不属于任何DB
+	hub *pubsub.Hub
+	// tracking 客户端缓存追踪的key不区分DB
+	tracking *trackingTable
+}
+
+// NewStandaloneServer 按照配置的数据库数量创建存储引擎
+func NewStandaloneServer() *Server {
+	databases := config.Properties.Databases
+	if databases <= 0 {
+		databases = defaultDatabases
+	}
+	server := &Server{
+		dbSet:    make([]*atomic.Pointer[DB], databases),
+		hub:      pubsub.MakeHub(),
+		tracking: newTrackingTable(),
+	}
+	for i := range server.dbSet {
+		db := newDB()
+		db.index.Store(int32(i))
+		db.hub = server.hub
+		db.tracking = server.tracking
+		holder := &atomic.Pointer[DB]{}
+		holder.Store(db)
+		server.dbSet[i] = holder
+	}
+	return server
+}
+
+// Exec 执行命令, 连接级别的命令在这里处理, 其余命令交给连接选中的DB
+func (server *Server) Exec(c redis.Conn, cmdLine [][]byte) (result redis.Reply) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Warn(fmt.Sprintf("error occurs: %v\n%s", err, string(debug.Stack())))
+			result = &protocol.UnknownErrReply{}
+		}
+	}()
+	if len(cmdLine) == 0 {
+		return protocol.MakeErrReply("ERR empty command")
+	}
+
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	server.tracking.register(c)
+	if cmdName != "client" {
+		defer server.tracking.resetCaching(c)
+	}
+	if errReply := pubsub.CheckSubscribeMode(c, cmdName); errReply != nil {
+		return errReply
+	}
+	switch cmdName {
+	case "multi":
+		return startMulti(c, cmdLine[1:])
+	case "exec":
+		return server.execExec(c, cmdLine[1:])
+	case "discard":
+		return discardMulti(c, cmdLine[1:])
+	case "watch":
+		return server.execWatch(c, cmdLine[1:])
+	}
+	if c.InMultiState() {
+		return enqueueCmd(c, cmdLine)
+	}
+	if reply := server.execServerCommand(c, cmdName, cmdLine); reply != nil {
+		return reply
+	}
+
+	selectedDB, errReply := server.selectDB(c.GetDBIndex())
+	if errReply != nil {
+		return errReply
+	}
+	for {
+		reply := selectedDB.Exec(c, cmdLine)
+		if reply != errBlockingDBSwapped {
+			return reply
+		}
+		selectedDB = server.mustSelectDB(c.GetDBIndex())
+	}
+}
+
+// execServerCommand 执行不属于某个DB的命令, cmdName不是这类命令时返回nil
+func (server *Server) execServerCommand(c redis.Conn, cmdName string, cmdLine [][]byte) redis.Reply {
+	switch cmdName {
+	case "unwatch":
+		return execUnwatch(c, cmdLine[1:])
+	case "ping":
+		if pubsub.InSubscribeMode(c) {
+			return pubsub.Ping(cmdLine[1:])
+		}
+		return execPing(cmdLine[1:])
+	case "subscribe":
+		return pubsub.Subscribe(server.hub, c, cmdLine[1:])
+	case "unsubscribe":
+		return pubsub.UnSubscribe(server.hub, c, cmdLine[1:])
+	case "psubscribe":
+		return pubsub.PSubscribe(server.hub, c, cmdLine[1:])
+	case "punsubscribe":
+		return pubsub.PUnSubscribe(server.hub, c, cmdLine[1:])
+	case "ssubscribe":
+		return pubsub.SSubscribe(server.hub, c, cmdLine[1:])
+	case "sunsubscribe":
+		return pubsub.SUnSubscribe(server.hub, c, cmdLine[1:])
+	case "publish":
+		return pubsub.Publish(server.hub, cmdLine[1:])
+	case "spublish":
+		return pubsub.SPublish(server.hub, cmdLine[1:])
+	case "pubsub":
+		return pubsub.PubSub(server.hub, cmdLine[1:])
+	case "hello":
+		return execHello(c, cmdLine[1:])
+	case "client":
+		return server.execClient(c, cmdLine[1:])
+	case "select":
+		return server.execSelect(c, cmdLine[1:])
+	case "swapdb":
+		return server.execSwapDB(c, cmdLine)
+	case "flushall":
+		return server.execFlushAll(c, cmdLine)
+	case "flushdb":
+		return server.execFlushDB(c, cmdLine)
+	case "move":
+		return server.execMove(c.GetDBIndex(), cmdLine)
+	case "copy":
+		return server.execCopy(c.GetDBIndex(), cmdLine)
+	case "dbsize":
+		if len(cmdLine) != 1 {
+			return protocol.MakeArgNumErrReply(cmdName)
+		}
+		keys, _ := server.GetDBSize(c.GetDBIndex())
+		return protocol.MakeIntReply(int64(keys))
+	}
+	return nil
+}
+
+// AfterClientClose 清理连接关闭后残留的状态
+func (server *Server) AfterClientClose(c redis.Conn) {
+	for i := range server.dbSet {
+		server.mustSelectDB(i).blocking.cancel(c)
+	}
+	server.hub.UnsubscribeAll(c)
+	server.tracking.unregister(c)
+}
+
+// Close 关闭存储引擎
+func (server *Server) Close() {
+}
+
+// ExecWithLock 在调用方已经持有锁的情况下执行命令
+func (server *Server) ExecWithLock(c redis.Conn, cmdLine [][]byte) redis.Reply {
+	selectedDB, errReply := server.selectDB(c.GetDBIndex())
+	if errReply != nil {
+		return errReply
+	}
+	return selectedDB.execWithLock(cmdLine)
+}
+
+// GetUndoLogs 返回用于回滚cmdLine的命令
+func (server *Server) GetUndoLogs(dbIndex int, cmdLine [][]byte) []CmdLine {
+	return server.mustSelectDB(dbIndex).GetUndoLogs(cmdLine)
+}
+
+// ForEach 遍历指定DB中的所有key, 没有过期时间的key传入零值
+func (server *Server) ForEach(dbIndex int, cb func(key string, data *database.DataEntity, expiration time.Time) bool) {
+	server.mustSelectDB(dbIndex).ForEach(func(key string, data *database.DataEntity, expiration *time.Time) bool {
+		var expireTime time.Time
+		if expiration != nil {
+			expireTime = *expiration
+		}
+		return cb(key, data, expireTime)
+	})
+}
+
+// RWLocks 对指定DB中的key加锁
+func (server *Server) RWLocks(dbIndex int, writeKeys []string, readKeys []string) {
+	server.mustSelectDB(dbIndex).RWLocks(writeKeys, readKeys)
+}
+
+// RWUnlocks 对指定DB中的key解锁
+func (server *Server) RWUnlocks(dbIndex int, writeKeys []string, readKeys []string) {
+	server.mustSelectDB(dbIndex).RWUnlock(writeKeys, readKeys)
+}
+
+// GetDBSize 返回指定DB中key的数量和设置了过期时间的key的数量
+func (server *Server) GetDBSize(dbIndex int) (int, int) {
+	db := server.mustSelectDB(dbIndex)
+	return db.dict.Len(), db.ttlMap.Len()
+}
+
+// multiDBLocks 同时对多个DB中的key加锁, 总是按照DB的id从小到大加锁, 避免两个方向相反的MOVE或者事务互相等待.
+// DB的编号会被SWAPDB交换, 不能用来决定加锁顺序
+type multiDBLocks map[*DB]*lockKeys
+
+type lockKeys struct {
+	write, read []string
+}
+
+func (locks multiDBLocks) add(db *DB, write, read []string) {
+	keys, ok := locks[db]
+	if !ok {
+		keys = &lockKeys{}
+		locks[db] = keys
+	}
+	keys.write = append(keys.write, write...)
+	keys.read = append(keys.read, read...)
+}
+
+func (locks multiDBLocks) lock() (unlock func()) {
+	dbs := make([]*DB, 0, len(locks))
+	for db := range locks {
+		dbs = append(dbs, db)
+	}
+	sort.Slice(dbs, func(i, j int) bool {
+		return dbs[i].id < dbs[j].id
+	})
+	for _, db := range dbs {
+		db.RWLocks(locks[db].write, locks[db].read)
+	}
+	return func() {
+		for i := len(dbs) - 1; i >= 0; i-- {
+			dbs[i].RWUnlock(locks[dbs[i]].write, locks[dbs[i]].read)
+		}
+	}
+}
+
+func (server *Server) selectDB(dbIndex int) (*DB, *protocol.StandardErrReply) {
+	if dbIndex >= len(server.dbSet) || dbIndex < 0 {
+		return nil, protocol.MakeErrReply("ERR DB index is out of range")
+	}
+	return server.dbSet[dbIndex].Load(), nil
+}
+
+func (server *Server) mustSelectDB(dbIndex int) *DB {
+	selectedDB, err := server.selectDB(dbIndex)
+	if err != nil {
+		panic(err)
+	}
+	return selectedDB
+}
+
+// execExec EXEC, 入队时有命令出错则放弃整个事务
+func (server *Server) execExec(c redis.Conn, args [][]byte) redis.Reply {
+	if len(args) != 0 {
+		return protocol.MakeArgNumErrReply("exec")
+	}
+	if !c.InMultiState() {
+		return protocol.MakeErrReply("ERR EXEC without MULTI")
+	}
+	defer c.SetMultiState(false)
+	if len(c.GetTxErrors()) > 0 {
+		return protocol.MakeErrReply("EXECABORT Transaction discarded because of previous errors.")
+	}
+	return server.ExecMulti(c, c.GetWatching(), c.GetQueuedCmdLine())
+}
+
+// execWatch WATCH key [key ...]
+func (server *Server) execWatch(c redis.Conn, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("watch")
+	}
+	if c.InMultiState() {
+		return protocol.MakeErrReply("ERR WATCH inside MULTI is not allowed")
+	}
+	selectedDB, errReply := server.selectDB(c.GetDBIndex())
+	if errReply != nil {
+		return errReply
+	}
+	selectedDB.watch(c, c.GetDBIndex(), args)
+	return protocol.MakeOkReply()
+}
+
+// execSelect SELECT index
+func (server *Server) execSelect(c redis.Conn, args [][]byte) redis.Reply {
+	if len(args) != 1 {
+		return protocol.MakeArgNumErrReply("select")
+	}
+	dbIndex, err := strconv.Atoi(string(args[0]))
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if dbIndex >= len(server.dbSet) || dbIndex < 0 {
+		return protocol.MakeErrReply("ERR DB index is out of range")
+	}
+	c.SelectDB(dbIndex)
+	return protocol.MakeOkReply()
+}
+
+// execSwapDB SWAPDB index1 index2, 交换后选中index1的连接将看到原来index2中的数据
+func (server *Server) execSwapDB(c redis.Conn, cmdLine [][]byte) redis.Reply {
+	server.swapMu.Lock()
+	defer server.swapMu.Unlock()
+	return server.swapDB(c, cmdLine)
+}
+
+// swapDB 执行SWAPDB, 调用方需要持有swapMu
+func (server *Server) swapDB(c redis.Conn, cmdLine [][]byte) redis.Reply {
+	args := cmdLine[1:]
+	if len(args) != 2 {
+		return protocol.MakeArgNumErrReply("swapdb")
+	}
+	index1, err := strconv.Atoi(string(args[0]))
+	if err != nil {
+		return protocol.MakeErrReply("ERR invalid first DB index")
+	}
+	index2, err := strconv.Atoi(string(args[1]))
+	if err != nil {
+		return protocol.MakeErrReply("ERR invalid second DB index")
+	}
+	db1, errReply := server.selectDB(index1)
+	if errReply != nil {
+		return errReply
+	}
+	db2, errReply := server.selectDB(index2)
+	if errReply != nil {
+		return errReply
+	}
+	if index1 == index2 {
+		return protocol.MakeOkReply()
+	}
+	server.dbSet[index1].Store(db2)
+	server.dbSet[index2].Store(db1)
+	swapBlocking(db1, db2)
+	db1.touchAll()
+	db2.touchAll()
+	server.mustSelectDB(c.GetDBIndex()).addAof(cmdLine)
+	return protocol.MakeOkReply()
+}
+
+// execFlushAll FLUSHALL [ASYNC|SYNC]
+func (server *Server) execFlushAll(c redis.Conn, cmdLine [][]byte) redis.Reply {
+	if errReply := checkFlushMode(cmdLine[1:]); errReply != nil {
+		return errReply
+	}
+	for i := range server.dbSet {
+		server.mustSelectDB(i).Flush()
+	}
+	server.tracking.invalidateAll(c)
+	// 重放时在任何DB中执行FLUSHALL都会清空所有DB, 只需要写入一次
+	server.mustSelectDB(c.GetDBIndex()).addAof(cmdLine)
+	return protocol.MakeOkReply()
+}
+
+// execFlushDB FLUSHDB [ASYNC|SYNC]
+func (server *Server) execFlushDB(c redis.Conn, cmdLine [][]byte) redis.Reply {
+	if errReply := checkFlushMode(cmdLine[1:]); errReply != nil {
+		return errReply
+	}
+	db, errReply := server.selectDB(c.GetDBIndex())
+	if errReply != nil {
+		return errReply
+	}
+	db.Flush()
+	server.tracking.invalidateAll(c)
+	db.addAof(cmdLine)
+	return protocol.MakeOkReply()
+}
+
+// checkFlushMode 清空总是同步完成的, 只校验参数
+func checkFlushMode(args [][]byte) redis.Reply {
+	if len(args) > 1 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	if len(args) == 1 {
+		mode := strings.ToUpper(string(args[0]))
+		if mode != "ASYNC" && mode != "SYNC" {
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+	return nil
+}
+
+// execMove MOVE key db, 将key连同过期时间移动到另一个DB
+func (server *Server) execMove(dbIndex int, cmdLine [][]byte) redis.Reply {
+	move, errReply := server.parseMove(dbIndex, cmdLine)
+	if errReply != nil {
+		return errReply
+	}
+	unlock := move.lock()
+	defer unlock()
+	return move.moveKey(cmdLine)
+}
+
+// crossDBCmd MOVE和COPY解析后的参数, 它们同时读写两个DB
+type crossDBCmd struct {
+	srcDB, dstDB *DB
+	src, dst     string
+	// move 为false时是COPY, 源key只读
+	move    bool
+	replace bool
+}
+
+// parseMove 解析MOVE的参数并找到源DB和目标DB
+func (server *Server) parseMove(dbIndex int, cmdLine [][]byte) (*crossDBCmd, redis.Reply) {
+	if len(cmdLine) != 3 {
+		return nil, protocol.MakeArgNumErrReply("move")
+	}
+	key := string(cmdLine[1])
+	dstIndex, err := strconv.Atoi(string(cmdLine[2]))
+	if err != nil {
+		return nil, protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if dstIndex == dbIndex {
+		return nil, protocol.MakeErrReply("ERR source and destination objects are the same")
+	}
+	srcDB, errReply := server.selectDB(dbIndex)
+	if errReply != nil {
+		return nil, errReply
+	}
+	dstDB, errReply := server.selectDB(dstIndex)
+	if errReply != nil {
+		return nil, errReply
+	}
+	return &crossDBCmd{srcDB: srcDB, dstDB: dstDB, src: key, dst: key, move: true}, nil
+}
+
+// addLocks 将命令在两个DB中涉及的key加入locks
+func (x *crossDBCmd) addLocks(locks multiDBLocks) {
+	if x.move {
+		locks.add(x.srcDB, []string{x.src}, nil)
+	} else {
+		locks.add(x.srcDB, nil, []string{x.src})
+	}
+	locks.add(x.dstDB, []string{x.dst}, nil)
+}
+
+func (x *crossDBCmd) lock() (unlock func()) {
+	locks := make(multiDBLocks)
+	x.addLocks(locks)
+	return locks.lock()
+}
+
+// undoLogs 在执行前调用, 返回恢复被修改的key的undo日志
+func (x *crossDBCmd) undoLogs() []txUndoLog {
+	logs := []txUndoLog{{db: x.dstDB, cmdLines: rollbackGivenKeys(x.dstDB, x.dst)}}
+	if x.move {
+		logs = append(logs, txUndoLog{db: x.srcDB, cmdLines: rollbackGivenKeys(x.srcDB, x.src)})
+	}
+	return logs
+}
+
+// moveKey 执行MOVE, 调用方需要持有两个key的锁
+func (x *crossDBCmd) moveKey(cmdLine [][]byte) redis.Reply {
+	srcDB, dstDB, key := x.srcDB, x.dstDB, x.src
+	entity, exists := srcDB.GetEntity(key)
+	if !exists {
+		return protocol.MakeIntReply(0)
+	}
+	if _, exists = dstDB.GetEntity(key); exists {
+		return protocol.MakeIntReply(0)
+	}
+	rawExpireTime, hasTTL := srcDB.ttlMap.Get(key)
+	srcDB.Remove(key)
+	dstDB.PutEntity(key, entity)
+	if hasTTL {
+		dstDB.Expire(key, rawExpireTime.(time.Time))
+	}
+	dstDB.restoreFieldTTL(key, entity)
+	srcDB.notify(notifyGeneric, "move_from", key)
+	dstDB.notify(notifyGeneric, "move_to", key)
+	srcDB.addVersion(key)
+	dstDB.addVersion(key)
+	srcDB.invalidate(key)
+	dstDB.blocking.signal(key)
+	srcDB.addAof(cmdLine)
+	return protocol.MakeIntReply(1)
+}
+
+// LoadRDB 从rdb文件中加载数据
+func (server *Server) LoadRDB(dec *core.Decoder) error {
+	return dec.Parse(func(o rdb.RedisObject) bool {
+		db, errReply := server.selectDB(o.GetDBIndex())
+		if errReply != nil {
+			logger.Warn("rdb object in unknown db: ", o.GetDBIndex())
+			return true
+		}
+		var entity *database.DataEntity
+		switch o.GetType() {
+		case rdb.StringType:
+			str := o.(*rdb.StringObject)
+			entity = &database.DataEntity{Data: str.Value}
+		case rdb.ListType:
+			values := o.(*rdb.ListObject).Values
+			l := list.NewQuickList()
+			for _, value := range values {
+				l.Add(value)
+			}
+			entity = &database.DataEntity{Data: l}
+		case rdb.HashType:
+			hash := o.(*rdb.HashObject)
+			d := dict.NewSimple()
+			for k, v := range hash.Hash {
+				d.Put(k, v)
+			}
+			entity = &database.DataEntity{Data: d}
+		case rdb.SetType:
+			members := o.(*rdb.SetObject).Members
+			s := newSet()
+			for _, member := range members {
+				s.Add(string(member))
+			}
+			entity = &database.DataEntity{Data: s}
+		case rdb.ZSetType:
+			entries := o.(*rdb.ZSetObject).Entries
+			zset := sortedset.New()
+			for _, e := range entries {
+				zset.Add(e.Member, e.Score)
+			}
+			entity = &database.DataEntity{Data: zset}
+		case rdb.StreamType:
+			entity = &database.DataEntity{Data: streamFromRDB(o.(*rdb.StreamObject))}
+		case rdb.AuxType, rdb.DBSizeType:
+			// 元数据不需要加载
+		default:
+			logger.Warn("unsupported rdb object type: ", o.GetType())
+		}
+		if entity != nil {
+			db.PutEntity(o.GetKey(), entity)
+			if o.GetExpiration() != nil {
+				db.Expire(o.GetKey(), *o.GetExpiration())
+			}
+		}
+		return true
+	})
+}
+
+// streamFromRDB 从rdb对象恢复stream以及消费者组, rdb中消息的field是无序的map, 按field排序后添加
+func streamFromRDB(obj *rdb.StreamObject) *stream.Stream {
+	toID := func(id *rdb.StreamId) stream.ID {
+		if id == nil {
+			return stream.MinID
+		}
+		return stream.ID{Ms: id.Ms, Seq: id.Sequence}
+	}
+	s := stream.New()
+	for _, entry := range obj.Entries {
+		for _, msg := range entry.Msgs {
+			if msg.Deleted {
+				continue
+			}
+			fieldNames := make([]string, 0, len(msg.Fields))
+			for field := range msg.Fields {
+				fieldNames = append(fieldNames, field)
+			}
+			sort.Strings(fieldNames)
+			fields := make([][]byte, 0, len(fieldNames)*2)
+			for _, field := range fieldNames {
+				fields = append(fields, []byte(field), []byte(msg.Fields[field]))
+			}
+			s.Add(toID(msg.Id), fields)
+		}
+	}
+	entriesAdded := int64(obj.AddedEntriesCount)
+	if obj.Version < 2 {
+		// 旧版本没有记录添加过的消息数量
+		entriesAdded = int64(s.Len())
+	}
+	s.SetLastID(toID(obj.LastId), entriesAdded, toID(obj.MaxDeletedId))
+	for _, g := range obj.Groups {
+		entriesRead := int64(g.EntriesRead)
+		if obj.Version < 2 {
+			entriesRead = stream.InvalidEntriesRead
+		}
+		group, _ := s.CreateGroup(g.Name, toID(g.LastId), entriesRead)
+		owners := make(map[stream.ID]*stream.Consumer)
+		for _, c := range g.Consumers {
+			consumer, _ := group.CreateConsumer(c.Name, time.UnixMilli(int64(c.SeenTime)))
+			if c.ActiveTime > 0 {
+				consumer.ActiveTime = time.UnixMilli(int64(c.ActiveTime))
+			}
+			for _, id := range c.Pending {
+				owners[toID(id)] = consumer
+			}
+		}
+		for _, nack := range g.Pending {
+			id := toID(nack.Id)
+			if consumer, ok := owners[id]; ok {
+				group.Assign(id, consumer, time.UnixMilli(int64(nack.DeliveryTime)), int64(nack.DeliveryCount))
+			}
+		}
+	}
+	return s
+}
+
+// execPing PING [message]
+func execPing(args [][]byte) redis.Reply {
+	switch len(args) {
+	case 0:
+		return &protocol.PongReply{}
+	case 1:
+		return protocol.MakeBulkReply(args[0])
+	default:
+		return protocol.MakeArgNumErrReply("ping")
+	}
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"goredis/redis/protocol"
+	"sync"
+	"testing"
+	"time"
+)
+
+var _ database.DBEngine = &Server{}
+
+func TestSelect(t *testing.T) {
+	server := NewStandaloneServer()
+	c := conn.NewFakeConn()
+	result := server.Exec(c, utils.ToCmdLine("select", "1"))
+	if !protocol.IsOKReply(result) {
+		t.Errorf("select failed: %s", result.ToBytes())
+	}
+	if c.GetDBIndex() != 1 {
+		t.Errorf("expected db 1, actual %d", c.GetDBIndex())
+	}
+	result = server.Exec(c, utils.ToCmdLine("select", "16"))
+	if !protocol.IsErrorReply(result) {
+		t.Error("select out of range should fail")
+	}
+}
+
+func TestSwapDBAndMove(t *testing.T) {
+	server := NewStandaloneServer()
+	c := conn.NewFakeConn()
+	server.mustSelectDB(0).PutEntity("a", &database.DataEntity{Data: []byte("a")})
+
+	result := server.Exec(c, utils.ToCmdLine("swapdb", "0", "1"))
+	if !protocol.IsOKReply(result) {
+		t.Errorf("swapdb failed: %s", result.ToBytes())
+	}
+	if size, _ := server.GetDBSize(0); size != 0 {
+		t.Errorf("expected empty db 0, actual %d keys", size)
+	}
+	if size, _ := server.GetDBSize(1); size != 1 {
+		t.Errorf("expected 1 key in db 1, actual %d", size)
+	}
+
+	c.SelectDB(1)
+	result = server.Exec(c, utils.ToCmdLine("move", "a", "2"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 1 {
+		t.Errorf("move failed: %s", result.ToBytes())
+	}
+	result = server.Exec(c, utils.ToCmdLine("move", "a", "2"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 0 {
+		t.Errorf("move missing key should return 0: %s", result.ToBytes())
+	}
+	c.SelectDB(2)
+	result = server.Exec(c, utils.ToCmdLine("dbsize"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 1 {
+		t.Errorf("dbsize failed: %s", result.ToBytes())
+	}
+
+	var aofLines []CmdLine
+	server.mustSelectDB(2).addAof = func(line CmdLine) {
+		aofLines = append(aofLines, line)
+	}
+	server.Exec(c, utils.ToCmdLine("flushall"))
+	if size, _ := server.GetDBSize(2); size != 0 {
+		t.Errorf("expected empty db after flushall, actual %d keys", size)
+	}
+	if len(aofLines) != 1 || string(aofLines[0][0]) != "flushall" {
+		t.Errorf("flushall should be written to aof, actual %d lines", len(aofLines))
+	}
+}
+
+func TestSwapDBBlockingAndAof(t *testing.T) {
+	server := NewStandaloneServer()
+	server.mustSelectDB(1).Exec(conn.NewFakeConn(), utils.ToCmdLine("rpush", "l", "a"))
+	var mu sync.Mutex
+	var aofLines []CmdLine
+	for i := 0; i < 2; i++ {
+		server.mustSelectDB(i).addAof = func(line CmdLine) {
+			mu.Lock()
+			aofLines = append(aofLines, line)
+			mu.Unlock()
+		}
+	}
+
+	// 阻塞在DB 0的客户端在交换后应当拿到原来DB 1中的数据
+	blocked := conn.NewFakeConn()
+	ch := make(chan redis.Reply, 1)
+	go func() {
+		ch <- server.Exec(blocked, utils.ToCmdLine("blpop", "l", "0"))
+	}()
+	for server.mustSelectDB(0).blocking.count.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c := conn.NewFakeConn()
+	result := server.Exec(c, utils.ToCmdLine("swapdb", "0", "1"))
+	if !protocol.IsOKReply(result) {
+		t.Errorf("swapdb failed: %s", result.ToBytes())
+	}
+	assertReply(t, <-ch, "*2\r\n$1\r\nl\r\n$1\r\na\r\n")
+	if len(aofLines) != 2 || string(aofLines[0][0]) != "swapdb" {
+		t.Errorf("swapdb should be written to aof before lpop, actual %d lines", len(aofLines))
+	}
+
+	server.mustSelectDB(1).PutEntity("k", &database.DataEntity{Data: []byte("v")})
+	c.SelectDB(1)
+	assertReply(t, server.Exec(c, utils.ToCmdLine("copy", "k", "k", "db", "0")), ":1\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("move", "k", "0")), ":0\r\n")
+}
@@ -0,0 +1,382 @@
+package database
+
+import (
+	"goredis/config"
+	"goredis/datastruct/set"
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/lib/utils"
+	"goredis/redis/protocol"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxRandMemberCount SRANDMEMBER的count为负数时最多返回的元素数量, 避免很小的集合被要求返回数十亿个元素时分配大量内存
+const maxRandMemberCount = 1 << 20
+
+// newSet 创建空集合, 先使用整数集合编码, 需要时自动升级为哈希表编码
+func newSet(members ...string) set.Set {
+	return set.NewIntSet(config.Properties.SetMaxIntsetEntries, members...)
+}
+
+// getAsSet 返回key对应的集合, key不存在时返回nil
+func (db *DB) getAsSet(key string) (set.Set, protocol.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return nil, nil
+	}
+	s, ok := entity.Data.(set.Set)
+	if !ok {
+		return nil, &protocol.WrongTypeErrReply{}
+	}
+	return s, nil
+}
+
+// getOrInitSet 返回key对应的集合, key不存在时创建一个空集合
+func (db *DB) getOrInitSet(key string) (s set.Set, isNew bool, errReply protocol.ErrorReply) {
+	s, errReply = db.getAsSet(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	if s == nil {
+		s = newSet()
+		db.PutEntity(key, &database.DataEntity{Data: s})
+		isNew = true
+	}
+	return s, isNew, nil
+}
+
+// removeEmptySet 集合为空时删除key
+func (db *DB) removeEmptySet(key string, s set.Set) {
+	if s.Len() == 0 {
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+}
+
+// getSets 读取多个集合, 不存在的key视为空集合
+func (db *DB) getSets(keys [][]byte) ([]set.Set, protocol.ErrorReply) {
+	sets := make([]set.Set, len(keys))
+	for i, key := range keys {
+		s, errReply := db.getAsSet(string(key))
+		if errReply != nil {
+			return nil, errReply
+		}
+		if s == nil {
+			s = set.New()
+		}
+		sets[i] = s
+	}
+	return sets, nil
+}
+
+func membersReply(s set.Set) redis.Reply {
+	members := make([][]byte, 0, s.Len())
+	s.ForEach(func(member string) bool {
+		members = append(members, []byte(member))
+		return true
+	})
+	return protocol.MakeMultiBulkReply(members)
+}
+
+// execSAdd SADD key member [member ...]
+func execSAdd(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	s, _, errReply := db.getOrInitSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	added := 0
+	for _, member := range args[1:] {
+		added += s.Add(string(member))
+	}
+	if added > 0 {
+		db.notify(notifySet, "sadd", key)
+	}
+	return protocol.MakeIntReply(int64(added))
+}
+
+// execSRem SREM key member [member ...]
+func execSRem(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	s, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeIntReply(0)
+	}
+	removed := 0
+	for _, member := range args[1:] {
+		removed += s.Remove(string(member))
+	}
+	if removed > 0 {
+		db.notify(notifySet, "srem", key)
+	}
+	db.removeEmptySet(key, s)
+	return protocol.MakeIntReply(int64(removed))
+}
+
+// execSIsMember SISMEMBER key member
+func execSIsMember(db *DB, args [][]byte) redis.Reply {
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s != nil && s.Has(string(args[1])) {
+		return protocol.MakeIntReply(1)
+	}
+	return protocol.MakeIntReply(0)
+}
+
+// execSMIsMember SMISMEMBER key member [member ...]
+func execSMIsMember(db *DB, args [][]byte) redis.Reply {
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	codes := make([]int64, len(args)-1)
+	for i, member := range args[1:] {
+		if s != nil && s.Has(string(member)) {
+			codes[i] = 1
+		}
+	}
+	return makeIntArrayReply(codes)
+}
+
+// execSCard SCARD key
+func execSCard(db *DB, args [][]byte) redis.Reply {
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(int64(s.Len()))
+}
+
+// execSMembers SMEMBERS key
+func execSMembers(db *DB, args [][]byte) redis.Reply {
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	return membersReply(s)
+}
+
+// execSPop SPOP key [count], 弹出的元素是随机的, 所以aof中记录为SREM
+func execSPop(db *DB, args [][]byte) redis.Reply {
+	if len(args) > 2 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	key := string(args[0])
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil || n < 0 || n > math.MaxInt32 {
+			return protocol.MakeErrReply("ERR value is out of range, must be positive")
+		}
+		count = int(n)
+	}
+	s, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		if len(args) == 2 {
+			return protocol.MakeEmptyMultiBulkReply()
+		}
+		return protocol.MakeNullBulkReply()
+	}
+	members := s.RandomDistinctMembers(count)
+	for _, member := range members {
+		s.Remove(member)
+	}
+	if len(members) > 0 {
+		db.notify(notifySet, "spop", key)
+	}
+	db.removeEmptySet(key, s)
+	if len(members) > 0 {
+		db.addAof(utils.ToCmdLine2("SREM", append([]string{key}, members...)...))
+	}
+	if len(args) == 1 {
+		return protocol.MakeBulkReply([]byte(members[0]))
+	}
+	return protocol.MakeMultiBulkReply(utils.ToCmdLine(members...))
+}
+
+// execSRandMember SRANDMEMBER key [count], count为负数时允许重复
+func execSRandMember(db *DB, args [][]byte) redis.Reply {
+	if len(args) > 2 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if len(args) == 1 {
+		if s == nil {
+			return protocol.MakeNullBulkReply()
+		}
+		return protocol.MakeBulkReply([]byte(s.RandomMembers(1)[0]))
+	}
+	count, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if count < -math.MaxInt32 || count > math.MaxInt32 {
+		return protocol.MakeErrReply("ERR value is out of range")
+	}
+	// 不重复时回复不会超过集合的大小, 允许重复时需要限制回复的大小
+	if count < -maxRandMemberCount {
+		return protocol.MakeErrReply("ERR count is too large")
+	}
+	if s == nil || count == 0 {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	var members []string
+	if count > 0 {
+		members = s.RandomDistinctMembers(int(count))
+	} else {
+		members = s.RandomMembers(int(-count))
+	}
+	return protocol.MakeMultiBulkReply(utils.ToCmdLine(members...))
+}
+
+// execSMove SMOVE source destination member
+func execSMove(db *DB, args [][]byte) redis.Reply {
+	src, dst := string(args[0]), string(args[1])
+	member := string(args[2])
+	srcSet, errReply := db.getAsSet(src)
+	if errReply != nil {
+		return errReply
+	}
+	dstSet, errReply := db.getAsSet(dst)
+	if errReply != nil {
+		return errReply
+	}
+	if srcSet == nil || !srcSet.Has(member) {
+		return protocol.MakeIntReply(0)
+	}
+	if src == dst {
+		// 源和目标相同时不做修改, 否则删除空集合后成员会加入不在DB中的集合
+		return protocol.MakeIntReply(1)
+	}
+	srcSet.Remove(member)
+	db.notify(notifySet, "srem", src)
+	db.removeEmptySet(src, srcSet)
+	if dstSet == nil {
+		dstSet, _, _ = db.getOrInitSet(dst)
+	}
+	if dstSet.Add(member) > 0 {
+		db.notify(notifySet, "sadd", dst)
+	}
+	return protocol.MakeIntReply(1)
+}
+
+// setAlgebra 集合运算
+type setAlgebra func(sets ...set.Set) set.Set
+
+// execSetAlgebra 实现SINTER/SUNION/SDIFF
+func execSetAlgebra(algebra setAlgebra) ExecFunc {
+	return func(db *DB, args [][]byte) redis.Reply {
+		sets, errReply := db.getSets(args)
+		if errReply != nil {
+			return errReply
+		}
+		return membersReply(algebra(sets...))
+	}
+}
+
+// execSetAlgebraStore 实现SINTERSTORE/SUNIONSTORE/SDIFFSTORE, 结果为空时删除目标key
+func execSetAlgebraStore(algebra setAlgebra, event string) ExecFunc {
+	return func(db *DB, args [][]byte) redis.Reply {
+		dst := string(args[0])
+		sets, errReply := db.getSets(args[1:])
+		if errReply != nil {
+			return errReply
+		}
+		result := algebra(sets...)
+		if result.Len() == 0 {
+			if db.Removes(dst) > 0 {
+				db.notify(notifyGeneric, "del", dst)
+			}
+			return protocol.MakeIntReply(0)
+		}
+		// 重新选择编码, 全是整数的结果使用整数集合保存
+		db.PutEntity(dst, &database.DataEntity{Data: newSet(result.ToSlice()...)})
+		db.Persist(dst)
+		db.notify(notifySet, event, dst)
+		return protocol.MakeIntReply(int64(result.Len()))
+	}
+}
+
+func prepareSetStore(args [][]byte) ([]string, []string) {
+	dst := string(args[0])
+	keys := make([]string, len(args)-1)
+	for i, arg := range args[1:] {
+		keys[i] = string(arg)
+	}
+	return []string{dst}, keys
+}
+
+// execSInterCard SINTERCARD numkeys key [key ...] [LIMIT limit]
+func execSInterCard(db *DB, args [][]byte) redis.Reply {
+	keys, limit, errReply := parseInterCard(args)
+	if errReply != nil {
+		return errReply
+	}
+	sets, errReply := db.getSets(keys)
+	if errReply != nil {
+		return errReply
+	}
+	return protocol.MakeIntReply(int64(set.IntersectCard(limit, sets...)))
+}
+
+// parseInterCard 解析numkeys key [key ...] [LIMIT limit], SINTERCARD和ZINTERCARD共用
+func parseInterCard(args [][]byte) ([][]byte, int, redis.Reply) {
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil || numKeys <= 0 {
+		return nil, 0, protocol.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys > len(args)-1 {
+		return nil, 0, protocol.MakeErrReply("ERR Number of keys can't be greater than number of args")
+	}
+	keys := args[1 : 1+numKeys]
+	rest := args[1+numKeys:]
+	limit := 0
+	if len(rest) > 0 {
+		if len(rest) != 2 || strings.ToUpper(string(rest[0])) != "LIMIT" {
+			return nil, 0, protocol.MakeSyntaxErrReply()
+		}
+		limit, err = strconv.Atoi(string(rest[1]))
+		if err != nil || limit < 0 {
+			return nil, 0, protocol.MakeErrReply("ERR LIMIT can't be negative")
+		}
+	}
+	return keys, limit, nil
+}
+
+func init() {
+	registerCommand("SAdd", execSAdd, writeFirstKey, rollbackFirstKey, -3, flagWrite)
+	registerCommand("SRem", execSRem, writeFirstKey, rollbackFirstKey, -3, flagWrite)
+	registerCommand("SIsMember", execSIsMember, readFirstKey, nil, 3, flagReadOnly)
+	registerCommand("SMIsMember", execSMIsMember, readFirstKey, nil, -3, flagReadOnly)
+	registerCommand("SCard", execSCard, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("SMembers", execSMembers, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("SPop", execSPop, writeFirstKey, rollbackFirstKey, -2, flagWrite|flagCustomAof)
+	registerCommand("SRandMember", execSRandMember, readFirstKey, nil, -2, flagReadOnly)
+	registerCommand("SMove", execSMove, prepareMove, undoMove, 4, flagWrite)
+	registerCommand("SInter", execSetAlgebra(set.Intersect), readAllKeys, nil, -2, flagReadOnly)
+	registerCommand("SUnion", execSetAlgebra(set.Union), readAllKeys, nil, -2, flagReadOnly)
+	registerCommand("SDiff", execSetAlgebra(set.Diff), readAllKeys, nil, -2, flagReadOnly)
+	registerCommand("SInterStore", execSetAlgebraStore(set.Intersect, "sinterstore"), prepareSetStore, rollbackFirstKey, -3, flagWrite)
+	registerCommand("SUnionStore", execSetAlgebraStore(set.Union, "sunionstore"), prepareSetStore, rollbackFirstKey, -3, flagWrite)
+	registerCommand("SDiffStore", execSetAlgebraStore(set.Diff, "sdiffstore"), prepareSetStore, rollbackFirstKey, -3, flagWrite)
+	registerCommand("SInterCard", execSInterCard, nil, nil, -3, flagReadOnly).attachKeys(1, lastKeyByNumKeys, 1)
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"goredis/redis/protocol"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	assertReply(t, db.Exec(c, utils.ToCmdLine("sadd", "s", "a", "b", "a")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("sismember", "s", "a")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("sismember", "none", "a")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("smismember", "s", "a", "c")), "*2\r\n:1\r\n:0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("smove", "s", "t", "b")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("scard", "t")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("smove", "t", "t", "b")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("smembers", "t")), "*1\r\n$1\r\nb\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("srem", "s", "a")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("exists", "s")), ":0\r\n")
+
+	db.Exec(c, utils.ToCmdLine("sadd", "r", "a", "b", "c"))
+	if reply, ok := db.Exec(c, utils.ToCmdLine("srandmember", "r", "-5")).(*protocol.MultiBulkReply); !ok || len(reply.Args) != 5 {
+		t.Error("srandmember with negative count should return repeated members")
+	}
+	assertReply(t, db.Exec(c, utils.ToCmdLine("srandmember", "r", "-2147483647")), "-ERR count is too large\r\n")
+	if reply, ok := db.Exec(c, utils.ToCmdLine("spop", "r", "2")).(*protocol.MultiBulkReply); !ok || len(reply.Args) != 2 {
+		t.Error("spop should pop 2 members")
+	}
+	assertReply(t, db.Exec(c, utils.ToCmdLine("scard", "r")), ":1\r\n")
+}
+
+func TestSetAlgebra(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("sadd", "a", "1", "2", "3"))
+	db.Exec(c, utils.ToCmdLine("sadd", "b", "2", "3", "4"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("sintercard", "2", "a", "b")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("sintercard", "2", "a", "b", "LIMIT", "1")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("sinter", "a", "none")), "*0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("sdiff", "a", "b")), "*1\r\n$1\r\n1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("sunionstore", "u", "a", "b")), ":4\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("sinterstore", "a", "a", "b")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("scard", "a")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("sdiffstore", "u", "a", "b")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("exists", "u")), ":0\r\n")
+
+	db.Exec(c, utils.ToCmdLine("set", "str", "v"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("sunion", "a", "str")),
+		"-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+}
+
+func TestSetEncoding(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("sadd", "s", "1", "2", "3"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("object", "encoding", "s")), "$6\r\nintset\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("sismember", "s", "2")), ":1\r\n")
+	db.Exec(c, utils.ToCmdLine("sadd", "s", "a"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("object", "encoding", "s")), "$9\r\nhashtable\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("scard", "s")), ":4\r\n")
+
+	db.Exec(c, utils.ToCmdLine("set", "str", "123"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("object", "encoding", "str")), "$3\r\nint\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("object", "encoding", "none")), "$-1\r\n")
+}
@@ -0,0 +1,1025 @@
+package database
+
+import (
+	"goredis/datastruct/set"
+	"goredis/datastruct/sortedset"
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/lib/utils"
+	"goredis/redis/protocol"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getAsSortedSet 返回key对应的有序集合, key不存在时返回nil
+func (db *DB) getAsSortedSet(key string) (*sortedset.SortedSet, protocol.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return nil, nil
+	}
+	zset, ok := entity.Data.(*sortedset.SortedSet)
+	if !ok {
+		return nil, &protocol.WrongTypeErrReply{}
+	}
+	return zset, nil
+}
+
+// getOrInitSortedSet 返回key对应的有序集合, key不存在时创建一个空的有序集合
+func (db *DB) getOrInitSortedSet(key string) (zset *sortedset.SortedSet, isNew bool, errReply protocol.ErrorReply) {
+	zset, errReply = db.getAsSortedSet(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	if zset == nil {
+		zset = sortedset.New()
+		db.PutEntity(key, &database.DataEntity{Data: zset})
+		isNew = true
+	}
+	return zset, isNew, nil
+}
+
+// removeEmptySortedSet 有序集合为空时删除key
+func (db *DB) removeEmptySortedSet(key string, zset *sortedset.SortedSet) {
+	if zset.Len() == 0 {
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+}
+
+func parseScore(raw []byte) (float64, redis.Reply) {
+	score, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, protocol.MakeErrReply("ERR value is not a valid float")
+	}
+	return score, nil
+}
+
+func elementsReply(elements []*sortedset.Element, withScores bool) redis.Reply {
+	size := len(elements)
+	if withScores {
+		size *= 2
+	}
+	result := make([][]byte, 0, size)
+	for _, elem := range elements {
+		result = append(result, []byte(elem.Member))
+		if withScores {
+			result = append(result, []byte(protocol.FormatFloat(elem.Score)))
+		}
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// zaddOption ZADD的选项
+type zaddOption struct {
+	nx, xx, gt, lt, ch, incr bool
+}
+
+// execZAdd ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]
+func execZAdd(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	option := &zaddOption{}
+	i := 1
+loop:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX":
+			option.nx = true
+		case "XX":
+			option.xx = true
+		case "GT":
+			option.gt = true
+		case "LT":
+			option.lt = true
+		case "CH":
+			option.ch = true
+		case "INCR":
+			option.incr = true
+		default:
+			break loop
+		}
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	if option.nx && option.xx {
+		return protocol.MakeErrReply("ERR XX and NX options at the same time are not compatible")
+	}
+	if (option.gt && option.lt) || (option.nx && (option.gt || option.lt)) {
+		return protocol.MakeErrReply("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if option.incr && len(pairs) > 2 {
+		return protocol.MakeErrReply("ERR INCR option supports a single increment-element pair")
+	}
+	scores := make([]float64, len(pairs)/2)
+	for j := range scores {
+		score, errReply := parseScore(pairs[2*j])
+		if errReply != nil {
+			return errReply
+		}
+		scores[j] = score
+	}
+
+	zset, _, errReply := db.getOrInitSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	defer db.removeEmptySortedSet(key, zset)
+	added, changed := 0, 0
+	var result redis.Reply = protocol.MakeNullBulkReply()
+	for j, score := range scores {
+		member := string(pairs[2*j+1])
+		elem, exists := zset.Get(member)
+		if (option.nx && exists) || (option.xx && !exists) {
+			continue
+		}
+		if option.incr && exists {
+			score += elem.Score
+			if math.IsNaN(score) {
+				return protocol.MakeErrReply("ERR resulting score is not a number (NaN)")
+			}
+		}
+		if exists {
+			if (option.gt && score <= elem.Score) || (option.lt && score >= elem.Score) {
+				continue
+			}
+			if score != elem.Score {
+				zset.Add(member, score)
+				changed++
+			}
+		} else {
+			zset.Add(member, score)
+			added++
+		}
+		result = protocol.MakeDoubleReply(score)
+	}
+	if added+changed > 0 {
+		if option.incr {
+			db.notify(notifyZSet, "zincr", key)
+		} else {
+			db.notify(notifyZSet, "zadd", key)
+		}
+	}
+	if option.incr {
+		return result
+	}
+	if option.ch {
+		return protocol.MakeIntReply(int64(added + changed))
+	}
+	return protocol.MakeIntReply(int64(added))
+}
+
+// execZIncrBy ZINCRBY key increment member
+func execZIncrBy(db *DB, args [][]byte) redis.Reply {
+	return execZAdd(db, [][]byte{args[0], []byte("INCR"), args[1], args[2]})
+}
+
+// execZRem ZREM key member [member ...]
+func execZRem(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	zset, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return protocol.MakeIntReply(0)
+	}
+	removed := 0
+	for _, member := range args[1:] {
+		if zset.Remove(string(member)) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		db.notify(notifyZSet, "zrem", key)
+	}
+	db.removeEmptySortedSet(key, zset)
+	return protocol.MakeIntReply(int64(removed))
+}
+
+// execZCard ZCARD key
+func execZCard(db *DB, args [][]byte) redis.Reply {
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(zset.Len())
+}
+
+// execZScore ZSCORE key member
+func execZScore(db *DB, args [][]byte) redis.Reply {
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	elem, exists := zset.Get(string(args[1]))
+	if !exists {
+		return protocol.MakeNullBulkReply()
+	}
+	return protocol.MakeDoubleReply(elem.Score)
+}
+
+// execZMScore ZMSCORE key member [member ...]
+func execZMScore(db *DB, args [][]byte) redis.Reply {
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]redis.Reply, len(args)-1)
+	for i, member := range args[1:] {
+		result[i] = protocol.MakeNullBulkReply()
+		if zset == nil {
+			continue
+		}
+		if elem, exists := zset.Get(string(member)); exists {
+			result[i] = protocol.MakeDoubleReply(elem.Score)
+		}
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+func rankGeneric(db *DB, args [][]byte, desc bool) redis.Reply {
+	withScore := false
+	if len(args) == 3 {
+		if strings.ToUpper(string(args[2])) != "WITHSCORE" {
+			return protocol.MakeSyntaxErrReply()
+		}
+		withScore = true
+	} else if len(args) > 3 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	var rank int64 = -1
+	if zset != nil {
+		rank = zset.GetRank(string(args[1]), desc)
+	}
+	if rank < 0 {
+		if withScore {
+			return protocol.MakeNullMultiBulkReply()
+		}
+		return protocol.MakeNullBulkReply()
+	}
+	if !withScore {
+		return protocol.MakeIntReply(rank)
+	}
+	elem, _ := zset.Get(string(args[1]))
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeIntReply(rank),
+		protocol.MakeDoubleReply(elem.Score),
+	})
+}
+
+// execZRank ZRANK key member [WITHSCORE]
+func execZRank(db *DB, args [][]byte) redis.Reply {
+	return rankGeneric(db, args, false)
+}
+
+// execZRevRank ZREVRANK key member [WITHSCORE]
+func execZRevRank(db *DB, args [][]byte) redis.Reply {
+	return rankGeneric(db, args, true)
+}
+
+// rangeSpec ZRANGE族命令的查询条件
+type rangeSpec struct {
+	byScore, byLex, rev bool
+	withScores          bool
+	// start stop 按排名查询时的范围
+	start, stop int64
+	// min max 按分数或者字典序查询时的范围
+	min, max sortedset.Border
+	// offset limit LIMIT选项, limit小于0表示不限制数量
+	offset, limit int64
+}
+
+// parseRangeSpec 解析 min max [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+func parseRangeSpec(args [][]byte, allowWithScores bool) (*rangeSpec, redis.Reply) {
+	spec := &rangeSpec{limit: -1}
+	hasLimit := false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "BYSCORE":
+			spec.byScore = true
+		case "BYLEX":
+			spec.byLex = true
+		case "REV":
+			spec.rev = true
+		case "WITHSCORES":
+			if !allowWithScores {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			spec.withScores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			offset, err1 := strconv.ParseInt(string(args[i+1]), 10, 64)
+			limit, err2 := strconv.ParseInt(string(args[i+2]), 10, 64)
+			if err1 != nil || err2 != nil {
+				return nil, protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			spec.offset, spec.limit = offset, limit
+			hasLimit = true
+			i += 2
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	if spec.byScore && spec.byLex {
+		return nil, protocol.MakeSyntaxErrReply()
+	}
+	if hasLimit && !spec.byScore && !spec.byLex {
+		return nil, protocol.MakeErrReply("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if spec.withScores && spec.byLex {
+		return nil, protocol.MakeErrReply("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+
+	// REV时先给出的是上边界
+	rawMin, rawMax := string(args[0]), string(args[1])
+	if spec.rev && (spec.byScore || spec.byLex) {
+		rawMin, rawMax = rawMax, rawMin
+	}
+	var err error
+	switch {
+	case spec.byScore:
+		if spec.min, err = sortedset.ParseScoreBorder(rawMin); err == nil {
+			spec.max, err = sortedset.ParseScoreBorder(rawMax)
+		}
+	case spec.byLex:
+		if spec.min, err = sortedset.ParseLexBorder(rawMin); err == nil {
+			spec.max, err = sortedset.ParseLexBorder(rawMax)
+		}
+	default:
+		var err1, err2 error
+		spec.start, err1 = strconv.ParseInt(rawMin, 10, 64)
+		spec.stop, err2 = strconv.ParseInt(rawMax, 10, 64)
+		if err1 != nil || err2 != nil {
+			return nil, protocol.MakeErrReply("ERR value is not an integer or out of range")
+		}
+	}
+	if err != nil {
+		return nil, protocol.MakeErrReply(err.Error())
+	}
+	return spec, nil
+}
+
+// rangeElements 按照查询条件返回元素
+func rangeElements(zset *sortedset.SortedSet, spec *rangeSpec) []*sortedset.Element {
+	if spec.byScore || spec.byLex {
+		if spec.offset < 0 {
+			return nil
+		}
+		return zset.RangeByBorder(spec.min, spec.max, spec.offset, spec.limit, spec.rev)
+	}
+	size := int(zset.Len())
+	start, end := normalizeRange(int(max(min(spec.start, math.MaxInt32), math.MinInt32)),
+		int(max(min(spec.stop, math.MaxInt32), math.MinInt32)), size)
+	if start >= end {
+		return nil
+	}
+	return zset.Range(int64(start), int64(end), spec.rev)
+}
+
+// execZRange ZRANGE key start stop [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+func execZRange(db *DB, args [][]byte) redis.Reply {
+	spec, errReply := parseRangeSpec(args[1:], true)
+	if errReply != nil {
+		return errReply
+	}
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	return elementsReply(rangeElements(zset, spec), spec.withScores)
+}
+
+// withFlags 把旧的ZRANGE族命令转换为ZRANGE的参数
+func withFlags(args [][]byte, flags ...string) [][]byte {
+	result := make([][]byte, 0, len(args)+len(flags))
+	result = append(result, args...)
+	for _, flag := range flags {
+		result = append(result, []byte(flag))
+	}
+	return result
+}
+
+// execZRevRange ZREVRANGE key start stop [WITHSCORES]
+func execZRevRange(db *DB, args [][]byte) redis.Reply {
+	return execZRange(db, withFlags(args, "REV"))
+}
+
+// execZRangeByScore ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+func execZRangeByScore(db *DB, args [][]byte) redis.Reply {
+	return execZRange(db, withFlags(args, "BYSCORE"))
+}
+
+// execZRevRangeByScore ZREVRANGEBYSCORE key max min [WITHSCORES] [LIMIT offset count]
+func execZRevRangeByScore(db *DB, args [][]byte) redis.Reply {
+	return execZRange(db, withFlags(args, "BYSCORE", "REV"))
+}
+
+// execZRangeByLex ZRANGEBYLEX key min max [LIMIT offset count]
+func execZRangeByLex(db *DB, args [][]byte) redis.Reply {
+	return execZRange(db, withFlags(args, "BYLEX"))
+}
+
+// execZRevRangeByLex ZREVRANGEBYLEX key max min [LIMIT offset count]
+func execZRevRangeByLex(db *DB, args [][]byte) redis.Reply {
+	return execZRange(db, withFlags(args, "BYLEX", "REV"))
+}
+
+// execZRangeStore ZRANGESTORE dst src min max [BYSCORE|BYLEX] [REV] [LIMIT offset count]
+func execZRangeStore(db *DB, args [][]byte) redis.Reply {
+	dst := string(args[0])
+	spec, errReply := parseRangeSpec(args[2:], false)
+	if errReply != nil {
+		return errReply
+	}
+	src, errReply := db.getAsSortedSet(string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+	var elements []*sortedset.Element
+	if src != nil {
+		elements = rangeElements(src, spec)
+	}
+	if len(elements) == 0 {
+		if db.Removes(dst) > 0 {
+			db.notify(notifyGeneric, "del", dst)
+		}
+		return protocol.MakeIntReply(0)
+	}
+	zset := sortedset.New()
+	for _, elem := range elements {
+		zset.Add(elem.Member, elem.Score)
+	}
+	db.PutEntity(dst, &database.DataEntity{Data: zset})
+	db.Persist(dst)
+	db.notify(notifyZSet, "zrangestore", dst)
+	return protocol.MakeIntReply(zset.Len())
+}
+
+func prepareZRangeStore(args [][]byte) ([]string, []string) {
+	return []string{string(args[0])}, []string{string(args[1])}
+}
+
+// countGeneric 实现ZCOUNT和ZLEXCOUNT
+func countGeneric(db *DB, args [][]byte, parse func(string) (sortedset.Border, error)) redis.Reply {
+	min, err := parse(string(args[1]))
+	if err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	max, err := parse(string(args[2]))
+	if err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(zset.Count(min, max))
+}
+
+func parseScoreBorder(s string) (sortedset.Border, error) {
+	return sortedset.ParseScoreBorder(s)
+}
+
+func parseLexBorder(s string) (sortedset.Border, error) {
+	return sortedset.ParseLexBorder(s)
+}
+
+// execZCount ZCOUNT key min max
+func execZCount(db *DB, args [][]byte) redis.Reply {
+	return countGeneric(db, args, parseScoreBorder)
+}
+
+// execZLexCount ZLEXCOUNT key min max
+func execZLexCount(db *DB, args [][]byte) redis.Reply {
+	return countGeneric(db, args, parseLexBorder)
+}
+
+// removeRangeGeneric 实现ZREMRANGEBYSCORE和ZREMRANGEBYLEX
+func removeRangeGeneric(db *DB, args [][]byte, parse func(string) (sortedset.Border, error), event string) redis.Reply {
+	key := string(args[0])
+	min, err := parse(string(args[1]))
+	if err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	max, err := parse(string(args[2]))
+	if err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	zset, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return protocol.MakeIntReply(0)
+	}
+	removed := zset.RemoveByBorder(min, max)
+	if removed > 0 {
+		db.notify(notifyZSet, event, key)
+	}
+	db.removeEmptySortedSet(key, zset)
+	return protocol.MakeIntReply(removed)
+}
+
+// execZRemRangeByScore ZREMRANGEBYSCORE key min max
+func execZRemRangeByScore(db *DB, args [][]byte) redis.Reply {
+	return removeRangeGeneric(db, args, parseScoreBorder, "zremrangebyscore")
+}
+
+// execZRemRangeByLex ZREMRANGEBYLEX key min max
+func execZRemRangeByLex(db *DB, args [][]byte) redis.Reply {
+	return removeRangeGeneric(db, args, parseLexBorder, "zremrangebylex")
+}
+
+// execZRemRangeByRank ZREMRANGEBYRANK key start stop
+func execZRemRangeByRank(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	start, errReply := parseIndex(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	stop, errReply := parseIndex(args[2])
+	if errReply != nil {
+		return errReply
+	}
+	zset, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return protocol.MakeIntReply(0)
+	}
+	begin, end := normalizeRange(start, stop, int(zset.Len()))
+	if begin >= end {
+		return protocol.MakeIntReply(0)
+	}
+	removed := zset.RemoveByRank(int64(begin), int64(end))
+	db.notify(notifyZSet, "zremrangebyrank", key)
+	db.removeEmptySortedSet(key, zset)
+	return protocol.MakeIntReply(removed)
+}
+
+// popGenericZSet 实现ZPOPMIN和ZPOPMAX
+func popGenericZSet(db *DB, args [][]byte, desc bool) redis.Reply {
+	if len(args) > 2 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	key := string(args[0])
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil || n < 0 {
+			return protocol.MakeErrReply("ERR value is out of range, must be positive")
+		}
+		count = int(min(n, math.MaxInt32))
+	}
+	zset, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	popped := popElements(zset, count, desc)
+	if len(popped) > 0 {
+		db.notify(notifyZSet, popZSetEvent(desc), key)
+	}
+	db.removeEmptySortedSet(key, zset)
+	return elementsReply(popped, true)
+}
+
+func popZSetEvent(desc bool) string {
+	if desc {
+		return "zpopmax"
+	}
+	return "zpopmin"
+}
+
+// popElements 弹出元素前把count限制在集合大小以内, 避免计算排名时溢出
+func popElements(zset *sortedset.SortedSet, count int, desc bool) []*sortedset.Element {
+	count = int(min(int64(count), zset.Len()))
+	if desc {
+		return zset.PopMax(count)
+	}
+	return zset.PopMin(count)
+}
+
+// execZPopMin ZPOPMIN key [count]
+func execZPopMin(db *DB, args [][]byte) redis.Reply {
+	return popGenericZSet(db, args, false)
+}
+
+// execZPopMax ZPOPMAX key [count]
+func execZPopMax(db *DB, args [][]byte) redis.Reply {
+	return popGenericZSet(db, args, true)
+}
+
+// popFirstNonEmptyZSet 从第一个非空的有序集合中弹出元素, 并把实际执行的弹出命令写入aof
+func popFirstNonEmptyZSet(db *DB, keys []string, desc bool, count int) (string, []*sortedset.Element, protocol.ErrorReply) {
+	for _, key := range keys {
+		zset, errReply := db.getAsSortedSet(key)
+		if errReply != nil {
+			return "", nil, errReply
+		}
+		if zset == nil {
+			continue
+		}
+		popped := popElements(zset, count, desc)
+		db.notify(notifyZSet, popZSetEvent(desc), key)
+		db.removeEmptySortedSet(key, zset)
+		cmdName := "ZPOPMIN"
+		if desc {
+			cmdName = "ZPOPMAX"
+		}
+		db.addAof(utils.ToCmdLine(cmdName, key, strconv.Itoa(len(popped))))
+		return key, popped, nil
+	}
+	return "", nil, nil
+}
+
+func blockingPopZSet(db *DB, args [][]byte, desc bool) redis.Reply {
+	keys := make([]string, len(args)-1)
+	for i := range keys {
+		keys[i] = string(args[i])
+	}
+	key, popped, errReply := popFirstNonEmptyZSet(db, keys, desc, 1)
+	if errReply != nil {
+		return errReply
+	}
+	if popped == nil {
+		return protocol.MakeNullMultiBulkReply()
+	}
+	return protocol.MakeMultiBulkReply([][]byte{[]byte(key), []byte(popped[0].Member), []byte(protocol.FormatFloat(popped[0].Score))})
+}
+
+// execBZPopMin BZPOPMIN key [key...] timeout
+func execBZPopMin(db *DB, args [][]byte) redis.Reply {
+	return blockingPopZSet(db, args, false)
+}
+
+// execBZPopMax BZPOPMAX key [key...] timeout
+func execBZPopMax(db *DB, args [][]byte) redis.Reply {
+	return blockingPopZSet(db, args, true)
+}
+
+// parseZMPop 解析 numkeys key [key...] MIN|MAX [COUNT count]
+func parseZMPop(args [][]byte) (keys []string, desc bool, count int, errReply redis.Reply) {
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil || numKeys <= 0 {
+		return nil, false, 0, protocol.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if len(args) < numKeys+2 {
+		return nil, false, 0, protocol.MakeSyntaxErrReply()
+	}
+	keys = make([]string, numKeys)
+	for i := range keys {
+		keys[i] = string(args[1+i])
+	}
+	switch strings.ToUpper(string(args[numKeys+1])) {
+	case "MIN":
+		desc = false
+	case "MAX":
+		desc = true
+	default:
+		return nil, false, 0, protocol.MakeSyntaxErrReply()
+	}
+	count = 1
+	rest := args[numKeys+2:]
+	if len(rest) > 0 {
+		if len(rest) != 2 || strings.ToUpper(string(rest[0])) != "COUNT" {
+			return nil, false, 0, protocol.MakeSyntaxErrReply()
+		}
+		count, err = strconv.Atoi(string(rest[1]))
+		if err != nil || count <= 0 {
+			return nil, false, 0, protocol.MakeErrReply("ERR count should be greater than 0")
+		}
+	}
+	return keys, desc, count, nil
+}
+
+func prepareZMPop(args [][]byte) ([]string, []string) {
+	keys, _, _, errReply := parseZMPop(args)
+	if errReply != nil {
+		return nil, nil
+	}
+	return keys, nil
+}
+
+func undoZMPop(db *DB, args [][]byte) []CmdLine {
+	keys, _ := prepareZMPop(args)
+	return rollbackGivenKeys(db, keys...)
+}
+
+// execZMPop ZMPOP numkeys key [key...] MIN|MAX [COUNT count]
+func execZMPop(db *DB, args [][]byte) redis.Reply {
+	keys, desc, count, errReply := parseZMPop(args)
+	if errReply != nil {
+		return errReply
+	}
+	key, popped, err := popFirstNonEmptyZSet(db, keys, desc, count)
+	if err != nil {
+		return err
+	}
+	if popped == nil {
+		return protocol.MakeNullMultiBulkReply()
+	}
+	elements := make([]redis.Reply, len(popped))
+	for i, elem := range popped {
+		elements[i] = protocol.MakeMultiBulkReply([][]byte{[]byte(elem.Member), []byte(protocol.FormatFloat(elem.Score))})
+	}
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte(key)),
+		protocol.MakeMultiRawReply(elements),
+	})
+}
+
+// execBZMPop BZMPOP timeout numkeys key [key...] MIN|MAX [COUNT count]
+func execBZMPop(db *DB, args [][]byte) redis.Reply {
+	return execZMPop(db, args[1:])
+}
+
+func prepareBZMPop(args [][]byte) ([]string, []string) {
+	return prepareZMPop(args[1:])
+}
+
+func undoBZMPop(db *DB, args [][]byte) []CmdLine {
+	return undoZMPop(db, args[1:])
+}
+
+func blockingBZMPop(args [][]byte) ([]string, time.Duration, redis.Reply) {
+	timeout, errReply := parseTimeout(args[0])
+	if errReply != nil {
+		return nil, 0, errReply
+	}
+	keys, _, _, errReply := parseZMPop(args[1:])
+	if errReply != nil {
+		return nil, 0, errReply
+	}
+	return keys, timeout, nil
+}
+
+// execZRandMember ZRANDMEMBER key [count [WITHSCORES]], count为负数时允许重复
+func execZRandMember(db *DB, args [][]byte) redis.Reply {
+	if len(args) > 3 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if len(args) == 1 {
+		if zset == nil {
+			return protocol.MakeNullBulkReply()
+		}
+		return protocol.MakeBulkReply([]byte(randomElements(zset, 1, true)[0].Member))
+	}
+	count, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if count < -math.MaxInt32 || count > math.MaxInt32 {
+		return protocol.MakeErrReply("ERR value is out of range")
+	}
+	withScores := false
+	if len(args) == 3 {
+		if strings.ToUpper(string(args[2])) != "WITHSCORES" {
+			return protocol.MakeSyntaxErrReply()
+		}
+		withScores = true
+	}
+	if zset == nil || count == 0 {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	if count > 0 {
+		return elementsReply(randomElements(zset, int(count), false), withScores)
+	}
+	return elementsReply(randomElements(zset, int(-count), true), withScores)
+}
+
+// randomElements 随机选取count个元素, repeatable为false时不重复.
+// 通过跳表按排名直接取出选中的元素, 不需要复制整个有序集合
+func randomElements(zset *sortedset.SortedSet, count int, repeatable bool) []*sortedset.Element {
+	size := zset.Len()
+	if repeatable {
+		result := make([]*sortedset.Element, count)
+		for i := range result {
+			result[i] = zset.GetByRank(rand.Int63n(size))
+		}
+		return result
+	}
+	ranks := utils.RandDistinctInts(int(size), min(count, int(size)))
+	result := make([]*sortedset.Element, len(ranks))
+	for i, rank := range ranks {
+		result[i] = zset.GetByRank(int64(rank))
+	}
+	return result
+}
+
+// getAsWeightedSets 读取参与运算的集合, 与redis一样把集合当作所有成员分数都是1的有序集合, 不存在的key为nil
+func (db *DB) getAsWeightedSets(keys [][]byte) ([]*sortedset.SortedSet, redis.Reply) {
+	sets := make([]*sortedset.SortedSet, len(keys))
+	for i, key := range keys {
+		entity, ok := db.GetEntity(string(key))
+		if !ok {
+			continue
+		}
+		switch data := entity.Data.(type) {
+		case *sortedset.SortedSet:
+			sets[i] = data
+		case set.Set:
+			zset := sortedset.New()
+			data.ForEach(func(member string) bool {
+				zset.Add(member, 1)
+				return true
+			})
+			sets[i] = zset
+		default:
+			return nil, &protocol.WrongTypeErrReply{}
+		}
+	}
+	return sets, nil
+}
+
+type zsetAlgebraOption struct {
+	keys       [][]byte
+	weights    []float64
+	aggregate  sortedset.Aggregate
+	withScores bool
+}
+
+// parseZSetAlgebra 解析numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX] [WITHSCORES],
+// ZDIFF不支持WEIGHTS和AGGREGATE, STORE命令不支持WITHSCORES
+func parseZSetAlgebra(name string, args [][]byte, allowWeights, allowWithScores bool) (*zsetAlgebraOption, redis.Reply) {
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil {
+		return nil, protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if numKeys < 1 {
+		return nil, protocol.MakeErrReply("ERR at least 1 input key is needed for '" + name + "' command")
+	}
+	if numKeys > len(args)-1 {
+		return nil, protocol.MakeSyntaxErrReply()
+	}
+	option := &zsetAlgebraOption{keys: args[1 : 1+numKeys]}
+	for i := 1 + numKeys; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch {
+		case arg == "WEIGHTS" && allowWeights && i+numKeys < len(args):
+			option.weights = make([]float64, numKeys)
+			for j := range option.weights {
+				weight, err := strconv.ParseFloat(string(args[i+1+j]), 64)
+				if err != nil || math.IsNaN(weight) {
+					return nil, protocol.MakeErrReply("ERR weight value is not a float")
+				}
+				option.weights[j] = weight
+			}
+			i += numKeys
+		case arg == "AGGREGATE" && allowWeights && i+1 < len(args):
+			switch strings.ToUpper(string(args[i+1])) {
+			case "SUM":
+				option.aggregate = sortedset.AggregateSum
+			case "MIN":
+				option.aggregate = sortedset.AggregateMin
+			case "MAX":
+				option.aggregate = sortedset.AggregateMax
+			default:
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			i++
+		case arg == "WITHSCORES" && allowWithScores:
+			option.withScores = true
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	return option, nil
+}
+
+type zsetAlgebra func(sets []*sortedset.SortedSet, weights []float64, aggregate sortedset.Aggregate) *sortedset.SortedSet
+
+// zsetDiff ZDIFF不使用权重和合并方式
+func zsetDiff(sets []*sortedset.SortedSet, _ []float64, _ sortedset.Aggregate) *sortedset.SortedSet {
+	return sortedset.Diff(sets)
+}
+
+// execZSetAlgebra 实现ZUNION, ZINTER和ZDIFF
+func execZSetAlgebra(name string, algebra zsetAlgebra, allowWeights bool) ExecFunc {
+	return func(db *DB, args [][]byte) redis.Reply {
+		option, errReply := parseZSetAlgebra(name, args, allowWeights, true)
+		if errReply != nil {
+			return errReply
+		}
+		sets, errReply := db.getAsWeightedSets(option.keys)
+		if errReply != nil {
+			return errReply
+		}
+		result := algebra(sets, option.weights, option.aggregate)
+		return elementsReply(result.Range(0, result.Len(), false), option.withScores)
+	}
+}
+
+// execZSetAlgebraStore 实现ZUNIONSTORE, ZINTERSTORE和ZDIFFSTORE, 结果为空时删除destination
+func execZSetAlgebraStore(name string, algebra zsetAlgebra, allowWeights bool) ExecFunc {
+	return func(db *DB, args [][]byte) redis.Reply {
+		dst := string(args[0])
+		option, errReply := parseZSetAlgebra(name, args[1:], allowWeights, false)
+		if errReply != nil {
+			return errReply
+		}
+		sets, errReply := db.getAsWeightedSets(option.keys)
+		if errReply != nil {
+			return errReply
+		}
+		result := algebra(sets, option.weights, option.aggregate)
+		if result.Len() == 0 {
+			if db.Removes(dst) > 0 {
+				db.notify(notifyGeneric, "del", dst)
+			}
+			return protocol.MakeIntReply(0)
+		}
+		db.PutEntity(dst, &database.DataEntity{Data: result})
+		db.Persist(dst)
+		db.notify(notifyZSet, name, dst)
+		return protocol.MakeIntReply(result.Len())
+	}
+}
+
+// execZInterCard ZINTERCARD numkeys key [key ...] [LIMIT limit]
+func execZInterCard(db *DB, args [][]byte) redis.Reply {
+	keys, limit, errReply := parseInterCard(args)
+	if errReply != nil {
+		return errReply
+	}
+	sets, errReply := db.getAsWeightedSets(keys)
+	if errReply != nil {
+		return errReply
+	}
+	return protocol.MakeIntReply(int64(sortedset.IntersectCard(limit, sets...)))
+}
+
+func init() {
+	registerCommand("ZAdd", execZAdd, writeFirstKey, rollbackFirstKey, -4, flagWrite)
+	registerCommand("ZIncrBy", execZIncrBy, writeFirstKey, rollbackFirstKey, 4, flagWrite)
+	registerCommand("ZRem", execZRem, writeFirstKey, rollbackFirstKey, -3, flagWrite)
+	registerCommand("ZCard", execZCard, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("ZScore", execZScore, readFirstKey, nil, 3, flagReadOnly)
+	registerCommand("ZMScore", execZMScore, readFirstKey, nil, -3, flagReadOnly)
+	registerCommand("ZRank", execZRank, readFirstKey, nil, -3, flagReadOnly)
+	registerCommand("ZRevRank", execZRevRank, readFirstKey, nil, -3, flagReadOnly)
+	registerCommand("ZRange", execZRange, readFirstKey, nil, -4, flagReadOnly)
+	registerCommand("ZRevRange", execZRevRange, readFirstKey, nil, -4, flagReadOnly)
+	registerCommand("ZRangeByScore", execZRangeByScore, readFirstKey, nil, -4, flagReadOnly)
+	registerCommand("ZRevRangeByScore", execZRevRangeByScore, readFirstKey, nil, -4, flagReadOnly)
+	registerCommand("ZRangeByLex", execZRangeByLex, readFirstKey, nil, -4, flagReadOnly)
+	registerCommand("ZRevRangeByLex", execZRevRangeByLex, readFirstKey, nil, -4, flagReadOnly)
+	registerCommand("ZRangeStore", execZRangeStore, prepareZRangeStore, rollbackFirstKey, -5, flagWrite)
+	registerCommand("ZCount", execZCount, readFirstKey, nil, 4, flagReadOnly)
+	registerCommand("ZLexCount", execZLexCount, readFirstKey, nil, 4, flagReadOnly)
+	registerCommand("ZRemRangeByScore", execZRemRangeByScore, writeFirstKey, rollbackFirstKey, 4, flagWrite)
+	registerCommand("ZRemRangeByRank", execZRemRangeByRank, writeFirstKey, rollbackFirstKey, 4, flagWrite)
+	registerCommand("ZRemRangeByLex", execZRemRangeByLex, writeFirstKey, rollbackFirstKey, 4, flagWrite)
+	registerCommand("ZPopMin", execZPopMin, writeFirstKey, rollbackFirstKey, -2, flagWrite)
+	registerCommand("ZPopMax", execZPopMax, writeFirstKey, rollbackFirstKey, -2, flagWrite)
+	registerCommand("ZMPop", execZMPop, prepareZMPop, undoZMPop, -4, flagWrite|flagCustomAof)
+	registerCommand("BZPopMin", execBZPopMin, prepareBlockingPop, undoBlockingPop, -3, flagWrite|flagCustomAof).
+		attachBlocking(lastArgTimeout(-1))
+	registerCommand("BZPopMax", execBZPopMax, prepareBlockingPop, undoBlockingPop, -3, flagWrite|flagCustomAof).
+		attachBlocking(lastArgTimeout(-1))
+	registerCommand("BZMPop", execBZMPop, prepareBZMPop, undoBZMPop, -5, flagWrite|flagCustomAof).
+		attachBlocking(blockingBZMPop)
+	registerCommand("ZRandMember", execZRandMember, readFirstKey, nil, -2, flagReadOnly)
+	registerCommand("ZUnion", execZSetAlgebra("zunion", sortedset.Union, true), nil, nil, -3, flagReadOnly).
+		attachKeys(1, lastKeyByNumKeys, 1)
+	registerCommand("ZInter", execZSetAlgebra("zinter", sortedset.Intersect, true), nil, nil, -3, flagReadOnly).
+		attachKeys(1, lastKeyByNumKeys, 1)
+	registerCommand("ZDiff", execZSetAlgebra("zdiff", zsetDiff, false), nil, nil, -3, flagReadOnly).
+		attachKeys(1, lastKeyByNumKeys, 1)
+	registerCommand("ZUnionStore", execZSetAlgebraStore("zunionstore", sortedset.Union, true), nil, rollbackFirstKey, -4, flagWrite).
+		attachKeys(2, lastKeyByNumKeys, 1)
+	registerCommand("ZInterStore", execZSetAlgebraStore("zinterstore", sortedset.Intersect, true), nil, rollbackFirstKey, -4, flagWrite).
+		attachKeys(2, lastKeyByNumKeys, 1)
+	registerCommand("ZDiffStore", execZSetAlgebraStore("zdiffstore", zsetDiff, false), nil, rollbackFirstKey, -4, flagWrite).
+		attachKeys(2, lastKeyByNumKeys, 1)
+	registerCommand("ZInterCard", execZInterCard, nil, nil, -3, flagReadOnly).attachKeys(1, lastKeyByNumKeys, 1)
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"goredis/interface/redis"
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"goredis/redis/protocol"
+	"testing"
+)
+
+func TestZAdd(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zadd", "z", "1", "a", "2", "b")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zadd", "z", "NX", "5", "a", "3", "c")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zscore", "z", "a")), "$1\r\n1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zadd", "z", "XX", "CH", "5", "a", "4", "d")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zadd", "z", "GT", "CH", "1", "a", "6", "b")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zmscore", "z", "a", "b", "d")), "*3\r\n$1\r\n5\r\n$1\r\n6\r\n$-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zadd", "z", "INCR", "1.5", "a")), "$3\r\n6.5\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zadd", "z", "NX", "INCR", "1", "a")), "$-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zincrby", "z", "-0.5", "a")), "$1\r\n6\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zadd", "z", "NX", "XX", "1", "a")),
+		"-ERR XX and NX options at the same time are not compatible\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zadd", "z", "1", "a", "2")), "-Err syntax error\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zadd", "z", "x", "a")), "-ERR value is not a valid float\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zcard", "z")), ":3\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrem", "z", "a", "b", "c", "none")), ":3\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("exists", "z")), ":0\r\n")
+}
+
+func TestZRange(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("zadd", "z", "1", "a", "2", "b", "3", "c", "4", "d"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrange", "z", "1", "-2")), "*2\r\n$1\r\nb\r\n$1\r\nc\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrange", "z", "0", "0", "REV", "WITHSCORES")), "*2\r\n$1\r\nd\r\n$1\r\n4\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrange", "z", "(1", "+inf", "BYSCORE", "LIMIT", "1", "2")),
+		"*2\r\n$1\r\nc\r\n$1\r\nd\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrange", "z", "3", "-inf", "BYSCORE", "REV")),
+		"*3\r\n$1\r\nc\r\n$1\r\nb\r\n$1\r\na\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrevrangebyscore", "z", "+inf", "(3")), "*1\r\n$1\r\nd\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrank", "z", "c", "WITHSCORE")), "*2\r\n:2\r\n$1\r\n3\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrevrank", "z", "c")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrank", "z", "none")), "$-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zcount", "z", "2", "(4")), ":2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrange", "z", "0", "1", "LIMIT", "0", "1")),
+		"-ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX\r\n")
+
+	db.Exec(c, utils.ToCmdLine("zadd", "lex", "0", "a", "0", "b", "0", "c", "0", "d"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrange", "lex", "[b", "(d", "BYLEX")), "*2\r\n$1\r\nb\r\n$1\r\nc\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrevrangebylex", "lex", "+", "(b")), "*2\r\n$1\r\nd\r\n$1\r\nc\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zlexcount", "lex", "-", "+")), ":4\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrangebylex", "lex", "b", "+")),
+		"-ERR min or max not valid string range item\r\n")
+
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrangestore", "dst", "z", "2", "+inf", "BYSCORE")), ":3\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrange", "dst", "0", "-1")), "*3\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nd\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrangestore", "dst", "z", "10", "20")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("exists", "dst")), ":0\r\n")
+}
+
+func TestZRemoveAndPop(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("zadd", "z", "1", "a", "2", "b", "3", "c", "4", "d", "5", "e"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zremrangebyscore", "z", "(4", "+inf")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zremrangebyrank", "z", "-1", "-1")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zpopmax", "z")), "*2\r\n$1\r\nc\r\n$1\r\n3\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zpopmin", "z", "5")), "*4\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$1\r\n2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("exists", "z")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zpopmin", "z")), "*0\r\n")
+
+	db.Exec(c, utils.ToCmdLine("zadd", "lex", "0", "a", "0", "b", "0", "c"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zremrangebylex", "lex", "(a", "[b")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zcard", "lex")), ":2\r\n")
+}
+
+func TestZSetUndo(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("zadd", "z", "1", "a", "2", "b"))
+	cmdLine := utils.ToCmdLine("zremrangebyrank", "z", "0", "-1")
+	undoLogs := db.GetUndoLogs(cmdLine)
+	db.Exec(c, cmdLine)
+	for _, undo := range undoLogs {
+		db.Exec(c, undo)
+	}
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrange", "z", "0", "-1", "WITHSCORES")),
+		"*4\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$1\r\n2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("type", "z")), "+zset\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zscan", "z", "0", "MATCH", "a")),
+		"*2\r\n$1\r\n0\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n")
+}
+
+func TestZSetAlgebra(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("zadd", "a", "1", "x", "2", "y", "3", "z"))
+	db.Exec(c, utils.ToCmdLine("zadd", "b", "10", "y", "20", "z"))
+	db.Exec(c, utils.ToCmdLine("sadd", "s", "z", "w"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zunion", "2", "a", "b", "WITHSCORES")),
+		"*6\r\n$1\r\nx\r\n$1\r\n1\r\n$1\r\ny\r\n$2\r\n12\r\n$1\r\nz\r\n$2\r\n23\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zinter", "2", "a", "b", "WEIGHTS", "2", "0.5", "AGGREGATE", "MAX", "WITHSCORES")),
+		"*4\r\n$1\r\ny\r\n$1\r\n5\r\n$1\r\nz\r\n$2\r\n10\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zinter", "2", "a", "s", "AGGREGATE", "MIN", "WITHSCORES")),
+		"*2\r\n$1\r\nz\r\n$1\r\n1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zdiff", "3", "a", "b", "none")), "*1\r\n$1\r\nx\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zintercard", "2", "a", "b", "LIMIT", "1")), ":1\r\n")
+
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zunionstore", "dst", "2", "b", "s")), ":3\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zscore", "dst", "z")), "$2\r\n21\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zinterstore", "dst", "2", "a", "none")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("exists", "dst")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zdiffstore", "dst", "1", "a", "WEIGHTS", "1")), "-Err syntax error\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zunion", "0", "a")), "-ERR at least 1 input key is needed for 'zunion' command\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zunion", "1", "a", "WEIGHTS", "x")), "-ERR weight value is not a float\r\n")
+
+	write, read := cmdTable["zunionstore"].prepare(utils.ToCmdLine("dst", "2", "a", "b", "WEIGHTS", "1", "2"))
+	if len(write) != 1 || write[0] != "dst" || len(read) != 2 || read[0] != "a" || read[1] != "b" {
+		t.Errorf("wrong keys: write %v, read %v", write, read)
+	}
+}
+
+func TestZRandMember(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("zadd", "z", "1", "a", "2", "b", "3", "c"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zrandmember", "z", "0")), "*0\r\n")
+	members := db.Exec(c, utils.ToCmdLine("zrandmember", "z", "10")).(*protocol.MultiBulkReply).Args
+	distinct := make(map[string]struct{})
+	for _, member := range members {
+		distinct[string(member)] = struct{}{}
+	}
+	if len(distinct) != 3 {
+		t.Errorf("expected 3 distinct members, actual %q", members)
+	}
+	members = db.Exec(c, utils.ToCmdLine("zrandmember", "z", "-10", "withscores")).(*protocol.MultiBulkReply).Args
+	if len(members) != 20 {
+		t.Errorf("expected 10 members with scores, actual %d", len(members)/2)
+	}
+}
+
+func TestZScoreRESP3(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("zadd", "z", "1.5", "a", "inf", "b"))
+	resp3 := func(reply redis.Reply) string {
+		return string(protocol.Marshal(reply, protocol.RESP3))
+	}
+	if actual := resp3(db.Exec(c, utils.ToCmdLine("zscore", "z", "a"))); actual != ",1.5\r\n" {
+		t.Errorf("expected double reply, actual %q", actual)
+	}
+	if actual := resp3(db.Exec(c, utils.ToCmdLine("zmscore", "z", "b", "none"))); actual != "*2\r\n,inf\r\n_\r\n" {
+		t.Errorf("expected double replies, actual %q", actual)
+	}
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zmscore", "z", "b", "none")), "*2\r\n$3\r\ninf\r\n$-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("zmscore", "none", "a")), "*1\r\n$-1\r\n")
+}
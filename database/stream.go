@@ -0,0 +1,828 @@
+package database
+
+import (
+	"goredis/datastruct/stream"
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/redis/protocol"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getAsStream 返回key对应的stream, key不存在时返回nil
+func (db *DB) getAsStream(key string) (*stream.Stream, protocol.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return nil, nil
+	}
+	s, ok := entity.Data.(*stream.Stream)
+	if !ok {
+		return nil, &protocol.WrongTypeErrReply{}
+	}
+	return s, nil
+}
+
+// parseStreamID 解析ms-seq格式的ID, 只有ms部分时序号为defaultSeq
+func parseStreamID(raw []byte, defaultSeq uint64) (stream.ID, protocol.ErrorReply) {
+	id, err := stream.ParseID(string(raw), defaultSeq)
+	if err != nil {
+		return id, protocol.MakeErrReply(err.Error())
+	}
+	return id, nil
+}
+
+// parseRangeID 解析区间的边界, 支持-和+, 以及(开头的开区间, 只有ms部分时起点的序号为0, 终点的序号为最大值
+func parseRangeID(raw []byte, isStart bool) (stream.ID, protocol.ErrorReply) {
+	str := string(raw)
+	switch str {
+	case "-":
+		return stream.MinID, nil
+	case "+":
+		return stream.MaxID, nil
+	}
+	exclusive := strings.HasPrefix(str, "(")
+	if exclusive {
+		str = str[1:]
+	}
+	defaultSeq := uint64(0)
+	if !isStart {
+		defaultSeq = math.MaxUint64
+	}
+	id, err := stream.ParseID(str, defaultSeq)
+	if err != nil {
+		return id, protocol.MakeErrReply(err.Error())
+	}
+	if !exclusive {
+		return id, nil
+	}
+	if isStart {
+		if next, ok := id.Next(); ok {
+			return next, nil
+		}
+		return id, protocol.MakeErrReply("ERR invalid start ID for the interval")
+	}
+	if prev, ok := id.Prev(); ok {
+		return prev, nil
+	}
+	return id, protocol.MakeErrReply("ERR invalid end ID for the interval")
+}
+
+func makeIDReply(id stream.ID) redis.Reply {
+	return protocol.MakeBulkReply([]byte(id.String()))
+}
+
+// makeEntryReply 消息的格式为[id, [field, value ...]]
+func makeEntryReply(entry *stream.Entry) redis.Reply {
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		makeIDReply(entry.ID),
+		protocol.MakeMultiBulkReply(entry.Fields),
+	})
+}
+
+func makeEntriesReply(entries []*stream.Entry) redis.Reply {
+	result := make([]redis.Reply, len(entries))
+	for i, entry := range entries {
+		result[i] = makeEntryReply(entry)
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+// streamTrimOption XADD和XTRIM的MAXLEN|MINID [=|~] threshold [LIMIT count]选项
+// 与redis不同, ~也会精确裁剪, LIMIT限制一次删除的消息数量
+type streamTrimOption struct {
+	strategy string
+	maxLen   int
+	minID    stream.ID
+	approx   bool
+	limit    int
+}
+
+func (opt *streamTrimOption) trim(s *stream.Stream) int {
+	switch opt.strategy {
+	case "MAXLEN":
+		return s.TrimMaxLen(opt.maxLen, opt.limit)
+	case "MINID":
+		return s.TrimMinID(opt.minID, opt.limit)
+	}
+	return 0
+}
+
+// parseStreamOptions 解析XADD和XTRIM的选项, XADD的选项在遇到第一个无法识别的参数(即ID)时结束, 返回该参数的位置
+func parseStreamOptions(args [][]byte, xadd bool) (*streamTrimOption, bool, int, redis.Reply) {
+	opt := &streamTrimOption{}
+	noMkStream := false
+	hasLimit := false
+	i := 1
+loop:
+	for ; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch {
+		case xadd && arg == "NOMKSTREAM":
+			noMkStream = true
+		case (arg == "MAXLEN" || arg == "MINID") && i+1 < len(args):
+			if opt.strategy != "" && opt.strategy != arg {
+				return nil, false, 0, protocol.MakeErrReply("ERR syntax error, MAXLEN and MINID options at the same time are not compatible")
+			}
+			opt.strategy = arg
+			i++
+			if op := string(args[i]); (op == "=" || op == "~") && i+1 < len(args) {
+				opt.approx = op == "~"
+				i++
+			}
+			if arg == "MAXLEN" {
+				maxLen, err := strconv.ParseInt(string(args[i]), 10, 64)
+				if err != nil {
+					return nil, false, 0, protocol.MakeErrReply("ERR value is not an integer or out of range")
+				}
+				if maxLen < 0 {
+					return nil, false, 0, protocol.MakeErrReply("ERR The MAXLEN argument must be >= 0.")
+				}
+				opt.maxLen = int(maxLen)
+			} else {
+				minID, errReply := parseStreamID(args[i], 0)
+				if errReply != nil {
+					return nil, false, 0, errReply
+				}
+				opt.minID = minID
+			}
+		case arg == "LIMIT" && i+1 < len(args):
+			i++
+			limit, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				return nil, false, 0, protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if limit < 0 {
+				return nil, false, 0, protocol.MakeErrReply("ERR The LIMIT argument must be >= 0.")
+			}
+			opt.limit = int(limit)
+			hasLimit = true
+		default:
+			if xadd {
+				break loop
+			}
+			return nil, false, 0, protocol.MakeSyntaxErrReply()
+		}
+	}
+	if hasLimit && !opt.approx {
+		return nil, false, 0, protocol.MakeErrReply("ERR syntax error, LIMIT cannot be used without the special ~ option")
+	}
+	return opt, noMkStream, i, nil
+}
+
+// parseXAddID 解析XADD的ID, 支持自动生成的*和ms-*
+func parseXAddID(s *stream.Stream, raw []byte) (stream.ID, protocol.ErrorReply) {
+	str := string(raw)
+	if str == "*" {
+		id, ok := s.NextID(uint64(time.Now().UnixMilli()))
+		if !ok {
+			return id, protocol.MakeErrReply("ERR The stream has exhausted the last possible ID, unable to add more items")
+		}
+		return id, nil
+	}
+	var id stream.ID
+	if msPart, found := strings.CutSuffix(str, "-*"); found {
+		ms, err := strconv.ParseUint(msPart, 10, 64)
+		if err != nil {
+			return id, protocol.MakeErrReply(stream.ErrInvalidID.Error())
+		}
+		var ok bool
+		id, ok = s.NextSeq(ms)
+		if !ok {
+			return id, protocol.MakeErrReply("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+		}
+		return id, nil
+	}
+	id, errReply := parseStreamID(raw, 0)
+	if errReply != nil {
+		return id, errReply
+	}
+	if id.IsZero() {
+		return id, protocol.MakeErrReply("ERR The ID specified in XADD must be greater than 0-0")
+	}
+	if !s.LastID().Less(id) {
+		return id, protocol.MakeErrReply("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	}
+	return id, nil
+}
+
+// execXAdd XADD key [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|id field value [field value ...]
+func execXAdd(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	trimOption, noMkStream, idIndex, errReply := parseStreamOptions(args, true)
+	if errReply != nil {
+		return errReply
+	}
+	fieldCount := len(args) - idIndex - 1
+	if fieldCount <= 0 || fieldCount%2 != 0 {
+		return protocol.MakeArgNumErrReply("xadd")
+	}
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	isNew := s == nil
+	if isNew {
+		if noMkStream {
+			return protocol.MakeNullBulkReply()
+		}
+		s = stream.New()
+	}
+	id, errReply := parseXAddID(s, args[idIndex])
+	if errReply != nil {
+		return errReply
+	}
+	if isNew {
+		db.PutEntity(key, &database.DataEntity{Data: s})
+	}
+	s.Add(id, args[idIndex+1:])
+	db.notify(notifyStream, "xadd", key)
+	if trimOption.trim(s) > 0 {
+		db.notify(notifyStream, "xtrim", key)
+	}
+
+	// 自动生成的ID替换为实际的ID, 重放时得到相同的结果
+	aofLine := make(CmdLine, 0, len(args)+1)
+	aofLine = append(aofLine, []byte("XADD"))
+	aofLine = append(aofLine, args[:idIndex]...)
+	aofLine = append(aofLine, []byte(id.String()))
+	aofLine = append(aofLine, args[idIndex+1:]...)
+	db.addAof(aofLine)
+	return makeIDReply(id)
+}
+
+// execXTrim XTRIM key MAXLEN|MINID [=|~] threshold [LIMIT count]
+func execXTrim(db *DB, args [][]byte) redis.Reply {
+	trimOption, _, _, errReply := parseStreamOptions(args, false)
+	if errReply != nil {
+		return errReply
+	}
+	if trimOption.strategy == "" {
+		return protocol.MakeSyntaxErrReply()
+	}
+	key := string(args[0])
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeIntReply(0)
+	}
+	trimmed := trimOption.trim(s)
+	if trimmed > 0 {
+		db.notify(notifyStream, "xtrim", key)
+	}
+	return protocol.MakeIntReply(int64(trimmed))
+}
+
+func execXLen(db *DB, args [][]byte) redis.Reply {
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(int64(s.Len()))
+}
+
+// execXDel XDEL key id [id ...], stream为空时也不会删除key
+func execXDel(db *DB, args [][]byte) redis.Reply {
+	ids := make([]stream.ID, len(args)-1)
+	for i, arg := range args[1:] {
+		id, errReply := parseStreamID(arg, 0)
+		if errReply != nil {
+			return errReply
+		}
+		ids[i] = id
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeIntReply(0)
+	}
+	deleted := 0
+	for _, id := range ids {
+		if s.Delete(id) {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		db.notify(notifyStream, "xdel", string(args[0]))
+	}
+	return protocol.MakeIntReply(int64(deleted))
+}
+
+// xrangeGeneric XRANGE key start end [COUNT count], XREVRANGE key end start [COUNT count]
+func xrangeGeneric(db *DB, args [][]byte, desc bool) redis.Reply {
+	startArg, endArg := args[1], args[2]
+	if desc {
+		startArg, endArg = endArg, startArg
+	}
+	start, errReply := parseRangeID(startArg, true)
+	if errReply != nil {
+		return errReply
+	}
+	end, errReply := parseRangeID(endArg, false)
+	if errReply != nil {
+		return errReply
+	}
+	count := 0
+	if len(args) > 3 {
+		if len(args) != 5 || strings.ToUpper(string(args[3])) != "COUNT" {
+			return protocol.MakeSyntaxErrReply()
+		}
+		n, err := strconv.ParseInt(string(args[4]), 10, 64)
+		if err != nil {
+			return protocol.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if n <= 0 {
+			return protocol.MakeEmptyMultiBulkReply()
+		}
+		count = int(min(n, math.MaxInt32))
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	return makeEntriesReply(s.Range(start, end, count, desc))
+}
+
+func execXRange(db *DB, args [][]byte) redis.Reply {
+	return xrangeGeneric(db, args, false)
+}
+
+func execXRevRange(db *DB, args [][]byte) redis.Reply {
+	return xrangeGeneric(db, args, true)
+}
+
+// execXSetID XSETID key last-id [ENTRIESADDED entries-added] [MAXDELETEDID max-deleted-id]
+func execXSetID(db *DB, args [][]byte) redis.Reply {
+	lastID, errReply := parseStreamID(args[1], 0)
+	if errReply != nil {
+		return errReply
+	}
+	entriesAdded := int64(-1)
+	maxDeletedID := stream.MinID
+	hasMaxDeletedID := false
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return protocol.MakeSyntaxErrReply()
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "ENTRIESADDED":
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if n < 0 {
+				return protocol.MakeErrReply("ERR entries_added must be positive")
+			}
+			entriesAdded = n
+		case "MAXDELETEDID":
+			maxDeletedID, errReply = parseStreamID(args[i+1], 0)
+			if errReply != nil {
+				return errReply
+			}
+			if lastID.Less(maxDeletedID) {
+				return protocol.MakeErrReply("ERR The ID specified in XSETID is smaller than the provided max_deleted_entry_id")
+			}
+			hasMaxDeletedID = true
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeErrReply("ERR no such key")
+	}
+	if s.Len() > 0 {
+		if lastID.Less(s.LastEntry().ID) {
+			return protocol.MakeErrReply("ERR The ID specified in XSETID is smaller than the target stream top item")
+		}
+		if entriesAdded != -1 && int64(s.Len()) > entriesAdded {
+			return protocol.MakeErrReply("ERR The entries_added specified in XSETID is smaller than the target stream length")
+		}
+	}
+	if entriesAdded == -1 {
+		entriesAdded = s.EntriesAdded()
+	}
+	if !hasMaxDeletedID {
+		maxDeletedID = s.MaxDeletedID()
+	}
+	s.SetLastID(lastID, entriesAdded, maxDeletedID)
+	db.notify(notifyStream, "xsetid", string(args[0]))
+	return protocol.MakeOkReply()
+}
+
+// xreadOption XREAD和XREADGROUP的参数
+type xreadOption struct {
+	group    string
+	consumer string
+	count    int
+	noAck    bool
+	block    bool
+	timeout  time.Duration
+	keys     []string
+	// idIndex 第一个ID在参数中的位置
+	idIndex int
+}
+
+// parseXRead 解析XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+// 以及XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+func parseXRead(args [][]byte, withGroup bool) (*xreadOption, redis.Reply) {
+	cmdName := "xread"
+	if withGroup {
+		cmdName = "xreadgroup"
+	}
+	opt := &xreadOption{}
+	streamsIndex := -1
+	for i := 0; i < len(args) && streamsIndex < 0; i++ {
+		arg := strings.ToUpper(string(args[i]))
+		hasNext := i+1 < len(args)
+		switch {
+		case arg == "COUNT" && hasNext:
+			i++
+			count, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				return nil, protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			opt.count = int(min(max(count, 0), math.MaxInt32))
+		case arg == "BLOCK" && hasNext:
+			i++
+			ms, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				return nil, protocol.MakeErrReply("ERR timeout is not an integer or out of range")
+			}
+			if ms < 0 {
+				return nil, protocol.MakeErrReply("ERR timeout is negative")
+			}
+			opt.block = true
+			opt.timeout = time.Duration(min(ms, math.MaxInt64/int64(time.Millisecond))) * time.Millisecond
+		case arg == "GROUP" && i+2 < len(args):
+			if !withGroup {
+				return nil, protocol.MakeErrReply("ERR The GROUP option is only supported by XREADGROUP. You called XREAD instead.")
+			}
+			opt.group, opt.consumer = string(args[i+1]), string(args[i+2])
+			i += 2
+		case arg == "NOACK":
+			if !withGroup {
+				return nil, protocol.MakeErrReply("ERR The NOACK option is only supported by XREADGROUP. You called XREAD instead.")
+			}
+			opt.noAck = true
+		case arg == "STREAMS":
+			streamsIndex = i + 1
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	if streamsIndex < 0 {
+		return nil, protocol.MakeSyntaxErrReply()
+	}
+	if withGroup && opt.group == "" {
+		return nil, protocol.MakeErrReply("ERR Missing GROUP option for XREADGROUP")
+	}
+	streamArgs := len(args) - streamsIndex
+	if streamArgs == 0 || streamArgs%2 != 0 {
+		special := "$"
+		if withGroup {
+			special = ">"
+		}
+		return nil, protocol.MakeErrReply("ERR Unbalanced '" + cmdName + "' list of streams: for each stream key an ID or '" + special + "' must be specified.")
+	}
+	keyCount := streamArgs / 2
+	opt.idIndex = streamsIndex + keyCount
+	opt.keys = make([]string, keyCount)
+	for i := range opt.keys {
+		opt.keys[i] = string(args[streamsIndex+i])
+	}
+	for _, raw := range args[opt.idIndex:] {
+		switch string(raw) {
+		case "$", "+":
+			if withGroup {
+				return nil, protocol.MakeErrReply("ERR The " + string(raw) + " ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The " + string(raw) + " ID would just return an empty result set.")
+			}
+		case ">":
+			if !withGroup {
+				return nil, protocol.MakeErrReply("ERR The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.")
+			}
+		default:
+			if _, errReply := parseStreamID(raw, 0); errReply != nil {
+				return nil, errReply
+			}
+		}
+	}
+	return opt, nil
+}
+
+// blockingXRead 只有指定了BLOCK时才阻塞
+func blockingXRead(withGroup bool) BlockingFunc {
+	return func(args [][]byte) ([]string, time.Duration, redis.Reply) {
+		opt, errReply := parseXRead(args, withGroup)
+		if errReply != nil {
+			return nil, 0, errReply
+		}
+		if !opt.block {
+			return nil, 0, nil
+		}
+		return opt.keys, opt.timeout, nil
+	}
+}
+
+func prepareXRead(args [][]byte) ([]string, []string) {
+	opt, errReply := parseXRead(args, false)
+	if errReply != nil {
+		return nil, nil
+	}
+	return nil, opt.keys
+}
+
+// makeStreamReply XREAD和XREADGROUP的结果中每个stream的格式为[key, [entry ...]]
+func makeStreamReply(key string, entries redis.Reply) redis.Reply {
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte(key)),
+		entries,
+	})
+}
+
+// execXRead XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...], 没有新消息时返回空
+func execXRead(db *DB, args [][]byte) redis.Reply {
+	opt, errReply := parseXRead(args, false)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]redis.Reply, 0)
+	for i, key := range opt.keys {
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return errReply
+		}
+		raw := args[opt.idIndex+i]
+		switch string(raw) {
+		case "+":
+			if s != nil && s.Len() > 0 {
+				entries := []*stream.Entry{s.LastEntry()}
+				result = append(result, makeStreamReply(key, makeEntriesReply(entries)))
+				continue
+			}
+			fallthrough
+		case "$":
+			// 阻塞后会再次执行命令, 之后只应该返回现在之后添加的消息, 所以把参数替换为当前最后的ID
+			lastID := stream.MinID
+			if s != nil {
+				lastID = s.LastID()
+			}
+			args[opt.idIndex+i] = []byte(lastID.String())
+			continue
+		}
+		if s == nil {
+			continue
+		}
+		id, _ := parseStreamID(raw, 0)
+		start, ok := id.Next()
+		if !ok {
+			continue
+		}
+		entries := s.Range(start, stream.MaxID, opt.count, false)
+		if len(entries) > 0 {
+			result = append(result, makeStreamReply(key, makeEntriesReply(entries)))
+		}
+	}
+	if len(result) == 0 {
+		return protocol.MakeNullMultiBulkReply()
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+// makeInfoEntryReply XINFO中的消息, 不存在时为nil
+func makeInfoEntryReply(entry *stream.Entry) redis.Reply {
+	if entry == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	return makeEntryReply(entry)
+}
+
+// makeEntriesReadReply 未知时返回nil
+func makeEntriesReadReply(entriesRead int64) redis.Reply {
+	if entriesRead == stream.InvalidEntriesRead {
+		return protocol.MakeNullBulkReply()
+	}
+	return protocol.MakeIntReply(entriesRead)
+}
+
+func makeLagReply(s *stream.Stream, group *stream.Group) redis.Reply {
+	lag, ok := s.Lag(group)
+	if !ok {
+		return protocol.MakeNullBulkReply()
+	}
+	return protocol.MakeIntReply(lag)
+}
+
+func makeStringReply(key string) redis.Reply {
+	return protocol.MakeBulkReply([]byte(key))
+}
+
+// execXInfo XINFO STREAM key [FULL [COUNT count]] | GROUPS key | CONSUMERS key group
+func execXInfo(db *DB, args [][]byte) redis.Reply {
+	subCmd := strings.ToUpper(string(args[0]))
+	switch subCmd {
+	case "STREAM":
+		if len(args) < 2 {
+			return protocol.MakeArgNumErrReply("xinfo|stream")
+		}
+	case "GROUPS":
+		if len(args) != 2 {
+			return protocol.MakeArgNumErrReply("xinfo|groups")
+		}
+	case "CONSUMERS":
+		if len(args) != 3 {
+			return protocol.MakeArgNumErrReply("xinfo|consumers")
+		}
+	default:
+		return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try XINFO HELP.")
+	}
+	key := string(args[1])
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeErrReply("ERR no such key")
+	}
+	switch subCmd {
+	case "STREAM":
+		return xinfoStream(s, args[2:])
+	case "GROUPS":
+		groups := s.Groups()
+		result := make([]redis.Reply, len(groups))
+		for i, group := range groups {
+			result[i] = protocol.MakeMapReply([]redis.Reply{
+				makeStringReply("name"), protocol.MakeBulkReply([]byte(group.Name)),
+				makeStringReply("consumers"), protocol.MakeIntReply(int64(len(group.Consumers()))),
+				makeStringReply("pending"), protocol.MakeIntReply(int64(group.PendingLen())),
+				makeStringReply("last-delivered-id"), makeIDReply(group.LastID),
+				makeStringReply("entries-read"), makeEntriesReadReply(group.EntriesRead),
+				makeStringReply("lag"), makeLagReply(s, group),
+			})
+		}
+		return protocol.MakeMultiRawReply(result)
+	}
+	group := s.Group(string(args[2]))
+	if group == nil {
+		return makeNoGroupReply(key, string(args[2]))
+	}
+	now := time.Now()
+	consumers := group.Consumers()
+	result := make([]redis.Reply, len(consumers))
+	for i, c := range consumers {
+		inactive := int64(-1)
+		if !c.ActiveTime.IsZero() {
+			inactive = now.Sub(c.ActiveTime).Milliseconds()
+		}
+		result[i] = protocol.MakeMapReply([]redis.Reply{
+			makeStringReply("name"), protocol.MakeBulkReply([]byte(c.Name)),
+			makeStringReply("pending"), protocol.MakeIntReply(int64(c.PendingLen())),
+			makeStringReply("idle"), protocol.MakeIntReply(now.Sub(c.SeenTime).Milliseconds()),
+			makeStringReply("inactive"), protocol.MakeIntReply(inactive),
+		})
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+// xinfoStream XINFO STREAM key [FULL [COUNT count]], FULL时COUNT默认为10, 0表示全部
+func xinfoStream(s *stream.Stream, args [][]byte) redis.Reply {
+	full := false
+	count := 10
+	switch {
+	case len(args) == 0:
+	case strings.ToUpper(string(args[0])) != "FULL":
+		return protocol.MakeSyntaxErrReply()
+	case len(args) == 1:
+		full = true
+	case len(args) == 3 && strings.ToUpper(string(args[1])) == "COUNT":
+		full = true
+		n, err := strconv.ParseInt(string(args[2]), 10, 64)
+		if err != nil {
+			return protocol.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		count = int(min(max(n, 0), math.MaxInt32))
+	default:
+		return protocol.MakeSyntaxErrReply()
+	}
+
+	pairs := []redis.Reply{
+		makeStringReply("length"), protocol.MakeIntReply(int64(s.Len())),
+		makeStringReply("radix-tree-keys"), protocol.MakeIntReply(int64(s.Len())),
+		makeStringReply("radix-tree-nodes"), protocol.MakeIntReply(int64(s.NodeCount() + 1)),
+		makeStringReply("last-generated-id"), makeIDReply(s.LastID()),
+		makeStringReply("max-deleted-entry-id"), makeIDReply(s.MaxDeletedID()),
+		makeStringReply("entries-added"), protocol.MakeIntReply(s.EntriesAdded()),
+		makeStringReply("recorded-first-entry-id"), makeIDReply(s.FirstID()),
+	}
+	if !full {
+		pairs = append(pairs,
+			makeStringReply("groups"), protocol.MakeIntReply(int64(len(s.Groups()))),
+			makeStringReply("first-entry"), makeInfoEntryReply(s.FirstEntry()),
+			makeStringReply("last-entry"), makeInfoEntryReply(s.LastEntry()),
+		)
+		return protocol.MakeMapReply(pairs)
+	}
+
+	withinCount := func(n int) bool {
+		return count == 0 || n < count
+	}
+	groups := s.Groups()
+	groupReplies := make([]redis.Reply, len(groups))
+	for i, group := range groups {
+		pending := make([]redis.Reply, 0)
+		group.RangePending(stream.MinID, func(pe *stream.PendingEntry) bool {
+			if !withinCount(len(pending)) {
+				return false
+			}
+			pending = append(pending, protocol.MakeMultiRawReply([]redis.Reply{
+				makeIDReply(pe.ID),
+				protocol.MakeBulkReply([]byte(pe.Consumer.Name)),
+				protocol.MakeIntReply(pe.DeliveryTime.UnixMilli()),
+				protocol.MakeIntReply(pe.DeliveryCount),
+			}))
+			return true
+		})
+		consumers := group.Consumers()
+		consumerReplies := make([]redis.Reply, len(consumers))
+		for j, c := range consumers {
+			consumerPending := make([]redis.Reply, 0)
+			c.RangePending(stream.MinID, func(pe *stream.PendingEntry) bool {
+				if !withinCount(len(consumerPending)) {
+					return false
+				}
+				consumerPending = append(consumerPending, protocol.MakeMultiRawReply([]redis.Reply{
+					makeIDReply(pe.ID),
+					protocol.MakeIntReply(pe.DeliveryTime.UnixMilli()),
+					protocol.MakeIntReply(pe.DeliveryCount),
+				}))
+				return true
+			})
+			activeTime := int64(-1)
+			if !c.ActiveTime.IsZero() {
+				activeTime = c.ActiveTime.UnixMilli()
+			}
+			consumerReplies[j] = protocol.MakeMapReply([]redis.Reply{
+				makeStringReply("name"), protocol.MakeBulkReply([]byte(c.Name)),
+				makeStringReply("seen-time"), protocol.MakeIntReply(c.SeenTime.UnixMilli()),
+				makeStringReply("active-time"), protocol.MakeIntReply(activeTime),
+				makeStringReply("pel-count"), protocol.MakeIntReply(int64(c.PendingLen())),
+				makeStringReply("pending"), protocol.MakeMultiRawReply(consumerPending),
+			})
+		}
+		groupReplies[i] = protocol.MakeMapReply([]redis.Reply{
+			makeStringReply("name"), protocol.MakeBulkReply([]byte(group.Name)),
+			makeStringReply("last-delivered-id"), makeIDReply(group.LastID),
+			makeStringReply("entries-read"), makeEntriesReadReply(group.EntriesRead),
+			makeStringReply("lag"), makeLagReply(s, group),
+			makeStringReply("pel-count"), protocol.MakeIntReply(int64(group.PendingLen())),
+			makeStringReply("pending"), protocol.MakeMultiRawReply(pending),
+			makeStringReply("consumers"), protocol.MakeMultiRawReply(consumerReplies),
+		})
+	}
+	entries := s.Range(stream.MinID, stream.MaxID, count, false)
+	pairs = append(pairs,
+		makeStringReply("entries"), makeEntriesReply(entries),
+		makeStringReply("groups"), protocol.MakeMultiRawReply(groupReplies),
+	)
+	return protocol.MakeMapReply(pairs)
+}
+
+// prepareSubCommandKey 用于key是第二个参数的子命令, 如XGROUP CREATE key
+func prepareSubCommandKey(write bool) PreFunc {
+	return func(args [][]byte) ([]string, []string) {
+		if len(args) < 2 {
+			return nil, nil
+		}
+		if write {
+			return []string{string(args[1])}, nil
+		}
+		return nil, []string{string(args[1])}
+	}
+}
+
+func init() {
+	registerCommand("XAdd", execXAdd, writeFirstKey, rollbackFirstKey, -5, flagWrite|flagCustomAof)
+	registerCommand("XTrim", execXTrim, writeFirstKey, rollbackFirstKey, -4, flagWrite)
+	registerCommand("XDel", execXDel, writeFirstKey, rollbackFirstKey, -3, flagWrite)
+	registerCommand("XSetID", execXSetID, writeFirstKey, rollbackFirstKey, -3, flagWrite)
+	registerCommand("XLen", execXLen, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("XRange", execXRange, readFirstKey, nil, -4, flagReadOnly)
+	registerCommand("XRevRange", execXRevRange, readFirstKey, nil, -4, flagReadOnly)
+	registerCommand("XRead", execXRead, prepareXRead, nil, -4, flagReadOnly).
+		attachBlocking(blockingXRead(false))
+	registerCommand("XInfo", execXInfo, prepareSubCommandKey(false), nil, -2, flagReadOnly)
+}
@@ -0,0 +1,660 @@
+package database
+
+import (
+	"goredis/datastruct/stream"
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/lib/utils"
+	"goredis/redis/protocol"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func makeNoGroupReply(key, group string) protocol.ErrorReply {
+	return protocol.MakeErrReply("NOGROUP No such consumer group '" + group + "' for key name '" + key + "'")
+}
+
+// getStreamGroup 返回stream和消费者组, 任意一个不存在时返回NOGROUP错误
+func (db *DB) getStreamGroup(key, groupName string) (*stream.Stream, *stream.Group, protocol.ErrorReply) {
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return nil, nil, errReply
+	}
+	var group *stream.Group
+	if s != nil {
+		group = s.Group(groupName)
+	}
+	if group == nil {
+		return nil, nil, protocol.MakeErrReply("NOGROUP No such key '" + key + "' or consumer group '" + groupName + "'")
+	}
+	return s, group, nil
+}
+
+// makeXClaimCmd 生成重建待确认消息的XCLAIM命令, 读取或者认领消息时写入aof, 重放时得到相同的投递时间和次数
+func makeXClaimCmd(key string, group *stream.Group, pe *stream.PendingEntry) CmdLine {
+	return utils.ToCmdLine("XCLAIM", key, group.Name, pe.Consumer.Name, "0", pe.ID.String(),
+		"TIME", strconv.FormatInt(pe.DeliveryTime.UnixMilli(), 10),
+		"RETRYCOUNT", strconv.FormatInt(pe.DeliveryCount, 10),
+		"FORCE", "JUSTID", "LASTID", group.LastID.String())
+}
+
+func makeXGroupSetIDCmd(key string, group *stream.Group) CmdLine {
+	return utils.ToCmdLine("XGROUP", "SETID", key, group.Name, group.LastID.String(),
+		"ENTRIESREAD", strconv.FormatInt(group.EntriesRead, 10))
+}
+
+// createConsumer 返回消费者, 不存在时创建并写入aof
+func (db *DB) createConsumer(key string, group *stream.Group, name string, now time.Time) *stream.Consumer {
+	c, created := group.CreateConsumer(name, now)
+	if created {
+		db.addAof(utils.ToCmdLine("XGROUP", "CREATECONSUMER", key, group.Name, name))
+		db.notify(notifyStream, "xgroup-createconsumer", key)
+	}
+	return c
+}
+
+// parseGroupID 解析XGROUP CREATE和SETID的ID, $表示stream当前最后的ID
+func parseGroupID(s *stream.Stream, raw []byte) (stream.ID, protocol.ErrorReply) {
+	if string(raw) == "$" {
+		if s == nil {
+			return stream.MinID, nil
+		}
+		return s.LastID(), nil
+	}
+	return parseStreamID(raw, 0)
+}
+
+func parseEntriesRead(raw []byte) (int64, protocol.ErrorReply) {
+	n, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if n < 0 && n != stream.InvalidEntriesRead {
+		return 0, protocol.MakeErrReply("ERR value for ENTRIESREAD must be positive or -1")
+	}
+	return n, nil
+}
+
+// execXGroup XGROUP CREATE|SETID|DESTROY|CREATECONSUMER|DELCONSUMER key group ...
+func execXGroup(db *DB, args [][]byte) redis.Reply {
+	subCmd := strings.ToUpper(string(args[0]))
+	var valid bool
+	switch subCmd {
+	case "CREATE":
+		valid = len(args) >= 4 && len(args) <= 7
+	case "SETID":
+		valid = len(args) == 4 || len(args) == 6
+	case "DESTROY":
+		valid = len(args) == 3
+	case "CREATECONSUMER", "DELCONSUMER":
+		valid = len(args) == 4
+	default:
+		return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try XGROUP HELP.")
+	}
+	if !valid {
+		return protocol.MakeArgNumErrReply("xgroup|" + strings.ToLower(subCmd))
+	}
+	key, groupName := string(args[1]), string(args[2])
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if subCmd == "CREATE" {
+		return xgroupCreate(db, s, args)
+	}
+	if s == nil {
+		return protocol.MakeErrReply("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+	}
+	if subCmd == "DESTROY" {
+		if s.DestroyGroup(groupName) {
+			db.notify(notifyStream, "xgroup-destroy", key)
+			return protocol.MakeIntReply(1)
+		}
+		return protocol.MakeIntReply(0)
+	}
+	group := s.Group(groupName)
+	if group == nil {
+		return makeNoGroupReply(key, groupName)
+	}
+	switch subCmd {
+	case "SETID":
+		id, errReply := parseGroupID(s, args[3])
+		if errReply != nil {
+			return errReply
+		}
+		entriesRead := int64(stream.InvalidEntriesRead)
+		if len(args) == 6 {
+			if strings.ToUpper(string(args[4])) != "ENTRIESREAD" {
+				return protocol.MakeSyntaxErrReply()
+			}
+			entriesRead, errReply = parseEntriesRead(args[5])
+			if errReply != nil {
+				return errReply
+			}
+		}
+		group.LastID = id
+		group.EntriesRead = entriesRead
+		db.notify(notifyStream, "xgroup-setid", key)
+		return protocol.MakeOkReply()
+	case "CREATECONSUMER":
+		if _, created := group.CreateConsumer(string(args[3]), time.Now()); created {
+			db.notify(notifyStream, "xgroup-createconsumer", key)
+			return protocol.MakeIntReply(1)
+		}
+		return protocol.MakeIntReply(0)
+	}
+	pending, ok := group.DeleteConsumer(string(args[3]))
+	if ok {
+		db.notify(notifyStream, "xgroup-delconsumer", key)
+	}
+	return protocol.MakeIntReply(int64(pending))
+}
+
+// xgroupCreate XGROUP CREATE key group id|$ [MKSTREAM] [ENTRIESREAD entries-read]
+func xgroupCreate(db *DB, s *stream.Stream, args [][]byte) redis.Reply {
+	mkStream := false
+	entriesRead := int64(stream.InvalidEntriesRead)
+	for i := 4; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "MKSTREAM":
+			mkStream = true
+		case "ENTRIESREAD":
+			if i+1 >= len(args) {
+				return protocol.MakeSyntaxErrReply()
+			}
+			var errReply protocol.ErrorReply
+			entriesRead, errReply = parseEntriesRead(args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			i++
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+	id, errReply := parseGroupID(s, args[3])
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		if !mkStream {
+			return protocol.MakeErrReply("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+		}
+		s = stream.New()
+		db.PutEntity(string(args[1]), &database.DataEntity{Data: s})
+	}
+	if _, ok := s.CreateGroup(string(args[2]), id, entriesRead); !ok {
+		return protocol.MakeErrReply("BUSYGROUP Consumer Group name already exists")
+	}
+	db.notify(notifyStream, "xgroup-create", string(args[1]))
+	return protocol.MakeOkReply()
+}
+
+func undoXGroup(db *DB, args [][]byte) []CmdLine {
+	if len(args) < 2 {
+		return nil
+	}
+	return rollbackGivenKeys(db, string(args[1]))
+}
+
+func prepareXReadGroup(args [][]byte) ([]string, []string) {
+	opt, errReply := parseXRead(args, true)
+	if errReply != nil {
+		return nil, nil
+	}
+	return opt.keys, nil
+}
+
+func undoXReadGroup(db *DB, args [][]byte) []CmdLine {
+	opt, errReply := parseXRead(args, true)
+	if errReply != nil {
+		return nil
+	}
+	return rollbackGivenKeys(db, opt.keys...)
+}
+
+// execXReadGroup XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+// ID为>时读取组内还没有投递过的新消息, 否则读取投递给这个消费者且还没有确认的历史消息
+func execXReadGroup(db *DB, args [][]byte) redis.Reply {
+	opt, errReply := parseXRead(args, true)
+	if errReply != nil {
+		return errReply
+	}
+	streams := make([]*stream.Stream, len(opt.keys))
+	groups := make([]*stream.Group, len(opt.keys))
+	for i, key := range opt.keys {
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return errReply
+		}
+		if s != nil {
+			groups[i] = s.Group(opt.group)
+		}
+		if groups[i] == nil {
+			return protocol.MakeErrReply("NOGROUP No such key '" + key + "' or consumer group '" + opt.group + "' in XREADGROUP with GROUP option")
+		}
+		streams[i] = s
+	}
+
+	now := time.Now()
+	result := make([]redis.Reply, 0)
+	for i, key := range opt.keys {
+		s, group := streams[i], groups[i]
+		c := db.createConsumer(key, group, opt.consumer, now)
+		c.SeenTime = now
+		raw := args[opt.idIndex+i]
+		if string(raw) != ">" {
+			id, _ := parseStreamID(raw, 0)
+			result = append(result, makeStreamReply(key, readConsumerHistory(db, key, s, group, c, id, opt.count, now)))
+			continue
+		}
+
+		start, ok := group.LastID.Next()
+		if !ok {
+			continue
+		}
+		entries := s.Range(start, stream.MaxID, opt.count, false)
+		if len(entries) == 0 {
+			continue
+		}
+		c.ActiveTime = now
+		for _, entry := range entries {
+			s.MarkRead(group, entry.ID)
+			if !opt.noAck {
+				pe := group.Assign(entry.ID, c, now, 1)
+				db.addAof(makeXClaimCmd(key, group, pe))
+			}
+		}
+		db.addAof(makeXGroupSetIDCmd(key, group))
+		result = append(result, makeStreamReply(key, makeEntriesReply(entries)))
+	}
+	if len(result) == 0 {
+		return protocol.MakeNullMultiBulkReply()
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+// readConsumerHistory 重新投递消费者ID大于after的待确认消息, 已经被删除的消息返回[id, nil]
+func readConsumerHistory(db *DB, key string, s *stream.Stream, group *stream.Group, c *stream.Consumer,
+	after stream.ID, count int, now time.Time) redis.Reply {
+	start, ok := after.Next()
+	if !ok {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	pending := make([]*stream.PendingEntry, 0)
+	c.RangePending(start, func(pe *stream.PendingEntry) bool {
+		pending = append(pending, pe)
+		return count <= 0 || len(pending) < count
+	})
+	result := make([]redis.Reply, len(pending))
+	for i, pe := range pending {
+		fields, ok := s.Get(pe.ID)
+		if !ok {
+			result[i] = protocol.MakeMultiRawReply([]redis.Reply{makeIDReply(pe.ID), protocol.MakeNullMultiBulkReply()})
+			continue
+		}
+		pe.DeliveryTime = now
+		pe.DeliveryCount++
+		db.addAof(makeXClaimCmd(key, group, pe))
+		result[i] = makeEntryReply(&stream.Entry{ID: pe.ID, Fields: fields})
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+// execXAck XACK key group id [id ...]
+func execXAck(db *DB, args [][]byte) redis.Reply {
+	ids := make([]stream.ID, len(args)-2)
+	for i, arg := range args[2:] {
+		id, errReply := parseStreamID(arg, 0)
+		if errReply != nil {
+			return errReply
+		}
+		ids[i] = id
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeIntReply(0)
+	}
+	group := s.Group(string(args[1]))
+	if group == nil {
+		return protocol.MakeIntReply(0)
+	}
+	acked := 0
+	for _, id := range ids {
+		if group.Ack(id) {
+			acked++
+		}
+	}
+	return protocol.MakeIntReply(int64(acked))
+}
+
+// execXPending XPENDING key group [[IDLE min-idle-time] start end count [consumer]]
+func execXPending(db *DB, args [][]byte) redis.Reply {
+	key, groupName := string(args[0]), string(args[1])
+	if len(args) == 2 {
+		_, group, errReply := db.getStreamGroup(key, groupName)
+		if errReply != nil {
+			return errReply
+		}
+		return xpendingSummary(group)
+	}
+
+	i := 2
+	minIdle := int64(0)
+	if strings.ToUpper(string(args[i])) == "IDLE" {
+		if len(args) < 4 {
+			return protocol.MakeSyntaxErrReply()
+		}
+		n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil {
+			return protocol.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		minIdle = n
+		i += 2
+	}
+	if len(args)-i != 3 && len(args)-i != 4 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	start, errReply := parseRangeID(args[i], true)
+	if errReply != nil {
+		return errReply
+	}
+	end, errReply := parseRangeID(args[i+1], false)
+	if errReply != nil {
+		return errReply
+	}
+	count, err := strconv.ParseInt(string(args[i+2]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	_, group, errReply := db.getStreamGroup(key, groupName)
+	if errReply != nil {
+		return errReply
+	}
+	rangePending := group.RangePending
+	if len(args)-i == 4 {
+		c := group.Consumer(string(args[i+3]))
+		if c == nil {
+			return protocol.MakeEmptyMultiBulkReply()
+		}
+		rangePending = c.RangePending
+	}
+
+	now := time.Now()
+	result := make([]redis.Reply, 0)
+	if count <= 0 || end.Less(start) {
+		return protocol.MakeMultiRawReply(result)
+	}
+	rangePending(start, func(pe *stream.PendingEntry) bool {
+		if end.Less(pe.ID) {
+			return false
+		}
+		idle := now.Sub(pe.DeliveryTime).Milliseconds()
+		if idle < minIdle {
+			return true
+		}
+		result = append(result, protocol.MakeMultiRawReply([]redis.Reply{
+			makeIDReply(pe.ID),
+			protocol.MakeBulkReply([]byte(pe.Consumer.Name)),
+			protocol.MakeIntReply(idle),
+			protocol.MakeIntReply(pe.DeliveryCount),
+		}))
+		return int64(len(result)) < count
+	})
+	return protocol.MakeMultiRawReply(result)
+}
+
+// xpendingSummary 返回[待确认数量, 最小ID, 最大ID, [[消费者, 待确认数量] ...]]
+func xpendingSummary(group *stream.Group) redis.Reply {
+	first, last, ok := group.PendingBounds()
+	if !ok {
+		return protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeIntReply(0),
+			protocol.MakeNullBulkReply(),
+			protocol.MakeNullBulkReply(),
+			protocol.MakeNullMultiBulkReply(),
+		})
+	}
+	consumers := make([]redis.Reply, 0)
+	for _, c := range group.Consumers() {
+		if c.PendingLen() == 0 {
+			continue
+		}
+		consumers = append(consumers, protocol.MakeMultiBulkReply([][]byte{
+			[]byte(c.Name),
+			[]byte(strconv.Itoa(c.PendingLen())),
+		}))
+	}
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeIntReply(int64(group.PendingLen())),
+		makeIDReply(first),
+		makeIDReply(last),
+		protocol.MakeMultiRawReply(consumers),
+	})
+}
+
+// parseMinIdle 解析最小空闲时间, 负数视为0
+func parseMinIdle(raw []byte, cmdName string) (time.Duration, protocol.ErrorReply) {
+	n, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, protocol.MakeErrReply("ERR Invalid min-idle-time argument for " + cmdName)
+	}
+	return time.Duration(min(max(n, 0), math.MaxInt64/int64(time.Millisecond))) * time.Millisecond, nil
+}
+
+// execXClaim XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-milliseconds]
+// [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid]
+func execXClaim(db *DB, args [][]byte) redis.Reply {
+	key, groupName, consumerName := string(args[0]), string(args[1]), string(args[2])
+	minIdle, errReply := parseMinIdle(args[3], "XCLAIM")
+	if errReply != nil {
+		return errReply
+	}
+	// ID一直持续到第一个不是ID的参数
+	ids := make([]stream.ID, 0)
+	i := 4
+	for ; i < len(args); i++ {
+		id, err := stream.ParseID(string(args[i]), 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+
+	now := time.Now()
+	deliveryTime := now
+	retryCount := int64(-1)
+	force, justID := false, false
+	var lastID *stream.ID
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		hasNext := i+1 < len(args)
+		switch {
+		case option == "FORCE":
+			force = true
+		case option == "JUSTID":
+			justID = true
+		case (option == "IDLE" || option == "TIME" || option == "RETRYCOUNT") && hasNext:
+			i++
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				return protocol.MakeErrReply("ERR Invalid " + option + " option argument for XCLAIM")
+			}
+			switch option {
+			case "IDLE":
+				deliveryTime = now.Add(-time.Duration(min(max(n, 0), math.MaxInt64/int64(time.Millisecond))) * time.Millisecond)
+			case "TIME":
+				deliveryTime = time.UnixMilli(n)
+			default:
+				retryCount = n
+			}
+		case option == "LASTID" && hasNext:
+			i++
+			id, errReply := parseStreamID(args[i], 0)
+			if errReply != nil {
+				return errReply
+			}
+			lastID = &id
+		default:
+			return protocol.MakeErrReply("ERR Unrecognized XCLAIM option '" + string(args[i]) + "'")
+		}
+	}
+	if deliveryTime.After(now) || deliveryTime.Before(time.UnixMilli(0)) {
+		deliveryTime = now
+	}
+
+	s, group, errReply := db.getStreamGroup(key, groupName)
+	if errReply != nil {
+		return errReply
+	}
+	if lastID != nil && group.LastID.Less(*lastID) {
+		group.LastID = *lastID
+		db.addAof(makeXGroupSetIDCmd(key, group))
+	}
+	c := db.createConsumer(key, group, consumerName, now)
+	c.SeenTime = now
+
+	result := make([]redis.Reply, 0, len(ids))
+	for _, id := range ids {
+		pe := group.Pending(id)
+		fields, exists := s.Get(id)
+		if pe == nil {
+			if !force || !exists {
+				continue
+			}
+		} else {
+			if !exists {
+				// 消息已经被删除, 从待确认列表中移除
+				group.Ack(id)
+				db.addAof(utils.ToCmdLine("XACK", key, groupName, id.String()))
+				continue
+			}
+			if now.Sub(pe.DeliveryTime) < minIdle {
+				continue
+			}
+		}
+		deliveryCount := int64(0)
+		if pe != nil {
+			deliveryCount = pe.DeliveryCount
+		}
+		if retryCount >= 0 {
+			deliveryCount = retryCount
+		} else if !justID {
+			deliveryCount++
+		}
+		pe = group.Assign(id, c, deliveryTime, deliveryCount)
+		c.ActiveTime = now
+		db.addAof(makeXClaimCmd(key, group, pe))
+		if justID {
+			result = append(result, makeIDReply(id))
+		} else {
+			result = append(result, makeEntryReply(&stream.Entry{ID: id, Fields: fields}))
+		}
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+// execXAutoClaim XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]
+// 返回[下一次扫描的起点, 认领的消息, 已经被删除的消息ID]
+func execXAutoClaim(db *DB, args [][]byte) redis.Reply {
+	key, groupName, consumerName := string(args[0]), string(args[1]), string(args[2])
+	minIdle, errReply := parseMinIdle(args[3], "XAUTOCLAIM")
+	if errReply != nil {
+		return errReply
+	}
+	start, errReply := parseRangeID(args[4], true)
+	if errReply != nil {
+		return errReply
+	}
+	count := 100
+	justID := false
+	for i := 5; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch {
+		case option == "JUSTID":
+			justID = true
+		case option == "COUNT" && i+1 < len(args):
+			i++
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				return protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if n <= 0 || n > math.MaxInt32/10 {
+				return protocol.MakeErrReply("ERR COUNT must be > 0")
+			}
+			count = int(n)
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+
+	s, group, errReply := db.getStreamGroup(key, groupName)
+	if errReply != nil {
+		return errReply
+	}
+	now := time.Now()
+	c := db.createConsumer(key, group, consumerName, now)
+	c.SeenTime = now
+
+	// 最多检查count*10条待确认消息, 遍历结束后再修改待确认列表
+	attempts := count * 10
+	claimable := make([]*stream.PendingEntry, 0)
+	deleted := make([]stream.ID, 0)
+	cursor := stream.MinID
+	group.RangePending(start, func(pe *stream.PendingEntry) bool {
+		if attempts == 0 || len(claimable) == count {
+			cursor = pe.ID
+			return false
+		}
+		attempts--
+		if _, exists := s.Get(pe.ID); !exists {
+			deleted = append(deleted, pe.ID)
+		} else if now.Sub(pe.DeliveryTime) >= minIdle {
+			claimable = append(claimable, pe)
+		}
+		return true
+	})
+
+	claimed := make([]redis.Reply, len(claimable))
+	for i, pe := range claimable {
+		deliveryCount := pe.DeliveryCount
+		if !justID {
+			deliveryCount++
+		}
+		pe = group.Assign(pe.ID, c, now, deliveryCount)
+		c.ActiveTime = now
+		db.addAof(makeXClaimCmd(key, group, pe))
+		if justID {
+			claimed[i] = makeIDReply(pe.ID)
+		} else {
+			fields, _ := s.Get(pe.ID)
+			claimed[i] = makeEntryReply(&stream.Entry{ID: pe.ID, Fields: fields})
+		}
+	}
+	deletedReplies := make([]redis.Reply, len(deleted))
+	for i, id := range deleted {
+		group.Ack(id)
+		db.addAof(utils.ToCmdLine("XACK", key, groupName, id.String()))
+		deletedReplies[i] = makeIDReply(id)
+	}
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		makeIDReply(cursor),
+		protocol.MakeMultiRawReply(claimed),
+		protocol.MakeMultiRawReply(deletedReplies),
+	})
+}
+
+func init() {
+	registerCommand("XGroup", execXGroup, prepareSubCommandKey(true), undoXGroup, -2, flagWrite)
+	registerCommand("XReadGroup", execXReadGroup, prepareXReadGroup, undoXReadGroup, -7, flagWrite|flagCustomAof).
+		attachBlocking(blockingXRead(true))
+	registerCommand("XAck", execXAck, writeFirstKey, rollbackFirstKey, -4, flagWrite)
+	registerCommand("XPending", execXPending, readFirstKey, nil, -3, flagReadOnly)
+	registerCommand("XClaim", execXClaim, writeFirstKey, rollbackFirstKey, -6, flagWrite|flagCustomAof)
+	registerCommand("XAutoClaim", execXAutoClaim, writeFirstKey, rollbackFirstKey, -6, flagWrite|flagCustomAof)
+}
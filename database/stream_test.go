@@ -0,0 +1,162 @@
+package database
+
+import (
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"goredis/redis/protocol"
+	"testing"
+)
+
+func TestXAddAndRange(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xadd", "s", "1-1", "a", "1")), "$3\r\n1-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xadd", "s", "1-*", "b", "2")), "$3\r\n1-2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xadd", "s", "2", "c", "3")), "$3\r\n2-0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xadd", "s", "1-5", "d", "4")),
+		"-ERR The ID specified in XADD is equal or smaller than the target stream top item\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xadd", "n", "0-0", "d", "4")), "-ERR The ID specified in XADD must be greater than 0-0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xadd", "n", "NOMKSTREAM", "*", "d", "4")), "$-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xadd", "s", "*", "d")), "-ERR wrong number of arguments for 'xadd' command\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xlen", "s")), ":3\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("type", "s")), "+stream\r\n")
+
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xrange", "s", "-", "+", "COUNT", "2")),
+		"*2\r\n*2\r\n$3\r\n1-1\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n*2\r\n$3\r\n1-2\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xrange", "s", "(1-1", "1")),
+		"*1\r\n*2\r\n$3\r\n1-2\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xrevrange", "s", "+", "-", "COUNT", "1")),
+		"*1\r\n*2\r\n$3\r\n2-0\r\n*2\r\n$1\r\nc\r\n$1\r\n3\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xrange", "s", "x", "+")), "-ERR Invalid stream ID specified as stream command argument\r\n")
+
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xdel", "s", "1-2", "9-9")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xadd", "s", "MAXLEN", "=", "1", "3-0", "e", "5")), "$3\r\n3-0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xlen", "s")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xtrim", "s", "MAXLEN", "1", "LIMIT", "10")),
+		"-ERR syntax error, LIMIT cannot be used without the special ~ option\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xtrim", "s", "MINID", "~", "4", "LIMIT", "10")), ":1\r\n")
+	// stream为空时也不会删除key, 也不会生成更小的ID
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xlen", "s")), ":0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xadd", "s", "3-0", "f", "6")),
+		"-ERR The ID specified in XADD is equal or smaller than the target stream top item\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xsetid", "s", "5-0", "MAXDELETEDID", "1-2")), "+OK\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xadd", "s", "5-*", "f", "6")), "$3\r\n5-1\r\n")
+}
+
+func TestXRead(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("xadd", "a", "1-1", "f", "v"))
+	db.Exec(c, utils.ToCmdLine("xadd", "b", "2-1", "f", "v"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xread", "COUNT", "1", "STREAMS", "a", "b", "0", "2-1")),
+		"*1\r\n*2\r\n$1\r\na\r\n*1\r\n*2\r\n$3\r\n1-1\r\n*2\r\n$1\r\nf\r\n$1\r\nv\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xread", "STREAMS", "a", "b", "$", "+")),
+		"*1\r\n*2\r\n$1\r\nb\r\n*1\r\n*2\r\n$3\r\n2-1\r\n*2\r\n$1\r\nf\r\n$1\r\nv\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xread", "STREAMS", "a", "1-1")), "*-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xread", "STREAMS", "a", "b", "0")),
+		"-ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xread", "STREAMS", "a", ">")),
+		"-ERR The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.\r\n")
+}
+
+func TestXReadGroup(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xgroup", "create", "s", "g", "$")),
+		"-ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xgroup", "create", "s", "g", "$", "MKSTREAM")), "+OK\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xgroup", "create", "s", "g", "0")), "-BUSYGROUP Consumer Group name already exists\r\n")
+	for _, id := range []string{"1-0", "2-0", "3-0"} {
+		db.Exec(c, utils.ToCmdLine("xadd", "s", id, "f", id))
+	}
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xreadgroup", "GROUP", "g", "alice", "COUNT", "2", "STREAMS", "s", ">")),
+		"*1\r\n*2\r\n$1\r\ns\r\n*2\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\nf\r\n$3\r\n1-0\r\n*2\r\n$3\r\n2-0\r\n*2\r\n$1\r\nf\r\n$3\r\n2-0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xreadgroup", "GROUP", "g", "bob", "STREAMS", "s", ">")),
+		"*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n3-0\r\n*2\r\n$1\r\nf\r\n$3\r\n3-0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xreadgroup", "GROUP", "g", "bob", "STREAMS", "s", ">")), "*-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xreadgroup", "GROUP", "none", "bob", "STREAMS", "s", ">")),
+		"-NOGROUP No such key 's' or consumer group 'none' in XREADGROUP with GROUP option\r\n")
+
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xpending", "s", "g")),
+		"*4\r\n:3\r\n$3\r\n1-0\r\n$3\r\n3-0\r\n*2\r\n*2\r\n$5\r\nalice\r\n$1\r\n2\r\n*2\r\n$3\r\nbob\r\n$1\r\n1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xack", "s", "g", "1-0", "1-0")), ":1\r\n")
+	// 读取alice的历史消息会增加投递次数
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xreadgroup", "GROUP", "g", "alice", "STREAMS", "s", "0")),
+		"*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n2-0\r\n*2\r\n$1\r\nf\r\n$3\r\n2-0\r\n")
+	reply := db.Exec(c, utils.ToCmdLine("xpending", "s", "g", "-", "+", "10", "alice")).(*protocol.MultiRawReply)
+	if len(reply.Replies) != 1 || string(reply.Replies[0].(*protocol.MultiRawReply).Replies[3].ToBytes()) != ":2\r\n" {
+		t.Errorf("wrong pending entries %q", reply.ToBytes())
+	}
+
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xclaim", "s", "g", "carol", "0", "2-0", "3-0", "JUSTID")),
+		"*2\r\n$3\r\n2-0\r\n$3\r\n3-0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xclaim", "s", "g", "carol", "3600000", "2-0")), "*0\r\n")
+	db.Exec(c, utils.ToCmdLine("xdel", "s", "3-0"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xautoclaim", "s", "g", "dave", "0", "0", "COUNT", "1", "JUSTID")),
+		"*3\r\n$3\r\n3-0\r\n*1\r\n$3\r\n2-0\r\n*0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xautoclaim", "s", "g", "dave", "0", "3-0")),
+		"*3\r\n$3\r\n0-0\r\n*0\r\n*1\r\n$3\r\n3-0\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xpending", "s", "g")),
+		"*4\r\n:1\r\n$3\r\n2-0\r\n$3\r\n2-0\r\n*1\r\n*2\r\n$4\r\ndave\r\n$1\r\n1\r\n")
+
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xgroup", "delconsumer", "s", "g", "dave")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xgroup", "createconsumer", "s", "g", "dave")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xgroup", "setid", "s", "g", "0", "ENTRIESREAD", "0")), "+OK\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xgroup", "destroy", "s", "g")), ":1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xgroup", "destroy", "s", "g")), ":0\r\n")
+}
+
+func TestXInfo(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("xadd", "s", "1-0", "f", "v"))
+	db.Exec(c, utils.ToCmdLine("xadd", "s", "2-0", "f", "v"))
+	db.Exec(c, utils.ToCmdLine("xgroup", "create", "s", "g", "0"))
+	db.Exec(c, utils.ToCmdLine("xreadgroup", "GROUP", "g", "alice", "COUNT", "1", "STREAMS", "s", ">"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xinfo", "groups", "s")),
+		"*1\r\n*12\r\n$4\r\nname\r\n$1\r\ng\r\n$9\r\nconsumers\r\n:1\r\n$7\r\npending\r\n:1\r\n"+
+			"$17\r\nlast-delivered-id\r\n$3\r\n1-0\r\n$12\r\nentries-read\r\n:1\r\n$3\r\nlag\r\n:1\r\n")
+	db.Exec(c, utils.ToCmdLine("xdel", "s", "2-0"))
+	reply := db.Exec(c, utils.ToCmdLine("xinfo", "groups", "s")).(*protocol.MultiRawReply)
+	group := reply.Replies[0].(*protocol.MapReply)
+	assertReply(t, group.Pairs[11], "$-1\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xinfo", "stream", "none")), "-ERR no such key\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("xinfo", "consumers", "s", "none")), "-NOGROUP No such consumer group 'none' for key name 's'\r\n")
+}
+
+func TestStreamUndo(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("xadd", "s", "1-0", "f", "v"))
+	db.Exec(c, utils.ToCmdLine("xadd", "s", "2-0", "f", "v"))
+	db.Exec(c, utils.ToCmdLine("xdel", "s", "1-0"))
+	db.Exec(c, utils.ToCmdLine("xgroup", "create", "s", "g", "0"))
+	db.Exec(c, utils.ToCmdLine("xgroup", "create", "s", "idle", "$"))
+	db.Exec(c, utils.ToCmdLine("xgroup", "createconsumer", "s", "idle", "bob"))
+	db.Exec(c, utils.ToCmdLine("xreadgroup", "GROUP", "g", "alice", "STREAMS", "s", ">"))
+	// 消费者的活跃时间无法通过命令恢复, 只比较其余的状态
+	snapshot := func() string {
+		return string(db.Exec(c, utils.ToCmdLine("xinfo", "stream", "s")).ToBytes()) +
+			string(db.Exec(c, utils.ToCmdLine("xinfo", "groups", "s")).ToBytes()) +
+			string(db.Exec(c, utils.ToCmdLine("xpending", "s", "g")).ToBytes()) +
+			string(db.Exec(c, utils.ToCmdLine("xpending", "s", "idle")).ToBytes())
+	}
+	before := snapshot()
+
+	for _, cmdLine := range [][][]byte{
+		utils.ToCmdLine("xadd", "s", "MAXLEN", "0", "*", "f", "v"),
+		utils.ToCmdLine("xack", "s", "g", "2-0"),
+		utils.ToCmdLine("xgroup", "destroy", "s", "idle"),
+		utils.ToCmdLine("xreadgroup", "GROUP", "g", "alice", "STREAMS", "s", "0"),
+		utils.ToCmdLine("xclaim", "s", "g", "carol", "0", "2-0"),
+	} {
+		undoLogs := db.GetUndoLogs(cmdLine)
+		db.Exec(c, cmdLine)
+		for _, undo := range undoLogs {
+			db.Exec(c, undo)
+		}
+		if after := snapshot(); after != before {
+			t.Errorf("%s: stream is different after undo\nbefore: %q\nafter:  %q", cmdLine[0], before, after)
+		}
+	}
+}
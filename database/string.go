@@ -0,0 +1,685 @@
+package database
+
+import (
+	"goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/lib/utils"
+	"goredis/redis/protocol"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxStringSize 与redis的proto-max-bulk-len默认值保持一致
+const maxStringSize = 512 << 20
+
+// getAsString 返回key对应的字符串, key不存在时返回nil.
+// 返回的切片指向保存的值, SETBIT, APPEND等命令会原地修改它, 放入回复之前需要复制, 见bulkCopy
+func (db *DB) getAsString(key string) ([]byte, protocol.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return nil, nil
+	}
+	bytes, ok := entity.Data.([]byte)
+	if !ok {
+		return nil, &protocol.WrongTypeErrReply{}
+	}
+	return bytes, nil
+}
+
+// execGet GET key
+func execGet(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	bytes, err := db.getAsString(key)
+	if err != nil {
+		return err
+	}
+	if bytes == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	return bulkCopy(bytes)
+}
+
+const (
+	upsertPolicy = iota // 默认
+	insertPolicy        // set nx
+	updatePolicy        // set xx
+)
+
+// parseExpireTime 将EX/PX/EXAT/PXAT的参数转换为绝对时间
+func parseExpireTime(cmdName, unit string, raw []byte) (time.Time, redis.Reply) {
+	value, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return time.Time{}, protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if value <= 0 {
+		return time.Time{}, protocol.MakeErrReply("ERR invalid expire time in '" + cmdName + "' command")
+	}
+	switch unit {
+	case "EX":
+		if value > math.MaxInt64/int64(time.Second) {
+			return time.Time{}, protocol.MakeErrReply("ERR invalid expire time in '" + cmdName + "' command")
+		}
+		return time.Now().Add(time.Duration(value) * time.Second), nil
+	case "PX":
+		if value > math.MaxInt64/int64(time.Millisecond) {
+			return time.Time{}, protocol.MakeErrReply("ERR invalid expire time in '" + cmdName + "' command")
+		}
+		return time.Now().Add(time.Duration(value) * time.Millisecond), nil
+	case "EXAT":
+		return time.Unix(value, 0), nil
+	default: // PXAT
+		return time.UnixMilli(value), nil
+	}
+}
+
+// execSet SET key value [NX|XX] [GET] [EX seconds|PX milliseconds|EXAT timestamp|PXAT timestamp|KEEPTTL]
+func execSet(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	value := args[1]
+	policy := upsertPolicy
+	returnOld, keepTTL, hasTTL := false, false, false
+	var expireAt time.Time
+
+	for i := 2; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch arg {
+		case "NX":
+			if policy == updatePolicy {
+				return protocol.MakeSyntaxErrReply()
+			}
+			policy = insertPolicy
+		case "XX":
+			if policy == insertPolicy {
+				return protocol.MakeSyntaxErrReply()
+			}
+			policy = updatePolicy
+		case "GET":
+			returnOld = true
+		case "KEEPTTL":
+			if hasTTL {
+				return protocol.MakeSyntaxErrReply()
+			}
+			keepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if hasTTL || keepTTL || i+1 >= len(args) {
+				return protocol.MakeSyntaxErrReply()
+			}
+			var errReply redis.Reply
+			expireAt, errReply = parseExpireTime("set", arg, args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			hasTTL = true
+			i++
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+
+	var old []byte
+	if returnOld {
+		var errReply protocol.ErrorReply
+		old, errReply = db.getAsString(key)
+		if errReply != nil {
+			return errReply
+		}
+		old = slices.Clone(old)
+	}
+
+	_, exists := db.GetEntity(key)
+	if (policy == insertPolicy && exists) || (policy == updatePolicy && !exists) {
+		if returnOld {
+			return bulkOrNull(old)
+		}
+		return protocol.MakeNullBulkReply()
+	}
+
+	db.PutEntity(key, &database.DataEntity{Data: value})
+	db.notify(notifyString, "set", key)
+	switch {
+	case hasTTL:
+		db.Expire(key, expireAt)
+		db.notify(notifyGeneric, "expire", key)
+		db.addAof(utils.ToCmdLine3("SET", args[0], value))
+		db.addAof(MakeExpireCmd(key, expireAt))
+	case keepTTL:
+		db.addAof(utils.ToCmdLine3("SET", args[0], value, []byte("KEEPTTL")))
+	default:
+		db.Persist(key)
+		db.addAof(utils.ToCmdLine3("SET", args[0], value))
+	}
+
+	if returnOld {
+		return bulkOrNull(old)
+	}
+	return protocol.MakeOkReply()
+}
+
+// bulkCopy 复制保存的值后放入回复, 回复在释放锁之后才写给客户端, 不能引用之后可能被原地修改的数组
+func bulkCopy(value []byte) redis.Reply {
+	return protocol.MakeBulkReply(slices.Clone(value))
+}
+
+func bulkOrNull(value []byte) redis.Reply {
+	if value == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	return protocol.MakeBulkReply(value)
+}
+
+// execSetNX SETNX key value
+func execSetNX(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	if _, exists := db.GetEntity(key); exists {
+		return protocol.MakeIntReply(0)
+	}
+	db.PutEntity(key, &database.DataEntity{Data: args[1]})
+	db.notify(notifyString, "set", key)
+	return protocol.MakeIntReply(1)
+}
+
+// execSetEX SETEX key seconds value
+func execSetEX(db *DB, args [][]byte) redis.Reply {
+	return setWithTTL(db, "setex", "EX", args)
+}
+
+// execPSetEX PSETEX key milliseconds value
+func execPSetEX(db *DB, args [][]byte) redis.Reply {
+	return setWithTTL(db, "psetex", "PX", args)
+}
+
+func setWithTTL(db *DB, cmdName, unit string, args [][]byte) redis.Reply {
+	key := string(args[0])
+	value := args[2]
+	expireAt, errReply := parseExpireTime(cmdName, unit, args[1])
+	if errReply != nil {
+		return errReply
+	}
+	db.PutEntity(key, &database.DataEntity{Data: value})
+	db.Expire(key, expireAt)
+	db.notify(notifyString, "set", key)
+	db.notify(notifyGeneric, "expire", key)
+	db.addAof(utils.ToCmdLine3("SET", args[0], value))
+	db.addAof(MakeExpireCmd(key, expireAt))
+	return protocol.MakeOkReply()
+}
+
+// execGetEX GETEX key [EX seconds|PX milliseconds|EXAT timestamp|PXAT timestamp|PERSIST]
+func execGetEX(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	persist, hasTTL := false, false
+	var expireAt time.Time
+	for i := 1; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch arg {
+		case "PERSIST":
+			if hasTTL {
+				return protocol.MakeSyntaxErrReply()
+			}
+			persist = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if hasTTL || persist || i+1 >= len(args) {
+				return protocol.MakeSyntaxErrReply()
+			}
+			var errReply redis.Reply
+			expireAt, errReply = parseExpireTime("getex", arg, args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			hasTTL = true
+			i++
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if bytes == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	if hasTTL {
+		db.Expire(key, expireAt)
+		db.notify(notifyGeneric, "expire", key)
+		db.addAof(MakeExpireCmd(key, expireAt))
+	} else if persist {
+		db.Persist(key)
+		db.notify(notifyGeneric, "persist", key)
+		db.addAof(utils.ToCmdLine3("PERSIST", args[0]))
+	}
+	return bulkCopy(bytes)
+}
+
+// execGetDel GETDEL key
+func execGetDel(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if bytes == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	db.Remove(key)
+	db.notify(notifyGeneric, "del", key)
+	return protocol.MakeBulkReply(bytes)
+}
+
+// execMGet MGET key [key...], 不存在的key和非字符串类型的key返回nil
+func execMGet(db *DB, args [][]byte) redis.Reply {
+	result := make([][]byte, len(args))
+	for i, arg := range args {
+		bytes, errReply := db.getAsString(string(arg))
+		if errReply != nil {
+			continue
+		}
+		result[i] = slices.Clone(bytes)
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+func prepareMSet(args [][]byte) ([]string, []string) {
+	size := len(args) / 2
+	keys := make([]string, size)
+	for i := 0; i < size; i++ {
+		keys[i] = string(args[2*i])
+	}
+	return keys, nil
+}
+
+func undoMSet(db *DB, args [][]byte) []CmdLine {
+	writeKeys, _ := prepareMSet(args)
+	return rollbackGivenKeys(db, writeKeys...)
+}
+
+// execMSet MSET key value [key value...]
+func execMSet(db *DB, args [][]byte) redis.Reply {
+	if len(args)%2 != 0 {
+		return protocol.MakeArgNumErrReply("mset")
+	}
+	for i := 0; i < len(args); i += 2 {
+		key := string(args[i])
+		db.PutEntity(key, &database.DataEntity{Data: args[i+1]})
+		db.Persist(key)
+		db.notify(notifyString, "set", key)
+	}
+	return protocol.MakeOkReply()
+}
+
+// execMSetNX MSETNX key value [key value...], 只有所有key都不存在时才设置
+func execMSetNX(db *DB, args [][]byte) redis.Reply {
+	if len(args)%2 != 0 {
+		return protocol.MakeArgNumErrReply("msetnx")
+	}
+	for i := 0; i < len(args); i += 2 {
+		if _, exists := db.GetEntity(string(args[i])); exists {
+			return protocol.MakeIntReply(0)
+		}
+	}
+	for i := 0; i < len(args); i += 2 {
+		db.PutEntity(string(args[i]), &database.DataEntity{Data: args[i+1]})
+		db.notify(notifyString, "set", string(args[i]))
+	}
+	return protocol.MakeIntReply(1)
+}
+
+// incrBy 将key的整数值加上delta, 保留key的过期时间
+func incrBy(db *DB, key string, delta int64) redis.Reply {
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	var value int64
+	if bytes != nil {
+		var err error
+		value, err = strconv.ParseInt(string(bytes), 10, 64)
+		if err != nil {
+			return protocol.MakeErrReply("ERR value is not an integer or out of range")
+		}
+	}
+	if (delta > 0 && value > math.MaxInt64-delta) || (delta < 0 && value < math.MinInt64-delta) {
+		return protocol.MakeErrReply("ERR increment or decrement would overflow")
+	}
+	value += delta
+	db.PutEntity(key, &database.DataEntity{Data: []byte(strconv.FormatInt(value, 10))})
+	db.notify(notifyString, "incrby", key)
+	return protocol.MakeIntReply(value)
+}
+
+func parseDelta(raw []byte) (int64, redis.Reply) {
+	delta, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	return delta, nil
+}
+
+// execIncr INCR key
+func execIncr(db *DB, args [][]byte) redis.Reply {
+	return incrBy(db, string(args[0]), 1)
+}
+
+// execIncrBy INCRBY key increment
+func execIncrBy(db *DB, args [][]byte) redis.Reply {
+	delta, errReply := parseDelta(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	return incrBy(db, string(args[0]), delta)
+}
+
+// execDecr DECR key
+func execDecr(db *DB, args [][]byte) redis.Reply {
+	return incrBy(db, string(args[0]), -1)
+}
+
+// execDecrBy DECRBY key decrement
+func execDecrBy(db *DB, args [][]byte) redis.Reply {
+	delta, errReply := parseDelta(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	if delta == math.MinInt64 {
+		return protocol.MakeErrReply("ERR decrement would overflow")
+	}
+	return incrBy(db, string(args[0]), -delta)
+}
+
+// execIncrByFloat INCRBYFLOAT key increment
+func execIncrByFloat(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	delta, err := strconv.ParseFloat(string(args[1]), 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not a valid float")
+	}
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	var value float64
+	if bytes != nil {
+		value, err = strconv.ParseFloat(string(bytes), 64)
+		if err != nil {
+			return protocol.MakeErrReply("ERR value is not a valid float")
+		}
+	}
+	value += delta
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return protocol.MakeErrReply("ERR increment would produce NaN or Infinity")
+	}
+	result := []byte(strconv.FormatFloat(value, 'f', -1, 64))
+	db.PutEntity(key, &database.DataEntity{Data: result})
+	db.notify(notifyString, "incrbyfloat", key)
+	return protocol.MakeBulkReply(result)
+}
+
+// execAppend APPEND key value
+func execAppend(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if len(bytes)+len(args[1]) > maxStringSize {
+		return protocol.MakeErrReply("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	}
+	// 原地追加, 容量不够时由append按倍数扩容, 连续的APPEND均摊只需要O(1)次复制.
+	// append只写入原长度之后的部分, 之前返回给客户端的值不受影响
+	value := append(bytes, args[1]...)
+	db.PutEntity(key, &database.DataEntity{Data: value})
+	db.notify(notifyString, "append", key)
+	return protocol.MakeIntReply(int64(len(value)))
+}
+
+// execStrLen STRLEN key
+func execStrLen(db *DB, args [][]byte) redis.Reply {
+	bytes, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return protocol.MakeIntReply(int64(len(bytes)))
+}
+
+// execGetRange GETRANGE key start end, 与redis一样支持负数下标, 越界时截断
+func execGetRange(db *DB, args [][]byte) redis.Reply {
+	start, err1 := strconv.ParseInt(string(args[1]), 10, 64)
+	end, err2 := strconv.ParseInt(string(args[2]), 10, 64)
+	if err1 != nil || err2 != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	bytes, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	size := int64(len(bytes))
+	if start < 0 && end < 0 && start > end {
+		return protocol.MakeBulkReply([]byte{})
+	}
+	if start < 0 {
+		start = max(size+start, 0)
+	}
+	if end < 0 {
+		end = max(size+end, 0)
+	}
+	end = min(end, size-1)
+	if start > end || size == 0 {
+		return protocol.MakeBulkReply([]byte{})
+	}
+	return bulkCopy(bytes[start : end+1])
+}
+
+// execSetRange SETRANGE key offset value, 超出原字符串长度的部分用0填充
+func execSetRange(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	offset, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if offset < 0 {
+		return protocol.MakeErrReply("ERR offset is out of range")
+	}
+	value := args[2]
+	if offset+int64(len(value)) > maxStringSize {
+		return protocol.MakeErrReply("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	}
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if len(value) == 0 {
+		// 不会创建key, 也不会修改已有的值
+		return protocol.MakeIntReply(int64(len(bytes)))
+	}
+	size := max(int64(len(bytes)), offset+int64(len(value)))
+	// 与SETBIT一样原地修改, 需要扩展时按照append的策略扩容, 扩展出来的部分用0填充
+	result := bytes
+	if n := int64(len(bytes)); size > n {
+		result = slices.Grow(bytes, int(size-n))[:size]
+		clear(result[n:])
+	}
+	copy(result[offset:], value)
+	db.PutEntity(key, &database.DataEntity{Data: result})
+	db.notify(notifyString, "setrange", key)
+	return protocol.MakeIntReply(size)
+}
+
+func prepareLCS(args [][]byte) ([]string, []string) {
+	return nil, []string{string(args[0]), string(args[1])}
+}
+
+// lcsMaxTableBytes LCS动态规划表的内存上限, 与redis的proto-max-bulk-len默认值相同
+const lcsMaxTableBytes = 512 << 20
+
+// lcsLength 只需要长度时按行滚动计算, 内存与较短的字符串成正比
+func lcsLength(a, b []byte) uint32 {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	prev := make([]uint32, len(b)+1)
+	cur := make([]uint32, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				cur[j] = prev[j-1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j-1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// execLCS LCS key1 key2 [LEN] [IDX] [MINMATCHLEN len] [WITHMATCHLEN]
+func execLCS(db *DB, args [][]byte) redis.Reply {
+	getLen, getIdx, withMatchLen := false, false, false
+	var minMatchLen int64
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "LEN":
+			getLen = true
+		case "IDX":
+			getIdx = true
+		case "WITHMATCHLEN":
+			withMatchLen = true
+		case "MINMATCHLEN":
+			if i+1 >= len(args) {
+				return protocol.MakeSyntaxErrReply()
+			}
+			var err error
+			minMatchLen, err = strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			minMatchLen = max(minMatchLen, 0)
+			i++
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+	if getLen && getIdx {
+		return protocol.MakeErrReply("ERR If you want both the length and indexes, please just use IDX.")
+	}
+	a, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	b, errReply := db.getAsString(string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+
+	if getLen {
+		return protocol.MakeIntReply(int64(lcsLength(a, b)))
+	}
+	// 与redis相同, 动态规划表的内存不能超过proto-max-bulk-len
+	if (uint64(len(a))+1)*(uint64(len(b))+1)*4 > lcsMaxTableBytes {
+		return protocol.MakeErrReply("ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len")
+	}
+
+	// dp[i][j] 是 a[:i] 和 b[:j] 的最长公共子序列长度
+	width := len(b) + 1
+	dp := make([]uint32, (len(a)+1)*width)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				dp[i*width+j] = dp[(i-1)*width+j-1] + 1
+			} else {
+				dp[i*width+j] = max(dp[(i-1)*width+j], dp[i*width+j-1])
+			}
+		}
+	}
+	lcsLen := dp[len(a)*width+len(b)]
+
+	// 从末尾回溯, 得到公共子序列以及连续匹配的区间
+	result := make([]byte, lcsLen)
+	idx := lcsLen
+	var matches []redis.Reply
+	aStart, aEnd, bStart, bEnd := len(a), 0, 0, 0 // aStart == len(a) 表示当前没有区间
+	i, j := len(a), len(b)
+	for i > 0 && j > 0 {
+		emitRange := false
+		if a[i-1] == b[j-1] {
+			result[idx-1] = a[i-1]
+			if aStart == len(a) {
+				aStart, aEnd = i-1, i-1
+				bStart, bEnd = j-1, j-1
+			} else if aStart == i && bStart == j {
+				// 区间连续, 向前扩展
+				aStart--
+				bStart--
+			} else {
+				emitRange = true
+			}
+			if aStart == 0 || bStart == 0 {
+				emitRange = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if dp[(i-1)*width+j] > dp[i*width+j-1] {
+				i--
+			} else {
+				j--
+			}
+			if aStart != len(a) {
+				emitRange = true
+			}
+		}
+		if emitRange {
+			matchLen := int64(aEnd - aStart + 1)
+			if getIdx && (minMatchLen == 0 || matchLen >= minMatchLen) {
+				match := []redis.Reply{
+					protocol.MakeMultiRawReply([]redis.Reply{
+						protocol.MakeIntReply(int64(aStart)), protocol.MakeIntReply(int64(aEnd)),
+					}),
+					protocol.MakeMultiRawReply([]redis.Reply{
+						protocol.MakeIntReply(int64(bStart)), protocol.MakeIntReply(int64(bEnd)),
+					}),
+				}
+				if withMatchLen {
+					match = append(match, protocol.MakeIntReply(matchLen))
+				}
+				matches = append(matches, protocol.MakeMultiRawReply(match))
+			}
+			aStart = len(a)
+		}
+	}
+
+	if !getIdx {
+		return protocol.MakeBulkReply(result)
+	}
+	return protocol.MakeMapReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("matches")), protocol.MakeMultiRawReply(matches),
+		protocol.MakeBulkReply([]byte("len")), protocol.MakeIntReply(int64(lcsLen)),
+	})
+}
+
+func init() {
+	registerCommand("Get", execGet, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("Set", execSet, writeFirstKey, rollbackFirstKey, -3, flagWrite|flagCustomAof)
+	registerCommand("SetNX", execSetNX, writeFirstKey, rollbackFirstKey, 3, flagWrite)
+	registerCommand("SetEX", execSetEX, writeFirstKey, rollbackFirstKey, 4, flagWrite|flagCustomAof)
+	registerCommand("PSetEX", execPSetEX, writeFirstKey, rollbackFirstKey, 4, flagWrite|flagCustomAof)
+	registerCommand("GetEX", execGetEX, writeFirstKey, rollbackFirstKey, -2, flagWrite|flagCustomAof)
+	registerCommand("GetDel", execGetDel, writeFirstKey, rollbackFirstKey, 2, flagWrite)
+	registerCommand("MGet", execMGet, readAllKeys, nil, -2, flagReadOnly)
+	registerCommand("MSet", execMSet, prepareMSet, undoMSet, -3, flagWrite)
+	registerCommand("MSetNX", execMSetNX, prepareMSet, undoMSet, -3, flagWrite)
+	registerCommand("Incr", execIncr, writeFirstKey, rollbackFirstKey, 2, flagWrite)
+	registerCommand("IncrBy", execIncrBy, writeFirstKey, rollbackFirstKey, 3, flagWrite)
+	registerCommand("IncrByFloat", execIncrByFloat, writeFirstKey, rollbackFirstKey, 3, flagWrite)
+	registerCommand("Decr", execDecr, writeFirstKey, rollbackFirstKey, 2, flagWrite)
+	registerCommand("DecrBy", execDecrBy, writeFirstKey, rollbackFirstKey, 3, flagWrite)
+	registerCommand("Append", execAppend, writeFirstKey, rollbackFirstKey, 3, flagWrite)
+	registerCommand("StrLen", execStrLen, readFirstKey, nil, 2, flagReadOnly)
+	registerCommand("GetRange", execGetRange, readFirstKey, nil, 4, flagReadOnly)
+	registerCommand("SetRange", execSetRange, writeFirstKey, rollbackFirstKey, 4, flagWrite)
+	registerCommand("LCS", execLCS, prepareLCS, nil, -3, flagReadOnly)
+}
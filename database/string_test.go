@@ -0,0 +1,168 @@
+package database
+
+import (
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"goredis/redis/protocol"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSetOptions(t *testing.T) {
+	db := newDB()
+	var aofLines []CmdLine
+	db.addAof = func(line CmdLine) {
+		aofLines = append(aofLines, line)
+	}
+	c := conn.NewFakeConn()
+	result := db.Exec(c, utils.ToCmdLine("set", "k", "v", "NX", "EX", "100"))
+	if !protocol.IsOKReply(result) {
+		t.Fatalf("set failed: %s", result.ToBytes())
+	}
+	if _, ok := db.ttlMap.Get("k"); !ok {
+		t.Error("expected ttl")
+	}
+	if len(aofLines) != 2 || string(aofLines[1][0]) != "PEXPIREAT" {
+		t.Errorf("expected SET and PEXPIREAT in aof, actual %d lines", len(aofLines))
+	}
+	result = db.Exec(c, utils.ToCmdLine("set", "k", "v2", "NX"))
+	if _, ok := result.(*protocol.NullBulkReply); !ok {
+		t.Errorf("set nx on existing key should return nil, actual %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("set", "k", "v2", "XX", "GET", "KEEPTTL"))
+	if string(result.ToBytes()) != "$1\r\nv\r\n" {
+		t.Errorf("set get failed: %s", result.ToBytes())
+	}
+	if _, ok := db.ttlMap.Get("k"); !ok {
+		t.Error("KEEPTTL should keep ttl")
+	}
+	db.Exec(c, utils.ToCmdLine("set", "k", "v3"))
+	if _, ok := db.ttlMap.Get("k"); ok {
+		t.Error("plain set should clear ttl")
+	}
+	result = db.Exec(c, utils.ToCmdLine("set", "k", "v", "NX", "XX"))
+	if !protocol.IsErrorReply(result) {
+		t.Errorf("expected syntax error, actual %s", result.ToBytes())
+	}
+	at := time.Now().Add(time.Hour).UnixMilli()
+	db.Exec(c, utils.ToCmdLine("set", "k", "v", "PXAT", strconv.FormatInt(at, 10)))
+	raw, _ := db.ttlMap.Get("k")
+	if expireAt, _ := raw.(time.Time); expireAt.UnixMilli() != at {
+		t.Errorf("expected expire at %d, actual %d", at, expireAt.UnixMilli())
+	}
+}
+
+func TestCounter(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("incrby", "n", "10"))
+	result := db.Exec(c, utils.ToCmdLine("decr", "n"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 9 {
+		t.Errorf("decr failed: %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("incrbyfloat", "n", "0.5"))
+	if string(result.ToBytes()) != "$3\r\n9.5\r\n" {
+		t.Errorf("incrbyfloat failed: %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("incr", "n"))
+	if !protocol.IsErrorReply(result) {
+		t.Errorf("incr on float should fail, actual %s", result.ToBytes())
+	}
+	db.Exec(c, utils.ToCmdLine("set", "max", "9223372036854775807"))
+	result = db.Exec(c, utils.ToCmdLine("incr", "max"))
+	if !protocol.IsErrorReply(result) {
+		t.Errorf("expected overflow error, actual %s", result.ToBytes())
+	}
+}
+
+func TestRange(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("set", "s", "This is a string"))
+	cases := []struct {
+		start, end, expected string
+	}{
+		{"0", "3", "This"},
+		{"-3", "-1", "ing"},
+		{"0", "-1", "This is a string"},
+		{"10", "100", "string"},
+		{"5", "3", ""},
+	}
+	for _, tc := range cases {
+		result := db.Exec(c, utils.ToCmdLine("getrange", "s", tc.start, tc.end))
+		if string(result.ToBytes()) != string(protocol.MakeBulkReply([]byte(tc.expected)).ToBytes()) {
+			t.Errorf("getrange %s %s: expected %q, actual %q", tc.start, tc.end, tc.expected, result.ToBytes())
+		}
+	}
+	result := db.Exec(c, utils.ToCmdLine("setrange", "r", "3", "ab"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 5 {
+		t.Errorf("setrange failed: %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("get", "r"))
+	if string(result.ToBytes()) != "$5\r\n\x00\x00\x00ab\r\n" {
+		t.Errorf("expected zero padding, actual %q", result.ToBytes())
+	}
+}
+
+func TestLCS(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("mset", "key1", "ohmytext", "key2", "mynewtext"))
+	result := db.Exec(c, utils.ToCmdLine("lcs", "key1", "key2"))
+	if string(result.ToBytes()) != "$6\r\nmytext\r\n" {
+		t.Errorf("lcs failed: %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("lcs", "key1", "key2", "len"))
+	if intResult, ok := result.(*protocol.IntReply); !ok || intResult.Code != 6 {
+		t.Errorf("lcs len failed: %s", result.ToBytes())
+	}
+	result = db.Exec(c, utils.ToCmdLine("lcs", "key1", "key2", "idx", "minmatchlen", "4", "withmatchlen"))
+	expected := "*4\r\n$7\r\nmatches\r\n*1\r\n*3\r\n*2\r\n:4\r\n:7\r\n*2\r\n:5\r\n:8\r\n:4\r\n$3\r\nlen\r\n:6\r\n"
+	if string(result.ToBytes()) != expected {
+		t.Errorf("lcs idx failed: %q", result.ToBytes())
+	}
+
+	db.Exec(c, utils.ToCmdLine("setrange", "big1", "12000", "a"))
+	db.Exec(c, utils.ToCmdLine("setrange", "big2", "12000", "a"))
+	assertReply(t, db.Exec(c, utils.ToCmdLine("lcs", "big1", "big2")),
+		"-ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len\r\n")
+}
+
+func TestAppendInPlace(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("set", "s", "a"))
+	cmdLine := utils.ToCmdLine("append", "s", "b")
+	undoLogs := db.GetUndoLogs(cmdLine)
+	for i := 0; i < 100; i++ {
+		db.Exec(c, utils.ToCmdLine("append", "s", "b"))
+	}
+	first := db.Exec(c, utils.ToCmdLine("getrange", "s", "0", "1"))
+	// 扩展出来的空位用0填充
+	assertReply(t, db.Exec(c, utils.ToCmdLine("setrange", "s", "102", "c")), ":103\r\n")
+	assertReply(t, db.Exec(c, utils.ToCmdLine("getrange", "s", "100", "-1")), "$3\r\nb\x00c\r\n")
+	assertReply(t, first, "$2\r\nab\r\n")
+	for _, undo := range undoLogs {
+		db.Exec(c, undo)
+	}
+	assertReply(t, db.Exec(c, utils.ToCmdLine("get", "s")), "$1\r\na\r\n")
+}
+
+func TestReplyNotAliased(t *testing.T) {
+	db := newDB()
+	c := conn.NewFakeConn()
+	db.Exec(c, utils.ToCmdLine("set", "s", "aaaa"))
+	db.Exec(c, utils.ToCmdLine("append", "s", "aaaa"))
+	// 回复在释放锁之后才写给客户端, 之后的写命令不能修改已经生成的回复
+	get := db.Exec(c, utils.ToCmdLine("get", "s"))
+	getRange := db.Exec(c, utils.ToCmdLine("getrange", "s", "0", "3"))
+	mget := db.Exec(c, utils.ToCmdLine("mget", "s"))
+	getOld := db.Exec(c, utils.ToCmdLine("set", "s", "x", "NX", "GET"))
+	db.Exec(c, utils.ToCmdLine("setrange", "s", "0", "bbbb"))
+	db.Exec(c, utils.ToCmdLine("append", "s", "cc"))
+	assertReply(t, get, "$8\r\naaaaaaaa\r\n")
+	assertReply(t, getRange, "$4\r\naaaa\r\n")
+	assertReply(t, mget, "*1\r\n$8\r\naaaaaaaa\r\n")
+	assertReply(t, getOld, "$8\r\naaaaaaaa\r\n")
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"goredis/config"
+	"goredis/interface/redis"
+	"goredis/redis/protocol"
+	"strconv"
+	"strings"
+)
+
+// redisVersion 是HELLO返回的兼容版本号
+const redisVersion = "7.2.0"
+
+// execHello 切换连接的协议版本并返回服务端信息
+// HELLO [protover [AUTH username password]]
+func execHello(c redis.Conn, args [][]byte) redis.Reply {
+	version := c.GetProtocol()
+	if len(args) > 0 {
+		ver, err := strconv.Atoi(string(args[0]))
+		if err != nil {
+			return protocol.MakeErrReply("ERR Protocol version is not an integer or out of range")
+		}
+		if ver != protocol.RESP2 && ver != protocol.RESP3 {
+			return protocol.MakeErrReply("NOPROTO unsupported protocol version")
+		}
+		version = ver
+	}
+	for i := 1; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		if option != "AUTH" || i+2 >= len(args) {
+			return protocol.MakeErrReply("ERR Syntax error in HELLO option '" + string(args[i]) + "'")
+		}
+		username, password := string(args[i+1]), string(args[i+2])
+		if !checkPassword(username, password) {
+			return protocol.MakeErrReply("WRONGPASS invalid username-password pair or user is disabled.")
+		}
+		c.SetPassword(password)
+		i += 2
+	}
+	c.SetProtocol(version)
+	return protocol.MakeMapReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("server")), protocol.MakeBulkReply([]byte("redis")),
+		protocol.MakeBulkReply([]byte("version")), protocol.MakeBulkReply([]byte(redisVersion)),
+		protocol.MakeBulkReply([]byte("proto")), protocol.MakeIntReply(int64(version)),
+		protocol.MakeBulkReply([]byte("mode")), protocol.MakeBulkReply([]byte("standalone")),
+		protocol.MakeBulkReply([]byte("role")), protocol.MakeBulkReply([]byte("master")),
+		protocol.MakeBulkReply([]byte("modules")), protocol.MakeEmptyMultiBulkReply(),
+	})
+}
+
+// checkPassword 只支持default用户, 未配置密码时接受任意密码
+func checkPassword(username, password string) bool {
+	if username != "default" {
+		return false
+	}
+	return config.Properties.RequirePass == "" || config.Properties.RequirePass == password
+}
+
+// execClient CLIENT ID|TRACKING|CACHING|GETREDIR
+func (server *Server) execClient(c redis.Conn, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("client")
+	}
+	switch strings.ToLower(string(args[0])) {
+	case "id":
+		if len(args) != 1 {
+			return protocol.MakeArgNumErrReply("client|id")
+		}
+		return protocol.MakeIntReply(c.ID())
+	case "tracking":
+		return server.tracking.execTracking(c, args[1:])
+	case "caching":
+		return server.tracking.execCaching(c, args[1:])
+	case "getredir":
+		return server.tracking.execGetRedir(c, args[1:])
+	}
+	return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try CLIENT HELP.")
+}
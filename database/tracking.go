@@ -0,0 +1,368 @@
+package database
+
+import (
+	"goredis/interface/redis"
+	"goredis/pubsub"
+	"goredis/redis/protocol"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// invalidateChannel RESP2客户端通过订阅这个频道接收失效消息
+const invalidateChannel = "__redis__:invalidate"
+
+// CLIENT CACHING对下一条命令的设置
+const (
+	cachingUnset = iota
+	cachingYes
+	cachingNo
+)
+
+// trackingClient 开启了CLIENT TRACKING的客户端, 除caching外的字段在开启后不会修改
+type trackingClient struct {
+	conn redis.Conn
+	// redirect 接收失效消息的连接, 为0时发给自己
+	redirect int64
+	bcast    bool
+	optIn    bool
+	optOut   bool
+	noLoop   bool
+	prefixes []string
+	caching  atomic.Int32
+}
+
+// trackingTable 记录客户端读取过的key, 这些key被修改、过期或者清空时发送失效消息.
+// key不区分DB, 与redis相同
+type trackingTable struct {
+	clients sync.Map // id -> *trackingClient
+	// conns 执行过命令的连接, 用于查找REDIRECT的目标
+	conns sync.Map // id -> redis.Conn
+
+	mu sync.Mutex
+	// keys 默认模式下key -> 读取过它的客户端
+	keys map[string]map[int64]struct{}
+	// prefixes BCAST模式下前缀 -> 订阅了这个前缀的客户端
+	prefixes map[string]map[int64]struct{}
+}
+
+func newTrackingTable() *trackingTable {
+	return &trackingTable{
+		keys:     make(map[string]map[int64]struct{}),
+		prefixes: make(map[string]map[int64]struct{}),
+	}
+}
+
+// register 记录连接, 之后其他客户端可以REDIRECT到这个连接
+func (t *trackingTable) register(c redis.Conn) {
+	if _, ok := t.conns.Load(c.ID()); !ok {
+		t.conns.Store(c.ID(), c)
+	}
+}
+
+// unregister 连接关闭时关闭追踪并且不再作为REDIRECT的目标
+func (t *trackingTable) unregister(c redis.Conn) {
+	t.disable(c)
+	t.conns.Delete(c.ID())
+}
+
+func (t *trackingTable) client(c redis.Conn) *trackingClient {
+	if c == nil {
+		return nil
+	}
+	raw, ok := t.clients.Load(c.ID())
+	if !ok {
+		return nil
+	}
+	return raw.(*trackingClient)
+}
+
+// mergePrefixes 添加还没有的前缀
+func (tc *trackingClient) mergePrefixes(prefixes []string) {
+	for _, prefix := range prefixes {
+		if !slices.Contains(tc.prefixes, prefix) {
+			tc.prefixes = append(tc.prefixes, prefix)
+		}
+	}
+}
+
+// enable 开启追踪, 已经开启时不能切换BCAST和OPTIN/OPTOUT模式, BCAST模式下追加新的前缀
+func (t *trackingTable) enable(tc *trackingClient) redis.Reply {
+	id := tc.conn.ID()
+	if old := t.client(tc.conn); old != nil {
+		if old.bcast != tc.bcast {
+			return protocol.MakeErrReply("ERR You can't switch BCAST mode on/off before disabling tracking for this client, and then re-enabling it with a different mode.")
+		}
+		if old.optIn != tc.optIn || old.optOut != tc.optOut {
+			return protocol.MakeErrReply("ERR You can't switch OPTIN/OPTOUT mode before disabling tracking for this client, and then re-enabling it with a different mode.")
+		}
+		prefixes := tc.prefixes
+		tc.prefixes = slices.Clone(old.prefixes)
+		tc.mergePrefixes(prefixes)
+	}
+	if tc.bcast && len(tc.prefixes) == 0 {
+		// 没有指定前缀时接收所有key的失效消息
+		tc.prefixes = []string{""}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, prefix := range tc.prefixes {
+		ids, ok := t.prefixes[prefix]
+		if !ok {
+			ids = make(map[int64]struct{})
+			t.prefixes[prefix] = ids
+		}
+		ids[id] = struct{}{}
+	}
+	t.clients.Store(id, tc)
+	return protocol.MakeOkReply()
+}
+
+// disable 关闭追踪, 默认模式下记录的key在发送失效消息时再清理
+func (t *trackingTable) disable(c redis.Conn) {
+	tc := t.client(c)
+	if tc == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, prefix := range tc.prefixes {
+		delete(t.prefixes[prefix], c.ID())
+		if len(t.prefixes[prefix]) == 0 {
+			delete(t.prefixes, prefix)
+		}
+	}
+	t.clients.Delete(c.ID())
+}
+
+// resetCaching CLIENT CACHING只对下一条命令生效
+func (t *trackingTable) resetCaching(c redis.Conn) {
+	if tc := t.client(c); tc != nil {
+		tc.caching.Store(cachingUnset)
+	}
+}
+
+// track 记录客户端读取的key, 调用方需要持有key的锁
+func (t *trackingTable) track(c redis.Conn, keys []string) {
+	tc := t.client(c)
+	if tc == nil || tc.bcast || len(keys) == 0 {
+		return
+	}
+	caching := tc.caching.Load()
+	if tc.optIn && caching != cachingYes || tc.optOut && caching == cachingNo {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range keys {
+		ids, ok := t.keys[key]
+		if !ok {
+			ids = make(map[int64]struct{})
+			t.keys[key] = ids
+		}
+		ids[c.ID()] = struct{}{}
+	}
+}
+
+// invalidate 向读取过这些key或者订阅了匹配前缀的客户端发送失效消息, origin是修改key的客户端, 可以为nil.
+// 调用方需要持有key的锁, 避免其他客户端在发送消息前读到旧值并重新被追踪
+func (t *trackingTable) invalidate(origin redis.Conn, keys []string) {
+	targets := make(map[int64][][]byte)
+	var order []int64
+	addTarget := func(id int64, key string) {
+		if _, ok := targets[id]; !ok {
+			order = append(order, id)
+		}
+		targets[id] = append(targets[id], []byte(key))
+	}
+	t.mu.Lock()
+	for _, key := range keys {
+		for id := range t.keys[key] {
+			addTarget(id, key)
+		}
+		delete(t.keys, key)
+		for prefix, ids := range t.prefixes {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			for id := range ids {
+				addTarget(id, key)
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	for _, id := range order {
+		raw, ok := t.clients.Load(id)
+		if !ok {
+			// 已经关闭了追踪
+			continue
+		}
+		tc := raw.(*trackingClient)
+		if tc.noLoop && origin != nil && origin.ID() == id {
+			continue
+		}
+		t.send(tc, protocol.MakeMultiBulkReply(targets[id]))
+	}
+}
+
+// invalidateAll 清空数据库时通知所有客户端丢弃全部缓存, 失效消息中的key为null
+func (t *trackingTable) invalidateAll(origin redis.Conn) {
+	t.mu.Lock()
+	clear(t.keys)
+	t.mu.Unlock()
+	t.clients.Range(func(key, value any) bool {
+		tc := value.(*trackingClient)
+		if !tc.noLoop || origin == nil || origin.ID() != tc.conn.ID() {
+			t.send(tc, protocol.MakeNullMultiBulkReply())
+		}
+		return true
+	})
+}
+
+// send RESP3连接接收invalidate推送, RESP2连接需要订阅__redis__:invalidate频道.
+// REDIRECT的目标不存在时通知客户端tracking-redir-broken
+func (t *trackingTable) send(tc *trackingClient, keys redis.Reply) {
+	target := tc.conn
+	if tc.redirect != 0 {
+		raw, ok := t.conns.Load(tc.redirect)
+		if !ok {
+			if tc.conn.GetProtocol() == protocol.RESP3 {
+				msg := protocol.MakePushReply([]redis.Reply{
+					protocol.MakeBulkReply([]byte("tracking-redir-broken")),
+					protocol.MakeIntReply(tc.redirect),
+				})
+				_, _ = tc.conn.Write(protocol.Marshal(msg, protocol.RESP3))
+			}
+			return
+		}
+		target = raw.(redis.Conn)
+	}
+	var msg redis.Reply
+	if target.GetProtocol() == protocol.RESP3 {
+		msg = protocol.MakePushReply([]redis.Reply{protocol.MakeBulkReply([]byte("invalidate")), keys})
+	} else if slices.Contains(target.GetChannels(), invalidateChannel) {
+		msg = pubsub.MakeMessage(invalidateChannel, keys)
+	} else {
+		return
+	}
+	_, _ = target.Write(protocol.Marshal(msg, target.GetProtocol()))
+}
+
+// trackCommand 只读命令记录读取的key, 写命令使这些key失效, 执行出错时不做处理.
+// 调用方需要持有key的锁
+func (db *DB) trackCommand(c redis.Conn, cmd *command, write, read []string, reply redis.Reply) {
+	if db.tracking == nil || protocol.IsErrorReply(reply) {
+		return
+	}
+	if cmd.flags&flagReadOnly != 0 {
+		db.tracking.track(c, read)
+		return
+	}
+	db.tracking.invalidate(c, write)
+}
+
+// invalidate 命令之外修改key时使用, 如过期和MOVE
+func (db *DB) invalidate(keys ...string) {
+	if db.tracking != nil {
+		db.tracking.invalidate(nil, keys)
+	}
+}
+
+// execTracking CLIENT TRACKING ON|OFF [REDIRECT client-id] [PREFIX prefix [PREFIX prefix ...]] [BCAST] [OPTIN] [OPTOUT] [NOLOOP]
+func (t *trackingTable) execTracking(c redis.Conn, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("client|tracking")
+	}
+	tc := &trackingClient{conn: c}
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "REDIRECT":
+			if i+1 >= len(args) {
+				return protocol.MakeSyntaxErrReply()
+			}
+			id, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if _, ok := t.conns.Load(id); !ok {
+				return protocol.MakeErrReply("ERR The client ID you want redirect to does not exist")
+			}
+			tc.redirect = id
+			i++
+		case "PREFIX":
+			if i+1 >= len(args) {
+				return protocol.MakeSyntaxErrReply()
+			}
+			tc.mergePrefixes([]string{string(args[i+1])})
+			i++
+		case "BCAST":
+			tc.bcast = true
+		case "OPTIN":
+			tc.optIn = true
+		case "OPTOUT":
+			tc.optOut = true
+		case "NOLOOP":
+			tc.noLoop = true
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+	switch strings.ToUpper(string(args[0])) {
+	case "ON":
+		if len(tc.prefixes) > 0 && !tc.bcast {
+			return protocol.MakeErrReply("ERR PREFIX option requires BCAST mode to be enabled")
+		}
+		if tc.optIn && tc.optOut {
+			return protocol.MakeErrReply("ERR You can't use both OPTIN and OPTOUT")
+		}
+		if tc.bcast && (tc.optIn || tc.optOut) {
+			return protocol.MakeErrReply("ERR OPTIN and OPTOUT are not compatible with BCAST")
+		}
+		return t.enable(tc)
+	case "OFF":
+		t.disable(c)
+		return protocol.MakeOkReply()
+	}
+	return protocol.MakeSyntaxErrReply()
+}
+
+// execCaching CLIENT CACHING YES|NO, OPTIN模式下YES表示追踪下一条命令读取的key, OPTOUT模式下NO表示不追踪
+func (t *trackingTable) execCaching(c redis.Conn, args [][]byte) redis.Reply {
+	if len(args) != 1 {
+		return protocol.MakeArgNumErrReply("client|caching")
+	}
+	tc := t.client(c)
+	if tc == nil || !tc.optIn && !tc.optOut {
+		return protocol.MakeErrReply("ERR CLIENT CACHING can be called only when the client is in tracking mode with OPTIN or OPTOUT mode enabled")
+	}
+	switch strings.ToUpper(string(args[0])) {
+	case "YES":
+		if !tc.optIn {
+			return protocol.MakeErrReply("ERR CLIENT CACHING YES is only valid when tracking is enabled in OPTIN mode.")
+		}
+		tc.caching.Store(cachingYes)
+	case "NO":
+		if !tc.optOut {
+			return protocol.MakeErrReply("ERR CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode.")
+		}
+		tc.caching.Store(cachingNo)
+	default:
+		return protocol.MakeSyntaxErrReply()
+	}
+	return protocol.MakeOkReply()
+}
+
+// execGetRedir CLIENT GETREDIR, 没有开启追踪时返回-1
+func (t *trackingTable) execGetRedir(c redis.Conn, args [][]byte) redis.Reply {
+	if len(args) != 0 {
+		return protocol.MakeArgNumErrReply("client|getredir")
+	}
+	tc := t.client(c)
+	if tc == nil {
+		return protocol.MakeIntReply(-1)
+	}
+	return protocol.MakeIntReply(tc.redirect)
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"goredis/interface/redis"
+	"goredis/lib/utils"
+	"goredis/pubsub"
+	"goredis/redis/conn"
+	"goredis/redis/protocol"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func makeInvalidateMsg(keys ...string) string {
+	return string(protocol.Marshal(protocol.MakePushReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("invalidate")),
+		protocol.MakeMultiBulkReply(utils.ToCmdLine(keys...)),
+	}), protocol.RESP3))
+}
+
+func assertWritten(t *testing.T, c *conn.FakeConn, expected string) {
+	t.Helper()
+	if actual := string(c.Bytes()); actual != expected {
+		t.Errorf("expected %q, actual %q", expected, actual)
+	}
+	c.Clean()
+}
+
+func TestTrackingDefaultMode(t *testing.T) {
+	server := NewStandaloneServer()
+	tracker := conn.NewFakeConn()
+	tracker.SetProtocol(protocol.RESP3)
+	writer := conn.NewFakeConn()
+	assertReply(t, server.Exec(tracker, utils.ToCmdLine("client", "tracking", "on")), "+OK\r\n")
+	assertReply(t, server.Exec(tracker, utils.ToCmdLine("client", "getredir")), ":0\r\n")
+
+	server.Exec(writer, utils.ToCmdLine("set", "a", "1"))
+	assertWritten(t, tracker, "")
+	server.Exec(tracker, utils.ToCmdLine("get", "a"))
+	server.Exec(writer, utils.ToCmdLine("set", "a", "2"))
+	assertWritten(t, tracker, makeInvalidateMsg("a"))
+	// 失效后需要重新读取才会再次追踪
+	server.Exec(writer, utils.ToCmdLine("set", "a", "3"))
+	assertWritten(t, tracker, "")
+
+	server.Exec(tracker, utils.ToCmdLine("mget", "a", "b"))
+	server.Exec(writer, utils.ToCmdLine("del", "a", "b"))
+	assertWritten(t, tracker, makeInvalidateMsg("a", "b"))
+
+	server.Exec(tracker, utils.ToCmdLine("get", "a"))
+	server.Exec(writer, utils.ToCmdLine("flushall"))
+	assertWritten(t, tracker, string(protocol.Marshal(protocol.MakePushReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("invalidate")),
+		protocol.MakeNullMultiBulkReply(),
+	}), protocol.RESP3)))
+
+	assertReply(t, server.Exec(tracker, utils.ToCmdLine("client", "tracking", "off")), "+OK\r\n")
+	server.Exec(tracker, utils.ToCmdLine("get", "a"))
+	server.Exec(writer, utils.ToCmdLine("set", "a", "1"))
+	assertWritten(t, tracker, "")
+}
+
+func TestTrackingRedirect(t *testing.T) {
+	server := NewStandaloneServer()
+	receiver := conn.NewFakeConn()
+	tracker := conn.NewFakeConn()
+	server.Exec(receiver, utils.ToCmdLine("subscribe", invalidateChannel))
+	receiver.Clean()
+	id := strconv.FormatInt(receiver.ID(), 10)
+	assertReply(t, server.Exec(tracker, utils.ToCmdLine("client", "tracking", "on", "redirect", "0")),
+		"-ERR The client ID you want redirect to does not exist\r\n")
+	assertReply(t, server.Exec(tracker, utils.ToCmdLine("client", "tracking", "on", "redirect", id, "noloop")), "+OK\r\n")
+	assertReply(t, server.Exec(tracker, utils.ToCmdLine("client", "getredir")), ":"+id+"\r\n")
+
+	server.Exec(tracker, utils.ToCmdLine("get", "a"))
+	server.Exec(tracker, utils.ToCmdLine("set", "a", "1"))
+	assertWritten(t, receiver, "")
+
+	writer := conn.NewFakeConn()
+	server.Exec(tracker, utils.ToCmdLine("get", "a"))
+	server.Exec(writer, utils.ToCmdLine("set", "a", "2"))
+	expected := string(pubsub.MakeMessage(invalidateChannel, protocol.MakeMultiBulkReply(utils.ToCmdLine("a"))).ToBytes())
+	assertWritten(t, receiver, expected)
+}
+
+func TestTrackingBroadcast(t *testing.T) {
+	server := NewStandaloneServer()
+	tracker := conn.NewFakeConn()
+	tracker.SetProtocol(protocol.RESP3)
+	writer := conn.NewFakeConn()
+	assertReply(t, server.Exec(tracker, utils.ToCmdLine("client", "tracking", "on", "prefix", "user:")),
+		"-ERR PREFIX option requires BCAST mode to be enabled\r\n")
+	assertReply(t, server.Exec(tracker, utils.ToCmdLine("client", "tracking", "on", "bcast", "prefix", "user:")), "+OK\r\n")
+	assertReply(t, server.Exec(tracker, utils.ToCmdLine("client", "tracking", "on")),
+		"-ERR You can't switch BCAST mode on/off before disabling tracking for this client, and then re-enabling it with a different mode.\r\n")
+
+	// BCAST模式下不需要读取过key
+	server.Exec(writer, utils.ToCmdLine("mset", "user:1", "a", "order:1", "b", "user:2", "c"))
+	assertWritten(t, tracker, makeInvalidateMsg("user:1", "user:2"))
+	server.Exec(writer, utils.ToCmdLine("set", "user:1", "a", "px", "1"))
+	tracker.Clean()
+	time.Sleep(10 * time.Millisecond)
+	server.Exec(writer, utils.ToCmdLine("exists", "user:1"))
+	assertWritten(t, tracker, makeInvalidateMsg("user:1"))
+}
+
+func TestTrackingOptIn(t *testing.T) {
+	server := NewStandaloneServer()
+	tracker := conn.NewFakeConn()
+	tracker.SetProtocol(protocol.RESP3)
+	writer := conn.NewFakeConn()
+	assertReply(t, server.Exec(tracker, utils.ToCmdLine("client", "caching", "yes")),
+		"-ERR CLIENT CACHING can be called only when the client is in tracking mode with OPTIN or OPTOUT mode enabled\r\n")
+	assertReply(t, server.Exec(tracker, utils.ToCmdLine("client", "tracking", "on", "optin")), "+OK\r\n")
+	assertReply(t, server.Exec(tracker, utils.ToCmdLine("client", "caching", "no")),
+		"-ERR CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode.\r\n")
+
+	server.Exec(tracker, utils.ToCmdLine("get", "a"))
+	server.Exec(writer, utils.ToCmdLine("set", "a", "1"))
+	assertWritten(t, tracker, "")
+
+	assertReply(t, server.Exec(tracker, utils.ToCmdLine("client", "caching", "yes")), "+OK\r\n")
+	server.Exec(tracker, utils.ToCmdLine("get", "a"))
+	server.Exec(tracker, utils.ToCmdLine("get", "b"))
+	server.Exec(writer, utils.ToCmdLine("mset", "a", "2", "b", "2"))
+	assertWritten(t, tracker, makeInvalidateMsg("a"))
+}
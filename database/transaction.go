@@ -0,0 +1,277 @@
+package database
+
+import (
+	"goredis/interface/redis"
+	"goredis/redis/protocol"
+	"strconv"
+	"strings"
+)
+
+// txServerCommands 可以在事务中执行的服务端命令及其参数个数, 规则与cmdTable相同
+var txServerCommands = map[string]int{
+	"unwatch":  1,
+	"ping":     -1,
+	"publish":  3,
+	"spublish": 3,
+	"pubsub":   -2,
+	"client":   -2,
+	"select":   2,
+	"swapdb":   3,
+	"flushall": -1,
+	"flushdb":  -1,
+	"move":     3,
+	"copy":     -3,
+	"dbsize":   1,
+}
+
+// noTxCommands 不能在事务中执行的命令, 它们直接向连接写入回复或者切换协议
+var noTxCommands = map[string]bool{
+	"subscribe":    true,
+	"unsubscribe":  true,
+	"psubscribe":   true,
+	"punsubscribe": true,
+	"ssubscribe":   true,
+	"sunsubscribe": true,
+	"hello":        true,
+}
+
+// txStandaloneCommands 作用于整个DB并且无法回滚的命令, 只能作为事务中唯一的命令
+var txStandaloneCommands = map[string]bool{
+	"swapdb":   true,
+	"flushall": true,
+	"flushdb":  true,
+}
+
+func isTxStandalone(cmdLine CmdLine) bool {
+	return txStandaloneCommands[strings.ToLower(string(cmdLine[0]))]
+}
+
+var errTxStandalone = protocol.MakeErrReply("ERR SWAPDB, FLUSHDB and FLUSHALL must be the only command in a transaction")
+
+// startMulti MULTI, 之后的命令进入队列直到EXEC或者DISCARD
+func startMulti(c redis.Conn, args [][]byte) redis.Reply {
+	if len(args) != 0 {
+		return protocol.MakeArgNumErrReply("multi")
+	}
+	if c.InMultiState() {
+		return protocol.MakeErrReply("ERR MULTI calls can not be nested")
+	}
+	c.SetMultiState(true)
+	return protocol.MakeOkReply()
+}
+
+// enqueueCmd 检查命令和参数个数后放入事务队列, 出错时记录下来, EXEC时放弃整个事务
+func enqueueCmd(c redis.Conn, cmdLine [][]byte) redis.Reply {
+	errReply := checkTxCommand(cmdLine)
+	if errReply == nil && !canEnqueue(c.GetQueuedCmdLine(), cmdLine) {
+		errReply = errTxStandalone
+	}
+	if errReply != nil {
+		if err, ok := errReply.(protocol.ErrorReply); ok {
+			c.AddTxError(err)
+		}
+		return errReply
+	}
+	c.EnqueueCmd(cmdLine)
+	return protocol.MakeQueuedReply()
+}
+
+// checkTxCommand 检查命令是否存在以及参数个数
+func checkTxCommand(cmdLine [][]byte) redis.Reply {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	if noTxCommands[cmdName] {
+		return protocol.MakeErrReply("ERR Command not allowed inside a transaction")
+	}
+	if arity, ok := txServerCommands[cmdName]; ok {
+		if !validateArity(arity, cmdLine) {
+			return protocol.MakeArgNumErrReply(cmdName)
+		}
+		return nil
+	}
+	_, errReply := lookupCommand(cmdLine)
+	return errReply
+}
+
+// canEnqueue 检查cmdLine能否与已经入队的命令组成一个事务
+func canEnqueue(queued []CmdLine, cmdLine CmdLine) bool {
+	if len(queued) == 0 {
+		return true
+	}
+	return !isTxStandalone(cmdLine) && !isTxStandalone(queued[0])
+}
+
+// discardMulti DISCARD, 放弃队列中的命令并取消所有WATCH
+func discardMulti(c redis.Conn, args [][]byte) redis.Reply {
+	if len(args) != 0 {
+		return protocol.MakeArgNumErrReply("discard")
+	}
+	if !c.InMultiState() {
+		return protocol.MakeErrReply("ERR DISCARD without MULTI")
+	}
+	c.SetMultiState(false)
+	return protocol.MakeOkReply()
+}
+
+// execUnwatch UNWATCH
+func execUnwatch(c redis.Conn, args [][]byte) redis.Reply {
+	if len(args) != 0 {
+		return protocol.MakeArgNumErrReply("unwatch")
+	}
+	clear(c.GetWatching())
+	return protocol.MakeOkReply()
+}
+
+// watch 记录key当前的版本, EXEC时版本发生变化则放弃事务. 之后SELECT到其他DB时仍然检查原来DB中的key
+func (db *DB) watch(c redis.Conn, dbIndex int, keys [][]byte) {
+	watching := c.GetWatching()
+	for _, key := range keys {
+		watching[redis.WatchKey{DBIndex: dbIndex, Key: string(key)}] = db.GetVersion(string(key))
+	}
+}
+
+// txCmd 事务中的一条命令, 在执行前按照事务中的SELECT确定它作用的DB
+type txCmd struct {
+	cmdLine CmdLine
+	name    string
+	// db DB命令执行时连接选中的DB, 服务端命令为nil
+	db          *DB
+	cmd         *command
+	write, read []string
+	// cross 参数正确的MOVE和COPY, 参数错误时为nil, 执行时返回错误
+	cross *crossDBCmd
+}
+
+// txUndoLog 一条命令在某个DB中的undo日志
+type txUndoLog struct {
+	db       *DB
+	cmdLines []CmdLine
+}
+
+// ExecMulti 执行事务. 先按照事务中的SELECT确定每条命令作用的DB, 然后对所有DB中涉及的key以及WATCH的key一次性加锁,
+// WATCH的key被修改过时返回空数组. DB命令出错时按相反的顺序执行undo日志回滚之前的命令(包括MOVE和COPY), 恢复连接选中的DB并返回这个错误.
+// 服务端命令出错时与redis相同把错误作为结果继续执行, PUBLISH等已经发出的消息无法回滚
+func (server *Server) ExecMulti(c redis.Conn, watching map[redis.WatchKey]uint32, cmdLines []CmdLine) redis.Reply {
+	for i, cmdLine := range cmdLines {
+		if !canEnqueue(cmdLines[:i], cmdLine) {
+			return errTxStandalone
+		}
+	}
+	if len(cmdLines) == 1 && strings.EqualFold(string(cmdLines[0][0]), "swapdb") {
+		server.swapMu.Lock()
+		defer server.swapMu.Unlock()
+	} else {
+		// 执行期间DB不会被交换, 计划时找到的DB在执行时仍然是连接选中的DB
+		server.swapMu.RLock()
+		defer server.swapMu.RUnlock()
+	}
+
+	locks := make(multiDBLocks)
+	txCmds, errReply := server.planTx(c.GetDBIndex(), cmdLines, locks)
+	if errReply != nil {
+		return errReply
+	}
+	for key := range watching {
+		db, errReply := server.selectDB(key.DBIndex)
+		if errReply != nil {
+			return errReply
+		}
+		locks.add(db, nil, []string{key.Key})
+	}
+	unlock := locks.lock()
+	defer unlock()
+
+	for key, version := range watching {
+		if server.mustSelectDB(key.DBIndex).GetVersion(key.Key) != version {
+			return protocol.MakeNullMultiBulkReply()
+		}
+	}
+	dbIndex := c.GetDBIndex()
+	results := make([]redis.Reply, 0, len(txCmds))
+	var undoLogs []txUndoLog
+	for _, tc := range txCmds {
+		var reply redis.Reply
+		switch {
+		case tc.cmd != nil:
+			undoLogs = append(undoLogs, txUndoLog{db: tc.db, cmdLines: tc.db.GetUndoLogs(tc.cmdLine)})
+			reply = tc.db.execute(tc.cmd, tc.cmdLine)
+			if protocol.IsErrorReply(reply) {
+				rollbackTx(undoLogs)
+				c.SelectDB(dbIndex)
+				return reply
+			}
+		case tc.cross != nil:
+			undoLogs = append(undoLogs, tc.cross.undoLogs()...)
+			if tc.cross.move {
+				reply = tc.cross.moveKey(tc.cmdLine)
+			} else {
+				reply = tc.cross.copyKey(tc.cmdLine)
+			}
+		case tc.name == "swapdb":
+			reply = server.swapDB(c, tc.cmdLine)
+		default:
+			// 参数错误的MOVE和COPY在这里返回错误
+			reply = server.execServerCommand(c, tc.name, tc.cmdLine)
+		}
+		results = append(results, reply)
+	}
+	for i, tc := range txCmds {
+		if tc.cmd == nil {
+			continue
+		}
+		tc.db.trackCommand(c, tc.cmd, tc.write, tc.read, results[i])
+		tc.db.addVersion(tc.cmd.versionKeys(tc.write)...)
+		tc.db.blocking.signal(tc.write...)
+	}
+	return protocol.MakeMultiRawReply(results)
+}
+
+// planTx 从dbIndex开始模拟事务中的SELECT, 确定每条命令作用的DB, 并将涉及的key加入locks.
+// 调用方需要持有swapMu
+func (server *Server) planTx(dbIndex int, cmdLines []CmdLine, locks multiDBLocks) ([]*txCmd, redis.Reply) {
+	txCmds := make([]*txCmd, len(cmdLines))
+	for i, cmdLine := range cmdLines {
+		if errReply := checkTxCommand(cmdLine); errReply != nil {
+			return nil, errReply
+		}
+		tc := &txCmd{cmdLine: cmdLine, name: strings.ToLower(string(cmdLine[0]))}
+		txCmds[i] = tc
+		switch tc.name {
+		case "select":
+			// 无效的编号在执行时返回错误, 不改变选中的DB
+			if index, err := strconv.Atoi(string(cmdLine[1])); err == nil && index >= 0 && index < len(server.dbSet) {
+				dbIndex = index
+			}
+		case "move":
+			tc.cross, _ = server.parseMove(dbIndex, cmdLine)
+		case "copy":
+			tc.cross, _ = server.parseCopy(dbIndex, cmdLine)
+		default:
+			if _, ok := txServerCommands[tc.name]; ok {
+				continue
+			}
+			cmd, _ := lookupCommand(cmdLine)
+			tc.cmd = cmd
+			tc.db = server.mustSelectDB(dbIndex)
+			tc.write, tc.read = cmd.prepare(cmdLine[1:])
+			locks.add(tc.db, tc.write, tc.read)
+		}
+		if tc.cross != nil {
+			tc.cross.addLocks(locks)
+		}
+	}
+	return txCmds, nil
+}
+
+// rollbackTx 从最后一条命令开始执行undo日志, 调用方需要持有相关key的锁
+func rollbackTx(undoLogs []txUndoLog) {
+	for i := len(undoLogs) - 1; i >= 0; i-- {
+		db := undoLogs[i].db
+		for _, cmdLine := range undoLogs[i].cmdLines {
+			cmd, errReply := lookupCommand(cmdLine)
+			if errReply != nil {
+				continue
+			}
+			db.execute(cmd, cmdLine)
+		}
+	}
+}
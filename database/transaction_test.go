@@ -0,0 +1,144 @@
+package database
+
+import (
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"testing"
+)
+
+func TestMulti(t *testing.T) {
+	server := NewStandaloneServer()
+	c := conn.NewFakeConn()
+	assertReply(t, server.Exec(c, utils.ToCmdLine("exec")), "-ERR EXEC without MULTI\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("multi")), "+OK\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("multi")), "-ERR MULTI calls can not be nested\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("set", "a", "1")), "+QUEUED\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("incr", "a")), "+QUEUED\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("get", "a")), "+QUEUED\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("exec")), "*3\r\n+OK\r\n:2\r\n$1\r\n2\r\n")
+
+	server.Exec(c, utils.ToCmdLine("multi"))
+	server.Exec(c, utils.ToCmdLine("set", "a", "3"))
+	assertReply(t, server.Exec(c, utils.ToCmdLine("discard")), "+OK\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("get", "a")), "$1\r\n2\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("discard")), "-ERR DISCARD without MULTI\r\n")
+
+	// 入队时出错会放弃整个事务
+	server.Exec(c, utils.ToCmdLine("multi"))
+	server.Exec(c, utils.ToCmdLine("set", "a", "3"))
+	assertReply(t, server.Exec(c, utils.ToCmdLine("set", "a")), "-ERR wrong number of arguments for 'set' command\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("exec")), "-EXECABORT Transaction discarded because of previous errors.\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("get", "a")), "$1\r\n2\r\n")
+}
+
+func TestMultiServerCommands(t *testing.T) {
+	server := NewStandaloneServer()
+	c := conn.NewFakeConn()
+	server.Exec(c, utils.ToCmdLine("set", "a", "1"))
+	server.Exec(c, utils.ToCmdLine("multi"))
+	assertReply(t, server.Exec(c, utils.ToCmdLine("ping")), "+QUEUED\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("move", "a", "1")), "+QUEUED\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("select", "1")), "+QUEUED\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("get", "a")), "+QUEUED\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("dbsize")), "+QUEUED\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("exec")), "*5\r\n+PONG\r\n:1\r\n+OK\r\n$1\r\n1\r\n:1\r\n")
+	if c.GetDBIndex() != 1 {
+		t.Errorf("expected db 1 after exec, actual %d", c.GetDBIndex())
+	}
+
+	server.Exec(c, utils.ToCmdLine("multi"))
+	assertReply(t, server.Exec(c, utils.ToCmdLine("select")), "-ERR wrong number of arguments for 'select' command\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("subscribe", "ch")), "-ERR Command not allowed inside a transaction\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("exec")), "-EXECABORT Transaction discarded because of previous errors.\r\n")
+}
+
+func TestMultiRollback(t *testing.T) {
+	server := NewStandaloneServer()
+	c := conn.NewFakeConn()
+	server.Exec(c, utils.ToCmdLine("rpush", "list", "a"))
+	server.Exec(c, utils.ToCmdLine("set", "str", "a"))
+	server.Exec(c, utils.ToCmdLine("multi"))
+	server.Exec(c, utils.ToCmdLine("rpush", "list", "b"))
+	server.Exec(c, utils.ToCmdLine("set", "new", "1"))
+	server.Exec(c, utils.ToCmdLine("del", "str"))
+	server.Exec(c, utils.ToCmdLine("incr", "list"))
+	assertReply(t, server.Exec(c, utils.ToCmdLine("exec")), "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("lrange", "list", "0", "-1")), "*1\r\n$1\r\na\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("exists", "new")), ":0\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("get", "str")), "$1\r\na\r\n")
+}
+
+func TestWatch(t *testing.T) {
+	server := NewStandaloneServer()
+	c := conn.NewFakeConn()
+	other := conn.NewFakeConn()
+	assertReply(t, server.Exec(c, utils.ToCmdLine("watch", "a")), "+OK\r\n")
+	server.Exec(other, utils.ToCmdLine("set", "a", "1"))
+	server.Exec(c, utils.ToCmdLine("multi"))
+	assertReply(t, server.Exec(c, utils.ToCmdLine("watch", "a")), "-ERR WATCH inside MULTI is not allowed\r\n")
+	server.Exec(c, utils.ToCmdLine("set", "a", "2"))
+	assertReply(t, server.Exec(c, utils.ToCmdLine("exec")), "*-1\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("get", "a")), "$1\r\n1\r\n")
+
+	// EXEC之后不再监视之前的key
+	server.Exec(c, utils.ToCmdLine("multi"))
+	server.Exec(c, utils.ToCmdLine("set", "a", "2"))
+	assertReply(t, server.Exec(c, utils.ToCmdLine("exec")), "*1\r\n+OK\r\n")
+
+	server.Exec(c, utils.ToCmdLine("watch", "a"))
+	server.Exec(other, utils.ToCmdLine("set", "a", "3"))
+	assertReply(t, server.Exec(c, utils.ToCmdLine("unwatch")), "+OK\r\n")
+	server.Exec(c, utils.ToCmdLine("multi"))
+	server.Exec(c, utils.ToCmdLine("get", "a"))
+	assertReply(t, server.Exec(c, utils.ToCmdLine("exec")), "*1\r\n$1\r\n3\r\n")
+
+	// 清空和交换DB也会使WATCH失效
+	for _, cmd := range [][]string{{"flushall"}, {"flushdb"}, {"swapdb", "0", "1"}} {
+		server.Exec(other, utils.ToCmdLine("set", "a", "1"))
+		server.Exec(c, utils.ToCmdLine("watch", "a"))
+		server.Exec(other, utils.ToCmdLine(cmd...))
+		server.Exec(c, utils.ToCmdLine("multi"))
+		server.Exec(c, utils.ToCmdLine("set", "a", "2"))
+		assertReply(t, server.Exec(c, utils.ToCmdLine("exec")), "*-1\r\n")
+	}
+}
+
+func TestMultiAcrossDBs(t *testing.T) {
+	server := NewStandaloneServer()
+	c := conn.NewFakeConn()
+	other := conn.NewFakeConn()
+
+	// WATCH的key属于WATCH时选中的DB, 之后SELECT不影响检查
+	server.Exec(c, utils.ToCmdLine("watch", "a"))
+	server.Exec(c, utils.ToCmdLine("select", "1"))
+	server.Exec(other, utils.ToCmdLine("set", "a", "1"))
+	server.Exec(c, utils.ToCmdLine("multi"))
+	server.Exec(c, utils.ToCmdLine("set", "b", "1"))
+	assertReply(t, server.Exec(c, utils.ToCmdLine("exec")), "*-1\r\n")
+
+	// 后面的命令出错时回滚前面所有DB中的修改, 包括MOVE, 并恢复选中的DB
+	server.Exec(c, utils.ToCmdLine("multi"))
+	server.Exec(c, utils.ToCmdLine("set", "b", "2"))
+	server.Exec(c, utils.ToCmdLine("select", "0"))
+	server.Exec(c, utils.ToCmdLine("move", "a", "2"))
+	server.Exec(c, utils.ToCmdLine("lpush", "a", "x"))
+	server.Exec(c, utils.ToCmdLine("incr", "a"))
+	assertReply(t, server.Exec(c, utils.ToCmdLine("exec")), "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+	if c.GetDBIndex() != 1 {
+		t.Errorf("expected db 1 after rollback, actual %d", c.GetDBIndex())
+	}
+	assertReply(t, server.Exec(c, utils.ToCmdLine("get", "b")), "$-1\r\n")
+	assertReply(t, server.Exec(other, utils.ToCmdLine("get", "a")), "$1\r\n1\r\n")
+	if size, _ := server.GetDBSize(2); size != 0 {
+		t.Errorf("expected move to be rolled back, actual %d keys in db 2", size)
+	}
+
+	server.Exec(c, utils.ToCmdLine("multi"))
+	server.Exec(c, utils.ToCmdLine("set", "b", "1"))
+	assertReply(t, server.Exec(c, utils.ToCmdLine("flushall")), "-ERR SWAPDB, FLUSHDB and FLUSHALL must be the only command in a transaction\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("exec")), "-EXECABORT Transaction discarded because of previous errors.\r\n")
+	server.Exec(c, utils.ToCmdLine("multi"))
+	server.Exec(c, utils.ToCmdLine("swapdb", "0", "1"))
+	assertReply(t, server.Exec(c, utils.ToCmdLine("exec")), "*1\r\n+OK\r\n")
+	assertReply(t, server.Exec(c, utils.ToCmdLine("get", "a")), "$1\r\n1\r\n")
+}
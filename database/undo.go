@@ -0,0 +1,42 @@
+package database
+
+import (
+	"goredis/lib/utils"
+	"time"
+)
+
+// rollbackFirstKey 生成将第一个key恢复到执行前状态的命令
+func rollbackFirstKey(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	return rollbackGivenKeys(db, key)
+}
+
+// rollbackGivenKeys 生成将给定的key恢复到执行前状态的命令
+func rollbackGivenKeys(db *DB, keys ...string) []CmdLine {
+	var undoCmdLines []CmdLine
+	for _, key := range keys {
+		entity, ok := db.GetEntity(key)
+		if !ok {
+			undoCmdLines = append(undoCmdLines, utils.ToCmdLine("DEL", key))
+			continue
+		}
+		cmdLines := EntityToCmds(key, entity)
+		if len(cmdLines) == 0 {
+			continue
+		}
+		undoCmdLines = append(undoCmdLines, utils.ToCmdLine("DEL", key)) // 先删除已有的值
+		undoCmdLines = append(undoCmdLines, cmdLines...)
+		undoCmdLines = append(undoCmdLines, toTTLCmd(db, key))
+	}
+	return undoCmdLines
+}
+
+// toTTLCmd 生成恢复key过期时间的命令, 没有过期时间时生成PERSIST
+func toTTLCmd(db *DB, key string) CmdLine {
+	raw, exists := db.ttlMap.Get(key)
+	if !exists {
+		return utils.ToCmdLine("PERSIST", key)
+	}
+	expireTime, _ := raw.(time.Time)
+	return MakeExpireCmd(key, expireTime)
+}
@@ -0,0 +1,99 @@
+package bitmap
+
+import (
+	"math/bits"
+	"slices"
+)
+
+// BitMap 按位访问的字符串, 与redis一样第0位是第一个字节的最高位
+type BitMap []byte
+
+// Grow 将bytes扩展到至少能容纳bitSize位, 扩展出来的部分为0.
+// 容量足够时原地扩展, 否则按照append的策略重新分配, 连续写入时均摊只需要O(1)次复制
+func Grow(bytes []byte, bitSize int64) BitMap {
+	size := int((bitSize + 7) / 8)
+	n := len(bytes)
+	if size <= n {
+		return bytes
+	}
+	bytes = slices.Grow(bytes, size-n)[:size]
+	// 容量中可能残留其他数据, 比如解析请求时bulk string后面的CRLF
+	clear(bytes[n:])
+	return bytes
+}
+
+// BitSize 位图的总位数
+func (b BitMap) BitSize() int64 {
+	return int64(len(b)) * 8
+}
+
+// GetBit 返回offset处的位, 超出长度的部分视为0
+func (b BitMap) GetBit(offset int64) byte {
+	index := offset >> 3
+	if index >= int64(len(b)) {
+		return 0
+	}
+	return b[index] >> (7 - offset&7) & 1
+}
+
+// SetBit 设置offset处的位, 调用方需要保证位图足够长
+func (b BitMap) SetBit(offset int64, val byte) {
+	mask := byte(1) << (7 - offset&7)
+	if val == 0 {
+		b[offset>>3] &^= mask
+	} else {
+		b[offset>>3] |= mask
+	}
+}
+
+// BitCount 统计第start位到第end位(包含)之间1的数量
+func (b BitMap) BitCount(start, end int64) int64 {
+	var count int64
+	for start <= end {
+		// 整字节部分一次统计
+		if start&7 == 0 && start+7 <= end {
+			count += int64(bits.OnesCount8(b[start>>3]))
+			start += 8
+			continue
+		}
+		count += int64(b.GetBit(start))
+		start++
+	}
+	return count
+}
+
+// BitPos 返回第start位到第end位(包含)之间第一个值为bit的位置, 不存在时返回-1
+func (b BitMap) BitPos(bit byte, start, end int64) int64 {
+	// 跳过整字节都不是bit的部分
+	skip := byte(0)
+	if bit == 0 {
+		skip = 0xff
+	}
+	for start <= end {
+		if start&7 == 0 && start+7 <= end && b[start>>3] == skip {
+			start += 8
+			continue
+		}
+		if b.GetBit(start) == bit {
+			return start
+		}
+		start++
+	}
+	return -1
+}
+
+// GetBits 以无符号整数的形式读取从offset开始的width位, 超出长度的部分视为0
+func (b BitMap) GetBits(offset int64, width uint) uint64 {
+	var value uint64
+	for i := range int64(width) {
+		value = value<<1 | uint64(b.GetBit(offset+i))
+	}
+	return value
+}
+
+// SetBits 将value的低width位写入从offset开始的位置, 调用方需要保证位图足够长
+func (b BitMap) SetBits(offset int64, width uint, value uint64) {
+	for i := range int64(width) {
+		b.SetBit(offset+i, byte(value>>(int64(width)-1-i)&1))
+	}
+}
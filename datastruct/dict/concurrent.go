@@ -17,6 +17,7 @@ type ConcurrentDict struct {
 
 type shard struct {
 	m     map[string]any
+	index ScanIndex
 	mutex sync.RWMutex
 }
 
@@ -124,6 +125,7 @@ func (dict *ConcurrentDict) Put(key string, val interface{}) (result int) {
 	}
 	dict.addCount()
 	s.m[key] = val
+	s.index.Add(key)
 	return 1
 }
 
@@ -141,6 +143,7 @@ func (dict *ConcurrentDict) PutWithLock(key string, val interface{}) (result int
 	}
 	dict.addCount()
 	s.m[key] = val
+	s.index.Add(key)
 	return 1
 }
 
@@ -158,6 +161,7 @@ func (dict *ConcurrentDict) PutIfAbsent(key string, val interface{}) (result int
 		return 0
 	}
 	s.m[key] = val
+	s.index.Add(key)
 	dict.addCount()
 	return 1
 }
@@ -174,6 +178,7 @@ func (dict *ConcurrentDict) PutIfAbsentWithLock(key string, val interface{}) (re
 		return 0
 	}
 	s.m[key] = val
+	s.index.Add(key)
 	dict.addCount()
 	return 1
 }
@@ -222,6 +227,7 @@ func (dict *ConcurrentDict) Remove(key string) (result int) {
 
 	if _, ok := s.m[key]; ok {
 		delete(s.m, key)
+		s.index.Remove(key)
 		dict.decreaseCount()
 		return 1
 	}
@@ -238,6 +244,7 @@ func (dict *ConcurrentDict) RemoveWithLock(key string) (result int) {
 
 	if _, ok := s.m[key]; ok {
 		delete(s.m, key)
+		s.index.Remove(key)
 		dict.decreaseCount()
 		return 1
 	}
@@ -260,7 +267,7 @@ func (dict *ConcurrentDict) ForEach(consumer Consumer) {
 	for _, s := range dict.table {
 		s.mutex.RLock()
 		f := func() bool {
-			defer s.mutex.Unlock()
+			defer s.mutex.RUnlock()
 			for k, v := range s.m {
 				continues := consumer(k, v)
 				if !continues {
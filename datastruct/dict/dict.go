@@ -16,4 +16,6 @@ type Dict interface {
 	// RandomDistinctKeys 随机返回limit个不重复的key
 	RandomDistinctKeys(limit int) []string
 	Clear()
+	// Scan 游标遍历, 返回0表示遍历结束
+	Scan(cursor uint64, count int, consumer func(key string, val any)) uint64
 }
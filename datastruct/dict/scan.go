@@ -0,0 +1,145 @@
+package dict
+
+import (
+	"math"
+	"math/bits"
+)
+
+// minScanBuckets 索引的最小桶数量, 必须是2的幂
+const minScanBuckets = 4
+
+// ScanIndex 按照 key 的哈希值的低位把 key 分到 2 的幂个桶中, 需要与被索引的 map 一起修改.
+// 游标是桶的下标, 与 redis 一样按照反向二进制的顺序递增, 遍历期间扩容或者缩容也不会遗漏一直存在的 key.
+// 每次调用只访问大约 count 个 key, 不需要遍历整个 map. 零值可以直接使用
+type ScanIndex struct {
+	buckets [][]string
+	size    int
+}
+
+// Add 添加 key, 调用方需要保证 key 之前不存在
+func (idx *ScanIndex) Add(key string) {
+	if idx.size >= len(idx.buckets) {
+		idx.resize(max(len(idx.buckets)*2, minScanBuckets))
+	}
+	i := idx.bucketOf(key)
+	idx.buckets[i] = append(idx.buckets[i], key)
+	idx.size++
+}
+
+// Remove 删除 key, key 不存在时什么也不做
+func (idx *ScanIndex) Remove(key string) {
+	if idx.size == 0 {
+		return
+	}
+	i := idx.bucketOf(key)
+	bucket := idx.buckets[i]
+	for j := range bucket {
+		if bucket[j] != key {
+			continue
+		}
+		last := len(bucket) - 1
+		bucket[j] = bucket[last]
+		bucket[last] = ""
+		idx.buckets[i] = bucket[:last]
+		idx.size--
+		break
+	}
+	if idx.size == 0 {
+		idx.buckets = nil
+	} else if len(idx.buckets) > minScanBuckets && idx.size < len(idx.buckets)/8 {
+		idx.resize(len(idx.buckets) / 2)
+	}
+}
+
+// Clear 删除所有 key
+func (idx *ScanIndex) Clear() {
+	idx.buckets = nil
+	idx.size = 0
+}
+
+func (idx *ScanIndex) bucketOf(key string) uint32 {
+	return fnv32(key) & uint32(len(idx.buckets)-1)
+}
+
+func (idx *ScanIndex) resize(n int) {
+	buckets := make([][]string, n)
+	mask := uint32(n - 1)
+	for _, bucket := range idx.buckets {
+		for _, key := range bucket {
+			i := fnv32(key) & mask
+			buckets[i] = append(buckets[i], key)
+		}
+	}
+	idx.buckets = buckets
+}
+
+// Scan 从 cursor 指向的桶开始遍历, 返回至少 count 个 key 或者连续遇到太多空桶后返回下一次遍历使用的游标, 返回 0 表示遍历结束.
+// 同一个桶中的 key 总是在同一次调用中返回
+func (idx *ScanIndex) Scan(cursor uint64, count int, consumer func(key string)) uint64 {
+	next, _ := idx.scan(cursor, max(count, 1), consumer)
+	return next
+}
+
+func (idx *ScanIndex) scan(cursor uint64, count int, consumer func(key string)) (next uint64, scanned int) {
+	if idx.size == 0 || cursor > math.MaxUint32 {
+		return 0, 0
+	}
+	mask := uint64(len(idx.buckets) - 1)
+	// 与 redis 相同, 最多访问 count*10 个空桶, 避免稀疏时一次调用访问过多的桶
+	emptyVisits := count * 10
+	for {
+		bucket := idx.buckets[cursor&mask]
+		for _, key := range bucket {
+			consumer(key)
+		}
+		scanned += len(bucket)
+		if len(bucket) == 0 {
+			emptyVisits--
+		}
+		// 高位全部置1后反向加一, 扩容或缩容前后同一个游标覆盖的桶相同
+		cursor |= ^mask
+		cursor = bits.Reverse64(bits.Reverse64(cursor) + 1)
+		if cursor == 0 || scanned >= count || emptyVisits <= 0 {
+			return cursor, scanned
+		}
+	}
+}
+
+// ScanMap 通过索引对普通 map 进行游标遍历, 返回下一次遍历使用的游标, 返回 0 表示遍历结束
+func ScanMap[V any](m map[string]V, index *ScanIndex, cursor uint64, count int, consumer func(key string, val V)) uint64 {
+	return index.Scan(cursor, count, func(key string) {
+		consumer(key, m[key])
+	})
+}
+
+// Scan 从 cursor 开始遍历, 大约处理 count 个 key 后返回下一次遍历使用的游标, 返回 0 表示遍历结束.
+// 游标的高 32 位是分片下标, 低 32 位是分片内索引的游标
+func (dict *ConcurrentDict) Scan(cursor uint64, count int, consumer func(key string, val any)) uint64 {
+	shardIndex := int(cursor >> 32)
+	start := cursor & math.MaxUint32
+	count = max(count, 1)
+	scanned := 0
+	for shardIndex < len(dict.table) && scanned < count {
+		s := dict.table[shardIndex]
+		s.mutex.RLock()
+		next, n := s.index.scan(start, count-scanned, func(key string) {
+			consumer(key, s.m[key])
+		})
+		s.mutex.RUnlock()
+		scanned += n
+		if next != 0 {
+			return uint64(shardIndex)<<32 | next
+		}
+		shardIndex++
+		start = 0
+	}
+	if shardIndex >= len(dict.table) {
+		return 0
+	}
+	return uint64(shardIndex) << 32
+}
+
+// Scan 对 SimpleDict 进行游标遍历, 游标含义与 ScanIndex 相同
+func (s *SimpleDict) Scan(cursor uint64, count int, consumer func(key string, val any)) uint64 {
+	return ScanMap(s.m, &s.index, cursor, count, consumer)
+}
@@ -0,0 +1,115 @@
+package dict
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentScan(t *testing.T) {
+	d := NewConcurrent(16)
+	for i := 0; i < 1000; i++ {
+		d.Put("key"+strconv.Itoa(i), i)
+	}
+
+	// 遍历期间不断写入和删除其他key
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			key := "tmp" + strconv.Itoa(i%500)
+			if i%2 == 0 {
+				d.Put(key, i)
+			} else {
+				d.Remove(key)
+			}
+		}
+	}()
+
+	seen := make(map[string]struct{})
+	cursor := uint64(0)
+	for {
+		cursor = d.Scan(cursor, 7, func(key string, val any) {
+			seen[key] = struct{}{}
+		})
+		if cursor == 0 {
+			break
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	for i := 0; i < 1000; i++ {
+		if _, ok := seen["key"+strconv.Itoa(i)]; !ok {
+			t.Errorf("key%d is not returned", i)
+		}
+	}
+}
+
+func TestSimpleScan(t *testing.T) {
+	d := NewSimple()
+	for i := 0; i < 100; i++ {
+		d.Put(strconv.Itoa(i), i)
+	}
+	count := 0
+	cursor := uint64(0)
+	for {
+		cursor = d.Scan(cursor, 10, func(key string, val any) {
+			count++
+		})
+		if cursor == 0 {
+			break
+		}
+	}
+	if count != 100 {
+		t.Errorf("expected 100 keys, actual %d", count)
+	}
+}
+
+func TestScanIndexResize(t *testing.T) {
+	idx := &ScanIndex{}
+	for i := 0; i < 1000; i++ {
+		idx.Add(strconv.Itoa(i))
+	}
+	// 遍历期间先扩容再缩容, 一直存在的key都应该被返回, 每次返回的数量与count相近
+	seen := make(map[string]struct{})
+	cursor := uint64(0)
+	for round := 0; ; round++ {
+		page := 0
+		cursor = idx.Scan(cursor, 10, func(key string) {
+			seen[key] = struct{}{}
+			page++
+		})
+		if page > 40 {
+			t.Errorf("expected about 10 keys per call, actual %d", page)
+		}
+		if cursor == 0 {
+			break
+		}
+		switch round {
+		case 5:
+			for i := 1000; i < 5000; i++ {
+				idx.Add(strconv.Itoa(i))
+			}
+		case 20:
+			for i := 100; i < 5000; i++ {
+				idx.Remove(strconv.Itoa(i))
+			}
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := seen[strconv.Itoa(i)]; !ok {
+			t.Errorf("key %d is not returned", i)
+		}
+	}
+	if idx.size != 100 || len(idx.buckets) > 8*idx.size {
+		t.Errorf("expected index to shrink, actual %d keys in %d buckets", idx.size, len(idx.buckets))
+	}
+}
@@ -1,7 +1,8 @@
 package dict
 
 type SimpleDict struct {
-	m map[string]interface{}
+	m     map[string]interface{}
+	index ScanIndex
 }
 
 func NewSimple() *SimpleDict {
@@ -28,6 +29,7 @@ func (s *SimpleDict) Put(key string, val interface{}) (result int) {
 	if existed {
 		return 0
 	}
+	s.index.Add(key)
 	return 1
 }
 
@@ -38,6 +40,7 @@ func (s *SimpleDict) PutIfAbsent(key string, val interface{}) (result int) {
 		return 0
 	}
 	s.m[key] = val
+	s.index.Add(key)
 	return 1
 }
 
@@ -56,6 +59,7 @@ func (s *SimpleDict) Remove(key string) (result int) {
 	_, existed := s.m[key]
 	delete(s.m, key)
 	if existed {
+		s.index.Remove(key)
 		return 1
 	}
 	return 0
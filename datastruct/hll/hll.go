@@ -0,0 +1,315 @@
+package hll
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// 与redis相同的HyperLogLog实现, 数据格式也与redis相同:
+// 16字节的头部("HYLL", 1字节编码, 3字节保留, 8字节小端序的基数缓存), 之后是稀疏或者稠密编码的16384个6位寄存器.
+// 基数缓存最高字节的最高位为1时表示缓存失效
+const (
+	precision     = 14
+	registerCount = 1 << precision
+	registerMask  = registerCount - 1
+	// q 哈希值中用于计算连续0个数的位数
+	q           = 64 - precision
+	registerBit = 6
+	registerMax = 1<<registerBit - 1
+
+	headerSize = 16
+	denseSize  = headerSize + (registerCount*registerBit+7)/8
+
+	encodingDense  = 0
+	encodingSparse = 1
+
+	hashSeed = 0xadc83b19
+
+	// alphaInf 0.5/ln(2)
+	alphaInf = 0.721347520444481703680
+)
+
+const (
+	EncodingDense  = "dense"
+	EncodingSparse = "sparse"
+)
+
+var magic = []byte("HYLL")
+
+var (
+	ErrInvalid   = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	errCorrupted = errors.New("INVALIDOBJ Corrupted HLL object detected")
+)
+
+// HyperLogLog 直接操作redis格式的[]byte, 可以作为普通字符串保存
+type HyperLogLog struct {
+	data []byte
+}
+
+// New 创建一个空的稀疏编码HyperLogLog
+func New() *HyperLogLog {
+	sparse, _ := encodeSparse(make([]uint8, registerCount))
+	data := make([]byte, headerSize, headerSize+len(sparse))
+	copy(data, magic)
+	data[4] = encodingSparse
+	data = append(data, sparse...)
+	return &HyperLogLog{data: data}
+}
+
+// Parse 校验data是否是合法的HyperLogLog, 返回的对象与data共享内存
+func Parse(data []byte) (*HyperLogLog, error) {
+	if len(data) < headerSize || string(data[:4]) != string(magic) || data[4] > encodingSparse {
+		return nil, ErrInvalid
+	}
+	if data[4] == encodingDense && len(data) != denseSize {
+		return nil, ErrInvalid
+	}
+	return &HyperLogLog{data: data}, nil
+}
+
+// Clone 复制一份, 修改前需要复制, 避免影响其他地方引用的字符串
+func (h *HyperLogLog) Clone() *HyperLogLog {
+	data := make([]byte, len(h.data))
+	copy(data, h.data)
+	return &HyperLogLog{data: data}
+}
+
+func (h *HyperLogLog) Bytes() []byte {
+	return h.data
+}
+
+func (h *HyperLogLog) Encoding() string {
+	if h.data[4] == encodingSparse {
+		return EncodingSparse
+	}
+	return EncodingDense
+}
+
+func (h *HyperLogLog) isSparse() bool {
+	return h.data[4] == encodingSparse
+}
+
+func (h *HyperLogLog) invalidateCache() {
+	h.data[15] |= 1 << 7
+}
+
+// getDenseRegister 寄存器按照从低位到高位的顺序紧密排列
+func getDenseRegister(registers []byte, index int) uint8 {
+	b := index * registerBit / 8
+	fb := uint(index * registerBit & 7)
+	value := uint(registers[b]) >> fb
+	if b+1 < len(registers) {
+		value |= uint(registers[b+1]) << (8 - fb)
+	}
+	return uint8(value & registerMax)
+}
+
+func setDenseRegister(registers []byte, index int, value uint8) {
+	b := index * registerBit / 8
+	fb := uint(index * registerBit & 7)
+	v := uint(value)
+	registers[b] &^= byte(registerMax << fb)
+	registers[b] |= byte(v << fb)
+	if b+1 < len(registers) {
+		registers[b+1] &^= byte(registerMax >> (8 - fb))
+		registers[b+1] |= byte(v >> (8 - fb))
+	}
+}
+
+// patternLen 返回元素对应的寄存器, 以及哈希值剩余部分从低位开始第一个1的位置
+func patternLen(element []byte) (int, uint8) {
+	hash := murmurHash64A(element, hashSeed)
+	index := int(hash & registerMask)
+	hash >>= precision
+	// 保证循环能够结束, 结果最大为q+1
+	hash |= 1 << q
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+// Registers 返回每个寄存器一个字节的展开形式
+func (h *HyperLogLog) Registers() ([]uint8, error) {
+	registers := make([]uint8, registerCount)
+	if h.isSparse() {
+		if err := decodeSparse(h.data[headerSize:], registers); err != nil {
+			return nil, err
+		}
+		return registers, nil
+	}
+	dense := h.data[headerSize:]
+	for i := range registers {
+		registers[i] = getDenseRegister(dense, i)
+	}
+	return registers, nil
+}
+
+// setRegisters 用展开的寄存器重写数据, 稀疏编码无法表示或者超过maxSparseBytes时转换为稠密编码
+func (h *HyperLogLog) setRegisters(registers []uint8, maxSparseBytes int) {
+	header := h.data[:headerSize]
+	if h.isSparse() {
+		sparse, ok := encodeSparse(registers)
+		if ok && headerSize+len(sparse) <= maxSparseBytes {
+			h.data = append(header, sparse...)
+			return
+		}
+	}
+	data := make([]byte, denseSize)
+	copy(data, header)
+	data[4] = encodingDense
+	for i, value := range registers {
+		setDenseRegister(data[headerSize:], i, value)
+	}
+	h.data = data
+}
+
+// Add 添加元素, 有寄存器被修改时返回true
+func (h *HyperLogLog) Add(elements [][]byte, maxSparseBytes int) (bool, error) {
+	if !h.isSparse() {
+		changed := false
+		dense := h.data[headerSize:]
+		for _, element := range elements {
+			index, count := patternLen(element)
+			if count > getDenseRegister(dense, index) {
+				setDenseRegister(dense, index, count)
+				changed = true
+			}
+		}
+		if changed {
+			h.invalidateCache()
+		}
+		return changed, nil
+	}
+
+	registers, err := h.Registers()
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	for _, element := range elements {
+		index, count := patternLen(element)
+		if count > registers[index] {
+			registers[index] = count
+			changed = true
+		}
+	}
+	if changed {
+		h.setRegisters(registers, maxSparseBytes)
+		h.invalidateCache()
+	}
+	return changed, nil
+}
+
+// ToDense 转换为稠密编码, 原来是稀疏编码时返回true
+func (h *HyperLogLog) ToDense() (bool, error) {
+	if !h.isSparse() {
+		return false, nil
+	}
+	registers, err := h.Registers()
+	if err != nil {
+		return false, err
+	}
+	h.setRegisters(registers, 0)
+	return true, nil
+}
+
+// Count 返回估算的基数, 缓存有效时直接使用缓存, 否则计算后更新缓存
+func (h *HyperLogLog) Count() (uint64, error) {
+	if h.CacheValid() {
+		return binary.LittleEndian.Uint64(h.data[8:headerSize]), nil
+	}
+	registers, err := h.Registers()
+	if err != nil {
+		return 0, err
+	}
+	count := CountRegisters(registers)
+	binary.LittleEndian.PutUint64(h.data[8:headerSize], count)
+	return count, nil
+}
+
+// CacheValid 基数缓存是否有效
+func (h *HyperLogLog) CacheValid() bool {
+	return h.data[15]&(1<<7) == 0
+}
+
+// Merge 把h的寄存器合并到registers中, 每个寄存器取较大值
+func (h *HyperLogLog) Merge(registers []uint8) error {
+	other, err := h.Registers()
+	if err != nil {
+		return err
+	}
+	for i, value := range other {
+		registers[i] = max(registers[i], value)
+	}
+	return nil
+}
+
+// FromRegisters 用展开的寄存器创建HyperLogLog, dense为false时尽量使用稀疏编码
+func FromRegisters(registers []uint8, dense bool, maxSparseBytes int) *HyperLogLog {
+	h := New()
+	if dense {
+		maxSparseBytes = 0
+	}
+	h.setRegisters(registers, maxSparseBytes)
+	h.invalidateCache()
+	return h
+}
+
+// Describe 返回稀疏编码的文字描述
+func (h *HyperLogLog) Describe() (string, error) {
+	return describeSparse(h.data[headerSize:])
+}
+
+// CountRegisters 使用Ertl提出的改进算法估算基数, 与redis的结果相同
+func CountRegisters(registers []uint8) uint64 {
+	// 稠密编码的寄存器最大可以是63
+	var histogram [registerMax + 1]int
+	for _, value := range registers {
+		histogram[value]++
+	}
+	m := float64(registerCount)
+	z := m * tau((m-float64(histogram[q+1]))/m)
+	for j := q; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * sigma(float64(histogram[0])/m)
+	return uint64(math.Round(alphaInf * m * m / z))
+}
+
+func sigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func tau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package hll
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestSparseEncoding(t *testing.T) {
+	registers := make([]uint8, registerCount)
+	registers[0], registers[1], registers[100], registers[110], registers[registerCount-1] = 3, 3, 32, 2, 1
+	sparse, ok := encodeSparse(registers)
+	if !ok {
+		t.Fatal("registers should be encoded as sparse")
+	}
+	desc, _ := describeSparse(sparse)
+	if desc != "v:3,2 Z:98 v:32,1 z:9 v:2,1 Z:16272 v:1,1" {
+		t.Errorf("wrong sparse encoding %s", desc)
+	}
+	decoded := make([]uint8, registerCount)
+	if err := decodeSparse(sparse, decoded); err != nil || string(decoded) != string(registers) {
+		t.Error("decoded registers are different")
+	}
+	if err := decodeSparse(sparse[:len(sparse)-1], decoded); err != errCorrupted {
+		t.Error("truncated sparse encoding should be corrupted")
+	}
+	registers[5] = 33
+	if _, ok := encodeSparse(registers); ok {
+		t.Error("value greater than 32 can't be encoded as sparse")
+	}
+}
+
+func TestCount(t *testing.T) {
+	h := New()
+	if count, _ := h.Count(); count != 0 {
+		t.Errorf("empty hll should count 0, actual %d", count)
+	}
+	for _, n := range []int{7, 1000, 100000} {
+		h = New()
+		elements := make([][]byte, n)
+		for i := range elements {
+			elements[i] = []byte("element:" + strconv.Itoa(i))
+		}
+		if changed, err := h.Add(elements, 3000); !changed || err != nil {
+			t.Fatalf("add should change registers, err: %v", err)
+		}
+		count, _ := h.Count()
+		if errRate := math.Abs(float64(count)-float64(n)) / float64(n); errRate > 0.03 {
+			t.Errorf("count %d is too far from %d", count, n)
+		}
+		if !h.CacheValid() {
+			t.Error("cache should be valid after count")
+		}
+		if n > 1000 && h.Encoding() != EncodingDense {
+			t.Error("large hll should be promoted to dense")
+		}
+		parsed, err := Parse(h.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		registers, _ := parsed.Registers()
+		if CountRegisters(registers) != count {
+			t.Error("count of parsed hll is different")
+		}
+	}
+}
+
+func TestDenseRegisters(t *testing.T) {
+	dense := make([]byte, denseSize-headerSize)
+	for i := 0; i < registerCount; i++ {
+		setDenseRegister(dense, i, uint8(i%64))
+	}
+	for i := 0; i < registerCount; i++ {
+		if v := getDenseRegister(dense, i); v != uint8(i%64) {
+			t.Fatalf("register %d: expected %d, actual %d", i, i%64, v)
+		}
+	}
+	if _, err := Parse([]byte("HYLL\x00")); err != ErrInvalid {
+		t.Error("short data should be invalid")
+	}
+}
@@ -0,0 +1,125 @@
+package hll
+
+import (
+	"strconv"
+	"strings"
+)
+
+// 稀疏编码由三种操作码组成:
+//
+//	ZERO  00xxxxxx          连续xxxxxx+1个值为0的寄存器, 1到64个
+//	XZERO 01xxxxxx yyyyyyyy 连续xxxxxxyyyyyyyy+1个值为0的寄存器, 1到16384个
+//	VAL   1vvvvvxx          连续xx+1个值为vvvvv+1的寄存器, 值为1到32, 1到4个
+const (
+	sparseZeroMaxLen  = 64
+	sparseXZeroMaxLen = 16384
+	sparseValMaxValue = 32
+	sparseValMaxLen   = 4
+)
+
+func isZero(op byte) bool {
+	return op&0xc0 == 0
+}
+
+func isXZero(op byte) bool {
+	return op&0xc0 == 0x40
+}
+
+// forEachSparse 依次遍历稀疏编码中的每段连续寄存器, 编码不合法时返回errCorrupted
+func forEachSparse(sparse []byte, consumer func(value uint8, runLen int)) error {
+	total := 0
+	for i := 0; i < len(sparse); i++ {
+		op := sparse[i]
+		var value uint8
+		var runLen int
+		switch {
+		case isZero(op):
+			runLen = int(op&0x3f) + 1
+		case isXZero(op):
+			if i+1 >= len(sparse) {
+				return errCorrupted
+			}
+			runLen = (int(op&0x3f)<<8 | int(sparse[i+1])) + 1
+			i++
+		default:
+			value = (op>>2)&0x1f + 1
+			runLen = int(op&0x3) + 1
+		}
+		total += runLen
+		if total > registerCount {
+			return errCorrupted
+		}
+		consumer(value, runLen)
+	}
+	if total != registerCount {
+		return errCorrupted
+	}
+	return nil
+}
+
+// decodeSparse 把稀疏编码展开为每个寄存器一个字节
+func decodeSparse(sparse []byte, registers []uint8) error {
+	index := 0
+	return forEachSparse(sparse, func(value uint8, runLen int) {
+		for range runLen {
+			registers[index] = value
+			index++
+		}
+	})
+}
+
+// encodeSparse 把寄存器编码为稀疏编码, 有寄存器的值超过32时返回false
+func encodeSparse(registers []uint8) ([]byte, bool) {
+	sparse := make([]byte, 0)
+	for i := 0; i < len(registers); {
+		value := registers[i]
+		if value > sparseValMaxValue {
+			return nil, false
+		}
+		j := i + 1
+		for j < len(registers) && registers[j] == value {
+			j++
+		}
+		runLen := j - i
+		if value == 0 {
+			for runLen > 0 {
+				n := min(runLen, sparseXZeroMaxLen)
+				if n <= sparseZeroMaxLen {
+					sparse = append(sparse, byte(n-1))
+				} else {
+					sparse = append(sparse, 0x40|byte((n-1)>>8), byte(n-1))
+				}
+				runLen -= n
+			}
+		} else {
+			for runLen > 0 {
+				n := min(runLen, sparseValMaxLen)
+				sparse = append(sparse, 0x80|(value-1)<<2|byte(n-1))
+				runLen -= n
+			}
+		}
+		i = j
+	}
+	return sparse, true
+}
+
+// describeSparse 与redis的PFDEBUG DECODE输出格式相同
+func describeSparse(sparse []byte) (string, error) {
+	parts := make([]string, 0)
+	for i := 0; i < len(sparse); i++ {
+		op := sparse[i]
+		switch {
+		case isZero(op):
+			parts = append(parts, "z:"+strconv.Itoa(int(op&0x3f)+1))
+		case isXZero(op):
+			if i+1 >= len(sparse) {
+				return "", errCorrupted
+			}
+			parts = append(parts, "Z:"+strconv.Itoa((int(op&0x3f)<<8|int(sparse[i+1]))+1))
+			i++
+		default:
+			parts = append(parts, "v:"+strconv.Itoa(int((op>>2)&0x1f)+1)+","+strconv.Itoa(int(op&0x3)+1))
+		}
+	}
+	return strings.Join(parts, " "), nil
+}
@@ -0,0 +1,30 @@
+package list
+
+// Expected 判断元素是否是要查找的值
+type Expected func(a any) bool
+
+// Consumer 遍历列表, i 是元素下标, 返回false时停止遍历
+type Consumer func(i int, v any) bool
+
+type List interface {
+	Add(val any)
+	Get(index int) (val any)
+	Set(index int, val any)
+	Insert(index int, val any)
+	Remove(index int) (val any)
+	RemoveLast() (val any)
+	RemoveAllByVal(expected Expected) int
+	// RemoveByVal 从表头开始删除最多count个匹配的元素
+	RemoveByVal(expected Expected, count int) int
+	// ReverseRemoveByVal 从表尾开始删除最多count个匹配的元素
+	ReverseRemoveByVal(expected Expected, count int) int
+	Len() int
+	ForEach(consumer Consumer)
+	// ReverseForEach 从表尾开始遍历, 传给consumer的仍然是元素的正向下标
+	ReverseForEach(consumer Consumer)
+	Contains(expected Expected) bool
+	// Range 返回下标在[start, stop)之间的元素
+	Range(start int, stop int) []any
+	// Trim 只保留下标在[start, stop)之间的元素
+	Trim(start int, stop int)
+}
@@ -0,0 +1,431 @@
+package list
+
+import "container/list"
+
+// pageSize 每个分段最多保存的元素数量, 两端的插入和删除只会移动一个分段内的元素
+const pageSize = 1024
+
+// QuickList 由若干个分段组成的双向链表, 每个分段是一个有界的切片
+type QuickList struct {
+	data *list.List // 元素类型是 []any
+	size int
+}
+
+// iterator 指向某个分段中的某个元素
+type iterator struct {
+	node   *list.Element
+	offset int
+	ql     *QuickList
+}
+
+func NewQuickList() *QuickList {
+	return &QuickList{
+		data: list.New(),
+	}
+}
+
+// Add 在表尾添加元素
+func (ql *QuickList) Add(val any) {
+	ql.size++
+	if ql.data.Len() == 0 {
+		page := make([]any, 0, pageSize)
+		page = append(page, val)
+		ql.data.PushBack(page)
+		return
+	}
+	backNode := ql.data.Back()
+	backPage := backNode.Value.([]any)
+	if len(backPage) >= pageSize {
+		page := make([]any, 0, pageSize)
+		page = append(page, val)
+		ql.data.PushBack(page)
+		return
+	}
+	backNode.Value = append(backPage, val)
+}
+
+// find 返回指向下标为index的元素的迭代器, 根据下标选择从表头或表尾开始查找
+func (ql *QuickList) find(index int) *iterator {
+	if ql == nil {
+		panic("list is nil")
+	}
+	if index < 0 || index >= ql.size {
+		panic("index out of bound")
+	}
+	var n *list.Element
+	var page []any
+	var pageBeg int
+	if index < ql.size/2 {
+		n = ql.data.Front()
+		pageBeg = 0
+		for {
+			page = n.Value.([]any)
+			if pageBeg+len(page) > index {
+				break
+			}
+			pageBeg += len(page)
+			n = n.Next()
+		}
+	} else {
+		n = ql.data.Back()
+		pageBeg = ql.size
+		for {
+			page = n.Value.([]any)
+			pageBeg -= len(page)
+			if pageBeg <= index {
+				break
+			}
+			n = n.Prev()
+		}
+	}
+	return &iterator{
+		node:   n,
+		offset: index - pageBeg,
+		ql:     ql,
+	}
+}
+
+func (iter *iterator) get() any {
+	return iter.page()[iter.offset]
+}
+
+func (iter *iterator) page() []any {
+	return iter.node.Value.([]any)
+}
+
+// next 移动到下一个元素, 已经是最后一个元素时返回false
+func (iter *iterator) next() bool {
+	page := iter.page()
+	if iter.offset < len(page)-1 {
+		iter.offset++
+		return true
+	}
+	if iter.node == iter.ql.data.Back() {
+		iter.offset = len(page)
+		return false
+	}
+	iter.offset = 0
+	iter.node = iter.node.Next()
+	return true
+}
+
+// prev 移动到上一个元素, 已经是第一个元素时返回false
+func (iter *iterator) prev() bool {
+	if iter.offset > 0 {
+		iter.offset--
+		return true
+	}
+	if iter.node == iter.ql.data.Front() {
+		iter.offset = -1
+		return false
+	}
+	iter.node = iter.node.Prev()
+	iter.offset = len(iter.page()) - 1
+	return true
+}
+
+func (iter *iterator) atEnd() bool {
+	if iter.ql.data.Len() == 0 {
+		return true
+	}
+	if iter.node != iter.ql.data.Back() {
+		return false
+	}
+	return iter.offset == len(iter.page())
+}
+
+func (iter *iterator) atBegin() bool {
+	if iter.ql.data.Len() == 0 {
+		return true
+	}
+	if iter.node != iter.ql.data.Front() {
+		return false
+	}
+	return iter.offset == -1
+}
+
+func (iter *iterator) set(val any) {
+	iter.page()[iter.offset] = val
+}
+
+// remove 删除迭代器指向的元素, 迭代器移动到原来的下一个元素
+func (iter *iterator) remove() any {
+	page := iter.page()
+	val := page[iter.offset]
+	page = append(page[:iter.offset], page[iter.offset+1:]...)
+	iter.ql.size--
+	if len(page) > 0 {
+		iter.node.Value = page
+		if iter.offset == len(page) {
+			// 删除的是分段中的最后一个元素
+			if iter.node != iter.ql.data.Back() {
+				iter.node = iter.node.Next()
+				iter.offset = 0
+			}
+			// 否则迭代器停在表尾之后
+		}
+		return val
+	}
+	// 分段已经为空, 删除分段
+	if iter.node == iter.ql.data.Back() {
+		if prevNode := iter.node.Prev(); prevNode != nil {
+			iter.ql.data.Remove(iter.node)
+			iter.node = prevNode
+			iter.offset = len(prevNode.Value.([]any))
+		} else {
+			// 删除的是最后一个元素
+			iter.ql.data.Remove(iter.node)
+			iter.node = nil
+			iter.offset = 0
+		}
+		return val
+	}
+	nextNode := iter.node.Next()
+	iter.ql.data.Remove(iter.node)
+	iter.node = nextNode
+	iter.offset = 0
+	return val
+}
+
+func (ql *QuickList) Get(index int) (val any) {
+	iter := ql.find(index)
+	return iter.get()
+}
+
+func (ql *QuickList) Set(index int, val any) {
+	iter := ql.find(index)
+	iter.set(val)
+}
+
+// Insert 在index处插入元素, 原来位于index及之后的元素向后移动
+func (ql *QuickList) Insert(index int, val any) {
+	if index == ql.size {
+		ql.Add(val)
+		return
+	}
+	iter := ql.find(index)
+	page := iter.page()
+	if index == 0 && len(page) >= pageSize {
+		// 表头分段已满时直接在前面新建分段, 保证LPUSH不需要拆分分段
+		newPage := make([]any, 0, pageSize)
+		newPage = append(newPage, val)
+		ql.data.PushFront(newPage)
+		ql.size++
+		return
+	}
+	if len(page) < pageSize {
+		page = append(page[:iter.offset+1], page[iter.offset:]...)
+		page[iter.offset] = val
+		iter.node.Value = page
+		ql.size++
+		return
+	}
+	// 分段已满, 拆分成两个分段
+	var nextPage []any
+	nextPage = append(nextPage, page[pageSize/2:]...)
+	page = page[:pageSize/2]
+	if iter.offset < len(page) {
+		page = append(page[:iter.offset+1], page[iter.offset:]...)
+		page[iter.offset] = val
+	} else {
+		i := iter.offset - pageSize/2
+		nextPage = append(nextPage[:i+1], nextPage[i:]...)
+		nextPage[i] = val
+	}
+	iter.node.Value = page
+	ql.data.InsertAfter(nextPage, iter.node)
+	ql.size++
+}
+
+func (ql *QuickList) Remove(index int) any {
+	iter := ql.find(index)
+	return iter.remove()
+}
+
+func (ql *QuickList) Len() int {
+	return ql.size
+}
+
+func (ql *QuickList) RemoveLast() any {
+	if ql.Len() == 0 {
+		return nil
+	}
+	ql.size--
+	lastNode := ql.data.Back()
+	lastPage := lastNode.Value.([]any)
+	if len(lastPage) == 1 {
+		ql.data.Remove(lastNode)
+		return lastPage[0]
+	}
+	val := lastPage[len(lastPage)-1]
+	lastPage = lastPage[:len(lastPage)-1]
+	lastNode.Value = lastPage
+	return val
+}
+
+// Trim 只保留下标在[start, stop)之间的元素. 两端被完整删除的分段直接丢弃, 部分删除的分段只切片一次
+func (ql *QuickList) Trim(start int, stop int) {
+	if start >= stop {
+		ql.data.Init()
+		ql.size = 0
+		return
+	}
+	for n := ql.size - stop; n > 0; {
+		node := ql.data.Back()
+		page := node.Value.([]any)
+		if len(page) <= n {
+			ql.data.Remove(node)
+			n -= len(page)
+			continue
+		}
+		clear(page[len(page)-n:])
+		node.Value = page[:len(page)-n]
+		break
+	}
+	for n := start; n > 0; {
+		node := ql.data.Front()
+		page := node.Value.([]any)
+		if len(page) <= n {
+			ql.data.Remove(node)
+			n -= len(page)
+			continue
+		}
+		// 剩余的元素移动到分段开头, 保留分段的容量
+		remain := copy(page, page[n:])
+		clear(page[remain:])
+		node.Value = page[:remain]
+		break
+	}
+	ql.size = stop - start
+}
+
+func (ql *QuickList) RemoveAllByVal(expected Expected) int {
+	if ql.size == 0 {
+		return 0
+	}
+	iter := ql.find(0)
+	removed := 0
+	for !iter.atEnd() {
+		if expected(iter.get()) {
+			iter.remove()
+			removed++
+			if ql.size == 0 {
+				break
+			}
+		} else {
+			iter.next()
+		}
+	}
+	return removed
+}
+
+func (ql *QuickList) RemoveByVal(expected Expected, count int) int {
+	if ql.size == 0 {
+		return 0
+	}
+	iter := ql.find(0)
+	removed := 0
+	for !iter.atEnd() && removed < count {
+		if expected(iter.get()) {
+			iter.remove()
+			removed++
+			if ql.size == 0 {
+				break
+			}
+		} else {
+			iter.next()
+		}
+	}
+	return removed
+}
+
+func (ql *QuickList) ReverseRemoveByVal(expected Expected, count int) int {
+	if ql.size == 0 {
+		return 0
+	}
+	iter := ql.find(ql.size - 1)
+	removed := 0
+	for !iter.atBegin() && removed < count {
+		if expected(iter.get()) {
+			iter.remove()
+			removed++
+			if ql.size == 0 {
+				break
+			}
+		}
+		// remove 之后迭代器指向被删除元素的下一个元素, 同样退回一步即可
+		iter.prev()
+	}
+	return removed
+}
+
+func (ql *QuickList) ForEach(consumer Consumer) {
+	if ql == nil {
+		panic("list is nil")
+	}
+	if ql.Len() == 0 {
+		return
+	}
+	iter := ql.find(0)
+	i := 0
+	for {
+		if !consumer(i, iter.get()) {
+			break
+		}
+		if !iter.next() {
+			break
+		}
+		i++
+	}
+}
+
+func (ql *QuickList) ReverseForEach(consumer Consumer) {
+	if ql == nil {
+		panic("list is nil")
+	}
+	if ql.Len() == 0 {
+		return
+	}
+	iter := ql.find(ql.size - 1)
+	i := ql.size - 1
+	for {
+		if !consumer(i, iter.get()) {
+			break
+		}
+		if !iter.prev() {
+			break
+		}
+		i--
+	}
+}
+
+func (ql *QuickList) Contains(expected Expected) bool {
+	contains := false
+	ql.ForEach(func(i int, actual any) bool {
+		if expected(actual) {
+			contains = true
+			return false
+		}
+		return true
+	})
+	return contains
+}
+
+func (ql *QuickList) Range(start int, stop int) []any {
+	if start < 0 || start >= ql.Len() {
+		panic("`start` out of range")
+	}
+	if stop < start || stop > ql.Len() {
+		panic("`stop` out of range")
+	}
+	sliceSize := stop - start
+	slice := make([]any, 0, sliceSize)
+	iter := ql.find(start)
+	i := 0
+	for i < sliceSize {
+		slice = append(slice, iter.get())
+		iter.next()
+		i++
+	}
+	return slice
+}
@@ -0,0 +1,139 @@
+package list
+
+import "testing"
+
+func toInts(l List) []int {
+	result := make([]int, 0, l.Len())
+	l.ForEach(func(i int, v any) bool {
+		result = append(result, v.(int))
+		return true
+	})
+	return result
+}
+
+func assertList(t *testing.T, l List, expected []int) {
+	t.Helper()
+	actual := toInts(l)
+	if len(actual) != len(expected) || l.Len() != len(expected) {
+		t.Fatalf("expected len %d, actual %d (Len() %d)", len(expected), len(actual), l.Len())
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("index %d: expected %d, actual %d", i, expected[i], actual[i])
+		}
+	}
+}
+
+func TestQuickList(t *testing.T) {
+	ql := NewQuickList()
+	var expected []int
+	// 跨越多个分段, 覆盖分段拆分和删除空分段
+	for i := 0; i < pageSize*3; i++ {
+		if i%2 == 0 {
+			ql.Add(i)
+			expected = append(expected, i)
+		} else {
+			ql.Insert(0, i)
+			expected = append([]int{i}, expected...)
+		}
+	}
+	assertList(t, ql, expected)
+
+	mid := len(expected) / 2
+	ql.Insert(mid, -1)
+	expected = append(expected[:mid], append([]int{-1}, expected[mid:]...)...)
+	assertList(t, ql, expected)
+
+	if v := ql.Remove(mid); v != -1 {
+		t.Fatalf("expected -1, actual %v", v)
+	}
+	expected = append(expected[:mid], expected[mid+1:]...)
+	assertList(t, ql, expected)
+
+	if v := ql.RemoveLast(); v != expected[len(expected)-1] {
+		t.Fatalf("remove last: expected %d, actual %v", expected[len(expected)-1], v)
+	}
+	expected = expected[:len(expected)-1]
+
+	for i := 0; i < pageSize+10; i++ {
+		ql.Remove(0)
+	}
+	expected = expected[pageSize+10:]
+	assertList(t, ql, expected)
+
+	for i, v := range ql.Range(10, 20) {
+		if v != expected[10+i] {
+			t.Fatalf("range index %d: expected %d, actual %v", i, expected[10+i], v)
+		}
+	}
+	reversed := make([]int, 0)
+	ql.ReverseForEach(func(i int, v any) bool {
+		if v != expected[i] {
+			t.Fatalf("reverse index %d: expected %d, actual %v", i, expected[i], v)
+		}
+		reversed = append(reversed, v.(int))
+		return true
+	})
+	if len(reversed) != len(expected) {
+		t.Fatalf("reverse foreach visited %d elements", len(reversed))
+	}
+}
+
+func TestTrim(t *testing.T) {
+	ql := NewQuickList()
+	var expected []int
+	for i := 0; i < pageSize*4; i++ {
+		ql.Insert(0, i)
+		expected = append([]int{i}, expected...)
+	}
+	start, stop := pageSize+3, pageSize*3-5
+	ql.Trim(start, stop)
+	expected = expected[start:stop]
+	assertList(t, ql, expected)
+
+	ql.Insert(0, -1)
+	ql.Add(-2)
+	expected = append(append([]int{-1}, expected...), -2)
+	assertList(t, ql, expected)
+
+	ql.Trim(3, 3)
+	assertList(t, ql, nil)
+	ql.Add(1)
+	assertList(t, ql, []int{1})
+}
+
+func TestRemoveByVal(t *testing.T) {
+	ql := NewQuickList()
+	for i := 0; i < pageSize*2; i++ {
+		ql.Add(i % 4)
+	}
+	isZero := func(a any) bool { return a == 0 }
+	if removed := ql.RemoveByVal(isZero, 3); removed != 3 {
+		t.Fatalf("expected 3 removed, actual %d", removed)
+	}
+	if ql.Get(0) != 1 {
+		t.Fatalf("first zeros should be removed from head")
+	}
+	if removed := ql.ReverseRemoveByVal(isZero, 2); removed != 2 {
+		t.Fatalf("expected 2 removed, actual %d", removed)
+	}
+	tail := ql.Range(ql.Len()-6, ql.Len())
+	for i, v := range []int{1, 2, 3, 1, 2, 3} {
+		if tail[i] != v {
+			t.Fatalf("last zeros should be removed from tail, actual %v", tail)
+		}
+	}
+	total := pageSize*2/4 - 5
+	if removed := ql.RemoveAllByVal(isZero); removed != total {
+		t.Fatalf("expected %d removed, actual %d", total, removed)
+	}
+	if ql.Contains(isZero) {
+		t.Fatal("all zeros should be removed")
+	}
+	isOne := func(a any) bool { return a == 1 }
+	ql.RemoveAllByVal(isOne)
+	ql.ReverseRemoveByVal(func(a any) bool { return true }, ql.Len())
+	if ql.Len() != 0 {
+		t.Fatalf("expected empty list, actual %d", ql.Len())
+	}
+}
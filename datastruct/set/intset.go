@@ -0,0 +1,193 @@
+package set
+
+import (
+	"goredis/lib/utils"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+const (
+	EncodingIntSet    = "intset"
+	EncodingHashTable = "hashtable"
+)
+
+// IntSet 整数集合, 元素按从小到大的顺序紧凑地保存在切片中, 通过二分查找定位.
+// 加入非整数元素或者元素数量超过maxEntries后升级为哈希表编码, 之后的操作都转发给哈希表
+type IntSet struct {
+	contents   []int64
+	maxEntries int
+	// hash 升级后的哈希表, 为nil时使用整数集合编码
+	hash Set
+}
+
+// NewIntSet 创建一个整数集合, 元素数量超过maxEntries后升级为哈希表编码
+func NewIntSet(maxEntries int, members ...string) *IntSet {
+	s := &IntSet{maxEntries: maxEntries}
+	for _, member := range members {
+		s.Add(member)
+	}
+	return s
+}
+
+// parseIntMember 判断元素能否用整数集合保存, 只接受规范的十进制表示, 保证转换回字符串后不变
+func parseIntMember(member string) (int64, bool) {
+	value, err := strconv.ParseInt(member, 10, 64)
+	if err != nil || strconv.FormatInt(value, 10) != member {
+		return 0, false
+	}
+	return value, true
+}
+
+// search 返回value的位置, 不存在时返回应当插入的位置
+func (s *IntSet) search(value int64) (int, bool) {
+	i := sort.Search(len(s.contents), func(i int) bool {
+		return s.contents[i] >= value
+	})
+	return i, i < len(s.contents) && s.contents[i] == value
+}
+
+// upgrade 转换为哈希表编码
+func (s *IntSet) upgrade() {
+	hash := New()
+	for _, value := range s.contents {
+		hash.Add(strconv.FormatInt(value, 10))
+	}
+	s.hash = hash
+	s.contents = nil
+}
+
+func (s *IntSet) Add(val string) int {
+	if s.hash != nil {
+		return s.hash.Add(val)
+	}
+	value, ok := parseIntMember(val)
+	if !ok {
+		s.upgrade()
+		return s.hash.Add(val)
+	}
+	i, exists := s.search(value)
+	if exists {
+		return 0
+	}
+	if len(s.contents) >= s.maxEntries {
+		s.upgrade()
+		return s.hash.Add(val)
+	}
+	s.contents = append(s.contents, 0)
+	copy(s.contents[i+1:], s.contents[i:])
+	s.contents[i] = value
+	return 1
+}
+
+func (s *IntSet) Remove(val string) int {
+	if s.hash != nil {
+		return s.hash.Remove(val)
+	}
+	value, ok := parseIntMember(val)
+	if !ok {
+		return 0
+	}
+	i, exists := s.search(value)
+	if !exists {
+		return 0
+	}
+	s.contents = append(s.contents[:i], s.contents[i+1:]...)
+	return 1
+}
+
+func (s *IntSet) Has(val string) bool {
+	if s.hash != nil {
+		return s.hash.Has(val)
+	}
+	value, ok := parseIntMember(val)
+	if !ok {
+		return false
+	}
+	_, exists := s.search(value)
+	return exists
+}
+
+func (s *IntSet) Len() int {
+	if s.hash != nil {
+		return s.hash.Len()
+	}
+	return len(s.contents)
+}
+
+func (s *IntSet) ToSlice() []string {
+	if s.hash != nil {
+		return s.hash.ToSlice()
+	}
+	slice := make([]string, len(s.contents))
+	for i, value := range s.contents {
+		slice[i] = strconv.FormatInt(value, 10)
+	}
+	return slice
+}
+
+func (s *IntSet) ForEach(consumer func(member string) bool) {
+	if s.hash != nil {
+		s.hash.ForEach(consumer)
+		return
+	}
+	for _, value := range s.contents {
+		if !consumer(strconv.FormatInt(value, 10)) {
+			break
+		}
+	}
+}
+
+func (s *IntSet) Copy() Set {
+	if s.hash != nil {
+		return s.hash.Copy()
+	}
+	contents := make([]int64, len(s.contents))
+	copy(contents, s.contents)
+	return &IntSet{contents: contents, maxEntries: s.maxEntries}
+}
+
+func (s *IntSet) RandomMembers(limit int) []string {
+	if s.hash != nil {
+		return s.hash.RandomMembers(limit)
+	}
+	if len(s.contents) == 0 {
+		return nil
+	}
+	members := make([]string, limit)
+	for i := range members {
+		members[i] = strconv.FormatInt(s.contents[rand.Intn(len(s.contents))], 10)
+	}
+	return members
+}
+
+func (s *IntSet) RandomDistinctMembers(limit int) []string {
+	if s.hash != nil {
+		return s.hash.RandomDistinctMembers(limit)
+	}
+	limit = min(limit, len(s.contents))
+	members := make([]string, limit)
+	for i, index := range utils.RandDistinctInts(len(s.contents), limit) {
+		members[i] = strconv.FormatInt(s.contents[index], 10)
+	}
+	return members
+}
+
+// Scan 与redis一样, 整数集合编码时一次返回所有元素
+func (s *IntSet) Scan(cursor uint64, count int, consumer func(member string)) uint64 {
+	if s.hash != nil {
+		return s.hash.Scan(cursor, count, consumer)
+	}
+	s.ForEach(func(member string) bool {
+		consumer(member)
+		return true
+	})
+	return 0
+}
+
+func (s *IntSet) Encoding() string {
+	if s.hash != nil {
+		return s.hash.Encoding()
+	}
+	return EncodingIntSet
+}
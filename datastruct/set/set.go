@@ -1,5 +1,12 @@
 package set
 
+import (
+	"goredis/datastruct/dict"
+	"goredis/lib/utils"
+	"math/rand"
+	"sort"
+)
+
 type Set interface {
 	Add(val string) int
 	Remove(val string) int
@@ -10,19 +17,24 @@ type Set interface {
 	Copy() Set
 	RandomMembers(limit int) []string
 	RandomDistinctMembers(limit int) []string
+	// Scan 游标遍历, 返回0表示遍历结束
+	Scan(cursor uint64, count int, consumer func(member string)) uint64
+	// Encoding 返回底层编码, 用于OBJECT ENCODING
+	Encoding() string
 }
 
 type set struct {
-	set map[string]struct{}
+	set   map[string]struct{}
+	index dict.ScanIndex
 }
 
 // New 创建一个新的集合实例
 func New(members ...string) Set {
-	s := make(map[string]struct{}, len(members))
+	s := &set{set: make(map[string]struct{}, len(members))}
 	for _, member := range members {
-		s[member] = struct{}{}
+		s.Add(member)
 	}
-	return &set{set: s}
+	return s
 }
 
 func (s *set) Add(val string) int {
@@ -31,6 +43,7 @@ func (s *set) Add(val string) int {
 		return 0
 	}
 	s.set[val] = struct{}{}
+	s.index.Add(val)
 	return 1
 }
 
@@ -38,6 +51,7 @@ func (s *set) Remove(val string) int {
 	_, ok := s.set[val]
 	if ok {
 		delete(s.set, val)
+		s.index.Remove(val)
 		return 1
 	}
 	return 0
@@ -80,45 +94,109 @@ func (s *set) Copy() Set {
 	return newSet
 }
 
+// RandomMembers 随机返回limit个元素, 可能包含重复的元素
 func (s *set) RandomMembers(limit int) []string {
+	if s == nil || len(s.set) == 0 {
+		return nil
+	}
+	if limit == 1 {
+		return []string{s.randomMember()}
+	}
+	positions := make([]int, limit)
+	for i := range positions {
+		positions[i] = rand.Intn(len(s.set))
+	}
+	return s.pick(positions)
+}
+
+// RandomDistinctMembers 随机返回最多limit个不重复的元素
+func (s *set) RandomDistinctMembers(limit int) []string {
 	if s == nil {
 		return nil
 	}
-	randomMembers := make([]string, 0, limit)
-	for s := range s.set {
-		randomMembers = append(randomMembers, s)
-		if len(randomMembers) == limit {
+	if limit == 1 && len(s.set) > 0 {
+		return []string{s.randomMember()}
+	}
+	return s.pick(utils.RandDistinctInts(len(s.set), min(limit, len(s.set))))
+}
+
+// randomMember 返回一个随机的元素. map的遍历从随机的位置开始, 只取第一个元素, 不需要像pick一样遍历
+func (s *set) randomMember() string {
+	for member := range s.set {
+		return member
+	}
+	return ""
+}
+
+// pick map不支持随机访问, 先随机选出位置, 再遍历一次取出这些位置上的元素, 不需要复制整个集合
+func (s *set) pick(positions []int) []string {
+	sort.Ints(positions)
+	members := make([]string, 0, len(positions))
+	i := 0
+	for member := range s.set {
+		// 可重复时同一个位置可能被选中多次
+		for len(members) < len(positions) && positions[len(members)] == i {
+			members = append(members, member)
+		}
+		if len(members) == len(positions) {
 			break
 		}
+		i++
 	}
-	return randomMembers
+	rand.Shuffle(len(members), func(i, j int) {
+		members[i], members[j] = members[j], members[i]
+	})
+	return members
 }
 
-func (s *set) RandomDistinctMembers(limit int) []string {
-	return s.RandomMembers(limit)
+func (s *set) Scan(cursor uint64, count int, consumer func(member string)) uint64 {
+	return s.index.Scan(cursor, count, consumer)
+}
+
+func (s *set) Encoding() string {
+	return EncodingHashTable
 }
 
-// Intersect 返回多个set的交集
+// Intersect 返回多个set的交集, 遍历最小的set并在其余的set中检查
 func Intersect(sets ...Set) Set {
 	newSet := New()
+	intersect(sets, func(member string) bool {
+		newSet.Add(member)
+		return true
+	})
+	return newSet
+}
+
+// IntersectCard 返回多个set交集的大小, limit大于0时计数达到limit后停止
+func IntersectCard(limit int, sets ...Set) int {
+	count := 0
+	intersect(sets, func(member string) bool {
+		count++
+		return limit <= 0 || count < limit
+	})
+	return count
+}
+
+func intersect(sets []Set, consumer func(member string) bool) {
 	if len(sets) == 0 {
-		return newSet
+		return
 	}
-
-	countMap := make(map[string]int)
-	for _, s := range sets {
-		s.ForEach(func(member string) bool {
-			countMap[member]++
-			return true
-		})
+	sorted := make([]Set, len(sets))
+	copy(sorted, sets)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Len() < sorted[j].Len()
+	})
+	if sorted[0].Len() == 0 {
+		return
 	}
-	totalLen := len(sets)
-	for k, v := range countMap {
-		if v == totalLen {
-			newSet.Add(k)
+	sorted[0].ForEach(func(member string) bool {
+		for _, s := range sorted[1:] {
+			if !s.Has(member) {
+				return true
+			}
 		}
-	}
-	return newSet
+		return consumer(member)
+	})
 }
 
 // Union 返回多个set的并集
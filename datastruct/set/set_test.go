@@ -0,0 +1,108 @@
+package set
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func assertMembers(t *testing.T, s Set, expected ...string) {
+	t.Helper()
+	actual := s.ToSlice()
+	sort.Strings(actual)
+	sort.Strings(expected)
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %v, actual %v", expected, actual)
+	}
+	for i := range actual {
+		if actual[i] != expected[i] {
+			t.Fatalf("expected %v, actual %v", expected, actual)
+		}
+	}
+}
+
+func TestAlgebra(t *testing.T) {
+	a := New("a", "b", "c", "d")
+	b := New("b", "c", "e")
+	c := New("c", "b")
+	assertMembers(t, Intersect(a, b, c), "b", "c")
+	assertMembers(t, Intersect(a, New()))
+	assertMembers(t, Union(a, b), "a", "b", "c", "d", "e")
+	assertMembers(t, Diff(a, b), "a", "d")
+	assertMembers(t, Diff(c, a))
+	if n := IntersectCard(0, a, b); n != 2 {
+		t.Errorf("expected 2, actual %d", n)
+	}
+	if n := IntersectCard(1, a, b); n != 1 {
+		t.Errorf("expected 1, actual %d", n)
+	}
+}
+
+func TestRandomMembers(t *testing.T) {
+	s := New("a", "b", "c")
+	if members := s.RandomMembers(10); len(members) != 10 {
+		t.Errorf("expected 10 members, actual %d", len(members))
+	}
+	distinct := New(s.RandomDistinctMembers(10)...)
+	assertMembers(t, distinct, "a", "b", "c")
+	if members := New().RandomMembers(3); len(members) != 0 {
+		t.Errorf("expected no members, actual %v", members)
+	}
+}
+
+func TestIntSet(t *testing.T) {
+	s := NewIntSet(4, "3", "1", "2", "1")
+	if s.Len() != 3 || s.Encoding() != EncodingIntSet {
+		t.Fatalf("expected intset with 3 members, actual %s with %d", s.Encoding(), s.Len())
+	}
+	if !s.Has("2") || s.Has("02") || s.Has("a") {
+		t.Error("wrong membership")
+	}
+	if members := s.ToSlice(); members[0] != "1" || members[2] != "3" {
+		t.Errorf("members should be sorted, actual %v", members)
+	}
+	if s.Remove("2") != 1 || s.Remove("2") != 0 {
+		t.Error("wrong remove result")
+	}
+
+	s.Add("-7")
+	s.Add("100")
+	if s.Encoding() != EncodingIntSet {
+		t.Error("should keep intset before exceeding max entries")
+	}
+	s.Add("5")
+	if s.Encoding() != EncodingHashTable {
+		t.Error("should upgrade after exceeding max entries")
+	}
+	assertMembers(t, s, "-7", "1", "3", "5", "100")
+
+	s = NewIntSet(512, "1")
+	s.Add("01")
+	if s.Encoding() != EncodingHashTable {
+		t.Error("should upgrade after adding non-integer member")
+	}
+	assertMembers(t, s, "1", "01")
+}
+
+func TestRandomMembersUniform(t *testing.T) {
+	s := New()
+	for i := 0; i < 100; i++ {
+		s.Add(strconv.Itoa(i))
+	}
+	distinct := s.RandomDistinctMembers(50)
+	if New(distinct...).Len() != 50 {
+		t.Errorf("expected 50 distinct members, actual %v", distinct)
+	}
+	// 每个元素都应该有机会被选中
+	seen := New(s.RandomMembers(3000)...)
+	if seen.Len() != 100 {
+		t.Errorf("expected all members to be picked, actual %d", seen.Len())
+	}
+	seen = New()
+	for i := 0; i < 3000; i++ {
+		seen.Add(s.RandomMembers(1)[0])
+	}
+	if seen.Len() != 100 {
+		t.Errorf("expected all members to be picked one by one, actual %d", seen.Len())
+	}
+}
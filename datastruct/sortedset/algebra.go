@@ -0,0 +1,154 @@
+package sortedset
+
+import (
+	"math"
+	"sort"
+)
+
+// Aggregate 多个集合中同一成员的分数的合并方式
+type Aggregate int
+
+const (
+	AggregateSum Aggregate = iota
+	AggregateMin
+	AggregateMax
+)
+
+// aggregate 合并两个分数, 与redis一样把inf + -inf产生的NaN当作0
+func (a Aggregate) aggregate(x, y float64) float64 {
+	switch a {
+	case AggregateMin:
+		return math.Min(x, y)
+	case AggregateMax:
+		return math.Max(x, y)
+	}
+	sum := x + y
+	if math.IsNaN(sum) {
+		return 0
+	}
+	return sum
+}
+
+// weighted 计算加权分数, weights为nil时权重都是1
+func weighted(weights []float64, i int, score float64) float64 {
+	if weights == nil {
+		return score
+	}
+	result := score * weights[i]
+	if math.IsNaN(result) {
+		// 0 * inf
+		return 0
+	}
+	return result
+}
+
+// Union 求并集, 结果中成员的分数为各集合中加权分数的合并. sets中的nil视为空集合
+func Union(sets []*SortedSet, weights []float64, aggregate Aggregate) *SortedSet {
+	result := New()
+	for i, s := range sets {
+		if s == nil {
+			continue
+		}
+		for member, elem := range s.dict {
+			score := weighted(weights, i, elem.Score)
+			if exists, ok := result.dict[member]; ok {
+				score = aggregate.aggregate(exists.Score, score)
+			}
+			result.Add(member, score)
+		}
+	}
+	return result
+}
+
+// Intersect 求交集, 从最小的集合开始遍历
+func Intersect(sets []*SortedSet, weights []float64, aggregate Aggregate) *SortedSet {
+	result := New()
+	order, ok := sortBySize(sets)
+	if !ok {
+		return result
+	}
+	smallest := order[0]
+	for member, elem := range sets[smallest].dict {
+		score := weighted(weights, smallest, elem.Score)
+		found := true
+		for _, i := range order[1:] {
+			other, ok := sets[i].dict[member]
+			if !ok {
+				found = false
+				break
+			}
+			score = aggregate.aggregate(score, weighted(weights, i, other.Score))
+		}
+		if found {
+			result.Add(member, score)
+		}
+	}
+	return result
+}
+
+// IntersectCard 返回交集的元素数量, limit大于0时数到limit就停止
+func IntersectCard(limit int, sets ...*SortedSet) int {
+	order, ok := sortBySize(sets)
+	if !ok {
+		return 0
+	}
+	count := 0
+	for member := range sets[order[0]].dict {
+		found := true
+		for _, i := range order[1:] {
+			if _, ok := sets[i].dict[member]; !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			count++
+			if limit > 0 && count == limit {
+				break
+			}
+		}
+	}
+	return count
+}
+
+// sortBySize 返回按集合大小从小到大排列的下标, 有空集合时返回false
+func sortBySize(sets []*SortedSet) ([]int, bool) {
+	if len(sets) == 0 {
+		return nil, false
+	}
+	order := make([]int, len(sets))
+	for i, s := range sets {
+		if s == nil || s.Len() == 0 {
+			return nil, false
+		}
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return sets[order[a]].Len() < sets[order[b]].Len()
+	})
+	return order, true
+}
+
+// Diff 求差集, 结果保留第一个集合中的分数
+func Diff(sets []*SortedSet) *SortedSet {
+	result := New()
+	if len(sets) == 0 || sets[0] == nil {
+		return result
+	}
+	for member, elem := range sets[0].dict {
+		found := false
+		for _, s := range sets[1:] {
+			if s == nil {
+				continue
+			}
+			if _, ok := s.dict[member]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result.Add(member, elem.Score)
+		}
+	}
+	return result
+}
@@ -2,6 +2,7 @@ package sortedset
 
 import (
 	"errors"
+	"math"
 	"strconv"
 )
 
@@ -10,13 +11,24 @@ const (
 	positiveInf int8 = 1
 )
 
+// Border 范围查询的边界, ScoreBorder按照分数比较, LexBorder按照成员的字典序比较
+type Border interface {
+	// greater 判断element是否在上边界之内
+	greater(element *Element) bool
+	// less 判断element是否在下边界之内
+	less(element *Element) bool
+	// intersected 以当前边界为下边界, max为上边界时, 范围是否可能包含元素
+	intersected(max Border) bool
+}
+
+// ScoreBorder 分数边界, 如 (1.5 表示不包含1.5, -inf +inf 表示无穷
 type ScoreBorder struct {
 	Inf     int8
 	Value   float64
 	Exclude bool
 }
 
-func (b *ScoreBorder) greater(value float64) bool {
+func (b *ScoreBorder) greater(element *Element) bool {
 	if b.Inf == negativeInf {
 		return false
 	}
@@ -24,27 +36,47 @@ func (b *ScoreBorder) greater(value float64) bool {
 		return true
 	}
 	if b.Exclude {
-		return b.Value > value
+		return b.Value > element.Score
 	}
-	return b.Value <= value
+	return b.Value >= element.Score
 }
 
-func (b *ScoreBorder) less(value float64) bool {
+func (b *ScoreBorder) less(element *Element) bool {
 	if b.Inf == negativeInf {
 		return true
-	} else if b.Inf == positiveInf {
+	}
+	if b.Inf == positiveInf {
 		return false
 	}
 	if b.Exclude {
-		return b.Value < value
+		return b.Value < element.Score
 	}
-	return b.Value <= value
+	return b.Value <= element.Score
+}
+
+func (b *ScoreBorder) intersected(max Border) bool {
+	maxBorder, ok := max.(*ScoreBorder)
+	if !ok {
+		return false
+	}
+	if b.Inf == positiveInf || maxBorder.Inf == negativeInf {
+		return false
+	}
+	if b.Inf == negativeInf || maxBorder.Inf == positiveInf {
+		return true
+	}
+	if b.Value > maxBorder.Value {
+		return false
+	}
+	return b.Value < maxBorder.Value || !(b.Exclude || maxBorder.Exclude)
 }
 
 var positiveInfBorder = &ScoreBorder{Inf: positiveInf}
 
 var negativeInfBorder = &ScoreBorder{Inf: negativeInf}
 
+var errScoreBorder = errors.New("ERR min or max is not a float")
+
 func ParseScoreBorder(s string) (*ScoreBorder, error) {
 	if s == "inf" || s == "+inf" {
 		return positiveInfBorder, nil
@@ -52,16 +84,93 @@ func ParseScoreBorder(s string) (*ScoreBorder, error) {
 	if s == "-inf" {
 		return negativeInfBorder, nil
 	}
-	if s[0] == '(' {
-		value, err := strconv.ParseFloat(s[1:], 64)
-		if err != nil {
-			return nil, errors.New("ERR min or max is not a float")
-		}
-		return &ScoreBorder{Inf: 0, Value: value, Exclude: true}, nil
+	exclude := false
+	if len(s) > 0 && s[0] == '(' {
+		exclude = true
+		s = s[1:]
 	}
 	value, err := strconv.ParseFloat(s, 64)
-	if err != nil {
-		return nil, errors.New("ERR min or max is not a float")
+	if err != nil || math.IsNaN(value) {
+		return nil, errScoreBorder
+	}
+	if math.IsInf(value, 0) {
+		// (+inf 和 (-inf 与不带括号的写法等价
+		if value > 0 {
+			return positiveInfBorder, nil
+		}
+		return negativeInfBorder, nil
+	}
+	return &ScoreBorder{Value: value, Exclude: exclude}, nil
+}
+
+// LexBorder 字典序边界, [a 表示包含a, (a 表示不包含a, - + 表示无穷
+// 与redis一样, 只有所有成员的分数相同时字典序范围查询的结果才有意义
+type LexBorder struct {
+	Inf     int8
+	Value   string
+	Exclude bool
+}
+
+func (b *LexBorder) greater(element *Element) bool {
+	if b.Inf == negativeInf {
+		return false
+	}
+	if b.Inf == positiveInf {
+		return true
+	}
+	if b.Exclude {
+		return b.Value > element.Member
+	}
+	return b.Value >= element.Member
+}
+
+func (b *LexBorder) less(element *Element) bool {
+	if b.Inf == negativeInf {
+		return true
+	}
+	if b.Inf == positiveInf {
+		return false
+	}
+	if b.Exclude {
+		return b.Value < element.Member
+	}
+	return b.Value <= element.Member
+}
+
+func (b *LexBorder) intersected(max Border) bool {
+	maxBorder, ok := max.(*LexBorder)
+	if !ok {
+		return false
+	}
+	if b.Inf == positiveInf || maxBorder.Inf == negativeInf {
+		return false
+	}
+	if b.Inf == negativeInf || maxBorder.Inf == positiveInf {
+		return true
+	}
+	if b.Value > maxBorder.Value {
+		return false
+	}
+	return b.Value < maxBorder.Value || !(b.Exclude || maxBorder.Exclude)
+}
+
+var errLexBorder = errors.New("ERR min or max not valid string range item")
+
+func ParseLexBorder(s string) (*LexBorder, error) {
+	if s == "+" {
+		return &LexBorder{Inf: positiveInf}, nil
+	}
+	if s == "-" {
+		return &LexBorder{Inf: negativeInf}, nil
+	}
+	if len(s) == 0 {
+		return nil, errLexBorder
+	}
+	switch s[0] {
+	case '(':
+		return &LexBorder{Value: s[1:], Exclude: true}, nil
+	case '[':
+		return &LexBorder{Value: s[1:]}, nil
 	}
-	return &ScoreBorder{Inf: 0, Value: value, Exclude: false}, nil
+	return nil, errLexBorder
 }
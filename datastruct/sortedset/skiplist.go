@@ -1,7 +1,6 @@
 package sortedset
 
 import (
-	"math/bits"
 	"math/rand/v2"
 )
 
@@ -16,7 +15,7 @@ type Element struct {
 
 type Level struct {
 	forward *node
-	span    int64
+	span    int64 // 到forward节点的距离
 }
 
 type node struct {
@@ -25,6 +24,7 @@ type node struct {
 	level    []*Level
 }
 
+// skipList 按照分数, 分数相同时按照成员的字典序从小到大排列, 排名从1开始
 type skipList struct {
 	header, tail *node
 	length       int64
@@ -52,37 +52,41 @@ func newSkipList() *skipList {
 	}
 }
 
+// randomLevel 每升高一层的概率为1/4
 func randomLevel() int16 {
-	total := uint64(1) << uint64(maxLevel)
-	k := rand.Uint64() % total
-	return maxLevel - int16(bits.Len64(k+1)) + 1
+	level := int16(1)
+	for level < maxLevel && rand.IntN(4) == 0 {
+		level++
+	}
+	return level
+}
+
+// before 判断节点是否排在(score, member)之前
+func (n *node) before(score float64, member string) bool {
+	return n.Score < score || (n.Score == score && n.Member < member)
 }
 
 func (s *skipList) insert(member string, score float64) *node {
 	update, rank := make([]*node, maxLevel), make([]int64, maxLevel)
 
-	node := s.header
+	// 找到每一层中插入位置的前驱节点, 以及前驱节点的排名
+	n := s.header
 	for i := s.level - 1; i >= 0; i-- {
 		if i == s.level-1 {
 			rank[i] = 0
 		} else {
 			rank[i] = rank[i+1]
 		}
-
-		if node.level[i] != nil {
-			for node.level[i].forward != nil &&
-				(node.level[i].forward.Score < score) ||
-				(node.level[i].forward.Score == score && node.level[i].forward.Member < member) {
-				rank[i] += node.level[i].span
-				node = node.level[i].forward
-			}
+		for n.level[i].forward != nil && n.level[i].forward.before(score, member) {
+			rank[i] += n.level[i].span
+			n = n.level[i].forward
 		}
-		update[i] = node
+		update[i] = n
 	}
 
 	level := randomLevel()
 	if level > s.level {
-		for i := range s.level {
+		for i := s.level; i < level; i++ {
 			rank[i] = 0
 			update[i] = s.header
 			update[i].level[i].span = s.length
@@ -90,46 +94,47 @@ func (s *skipList) insert(member string, score float64) *node {
 		s.level = level
 	}
 
-	node = newNode(level, score, member)
-	for i := range s.level {
-		node.level[i].forward = update[i].level[i].forward
-		update[i].level[i].forward = node
+	n = newNode(level, score, member)
+	for i := range level {
+		n.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = n
 
-		node.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		n.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
 		update[i].level[i].span = rank[0] - rank[i] + 1
 	}
-
+	// 更高的层没有指向新节点, 跨度加一即可
 	for i := level; i < s.level; i++ {
 		update[i].level[i].span++
 	}
 
 	if update[0] == s.header {
-		node.backward = nil
+		n.backward = nil
 	} else {
-		node.backward = update[0]
+		n.backward = update[0]
 	}
-	if node.level[0].forward != nil {
-		node.level[0].forward.backward = node
+	if n.level[0].forward != nil {
+		n.level[0].forward.backward = n
 	} else {
-		s.tail = node
+		s.tail = n
 	}
 	s.length++
-	return node
+	return n
 }
 
-func (s *skipList) removeNode(node *node, update []*node) {
+// removeNode 删除节点, update是每一层的前驱节点
+func (s *skipList) removeNode(n *node, update []*node) {
 	for i := range s.level {
-		if update[i].level[i].forward == node {
-			// 前驱节点上有目标节点，则更新跨度
-			update[i].level[i].span += node.level[i].span - 1
+		if update[i].level[i].forward == n {
+			update[i].level[i].span += n.level[i].span - 1
+			update[i].level[i].forward = n.level[i].forward
 		} else {
 			update[i].level[i].span--
 		}
 	}
-	if node.level[0].forward != nil {
-		node.level[0].forward.backward = node.backward
+	if n.level[0].forward != nil {
+		n.level[0].forward.backward = n.backward
 	} else {
-		s.tail = node.backward
+		s.tail = n.backward
 	}
 	for s.level > 1 && s.header.level[s.level-1].forward == nil {
 		s.level--
@@ -139,159 +144,151 @@ func (s *skipList) removeNode(node *node, update []*node) {
 
 func (s *skipList) remove(member string, score float64) bool {
 	update := make([]*node, maxLevel)
-	node := s.header
+	n := s.header
 	for i := s.level - 1; i >= 0; i-- {
-		for node.level[i].forward != nil &&
-			(node.level[i].forward.Score < score ||
-				(node.level[i].forward.Score == score &&
-					node.level[i].forward.Member < member)) {
-			node = node.level[i].forward
+		for n.level[i].forward != nil && n.level[i].forward.before(score, member) {
+			n = n.level[i].forward
 		}
-		update[i] = node
+		update[i] = n
 	}
-	node = node.level[0].forward
-	if node != nil && node.Score == score && node.Member == member {
-		s.removeNode(node, update)
+	n = n.level[0].forward
+	if n != nil && n.Score == score && n.Member == member {
+		s.removeNode(n, update)
 		return true
 	}
 	return false
 }
 
+// getRank 返回成员的排名, 从1开始, 不存在时返回0
 func (s *skipList) getRank(member string, score float64) int64 {
 	var rank int64
-	node := s.header
+	n := s.header
 	for i := s.level - 1; i >= 0; i-- {
-		for node.level[i].forward != nil &&
-			(node.level[i].forward.Score < score ||
-				(node.level[i].forward.Score == score &&
-					node.level[i].forward.Member < member)) {
-			node = node.level[i].forward
+		for n.level[i].forward != nil &&
+			(n.level[i].forward.before(score, member) ||
+				(n.level[i].forward.Score == score && n.level[i].forward.Member == member)) {
+			rank += n.level[i].span
+			n = n.level[i].forward
 		}
-		if node.Member == member {
+		if n != s.header && n.Member == member {
 			return rank
 		}
 	}
 	return 0
 }
 
+// getByRank 返回排名为rank的节点, 排名从1开始
 func (s *skipList) getByRank(rank int64) *node {
 	var i int64
-	node := s.header
-	for level := s.level - 1; i >= 0; i-- {
-		for node.level[level].forward != nil &&
-			(node.level[level].span+i <= rank) {
-			i += node.level[level].span
-			node = node.level[level].forward
+	n := s.header
+	for level := s.level - 1; level >= 0; level-- {
+		for n.level[level].forward != nil && i+n.level[level].span <= rank {
+			i += n.level[level].span
+			n = n.level[level].forward
 		}
 		if i == rank {
-			return node
+			return n
 		}
 	}
 	return nil
 }
 
-func (s *skipList) hasInRange(min *ScoreBorder, max *ScoreBorder) bool {
-	if min.Value > max.Value || (min.Value == max.Value && (min.Exclude || max.Exclude)) {
+// hasInRange 判断是否有元素在[min, max]之间
+func (s *skipList) hasInRange(min Border, max Border) bool {
+	if !min.intersected(max) {
 		return false
 	}
 	n := s.tail
-	if n == nil || !min.less(n.Score) {
+	if n == nil || !min.less(&n.Element) {
 		return false
 	}
 	n = s.header.level[0].forward
-	if n == nil || !max.greater(n.Score) {
+	if n == nil || !max.greater(&n.Element) {
 		return false
 	}
 	return true
 }
 
-func (s *skipList) getFirstInScoreRange(min *ScoreBorder, max *ScoreBorder) *node {
+func (s *skipList) getFirstInRange(min Border, max Border) *node {
 	if !s.hasInRange(min, max) {
 		return nil
 	}
 	n := s.header
 	for level := s.level - 1; level >= 0; level-- {
-		for n.level[level].forward != nil && !min.less(n.level[level].forward.Score) {
+		for n.level[level].forward != nil && !min.less(&n.level[level].forward.Element) {
 			n = n.level[level].forward
 		}
 	}
 	n = n.level[0].forward
-	if !max.greater(n.Score) {
+	if !max.greater(&n.Element) {
 		return nil
 	}
 	return n
 }
 
-func (s *skipList) getLastInScoreRange(min *ScoreBorder, max *ScoreBorder) *node {
+func (s *skipList) getLastInRange(min Border, max Border) *node {
 	if !s.hasInRange(min, max) {
 		return nil
 	}
 	n := s.header
 	for level := s.level - 1; level >= 0; level-- {
-		for n.level[level].forward != nil && max.greater(n.level[level].forward.Score) {
+		for n.level[level].forward != nil && max.greater(&n.level[level].forward.Element) {
 			n = n.level[level].forward
 		}
 	}
-	if !min.less(n.Score) {
+	if !min.less(&n.Element) {
 		return nil
 	}
 	return n
 }
 
-func (s *skipList) RemoveRangeByScore(min *ScoreBorder, max *ScoreBorder, limit int) (removed []*Element) {
+// removeRange 删除[min, max]之间的元素, limit大于0时最多删除limit个
+func (s *skipList) removeRange(min Border, max Border, limit int) (removed []*Element) {
 	update := make([]*node, maxLevel)
 	removed = make([]*Element, 0)
 
-	node := s.header
+	n := s.header
 	for i := s.level - 1; i >= 0; i-- {
-		for node.level[i].forward != nil {
-			if min.less(node.level[i].forward.Score) {
-				break
-			}
-			node = node.level[i].forward
+		for n.level[i].forward != nil && !min.less(&n.level[i].forward.Element) {
+			n = n.level[i].forward
 		}
-		update[i] = node
+		update[i] = n
 	}
 
-	node = node.level[0].forward
-	for node != nil {
-		if !max.greater(node.Score) {
-			break
-		}
-		next := node.level[0].forward
-		removedElem := &node.Element
-		removed = append(removed, removedElem)
-		s.removeNode(node, update)
+	n = n.level[0].forward
+	for n != nil && max.greater(&n.Element) {
+		next := n.level[0].forward
+		removed = append(removed, &n.Element)
+		s.removeNode(n, update)
 		if limit > 0 && len(removed) == limit {
 			break
 		}
-		node = next
+		n = next
 	}
 	return removed
 }
 
-// RemoveRangeByRank [start, stop)
-func (s *skipList) RemoveRangeByRank(start int64, stop int64) (removed []*Element) {
+// removeRangeByRank 删除排名在[start, stop)之间的元素, 排名从1开始
+func (s *skipList) removeRangeByRank(start int64, stop int64) (removed []*Element) {
 	var i int64
 	update := make([]*node, maxLevel)
 	removed = make([]*Element, 0)
 
-	node := s.header
+	n := s.header
 	for level := s.level - 1; level >= 0; level-- {
-		for node.level[level].forward != nil && (i+node.level[level].span) < start {
-			i += node.level[level].span
-			node = node.level[level].forward
+		for n.level[level].forward != nil && i+n.level[level].span < start {
+			i += n.level[level].span
+			n = n.level[level].forward
 		}
-		update[level] = node
+		update[level] = n
 	}
 	i++
-	node = node.level[0].forward
-	for node != nil && i < stop {
-		next := node.level[0].forward
-		removedElem := node.Element
-		removed = append(removed, &removedElem)
-		s.removeNode(node, update)
-		node = next
+	n = n.level[0].forward
+	for n != nil && i < stop {
+		next := n.level[0].forward
+		removed = append(removed, &n.Element)
+		s.removeNode(n, update)
+		n = next
 		i++
 	}
 	return removed
@@ -1,7 +1,14 @@
 package sortedset
 
+import (
+	"goredis/datastruct/dict"
+	"strconv"
+)
+
+// SortedSet 有序集合, 用map按成员查找分数, 用跳表按分数排序
 type SortedSet struct {
 	dict     map[string]*Element
+	index    dict.ScanIndex
 	skiplist *skipList
 }
 
@@ -12,6 +19,7 @@ func New() *SortedSet {
 	}
 }
 
+// Add 添加成员或者更新成员的分数, 成员是新增的时返回true
 func (s *SortedSet) Add(member string, score float64) bool {
 	elem, ok := s.dict[member]
 	s.dict[member] = &Element{member, score}
@@ -23,6 +31,7 @@ func (s *SortedSet) Add(member string, score float64) bool {
 		return false
 	}
 	s.skiplist.insert(member, score)
+	s.index.Add(member)
 	return true
 }
 
@@ -40,11 +49,13 @@ func (s *SortedSet) Remove(member string) bool {
 	if ok {
 		s.skiplist.remove(member, v.Score)
 		delete(s.dict, member)
+		s.index.Remove(member)
 		return true
 	}
 	return false
 }
 
+// GetRank 返回成员的排名, 从0开始, 成员不存在时返回-1
 func (s *SortedSet) GetRank(member string, desc bool) int64 {
 	elem, ok := s.dict[member]
 	if !ok {
@@ -59,10 +70,49 @@ func (s *SortedSet) GetRank(member string, desc bool) int64 {
 	return r
 }
 
-func (s *SortedSet) ForEach(start, stop int64, desc bool, consumer func(element *Element) bool)) {
+// GetByRank 返回排名为rank的元素, 排名从0开始, 调用方需要保证rank在[0, Len())之间
+func (s *SortedSet) GetByRank(rank int64) *Element {
+	return &s.skiplist.getByRank(rank + 1).Element
+}
+
+// ForEach 遍历排名在[start, stop)之间的元素, 排名从0开始, desc为true时从大到小排名
+func (s *SortedSet) ForEach(start, stop int64, desc bool, consumer func(element *Element) bool) {
+	size := s.Len()
+	if start < 0 || start > size {
+		panic("illegal start " + strconv.FormatInt(start, 10))
+	}
+	if stop < start || stop > size {
+		panic("illegal stop " + strconv.FormatInt(stop, 10))
+	}
+	if start == stop {
+		return
+	}
 
+	var n *node
+	if desc {
+		n = s.skiplist.tail
+		if start > 0 {
+			n = s.skiplist.getByRank(size - start)
+		}
+	} else {
+		n = s.skiplist.header.level[0].forward
+		if start > 0 {
+			n = s.skiplist.getByRank(start + 1)
+		}
+	}
+	for i := start; i < stop; i++ {
+		if !consumer(&n.Element) {
+			break
+		}
+		if desc {
+			n = n.backward
+		} else {
+			n = n.level[0].forward
+		}
+	}
 }
 
+// Range 返回排名在[start, stop)之间的元素
 func (s *SortedSet) Range(start, stop int64, desc bool) []*Element {
 	sliceSize := int(stop - start)
 	slice := make([]*Element, sliceSize)
@@ -75,71 +125,110 @@ func (s *SortedSet) Range(start, stop int64, desc bool) []*Element {
 	return slice
 }
 
-func (s *SortedSet) Count(min, max *ScoreBorder) int64 {
-	var i int64
-	s.ForEach(0, s.Len(), false, func(element *Element) bool {
-		gtMin := min.less(element.Score)
-		if !gtMin {
-			return true
-		}
-		ltMax := max.greater(element.Score)
-		if !ltMax {
-			return false
-		}
-		i++
-		return true
-	})
-	return i
+// Count 返回[min, max]之间的元素数量
+func (s *SortedSet) Count(min, max Border) int64 {
+	first := s.skiplist.getFirstInRange(min, max)
+	if first == nil {
+		return 0
+	}
+	last := s.skiplist.getLastInRange(min, max)
+	return s.skiplist.getRank(last.Member, last.Score) - s.skiplist.getRank(first.Member, first.Score) + 1
 }
 
-func (s *SortedSet) ForEachByScore(min, max *ScoreBorder, offset, limit int64, desc bool, consumer func(element *Element) bool) {
-	var node *node
+// ForEachByBorder 遍历[min, max]之间的元素, 跳过前offset个, limit小于0时不限制数量
+func (s *SortedSet) ForEachByBorder(min, max Border, offset, limit int64, desc bool, consumer func(element *Element) bool) {
+	var n *node
 	if desc {
-		node = s.skiplist.getLastInScoreRange(min, max)
+		n = s.skiplist.getLastInRange(min, max)
 	} else {
-		node = s.skiplist.getFirstInScoreRange(min, max)
+		n = s.skiplist.getFirstInRange(min, max)
 	}
 
-	for node != nil && offset > 0 {
+	for n != nil && offset > 0 {
 		if desc {
-			node = node.backward
+			n = n.backward
 		} else {
-			node = node.level[0].forward
+			n = n.level[0].forward
 		}
 		offset--
 	}
 
+	for i := int64(0); n != nil && (limit < 0 || i < limit); i++ {
+		if desc && !min.less(&n.Element) {
+			break
+		}
+		if !desc && !max.greater(&n.Element) {
+			break
+		}
+		if !consumer(&n.Element) {
+			break
+		}
+		if desc {
+			n = n.backward
+		} else {
+			n = n.level[0].forward
+		}
+	}
+}
 
+// RangeByBorder 返回[min, max]之间的元素, 跳过前offset个, limit小于0时不限制数量
+func (s *SortedSet) RangeByBorder(min, max Border, offset, limit int64, desc bool) []*Element {
+	slice := make([]*Element, 0)
+	s.ForEachByBorder(min, max, offset, limit, desc, func(element *Element) bool {
+		slice = append(slice, element)
+		return true
+	})
+	return slice
 }
 
-func (s *SortedSet) RemoveByScore(min, max *ScoreBorder) int64 {
-	removed := s.skiplist.RemoveRangeByScore(min, max, 0)
+// RemoveByBorder 删除[min, max]之间的元素, 返回删除的数量
+func (s *SortedSet) RemoveByBorder(min, max Border) int64 {
+	removed := s.skiplist.removeRange(min, max, 0)
 	for _, elem := range removed {
 		delete(s.dict, elem.Member)
+		s.index.Remove(elem.Member)
 	}
 	return int64(len(removed))
 }
 
+// PopMin 删除并返回分数最小的count个元素
 func (s *SortedSet) PopMin(count int) []*Element {
-	first := s.skiplist.getFirstInScoreRange(negativeInfBorder, positiveInfBorder)
-	if first == nil {
-		return nil
+	removed := s.skiplist.removeRangeByRank(1, int64(count)+1)
+	for _, elem := range removed {
+		delete(s.dict, elem.Member)
+		s.index.Remove(elem.Member)
 	}
-	border := &ScoreBorder{
-		Value:   first.Score,
-		Exclude: false,
+	return removed
+}
+
+// PopMax 删除并返回分数最大的count个元素, 按分数从大到小排列
+func (s *SortedSet) PopMax(count int) []*Element {
+	size := s.Len()
+	start := max(size-int64(count), 0)
+	removed := s.skiplist.removeRangeByRank(start+1, size+1)
+	for i, j := 0, len(removed)-1; i < j; i, j = i+1, j-1 {
+		removed[i], removed[j] = removed[j], removed[i]
 	}
-	removed := s.skiplist.RemoveRangeByScore(border, positiveInfBorder, count)
 	for _, elem := range removed {
 		delete(s.dict, elem.Member)
+		s.index.Remove(elem.Member)
 	}
 	return removed
 }
 
+// RemoveByRank 删除排名在[start, stop)之间的元素, 排名从0开始
 func (s *SortedSet) RemoveByRank(start, stop int64) int64 {
-	removed := s.skiplist.RemoveRangeByRank(start+1, stop+1)
+	removed := s.skiplist.removeRangeByRank(start+1, stop+1)
 	for _, elem := range removed {
 		delete(s.dict, elem.Member)
+		s.index.Remove(elem.Member)
 	}
 	return int64(len(removed))
 }
+
+// Scan 游标遍历, 返回0表示遍历结束
+func (s *SortedSet) Scan(cursor uint64, count int, consumer func(element *Element)) uint64 {
+	return dict.ScanMap(s.dict, &s.index, cursor, count, func(_ string, element *Element) {
+		consumer(element)
+	})
+}
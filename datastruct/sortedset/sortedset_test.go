@@ -0,0 +1,118 @@
+package sortedset
+
+import (
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// sortedElements 用排序后的切片作为对照
+func sortedElements(s *SortedSet) []Element {
+	elements := make([]Element, 0, len(s.dict))
+	for _, elem := range s.dict {
+		elements = append(elements, *elem)
+	}
+	sort.Slice(elements, func(i, j int) bool {
+		a, b := elements[i], elements[j]
+		return a.Score < b.Score || (a.Score == b.Score && a.Member < b.Member)
+	})
+	return elements
+}
+
+func TestRandomOperations(t *testing.T) {
+	s := New()
+	for i := 0; i < 2000; i++ {
+		member := strconv.Itoa(rand.IntN(300))
+		if rand.IntN(3) == 0 {
+			s.Remove(member)
+		} else {
+			s.Add(member, float64(rand.IntN(50)))
+		}
+	}
+	expected := sortedElements(s)
+	if s.skiplist.length != int64(len(expected)) {
+		t.Fatalf("expected length %d, actual %d", len(expected), s.skiplist.length)
+	}
+	for i, elem := range s.Range(0, s.Len(), false) {
+		if *elem != expected[i] {
+			t.Fatalf("wrong element at %d: expected %v, actual %v", i, expected[i], *elem)
+		}
+		if byRank := s.GetByRank(int64(i)); *byRank != expected[i] {
+			t.Fatalf("wrong element of rank %d: expected %v, actual %v", i, expected[i], *byRank)
+		}
+		if rank := s.GetRank(elem.Member, false); rank != int64(i) {
+			t.Fatalf("wrong rank of %s: expected %d, actual %d", elem.Member, i, rank)
+		}
+		if rank := s.GetRank(elem.Member, true); rank != int64(len(expected)-1-i) {
+			t.Fatalf("wrong reverse rank of %s", elem.Member)
+		}
+	}
+	desc := s.Range(1, s.Len(), true)
+	if *desc[0] != expected[len(expected)-2] {
+		t.Fatalf("wrong reverse range")
+	}
+}
+
+func TestBorder(t *testing.T) {
+	s := New()
+	for i := 0; i < 10; i++ {
+		s.Add(strconv.Itoa(i), float64(i))
+	}
+	min, _ := ParseScoreBorder("(2")
+	max, _ := ParseScoreBorder("5")
+	if n := s.Count(min, max); n != 3 {
+		t.Errorf("expected 3, actual %d", n)
+	}
+	elements := s.RangeByBorder(min, max, 1, 1, true)
+	if len(elements) != 1 || elements[0].Member != "4" {
+		t.Errorf("wrong range result %v", elements)
+	}
+	if _, err := ParseScoreBorder("abc"); err == nil {
+		t.Error("expected error")
+	}
+	if n := s.RemoveByBorder(min, max); n != 3 || s.Len() != 7 {
+		t.Errorf("wrong remove result %d", n)
+	}
+
+	lex := New()
+	for _, member := range []string{"a", "b", "c", "d"} {
+		lex.Add(member, 0)
+	}
+	lexMin, _ := ParseLexBorder("[b")
+	lexMax, _ := ParseLexBorder("+")
+	if n := lex.Count(lexMin, lexMax); n != 3 {
+		t.Errorf("expected 3, actual %d", n)
+	}
+	lexMax, _ = ParseLexBorder("(b")
+	if n := lex.Count(lexMin, lexMax); n != 0 {
+		t.Errorf("expected empty range, actual %d", n)
+	}
+	if _, err := ParseLexBorder("b"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestPopAndRemoveByRank(t *testing.T) {
+	s := New()
+	for i := 0; i < 10; i++ {
+		s.Add(strconv.Itoa(i), float64(i))
+	}
+	popped := s.PopMin(2)
+	if len(popped) != 2 || popped[0].Member != "0" || popped[1].Member != "1" {
+		t.Errorf("wrong pop result %v", popped)
+	}
+	if n := s.RemoveByRank(0, 3); n != 3 {
+		t.Errorf("expected 3, actual %d", n)
+	}
+	if elem := s.Range(0, 1, false)[0]; elem.Member != "5" {
+		t.Errorf("expected 5, actual %s", elem.Member)
+	}
+	popped = s.PopMax(2)
+	if len(popped) != 2 || popped[0].Member != "9" || popped[1].Member != "8" {
+		t.Errorf("wrong pop result %v", popped)
+	}
+	if popped = s.PopMax(10); len(popped) != 3 || s.Len() != 0 || s.skiplist.tail != nil {
+		t.Errorf("all elements should be popped, got %v", popped)
+	}
+}
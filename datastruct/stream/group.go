@@ -0,0 +1,233 @@
+package stream
+
+import (
+	"sort"
+	"time"
+)
+
+// InvalidEntriesRead 消费者组已读取的消息数量未知
+const InvalidEntriesRead = -1
+
+// PendingEntry 已经投递但还没有被确认的消息
+type PendingEntry struct {
+	ID            ID
+	Consumer      *Consumer
+	DeliveryTime  time.Time
+	DeliveryCount int64
+}
+
+// Consumer 消费者组中的消费者, pel保存投递给它且没有确认的消息
+type Consumer struct {
+	Name string
+	// SeenTime 最后一次尝试读取或者认领消息的时间
+	SeenTime time.Time
+	// ActiveTime 最后一次成功读取或者认领消息的时间, 从未成功时为零值
+	ActiveTime time.Time
+	pel        *index[*PendingEntry]
+}
+
+func (c *Consumer) PendingLen() int {
+	return c.pel.len()
+}
+
+// RangePending 从第一个不小于start的消息开始按ID顺序遍历消费者的待确认消息, 遍历时不能修改
+func (c *Consumer) RangePending(start ID, consumer func(pe *PendingEntry) bool) {
+	c.pel.ascend(start, func(id ID, pe *PendingEntry) bool {
+		return consumer(pe)
+	})
+}
+
+// Group 消费者组, pel保存组内所有待确认的消息
+type Group struct {
+	Name string
+	// LastID 最后投递给组内消费者的ID
+	LastID ID
+	// EntriesRead 组已经读取的消息数量, 用于计算lag, 未知时为InvalidEntriesRead
+	EntriesRead int64
+	pel         *index[*PendingEntry]
+	consumers   map[string]*Consumer
+}
+
+func newGroup(name string, lastID ID, entriesRead int64) *Group {
+	return &Group{
+		Name:        name,
+		LastID:      lastID,
+		EntriesRead: entriesRead,
+		pel:         &index[*PendingEntry]{},
+		consumers:   make(map[string]*Consumer),
+	}
+}
+
+// Consumer 返回消费者, 不存在时返回nil
+func (g *Group) Consumer(name string) *Consumer {
+	return g.consumers[name]
+}
+
+// CreateConsumer 返回消费者, 不存在时创建, 第二个返回值表示是否新建
+func (g *Group) CreateConsumer(name string, now time.Time) (*Consumer, bool) {
+	if c, ok := g.consumers[name]; ok {
+		return c, false
+	}
+	c := &Consumer{
+		Name:     name,
+		SeenTime: now,
+		pel:      &index[*PendingEntry]{},
+	}
+	g.consumers[name] = c
+	return c, true
+}
+
+// DeleteConsumer 删除消费者以及它的待确认消息, 返回删除的待确认消息数量
+func (g *Group) DeleteConsumer(name string) (int, bool) {
+	c, ok := g.consumers[name]
+	if !ok {
+		return 0, false
+	}
+	pending := c.pel.len()
+	c.pel.ascend(MinID, func(id ID, pe *PendingEntry) bool {
+		g.pel.remove(id)
+		return true
+	})
+	delete(g.consumers, name)
+	return pending, true
+}
+
+// Consumers 返回按名称排序的所有消费者
+func (g *Group) Consumers() []*Consumer {
+	consumers := make([]*Consumer, 0, len(g.consumers))
+	for _, c := range g.consumers {
+		consumers = append(consumers, c)
+	}
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].Name < consumers[j].Name
+	})
+	return consumers
+}
+
+func (g *Group) PendingLen() int {
+	return g.pel.len()
+}
+
+// Pending 返回待确认的消息, 不存在时返回nil
+func (g *Group) Pending(id ID) *PendingEntry {
+	pe, _ := g.pel.get(id)
+	return pe
+}
+
+// RangePending 从第一个不小于start的消息开始按ID顺序遍历组内的待确认消息, 遍历时不能修改
+func (g *Group) RangePending(start ID, consumer func(pe *PendingEntry) bool) {
+	g.pel.ascend(start, func(id ID, pe *PendingEntry) bool {
+		return consumer(pe)
+	})
+}
+
+// PendingBounds 返回待确认消息的最小和最大ID, 没有待确认消息时返回false
+func (g *Group) PendingBounds() (ID, ID, bool) {
+	first, _, ok := g.pel.first()
+	if !ok {
+		return ID{}, ID{}, false
+	}
+	last, _, _ := g.pel.last()
+	return first, last, true
+}
+
+// Assign 将消息记为投递给consumer的待确认消息, 已经属于其他消费者时转移给consumer
+func (g *Group) Assign(id ID, consumer *Consumer, deliveryTime time.Time, deliveryCount int64) *PendingEntry {
+	pe := g.Pending(id)
+	if pe == nil {
+		pe = &PendingEntry{ID: id}
+		g.pel.put(id, pe)
+	} else if pe.Consumer != consumer {
+		pe.Consumer.pel.remove(id)
+	}
+	pe.Consumer = consumer
+	pe.DeliveryTime = deliveryTime
+	pe.DeliveryCount = deliveryCount
+	consumer.pel.put(id, pe)
+	return pe
+}
+
+// Ack 确认消息, 消息不在待确认列表中时返回false
+func (g *Group) Ack(id ID) bool {
+	pe, ok := g.pel.remove(id)
+	if !ok {
+		return false
+	}
+	pe.Consumer.pel.remove(id)
+	return true
+}
+
+func (g *Group) clone() *Group {
+	result := newGroup(g.Name, g.LastID, g.EntriesRead)
+	for name, c := range g.consumers {
+		result.consumers[name] = &Consumer{
+			Name:       c.Name,
+			SeenTime:   c.SeenTime,
+			ActiveTime: c.ActiveTime,
+			pel:        &index[*PendingEntry]{},
+		}
+	}
+	g.pel.ascend(MinID, func(id ID, pe *PendingEntry) bool {
+		result.Assign(id, result.consumers[pe.Consumer.Name], pe.DeliveryTime, pe.DeliveryCount)
+		return true
+	})
+	return result
+}
+
+// hasTombstones 在start之后是否有被XDEL删除的消息, 有删除时无法准确计算已读取的数量
+func (s *Stream) hasTombstones(start ID) bool {
+	if s.Len() == 0 || s.maxDeletedID.IsZero() {
+		return false
+	}
+	return !s.maxDeletedID.Less(start)
+}
+
+// estimateEntriesRead 估算id之前(含id)添加过的消息数量, 无法确定时返回InvalidEntriesRead
+func (s *Stream) estimateEntriesRead(id ID) int64 {
+	if s.entriesAdded == 0 {
+		return 0
+	}
+	cmpLast := id.Compare(s.lastID)
+	if s.Len() == 0 && cmpLast <= 0 {
+		return s.entriesAdded
+	}
+	if cmpLast == 0 {
+		return s.entriesAdded
+	}
+	if cmpLast > 0 {
+		return InvalidEntriesRead
+	}
+	firstID := s.FirstID()
+	if s.maxDeletedID.IsZero() || s.maxDeletedID.Less(firstID) {
+		// 第一条消息之后没有被删除的消息
+		switch id.Compare(firstID) {
+		case -1:
+			return s.entriesAdded - int64(s.Len())
+		case 0:
+			return s.entriesAdded - int64(s.Len()) + 1
+		}
+	}
+	return InvalidEntriesRead
+}
+
+// MarkRead 组读取了id之前的所有新消息, 更新LastID和EntriesRead
+func (s *Stream) MarkRead(g *Group, id ID) {
+	if g.EntriesRead != InvalidEntriesRead && !s.hasTombstones(id) {
+		g.EntriesRead++
+	} else if s.entriesAdded > 0 {
+		g.EntriesRead = s.estimateEntriesRead(id)
+	}
+	g.LastID = id
+}
+
+// Lag 组内还没有被读取的消息数量, 无法确定时返回false
+func (s *Stream) Lag(g *Group) (int64, bool) {
+	if g.EntriesRead != InvalidEntriesRead && !s.hasTombstones(g.LastID) {
+		return s.entriesAdded - g.EntriesRead, true
+	}
+	entriesRead := s.estimateEntriesRead(g.LastID)
+	if entriesRead == InvalidEntriesRead {
+		return 0, false
+	}
+	return s.entriesAdded - entriesRead, true
+}
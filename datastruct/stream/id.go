@@ -0,0 +1,88 @@
+package stream
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ID 消息ID, 由毫秒时间戳和同一毫秒内的序号组成, 格式为ms-seq
+type ID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+var (
+	// MinID 最小的ID, 即0-0
+	MinID = ID{}
+	// MaxID 最大的ID
+	MaxID = ID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+	ErrInvalidID = errors.New("ERR Invalid stream ID specified as stream command argument")
+)
+
+// ParseID 解析ms-seq格式的ID, 只有ms部分时序号为defaultSeq
+func ParseID(s string, defaultSeq uint64) (ID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidID
+	}
+	if !hasSeq {
+		return ID{Ms: ms, Seq: defaultSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidID
+	}
+	return ID{Ms: ms, Seq: seq}, nil
+}
+
+func (id ID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+func (id ID) Compare(other ID) int {
+	switch {
+	case id.Ms < other.Ms:
+		return -1
+	case id.Ms > other.Ms:
+		return 1
+	case id.Seq < other.Seq:
+		return -1
+	case id.Seq > other.Seq:
+		return 1
+	}
+	return 0
+}
+
+func (id ID) Less(other ID) bool {
+	return id.Compare(other) < 0
+}
+
+func (id ID) IsZero() bool {
+	return id == MinID
+}
+
+// Next 返回紧接着的下一个ID, id已经是最大值时返回false
+func (id ID) Next() (ID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return ID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return ID{Ms: id.Ms + 1}, true
+	}
+	return id, false
+}
+
+// Prev 返回紧挨着的前一个ID, id已经是最小值时返回false
+func (id ID) Prev() (ID, bool) {
+	switch {
+	case id.Seq > 0:
+		return ID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	case id.Ms > 0:
+		return ID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	}
+	return id, false
+}
@@ -0,0 +1,189 @@
+package stream
+
+import (
+	"slices"
+	"sort"
+)
+
+// nodeSize 每个叶子节点最多保存的元素数量
+const nodeSize = 128
+
+type node[V any] struct {
+	ids    []ID
+	values []V
+}
+
+// search 返回第一个不小于id的位置, 以及该位置是否就是id
+func (n *node[V]) search(id ID) (int, bool) {
+	i := sort.Search(len(n.ids), func(i int) bool {
+		return !n.ids[i].Less(id)
+	})
+	return i, i < len(n.ids) && n.ids[i] == id
+}
+
+// index 按ID排序的两层B+树, 叶子节点保存有序的元素, 根节点是按第一个ID排序的叶子节点数组.
+// 与redis中rax的每个节点保存一个listpack类似, 只在末尾追加时节点总是满的
+type index[V any] struct {
+	nodes []*node[V]
+	size  int
+}
+
+func (idx *index[V]) len() int {
+	return idx.size
+}
+
+// locate 返回可能包含id的叶子节点: 最后一个第一个ID不大于id的节点, 所有节点都大于id时返回第一个节点
+func (idx *index[V]) locate(id ID) int {
+	i := sort.Search(len(idx.nodes), func(i int) bool {
+		return id.Less(idx.nodes[i].ids[0])
+	})
+	if i > 0 {
+		i--
+	}
+	return i
+}
+
+func (idx *index[V]) get(id ID) (V, bool) {
+	var zero V
+	if len(idx.nodes) == 0 {
+		return zero, false
+	}
+	n := idx.nodes[idx.locate(id)]
+	i, found := n.search(id)
+	if !found {
+		return zero, false
+	}
+	return n.values[i], true
+}
+
+// put 插入或者替换元素, 插入新元素时返回true
+func (idx *index[V]) put(id ID, value V) bool {
+	if len(idx.nodes) == 0 {
+		idx.nodes = append(idx.nodes, &node[V]{ids: []ID{id}, values: []V{value}})
+		idx.size++
+		return true
+	}
+	pos := idx.locate(id)
+	n := idx.nodes[pos]
+	i, found := n.search(id)
+	if found {
+		n.values[i] = value
+		return false
+	}
+	idx.size++
+	if pos == len(idx.nodes)-1 && i == len(n.ids) && len(n.ids) >= nodeSize {
+		// 在末尾追加时不分裂节点, 直接创建新节点
+		idx.nodes = append(idx.nodes, &node[V]{ids: []ID{id}, values: []V{value}})
+		return true
+	}
+	n.ids = slices.Insert(n.ids, i, id)
+	n.values = slices.Insert(n.values, i, value)
+	if len(n.ids) > nodeSize {
+		half := len(n.ids) / 2
+		right := &node[V]{
+			ids:    slices.Clone(n.ids[half:]),
+			values: slices.Clone(n.values[half:]),
+		}
+		clear(n.values[half:])
+		n.ids, n.values = n.ids[:half], n.values[:half]
+		idx.nodes = slices.Insert(idx.nodes, pos+1, right)
+	}
+	return true
+}
+
+// remove 删除元素, 叶子节点为空时删除节点
+func (idx *index[V]) remove(id ID) (V, bool) {
+	var zero V
+	if len(idx.nodes) == 0 {
+		return zero, false
+	}
+	pos := idx.locate(id)
+	n := idx.nodes[pos]
+	i, found := n.search(id)
+	if !found {
+		return zero, false
+	}
+	value := n.values[i]
+	n.ids = slices.Delete(n.ids, i, i+1)
+	n.values = slices.Delete(n.values, i, i+1)
+	if len(n.ids) == 0 {
+		idx.nodes = slices.Delete(idx.nodes, pos, pos+1)
+	}
+	idx.size--
+	return value, true
+}
+
+func (idx *index[V]) first() (ID, V, bool) {
+	if len(idx.nodes) == 0 {
+		var zero V
+		return ID{}, zero, false
+	}
+	n := idx.nodes[0]
+	return n.ids[0], n.values[0], true
+}
+
+func (idx *index[V]) last() (ID, V, bool) {
+	if len(idx.nodes) == 0 {
+		var zero V
+		return ID{}, zero, false
+	}
+	n := idx.nodes[len(idx.nodes)-1]
+	return n.ids[len(n.ids)-1], n.values[len(n.values)-1], true
+}
+
+// ascend 从第一个不小于start的元素开始按ID从小到大遍历, consumer返回false时停止, 遍历时不能修改index
+func (idx *index[V]) ascend(start ID, consumer func(id ID, value V) bool) {
+	if len(idx.nodes) == 0 {
+		return
+	}
+	pos := idx.locate(start)
+	i, _ := idx.nodes[pos].search(start)
+	for ; pos < len(idx.nodes); pos++ {
+		n := idx.nodes[pos]
+		for ; i < len(n.ids); i++ {
+			if !consumer(n.ids[i], n.values[i]) {
+				return
+			}
+		}
+		i = 0
+	}
+}
+
+// descend 从最后一个不大于start的元素开始按ID从大到小遍历, consumer返回false时停止, 遍历时不能修改index
+func (idx *index[V]) descend(start ID, consumer func(id ID, value V) bool) {
+	if len(idx.nodes) == 0 {
+		return
+	}
+	pos := idx.locate(start)
+	i, found := idx.nodes[pos].search(start)
+	if !found {
+		i--
+	}
+	for ; pos >= 0; pos-- {
+		n := idx.nodes[pos]
+		for ; i >= 0; i-- {
+			if !consumer(n.ids[i], n.values[i]) {
+				return
+			}
+		}
+		if pos > 0 {
+			i = len(idx.nodes[pos-1].ids) - 1
+		}
+	}
+}
+
+// clone 复制index, copyValue用于复制每个元素
+func (idx *index[V]) clone(copyValue func(V) V) *index[V] {
+	result := &index[V]{
+		nodes: make([]*node[V], len(idx.nodes)),
+		size:  idx.size,
+	}
+	for pos, n := range idx.nodes {
+		values := make([]V, len(n.values))
+		for i, value := range n.values {
+			values[i] = copyValue(value)
+		}
+		result.nodes[pos] = &node[V]{ids: slices.Clone(n.ids), values: values}
+	}
+	return result
+}
@@ -0,0 +1,230 @@
+package stream
+
+import (
+	"slices"
+	"sort"
+)
+
+// Entry stream中的一条消息, Fields是依次排列的field和value
+type Entry struct {
+	ID     ID
+	Fields [][]byte
+}
+
+// Stream 只能在末尾追加的消息日志, 消息按ID有序保存, 并记录消费者组的消费进度
+type Stream struct {
+	entries *index[[][]byte]
+	// lastID 最后生成的ID, 消息被删除后也不会变小
+	lastID ID
+	// maxDeletedID 被XDEL删除的最大ID
+	maxDeletedID ID
+	// entriesAdded 添加过的消息总数
+	entriesAdded int64
+	groups       map[string]*Group
+}
+
+func New() *Stream {
+	return &Stream{
+		entries: &index[[][]byte]{},
+		groups:  make(map[string]*Group),
+	}
+}
+
+func (s *Stream) Len() int {
+	return s.entries.len()
+}
+
+// NodeCount 叶子节点数量
+func (s *Stream) NodeCount() int {
+	return len(s.entries.nodes)
+}
+
+func (s *Stream) LastID() ID {
+	return s.lastID
+}
+
+func (s *Stream) MaxDeletedID() ID {
+	return s.maxDeletedID
+}
+
+func (s *Stream) EntriesAdded() int64 {
+	return s.entriesAdded
+}
+
+// FirstID 第一条消息的ID, stream为空时返回0-0
+func (s *Stream) FirstID() ID {
+	id, _, _ := s.entries.first()
+	return id
+}
+
+// SetLastID 用于XSETID, 由调用方保证参数合法
+func (s *Stream) SetLastID(lastID ID, entriesAdded int64, maxDeletedID ID) {
+	s.lastID = lastID
+	s.entriesAdded = entriesAdded
+	s.maxDeletedID = maxDeletedID
+}
+
+// NextID 生成自动ID, 时间戳不小于最后一个ID的时间戳, 时间戳相同时序号加1, ID耗尽时返回false
+func (s *Stream) NextID(nowMs uint64) (ID, bool) {
+	if nowMs > s.lastID.Ms {
+		return ID{Ms: nowMs}, true
+	}
+	return s.lastID.Next()
+}
+
+// NextSeq 生成ms-*形式的ID, 无法生成大于最后一个ID的ID时返回false
+func (s *Stream) NextSeq(ms uint64) (ID, bool) {
+	switch {
+	case ms > s.lastID.Ms:
+		return ID{Ms: ms}, true
+	case ms == s.lastID.Ms && s.lastID.Seq < MaxID.Seq:
+		return ID{Ms: ms, Seq: s.lastID.Seq + 1}, true
+	}
+	return ID{}, false
+}
+
+// Add 添加消息, 由调用方保证id大于最后一个ID
+func (s *Stream) Add(id ID, fields [][]byte) {
+	s.entries.put(id, fields)
+	s.lastID = id
+	s.entriesAdded++
+}
+
+// Get 返回id对应消息的field和value
+func (s *Stream) Get(id ID) ([][]byte, bool) {
+	return s.entries.get(id)
+}
+
+// Delete 删除消息, 并更新被删除的最大ID
+func (s *Stream) Delete(id ID) bool {
+	if _, ok := s.entries.remove(id); !ok {
+		return false
+	}
+	if s.maxDeletedID.Less(id) {
+		s.maxDeletedID = id
+	}
+	return true
+}
+
+// Range 返回[start, end]之间的消息, desc为true时从end开始倒序返回, count不大于0时不限制数量
+func (s *Stream) Range(start, end ID, count int, desc bool) []*Entry {
+	result := make([]*Entry, 0)
+	if end.Less(start) {
+		return result
+	}
+	consumer := func(id ID, fields [][]byte) bool {
+		if id.Less(start) || end.Less(id) {
+			return false
+		}
+		result = append(result, &Entry{ID: id, Fields: fields})
+		return count <= 0 || len(result) < count
+	}
+	if desc {
+		s.entries.descend(end, consumer)
+	} else {
+		s.entries.ascend(start, consumer)
+	}
+	return result
+}
+
+// FirstEntry 返回第一条消息, stream为空时返回nil
+func (s *Stream) FirstEntry() *Entry {
+	id, fields, ok := s.entries.first()
+	if !ok {
+		return nil
+	}
+	return &Entry{ID: id, Fields: fields}
+}
+
+// LastEntry 返回最后一条消息, stream为空时返回nil
+func (s *Stream) LastEntry() *Entry {
+	id, fields, ok := s.entries.last()
+	if !ok {
+		return nil
+	}
+	return &Entry{ID: id, Fields: fields}
+}
+
+// trim 从头部删除满足条件的消息, limit不大于0时不限制删除数量, 返回删除的数量
+func (s *Stream) trim(limit int, shouldRemove func(id ID) bool) int {
+	removed := 0
+	for limit <= 0 || removed < limit {
+		id, _, ok := s.entries.first()
+		if !ok || !shouldRemove(id) {
+			break
+		}
+		s.entries.remove(id)
+		removed++
+	}
+	return removed
+}
+
+// TrimMaxLen 删除最早的消息使长度不超过maxLen, 裁剪不会修改maxDeletedID
+func (s *Stream) TrimMaxLen(maxLen, limit int) int {
+	return s.trim(limit, func(id ID) bool {
+		return s.entries.len() > maxLen
+	})
+}
+
+// TrimMinID 删除ID小于minID的消息
+func (s *Stream) TrimMinID(minID ID, limit int) int {
+	return s.trim(limit, func(id ID) bool {
+		return id.Less(minID)
+	})
+}
+
+// Group 返回消费者组, 不存在时返回nil
+func (s *Stream) Group(name string) *Group {
+	return s.groups[name]
+}
+
+// CreateGroup 创建消费者组, 已经存在时返回false
+func (s *Stream) CreateGroup(name string, lastID ID, entriesRead int64) (*Group, bool) {
+	if _, ok := s.groups[name]; ok {
+		return nil, false
+	}
+	group := newGroup(name, lastID, entriesRead)
+	s.groups[name] = group
+	return group, true
+}
+
+func (s *Stream) DestroyGroup(name string) bool {
+	if _, ok := s.groups[name]; !ok {
+		return false
+	}
+	delete(s.groups, name)
+	return true
+}
+
+// Groups 返回按名称排序的所有消费者组
+func (s *Stream) Groups() []*Group {
+	groups := make([]*Group, 0, len(s.groups))
+	for _, group := range s.groups {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// Clone 深复制stream以及所有消费者组
+func (s *Stream) Clone() *Stream {
+	result := &Stream{
+		entries: s.entries.clone(func(fields [][]byte) [][]byte {
+			cloned := make([][]byte, len(fields))
+			for i, field := range fields {
+				cloned[i] = slices.Clone(field)
+			}
+			return cloned
+		}),
+		lastID:       s.lastID,
+		maxDeletedID: s.maxDeletedID,
+		entriesAdded: s.entriesAdded,
+		groups:       make(map[string]*Group, len(s.groups)),
+	}
+	for name, group := range s.groups {
+		result.groups[name] = group.clone()
+	}
+	return result
+}
@@ -0,0 +1,121 @@
+package stream
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestIndex(t *testing.T) {
+	idx := &index[int]{}
+	perm := rand.Perm(1000)
+	for _, i := range perm {
+		idx.put(ID{Ms: uint64(i)}, i)
+	}
+	if idx.len() != 1000 {
+		t.Fatalf("expected 1000 elements, actual %d", idx.len())
+	}
+	expected := 0
+	idx.ascend(MinID, func(id ID, value int) bool {
+		if value != expected || id.Ms != uint64(expected) {
+			t.Fatalf("expected %d, actual %d", expected, value)
+		}
+		expected++
+		return true
+	})
+	for i := 0; i < 1000; i += 2 {
+		if _, ok := idx.remove(ID{Ms: uint64(i)}); !ok {
+			t.Fatalf("%d should be removed", i)
+		}
+	}
+	expected = 999
+	idx.descend(ID{Ms: 1000}, func(id ID, value int) bool {
+		if value != expected {
+			t.Fatalf("expected %d, actual %d", expected, value)
+		}
+		expected -= 2
+		return true
+	})
+	if expected != -1 || idx.len() != 500 {
+		t.Error("wrong elements after remove")
+	}
+	if _, ok := idx.get(ID{Ms: 500}); ok {
+		t.Error("removed element should not exist")
+	}
+	if v, _ := idx.get(ID{Ms: 501}); v != 501 {
+		t.Error("element 501 should exist")
+	}
+}
+
+func TestStream(t *testing.T) {
+	s := New()
+	for i := 1; i <= 300; i++ {
+		s.Add(ID{Ms: 1, Seq: uint64(i)}, [][]byte{[]byte("i"), []byte(strconv.Itoa(i))})
+	}
+	if id, _ := s.NextID(1); id != (ID{Ms: 1, Seq: 301}) {
+		t.Errorf("wrong next id %s", id)
+	}
+	if _, ok := s.NextSeq(0); ok {
+		t.Error("id smaller than last id should not be generated")
+	}
+	entries := s.Range(ID{Ms: 1, Seq: 10}, ID{Ms: 1, Seq: 20}, 5, true)
+	if len(entries) != 5 || entries[0].ID.Seq != 20 || entries[4].ID.Seq != 16 {
+		t.Error("wrong reversed range")
+	}
+	s.Delete(ID{Ms: 1, Seq: 150})
+	if s.MaxDeletedID() != (ID{Ms: 1, Seq: 150}) || s.Len() != 299 {
+		t.Error("wrong stream after delete")
+	}
+	if removed := s.TrimMaxLen(100, 0); removed != 199 || s.FirstID() != (ID{Ms: 1, Seq: 201}) {
+		t.Errorf("wrong trim result %d", removed)
+	}
+	if removed := s.TrimMinID(ID{Ms: 1, Seq: 250}, 10); removed != 10 {
+		t.Errorf("trim should be limited, actual %d", removed)
+	}
+	if s.EntriesAdded() != 300 || s.LastID() != (ID{Ms: 1, Seq: 300}) {
+		t.Error("trim should not change last id")
+	}
+}
+
+func TestGroup(t *testing.T) {
+	s := New()
+	for i := 1; i <= 5; i++ {
+		s.Add(ID{Ms: uint64(i)}, [][]byte{[]byte("f"), []byte("v")})
+	}
+	g, _ := s.CreateGroup("g", MinID, 0)
+	if lag, ok := s.Lag(g); !ok || lag != 5 {
+		t.Errorf("expected lag 5, actual %d", lag)
+	}
+	now := time.Now()
+	alice, _ := g.CreateConsumer("alice", now)
+	bob, _ := g.CreateConsumer("bob", now)
+	for _, entry := range s.Range(MinID, MaxID, 3, false) {
+		g.Assign(entry.ID, alice, now, 1)
+		s.MarkRead(g, entry.ID)
+	}
+	if lag, _ := s.Lag(g); lag != 2 || g.LastID != (ID{Ms: 3}) {
+		t.Errorf("expected lag 2, actual %d", lag)
+	}
+	g.Assign(ID{Ms: 2}, bob, now, 2)
+	if alice.PendingLen() != 2 || bob.PendingLen() != 1 || g.PendingLen() != 3 {
+		t.Error("pending entry should be transferred")
+	}
+
+	cloned := s.Clone()
+	if !g.Ack(ID{Ms: 2}) || g.Ack(ID{Ms: 2}) || bob.PendingLen() != 0 {
+		t.Error("wrong ack result")
+	}
+	if pending, _ := g.DeleteConsumer("alice"); pending != 2 || g.PendingLen() != 0 {
+		t.Error("pending entries of deleted consumer should be removed")
+	}
+	clonedGroup := cloned.Group("g")
+	if clonedGroup.PendingLen() != 3 || clonedGroup.Pending(ID{Ms: 2}).Consumer != clonedGroup.Consumer("bob") {
+		t.Error("cloned group should not be affected")
+	}
+
+	s.Delete(ID{Ms: 4})
+	if _, ok := s.Lag(g); ok {
+		t.Error("lag should be unknown after deleting unread entry")
+	}
+}
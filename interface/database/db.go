@@ -21,7 +21,7 @@ type DB interface {
 type DBEngine interface {
 	DB
 	ExecWithLock(conn redis.Conn, line CmdLine) redis.Reply
-	ExecMulti(conn redis.Conn, watching map[string]uint32, cmdLines []CmdLine) redis.Reply
+	ExecMulti(conn redis.Conn, watching map[redis.WatchKey]uint32, cmdLines []CmdLine) redis.Reply
 	GetUndoLogs(dbIndex int, cmdLine [][]byte) []CmdLine
 	ForEach(dbIndex int, cb func(key string, data *DataEntity, expiration time.Time) bool)
 	RWLocks(dbIndex int, writeKeys []string, readKeys []string)
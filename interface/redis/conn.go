@@ -1,5 +1,11 @@
 package redis
 
+// WatchKey 被WATCH的key及其所在的DB编号
+type WatchKey struct {
+	DBIndex int
+	Key     string
+}
+
 // Conn 代表与Redis客户端的连接
 type Conn interface {
 	Write([]byte) (int, error)
@@ -9,13 +15,36 @@ type Conn interface {
 	SetMultiState(bool)
 	GetQueuedCmdLine() [][][]byte
 	EnqueueCmd([][]byte)
-	ClearQueueCmds()
-	GetWatching() map[string]uint32
+	ClearQueuedCmds()
+	GetWatching() map[WatchKey]uint32
 	AddTxError(error)
 	GetTxErrors() []error
 
+	// Subscribe 等方法记录连接订阅的频道、模式和分片频道
+	Subscribe(channel string)
+	UnSubsribe(channel string)
+	// SubsCount 返回订阅的频道和模式的总数
+	SubsCount() int
+	GetChannels() []string
+	PSubscribe(pattern string)
+	PUnSubscribe(pattern string)
+	GetPatterns() []string
+	SSubscribe(channel string)
+	SUnSubscribe(channel string)
+	SSubsCount() int
+	GetShardChannels() []string
+
 	GetDBIndex() int
 	SelectDB(int)
 
+	SetPassword(string)
+	GetPassword() string
+
+	// GetProtocol 返回连接使用的RESP协议版本
+	GetProtocol() int
+	SetProtocol(int)
+
 	Name() string
+	// ID 返回连接的唯一编号, CLIENT TRACKING的REDIRECT使用它指定接收失效消息的连接
+	ID() int64
 }
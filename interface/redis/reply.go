@@ -4,3 +4,10 @@ package redis
 type Reply interface {
 	ToBytes() []byte
 }
+
+// ProtocolReply 可以按协议版本序列化的回复
+// ToBytes 总是返回RESP2的编码, RESP3特有的类型在RESP2连接上会降级为数组、字符串或整数
+type ProtocolReply interface {
+	Reply
+	ToProtocolBytes(version int) []byte
+}
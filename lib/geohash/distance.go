@@ -0,0 +1,34 @@
+package geohash
+
+import "math"
+
+// earthRadius 与redis使用相同的地球半径, 单位为米
+const earthRadius = 6372797.560856
+
+// mercatorMax 墨卡托投影中赤道长度的一半
+const mercatorMax = 20037726.37
+
+func degRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// Distance 使用haversine公式计算两点之间的距离, 单位为米
+func Distance(latitude1, longitude1, latitude2, longitude2 float64) float64 {
+	lat1, lng1 := degRad(latitude1), degRad(longitude1)
+	lat2, lng2 := degRad(latitude2), degRad(longitude2)
+	u := math.Sin((lat2 - lat1) / 2)
+	v := math.Sin((lng2 - lng1) / 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(u*u+math.Cos(lat1)*math.Cos(lat2)*v*v))
+}
+
+// DistanceInBox 判断点是否在以center为中心, 宽width高height(单位为米)的矩形内, 在矩形内时返回到中心的距离
+func DistanceInBox(width, height, centerLatitude, centerLongitude, latitude, longitude float64) (float64, bool) {
+	// 纬度方向的距离计算更简单, 先检查纬度
+	if earthRadius*math.Abs(degRad(latitude)-degRad(centerLatitude)) > height/2 {
+		return 0, false
+	}
+	if Distance(latitude, longitude, latitude, centerLongitude) > width/2 {
+		return 0, false
+	}
+	return Distance(centerLatitude, centerLongitude, latitude, longitude), true
+}
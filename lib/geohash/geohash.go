@@ -6,16 +6,36 @@ var bits = []uint8{1 << 7, 1 << 6, 1 << 5, 1 << 4, 1 << 3, 1 << 2, 1 << 1, 1 <<
 var enc = base32.NewEncoding("0123456789bcdefghjkmnpqrstuvwxyz").WithPadding(base32.NoPadding)
 
 const (
-	defaultBitSize = 64 // 32bit
+	// 与redis一样经度和纬度各二分26次, 交错后得到52位的整数, 可以无损地保存在float64中
+	defaultStep    = 26
+	defaultBitSize = defaultStep * 2
+
+	// MaxLatitude 墨卡托投影能表示的纬度范围
+	MaxLatitude  = 85.05112878
+	MinLatitude  = -85.05112878
+	MaxLongitude = 180
+	MinLongitude = -180
 )
 
-func encode0(latitude, longtitude float64, bitSize uint) ([]byte, [2][2]float64) {
-	box := [2][2]float64{
-		{-180, -180},
-		{-90, -90},
-	}
+// box [经度, 纬度][最小值, 最大值]
+type box = [2][2]float64
+
+// mercatorBox 分数使用的编码范围
+var mercatorBox = box{
+	{MinLongitude, MaxLongitude},
+	{MinLatitude, MaxLatitude},
+}
 
-	pos := [2]float64{longtitude, latitude}
+// standardBox 标准geohash字符串使用的编码范围
+var standardBox = box{
+	{-180, 180},
+	{-90, 90},
+}
+
+// encode0 在area内交替对经度和纬度做bitSize次二分, 落在上半部分时对应的位为1,
+// 返回按大端序保存的结果以及最后所在的区域
+func encode0(latitude, longitude float64, area box, bitSize uint) ([]byte, box) {
+	pos := [2]float64{longitude, latitude}
 	hashLen := bitSize >> 3
 	if bitSize&7 > 0 {
 		hashLen++
@@ -24,13 +44,86 @@ func encode0(latitude, longtitude float64, bitSize uint) ([]byte, [2][2]float64)
 	var precision uint = 0
 	for precision < bitSize {
 		for dire, val := range pos {
-			mid := (box[dire][0] + box[dire][1]) / 2
+			mid := (area[dire][0] + area[dire][1]) / 2
 			if val < mid {
-				box[dire][1] = mid
+				area[dire][1] = mid
 			} else {
-				box[dire][0] = mid
+				area[dire][0] = mid
+				hash[precision>>3] |= bits[precision&7]
+			}
+			precision++
+			if precision == bitSize {
+				break
+			}
+		}
+	}
+	return hash, area
+}
 
+// decode0 encode0的逆过程, 返回hash表示的区域
+func decode0(hash []byte, area box, bitSize uint) box {
+	var precision uint = 0
+	for precision < bitSize {
+		for dire := range area {
+			mid := (area[dire][0] + area[dire][1]) / 2
+			if hash[precision>>3]&bits[precision&7] == 0 {
+				area[dire][1] = mid
+			} else {
+				area[dire][0] = mid
+			}
+			precision++
+			if precision == bitSize {
+				break
 			}
 		}
 	}
+	return area
+}
+
+// toInt 把按大端序保存的bitSize位转换为整数
+func toInt(hash []byte, bitSize uint) uint64 {
+	var code uint64
+	for _, b := range hash {
+		code = code<<8 | uint64(b)
+	}
+	return code >> (uint(len(hash))*8 - bitSize)
+}
+
+// fromInt toInt的逆过程
+func fromInt(code uint64, bitSize uint) []byte {
+	hashLen := (bitSize + 7) >> 3
+	code <<= hashLen*8 - bitSize
+	hash := make([]byte, hashLen)
+	for i := int(hashLen) - 1; i >= 0; i-- {
+		hash[i] = byte(code)
+		code >>= 8
+	}
+	return hash
+}
+
+// Valid 判断经纬度是否在可以编码的范围内
+func Valid(latitude, longitude float64) bool {
+	return longitude >= MinLongitude && longitude <= MaxLongitude &&
+		latitude >= MinLatitude && latitude <= MaxLatitude
+}
+
+// Encode 把经纬度编码为52位整数, 用作有序集合中的分数
+func Encode(latitude, longitude float64) uint64 {
+	hash, _ := encode0(latitude, longitude, mercatorBox, defaultBitSize)
+	return toInt(hash, defaultBitSize)
+}
+
+// Decode 返回编码对应区域的中心点
+func Decode(code uint64) (latitude, longitude float64) {
+	area := decode0(fromInt(code, defaultBitSize), mercatorBox, defaultBitSize)
+	longitude = min(max((area[0][0]+area[0][1])/2, MinLongitude), MaxLongitude)
+	latitude = min(max((area[1][0]+area[1][1])/2, MinLatitude), MaxLatitude)
+	return latitude, longitude
+}
+
+// ToString 返回与redis的GEOHASH命令相同的11位字符串:
+// 在标准范围内重新编码为52位, 前10个字符使用前50位, 最后一个字符固定为0
+func ToString(latitude, longitude float64) string {
+	hash, _ := encode0(latitude, longitude, standardBox, defaultBitSize)
+	return enc.EncodeToString(hash)[:10] + "0"
 }
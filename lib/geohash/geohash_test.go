@@ -0,0 +1,58 @@
+package geohash
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	// 与redis GEOADD Sicily 13.361389 38.115556 Palermo 的结果一致
+	code := Encode(38.115556, 13.361389)
+	if code != 3479099956230698 {
+		t.Errorf("wrong code %d", code)
+	}
+	lat, lng := Decode(code)
+	if math.Abs(lat-38.115556) > 1e-5 || math.Abs(lng-13.361389) > 1e-5 {
+		t.Errorf("wrong position %f,%f", lng, lat)
+	}
+	if s := ToString(lat, lng); s != "sqc8b49rny0" {
+		t.Errorf("wrong geohash %s", s)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	dist := Distance(38.115556, 13.361389, 37.502669, 15.087269)
+	if math.Abs(dist-166274.1516) > 1 {
+		t.Errorf("wrong distance %f", dist)
+	}
+	if _, ok := DistanceInBox(400000, 100000, 37, 15, 38.115556, 13.361389); ok {
+		t.Error("point should be out of box")
+	}
+	if _, ok := DistanceInBox(400000, 400000, 37, 15, 38.115556, 13.361389); !ok {
+		t.Error("point should be in box")
+	}
+}
+
+func TestGetNeighbours(t *testing.T) {
+	points := [][2]float64{{38.115556, 13.361389}, {37.502669, 15.087269}, {0, 179.9999}, {0, -179.9999}, {85, 0}}
+	for _, radius := range []float64{10, 1000, 200000, 5000000} {
+		for _, center := range points {
+			ranges := GetNeighbours(center[0], center[1], radius)
+			for _, p := range points {
+				if Distance(center[0], center[1], p[0], p[1]) > radius {
+					continue
+				}
+				code := Encode(p[0], p[1])
+				covered := false
+				for _, r := range ranges {
+					if code >= r[0] && code < r[1] {
+						covered = true
+					}
+				}
+				if !covered {
+					t.Errorf("%v should be covered by the neighbours of %v with radius %f", p, center, radius)
+				}
+			}
+		}
+	}
+}
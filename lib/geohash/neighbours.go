@@ -0,0 +1,70 @@
+package geohash
+
+// estimateStep 根据搜索半径估算二分次数, 使中心所在的区域及其周围8个区域能够覆盖搜索范围
+func estimateStep(radius, latitude float64) uint {
+	if radius == 0 {
+		return defaultStep
+	}
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	step -= 2
+	// 越靠近两极, 同样经度差对应的距离越短, 需要更大的区域
+	if latitude > 66 || latitude < -66 {
+		step--
+		if latitude > 80 || latitude < -80 {
+			step--
+		}
+	}
+	return uint(min(max(step, 1), defaultStep))
+}
+
+// GetNeighbours 返回覆盖以(latitude, longitude)为中心, radius(单位为米)为半径的圆的分数范围,
+// 每个范围为[min, max), 最多9个
+func GetNeighbours(latitude, longitude, radius float64) [][2]uint64 {
+	step := estimateStep(radius, latitude)
+	var area box
+	for {
+		_, area = encode0(latitude, longitude, mercatorBox, step*2)
+		width, height := area[0][1]-area[0][0], area[1][1]-area[1][0]
+		// 搜索范围靠近区域边缘时, 周围的区域可能不足以覆盖搜索范围, 需要降低精度
+		tooSmall := Distance(latitude, longitude, area[1][1]+height, longitude) < radius ||
+			Distance(latitude, longitude, area[1][0]-height, longitude) < radius ||
+			Distance(latitude, longitude, latitude, area[0][1]+width) < radius ||
+			Distance(latitude, longitude, latitude, area[0][0]-width) < radius
+		if step == 1 || !tooSmall {
+			break
+		}
+		step--
+	}
+
+	width, height := area[0][1]-area[0][0], area[1][1]-area[1][0]
+	centerLng, centerLat := (area[0][0]+area[0][1])/2, (area[1][0]+area[1][1])/2
+	shift := defaultBitSize - step*2
+	seen := make(map[uint64]struct{})
+	ranges := make([][2]uint64, 0, 9)
+	for _, dLat := range []float64{0, -1, 1} {
+		for _, dLng := range []float64{0, -1, 1} {
+			lat, lng := centerLat+dLat*height, centerLng+dLng*width
+			if lat < MinLatitude || lat > MaxLatitude {
+				continue
+			}
+			// 经度方向首尾相接
+			if lng > MaxLongitude {
+				lng -= 360
+			} else if lng < MinLongitude {
+				lng += 360
+			}
+			hash, _ := encode0(lat, lng, mercatorBox, step*2)
+			code := toInt(hash, step*2)
+			if _, ok := seen[code]; ok {
+				continue
+			}
+			seen[code] = struct{}{}
+			ranges = append(ranges, [2]uint64{code << shift, (code + 1) << shift})
+		}
+	}
+	return ranges
+}
@@ -5,7 +5,7 @@ import "time"
 var tw = New(time.Second, 3600)
 
 func init() {
-	tw.start()
+	tw.Start()
 }
 
 // Delay 在duration时间后执行任务
@@ -112,7 +112,7 @@ func (tw *TimeWheel) tickerHandler() {
 	} else {
 		tw.curPos++
 	}
-	go tw.scanAndRunTask(l)
+	tw.scanAndRunTask(l)
 }
 
 // scanAndRunTask 扫描时间轮槽位上的链表，并执行对应圈数的任务
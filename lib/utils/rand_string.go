@@ -26,3 +26,22 @@ func RandHexString(n int) string {
 	}
 	return string(b)
 }
+
+// RandDistinctInts 从[0, n)中随机选取k个不重复的整数, 顺序随机, k不能大于n.
+// 使用Floyd算法, 只需要O(k)的内存, 不需要生成整个排列
+func RandDistinctInts(n, k int) []int {
+	result := make([]int, 0, k)
+	chosen := make(map[int]struct{}, k)
+	for j := n - k; j < n; j++ {
+		t := rand.Intn(j + 1)
+		if _, ok := chosen[t]; ok {
+			t = j
+		}
+		chosen[t] = struct{}{}
+		result = append(result, t)
+	}
+	rand.Shuffle(len(result), func(i, j int) {
+		result[i], result[j] = result[j], result[i]
+	})
+	return result
+}
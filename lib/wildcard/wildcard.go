@@ -0,0 +1,129 @@
+package wildcard
+
+// 与redis的stringmatchlen保持一致的glob匹配:
+//   - ?     匹配任意单个字符
+//   - *     匹配任意长度的字符串
+//   - [abc] 匹配集合中的字符, 支持 [^abc] 取反和 [a-z] 区间
+//   - \x    转义, 匹配字符x本身
+
+const (
+	normalChar   = iota
+	starChar     // *
+	questionChar // ?
+	charClass    // [...]
+)
+
+type item struct {
+	character byte
+	typeCode  int
+	negate    bool
+	// class类型的匹配区间, 单个字符用起止相同的区间表示
+	ranges [][2]byte
+}
+
+// Pattern 编译后的glob模式
+type Pattern struct {
+	items []*item
+}
+
+// CompilePattern 编译glob模式, 未闭合的 [ 与redis一样视为延伸到模式末尾
+func CompilePattern(src string) *Pattern {
+	items := make([]*item, 0, len(src))
+	for i := 0; i < len(src); i++ {
+		ch := src[i]
+		switch ch {
+		case '*':
+			// 连续的*等价于一个
+			if len(items) > 0 && items[len(items)-1].typeCode == starChar {
+				continue
+			}
+			items = append(items, &item{typeCode: starChar})
+		case '?':
+			items = append(items, &item{typeCode: questionChar})
+		case '[':
+			it := &item{typeCode: charClass}
+			i++
+			if i < len(src) && src[i] == '^' {
+				it.negate = true
+				i++
+			}
+			for ; i < len(src) && src[i] != ']'; i++ {
+				c := src[i]
+				if c == '\\' && i+1 < len(src) {
+					i++
+					c = src[i]
+				} else if i+2 < len(src) && src[i+1] == '-' {
+					end := src[i+2]
+					if c > end {
+						c, end = end, c
+					}
+					it.ranges = append(it.ranges, [2]byte{c, end})
+					i += 2
+					continue
+				}
+				it.ranges = append(it.ranges, [2]byte{c, c})
+			}
+			items = append(items, it)
+		case '\\':
+			// 末尾的反斜杠匹配它自己
+			if i+1 < len(src) {
+				i++
+			}
+			items = append(items, &item{typeCode: normalChar, character: src[i]})
+		default:
+			items = append(items, &item{typeCode: normalChar, character: ch})
+		}
+	}
+	return &Pattern{items: items}
+}
+
+func (it *item) matchByte(c byte) bool {
+	switch it.typeCode {
+	case questionChar:
+		return true
+	case charClass:
+		for _, r := range it.ranges {
+			if c >= r[0] && c <= r[1] {
+				return !it.negate
+			}
+		}
+		return it.negate
+	default:
+		return it.character == c
+	}
+}
+
+// IsMatch 判断字符串是否匹配模式
+func (p *Pattern) IsMatch(s string) bool {
+	items := p.items
+	si, pi := 0, 0
+	// 最近一个*的位置以及它当前吞掉的字符串末尾, 匹配失败时回溯到这里让*多匹配一个字符
+	starIdx, starMatch := -1, 0
+	for si < len(s) {
+		if pi < len(items) && items[pi].typeCode == starChar {
+			starIdx, starMatch = pi, si
+			pi++
+			continue
+		}
+		if pi < len(items) && items[pi].matchByte(s[si]) {
+			si++
+			pi++
+			continue
+		}
+		if starIdx < 0 {
+			return false
+		}
+		starMatch++
+		si = starMatch
+		pi = starIdx + 1
+	}
+	for pi < len(items) && items[pi].typeCode == starChar {
+		pi++
+	}
+	return pi == len(items)
+}
+
+// IsMatchAll 模式是否只是单个*, 调用方可以借此跳过逐个匹配
+func (p *Pattern) IsMatchAll() bool {
+	return len(p.items) == 1 && p.items[0].typeCode == starChar
+}
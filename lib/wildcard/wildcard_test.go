@@ -0,0 +1,37 @@
+package wildcard
+
+import "testing"
+
+func TestIsMatch(t *testing.T) {
+	cases := []struct {
+		pattern, str string
+		expected     bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hlo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hallo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"[\\]]", "]", true},
+		{"a\\", "a\\", true},
+		{"user:*:name", "user:1:name", true},
+		{"user:*:name", "user:1:age", false},
+		{"*a*b", "xaybzab", true},
+		{"a[bc", "ab", true},
+	}
+	for _, c := range cases {
+		if actual := CompilePattern(c.pattern).IsMatch(c.str); actual != c.expected {
+			t.Errorf("%q match %q: expected %v, actual %v", c.pattern, c.str, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"goredis/config"
+	"goredis/lib/logger"
+	"goredis/redis/server"
+	"goredis/tcp"
+	"os"
+)
+
+const defaultConfigFile = "redis.conf"
+
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && !info.IsDir()
+}
+
+func main() {
+	logger.Setup(&logger.Settings{
+		Path:       "logs",
+		Name:       "goredis",
+		Ext:        "log",
+		TimeFormat: "2006-01-02",
+	})
+
+	// 优先使用环境变量CONFIG指定的配置文件, 否则尝试读取当前目录下的redis.conf
+	configFilename := os.Getenv("CONFIG")
+	if configFilename == "" && fileExists(defaultConfigFile) {
+		configFilename = defaultConfigFile
+	}
+	if configFilename != "" {
+		config.SetupConfig(configFilename)
+	}
+
+	err := tcp.ListenAndServeWithSignal(&tcp.Config{
+		Addr: fmt.Sprintf("%s:%d", config.Properties.Bind, config.Properties.Port),
+	}, server.MakeHandler())
+	if err != nil {
+		logger.Error(err)
+	}
+}
@@ -0,0 +1,150 @@
+// Package pubsub 实现了发布订阅, 包括普通频道、glob模式和分片频道
+package pubsub
+
+import (
+	"goredis/datastruct/dict"
+	"goredis/datastruct/lock"
+	"goredis/interface/redis"
+	"goredis/lib/wildcard"
+)
+
+const (
+	subscriptionDictSize = 1 << 4
+	subscriptionLockSize = 1 << 4
+)
+
+// subscription 订阅了同一个频道或者模式的客户端
+type subscription struct {
+	// pattern 只有模式订阅才会编译
+	pattern *wildcard.Pattern
+	conns   map[redis.Conn]struct{}
+}
+
+// registry 频道或者模式到订阅者的映射, 同一个key上的修改由locker串行化
+type registry struct {
+	subs   *dict.ConcurrentDict // key -> *subscription
+	locker *lock.Locks
+	isGlob bool
+}
+
+func newRegistry(isGlob bool) *registry {
+	return &registry{
+		subs:   dict.NewConcurrent(subscriptionDictSize),
+		locker: lock.New(subscriptionLockSize),
+		isGlob: isGlob,
+	}
+}
+
+// subscribe 将c加入key的订阅者, 已经订阅时返回false
+func (r *registry) subscribe(key string, c redis.Conn) bool {
+	r.locker.Lock(key)
+	defer r.locker.UnLock(key)
+	raw, ok := r.subs.Get(key)
+	if !ok {
+		sub := &subscription{conns: make(map[redis.Conn]struct{})}
+		if r.isGlob {
+			sub.pattern = wildcard.CompilePattern(key)
+		}
+		r.subs.Put(key, sub)
+		raw = sub
+	}
+	sub := raw.(*subscription)
+	if _, subscribed := sub.conns[c]; subscribed {
+		return false
+	}
+	sub.conns[c] = struct{}{}
+	return true
+}
+
+// unsubscribe 将c从key的订阅者中移除, 没有订阅者时删除key
+func (r *registry) unsubscribe(key string, c redis.Conn) bool {
+	r.locker.Lock(key)
+	defer r.locker.UnLock(key)
+	raw, ok := r.subs.Get(key)
+	if !ok {
+		return false
+	}
+	sub := raw.(*subscription)
+	if _, subscribed := sub.conns[c]; !subscribed {
+		return false
+	}
+	delete(sub.conns, c)
+	if len(sub.conns) == 0 {
+		r.subs.Remove(key)
+	}
+	return true
+}
+
+// subscribers 返回key的订阅者
+func (r *registry) subscribers(key string) []redis.Conn {
+	r.locker.RLock(key)
+	defer r.locker.RunLock(key)
+	raw, ok := r.subs.Get(key)
+	if !ok {
+		return nil
+	}
+	return connsOf(raw.(*subscription))
+}
+
+func (r *registry) count(key string) int {
+	r.locker.RLock(key)
+	defer r.locker.RunLock(key)
+	raw, ok := r.subs.Get(key)
+	if !ok {
+		return 0
+	}
+	return len(raw.(*subscription).conns)
+}
+
+// match 遍历所有与channel匹配的模式以及它们的订阅者
+func (r *registry) match(channel string, consumer func(pattern string, conns []redis.Conn)) {
+	for _, key := range r.subs.Keys() {
+		raw, ok := r.subs.Get(key)
+		if !ok || !raw.(*subscription).pattern.IsMatch(channel) {
+			continue
+		}
+		if conns := r.subscribers(key); len(conns) > 0 {
+			consumer(key, conns)
+		}
+	}
+}
+
+func connsOf(sub *subscription) []redis.Conn {
+	conns := make([]redis.Conn, 0, len(sub.conns))
+	for c := range sub.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// Hub 保存所有频道、模式和分片频道的订阅者
+type Hub struct {
+	channels *registry
+	patterns *registry
+	shards   *registry
+}
+
+// MakeHub 创建Hub
+func MakeHub() *Hub {
+	return &Hub{
+		channels: newRegistry(false),
+		patterns: newRegistry(true),
+		shards:   newRegistry(false),
+	}
+}
+
+// UnsubscribeAll 客户端断开时取消它的所有订阅
+func (hub *Hub) UnsubscribeAll(c redis.Conn) {
+	for _, channel := range c.GetChannels() {
+		hub.channels.unsubscribe(channel, c)
+		c.UnSubsribe(channel)
+	}
+	for _, pattern := range c.GetPatterns() {
+		hub.patterns.unsubscribe(pattern, c)
+		c.PUnSubscribe(pattern)
+	}
+	for _, channel := range c.GetShardChannels() {
+		hub.shards.unsubscribe(channel, c)
+		c.SUnSubscribe(channel)
+	}
+}
@@ -0,0 +1,288 @@
+package pubsub
+
+import (
+	"goredis/interface/redis"
+	"goredis/lib/wildcard"
+	"goredis/redis/protocol"
+	"sort"
+	"strings"
+)
+
+// 订阅相关的回复与消息在RESP3下是推送类型, RESP2下是普通数组
+const (
+	kindSubscribe    = "subscribe"
+	kindUnsubscribe  = "unsubscribe"
+	kindPSubscribe   = "psubscribe"
+	kindPUnsubscribe = "punsubscribe"
+	kindSSubscribe   = "ssubscribe"
+	kindSUnsubscribe = "sunsubscribe"
+	kindMessage      = "message"
+	kindPMessage     = "pmessage"
+	kindSMessage     = "smessage"
+)
+
+// subscribeModeCommands RESP2连接订阅后只能执行的命令
+var subscribeModeCommands = map[string]bool{
+	"subscribe":    true,
+	"unsubscribe":  true,
+	"psubscribe":   true,
+	"punsubscribe": true,
+	"ssubscribe":   true,
+	"sunsubscribe": true,
+	"ping":         true,
+	"quit":         true,
+	"reset":        true,
+}
+
+// InSubscribeMode RESP2连接订阅了任何频道后进入订阅模式, RESP3连接可以在订阅的同时执行其他命令
+func InSubscribeMode(c redis.Conn) bool {
+	return c.GetProtocol() == protocol.RESP2 && c.SubsCount()+c.SSubsCount() > 0
+}
+
+// CheckSubscribeMode 订阅模式下执行不允许的命令时返回错误
+func CheckSubscribeMode(c redis.Conn, cmdName string) redis.Reply {
+	if !InSubscribeMode(c) || subscribeModeCommands[cmdName] {
+		return nil
+	}
+	return protocol.MakeErrReply("ERR Can't execute '" + cmdName +
+		"': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context")
+}
+
+// Ping 订阅模式下的PING以数组形式回复
+func Ping(args [][]byte) redis.Reply {
+	if len(args) > 1 {
+		return protocol.MakeArgNumErrReply("ping")
+	}
+	message := []byte("")
+	if len(args) == 1 {
+		message = args[0]
+	}
+	return protocol.MakeMultiBulkReply([][]byte{[]byte("pong"), message})
+}
+
+func makeMsg(kind string, items ...redis.Reply) redis.Reply {
+	replies := make([]redis.Reply, 0, len(items)+1)
+	replies = append(replies, protocol.MakeBulkReply([]byte(kind)))
+	replies = append(replies, items...)
+	return protocol.MakePushReply(replies)
+}
+
+func send(c redis.Conn, msg redis.Reply) {
+	_, _ = c.Write(protocol.Marshal(msg, c.GetProtocol()))
+}
+
+func makeSubscribeMsg(kind string, channel string, count int) redis.Reply {
+	return makeMsg(kind, protocol.MakeBulkReply([]byte(channel)), protocol.MakeIntReply(int64(count)))
+}
+
+// Subscribe SUBSCRIBE channel [channel ...], 每个频道单独回复一条订阅确认
+func Subscribe(hub *Hub, c redis.Conn, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("subscribe")
+	}
+	for _, arg := range args {
+		channel := string(arg)
+		if hub.channels.subscribe(channel, c) {
+			c.Subscribe(channel)
+		}
+		send(c, makeSubscribeMsg(kindSubscribe, channel, c.SubsCount()))
+	}
+	return &protocol.NoReply{}
+}
+
+// UnSubscribe UNSUBSCRIBE [channel ...], 没有参数时取消订阅所有频道
+func UnSubscribe(hub *Hub, c redis.Conn, args [][]byte) redis.Reply {
+	channels := toStrings(args)
+	if len(channels) == 0 {
+		channels = c.GetChannels()
+	}
+	if len(channels) == 0 {
+		send(c, makeMsg(kindUnsubscribe, protocol.MakeNullBulkReply(), protocol.MakeIntReply(int64(c.SubsCount()))))
+		return &protocol.NoReply{}
+	}
+	for _, channel := range channels {
+		if hub.channels.unsubscribe(channel, c) {
+			c.UnSubsribe(channel)
+		}
+		send(c, makeSubscribeMsg(kindUnsubscribe, channel, c.SubsCount()))
+	}
+	return &protocol.NoReply{}
+}
+
+// PSubscribe PSUBSCRIBE pattern [pattern ...]
+func PSubscribe(hub *Hub, c redis.Conn, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("psubscribe")
+	}
+	for _, arg := range args {
+		pattern := string(arg)
+		if hub.patterns.subscribe(pattern, c) {
+			c.PSubscribe(pattern)
+		}
+		send(c, makeSubscribeMsg(kindPSubscribe, pattern, c.SubsCount()))
+	}
+	return &protocol.NoReply{}
+}
+
+// PUnSubscribe PUNSUBSCRIBE [pattern ...], 没有参数时取消订阅所有模式
+func PUnSubscribe(hub *Hub, c redis.Conn, args [][]byte) redis.Reply {
+	patterns := toStrings(args)
+	if len(patterns) == 0 {
+		patterns = c.GetPatterns()
+	}
+	if len(patterns) == 0 {
+		send(c, makeMsg(kindPUnsubscribe, protocol.MakeNullBulkReply(), protocol.MakeIntReply(int64(c.SubsCount()))))
+		return &protocol.NoReply{}
+	}
+	for _, pattern := range patterns {
+		if hub.patterns.unsubscribe(pattern, c) {
+			c.PUnSubscribe(pattern)
+		}
+		send(c, makeSubscribeMsg(kindPUnsubscribe, pattern, c.SubsCount()))
+	}
+	return &protocol.NoReply{}
+}
+
+// SSubscribe SSUBSCRIBE shardchannel [shardchannel ...], 分片频道的订阅数量单独计算
+func SSubscribe(hub *Hub, c redis.Conn, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("ssubscribe")
+	}
+	for _, arg := range args {
+		channel := string(arg)
+		if hub.shards.subscribe(channel, c) {
+			c.SSubscribe(channel)
+		}
+		send(c, makeSubscribeMsg(kindSSubscribe, channel, c.SSubsCount()))
+	}
+	return &protocol.NoReply{}
+}
+
+// SUnSubscribe SUNSUBSCRIBE [shardchannel ...], 没有参数时取消订阅所有分片频道
+func SUnSubscribe(hub *Hub, c redis.Conn, args [][]byte) redis.Reply {
+	channels := toStrings(args)
+	if len(channels) == 0 {
+		channels = c.GetShardChannels()
+	}
+	if len(channels) == 0 {
+		send(c, makeMsg(kindSUnsubscribe, protocol.MakeNullBulkReply(), protocol.MakeIntReply(int64(c.SSubsCount()))))
+		return &protocol.NoReply{}
+	}
+	for _, channel := range channels {
+		if hub.shards.unsubscribe(channel, c) {
+			c.SUnSubscribe(channel)
+		}
+		send(c, makeSubscribeMsg(kindSUnsubscribe, channel, c.SSubsCount()))
+	}
+	return &protocol.NoReply{}
+}
+
+// Publish PUBLISH channel message, 返回收到消息的客户端数量, 包括通过模式订阅收到的
+func Publish(hub *Hub, args [][]byte) redis.Reply {
+	if len(args) != 2 {
+		return protocol.MakeArgNumErrReply("publish")
+	}
+	return protocol.MakeIntReply(int64(hub.Publish(string(args[0]), args[1])))
+}
+
+// Publish 向频道以及匹配的模式的订阅者发送消息, 返回收到消息的客户端数量
+func (hub *Hub) Publish(channel string, message []byte) int {
+	channelReply := protocol.MakeBulkReply([]byte(channel))
+	messageReply := protocol.MakeBulkReply(message)
+	receivers := 0
+	msg := makeMsg(kindMessage, channelReply, messageReply)
+	for _, c := range hub.channels.subscribers(channel) {
+		send(c, msg)
+		receivers++
+	}
+	hub.patterns.match(channel, func(pattern string, conns []redis.Conn) {
+		msg := makeMsg(kindPMessage, protocol.MakeBulkReply([]byte(pattern)), channelReply, messageReply)
+		for _, c := range conns {
+			send(c, msg)
+			receivers++
+		}
+	})
+	return receivers
+}
+
+// MakeMessage 构造频道消息, 消息内容可以是任意类型的回复, 如客户端缓存的失效消息中的key数组
+func MakeMessage(channel string, payload redis.Reply) redis.Reply {
+	return makeMsg(kindMessage, protocol.MakeBulkReply([]byte(channel)), payload)
+}
+
+// SPublish SPUBLISH shardchannel message, 只发送给分片频道的订阅者
+func SPublish(hub *Hub, args [][]byte) redis.Reply {
+	if len(args) != 2 {
+		return protocol.MakeArgNumErrReply("spublish")
+	}
+	channel := string(args[0])
+	msg := makeMsg(kindSMessage, protocol.MakeBulkReply(args[0]), protocol.MakeBulkReply(args[1]))
+	conns := hub.shards.subscribers(channel)
+	for _, c := range conns {
+		send(c, msg)
+	}
+	return protocol.MakeIntReply(int64(len(conns)))
+}
+
+// PubSub PUBSUB CHANNELS|NUMSUB|NUMPAT|SHARDCHANNELS|SHARDNUMSUB
+func PubSub(hub *Hub, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("pubsub")
+	}
+	subCmd := strings.ToLower(string(args[0]))
+	switch subCmd {
+	case "channels":
+		return listChannels(hub.channels, subCmd, args[1:])
+	case "shardchannels":
+		return listChannels(hub.shards, subCmd, args[1:])
+	case "numsub":
+		return countSubscribers(hub.channels, args[1:])
+	case "shardnumsub":
+		return countSubscribers(hub.shards, args[1:])
+	case "numpat":
+		if len(args) != 1 {
+			return protocol.MakeArgNumErrReply("pubsub|numpat")
+		}
+		return protocol.MakeIntReply(int64(hub.patterns.subs.Len()))
+	}
+	return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try PUBSUB HELP.")
+}
+
+// listChannels 返回至少有一个订阅者的频道, 可以按照glob模式过滤
+func listChannels(r *registry, subCmd string, args [][]byte) redis.Reply {
+	if len(args) > 1 {
+		return protocol.MakeArgNumErrReply("pubsub|" + subCmd)
+	}
+	var pattern *wildcard.Pattern
+	if len(args) == 1 {
+		pattern = wildcard.CompilePattern(string(args[0]))
+	}
+	channels := r.subs.Keys()
+	sort.Strings(channels)
+	result := make([][]byte, 0, len(channels))
+	for _, channel := range channels {
+		if pattern == nil || pattern.IsMatch(channel) {
+			result = append(result, []byte(channel))
+		}
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// countSubscribers 返回每个频道的订阅者数量
+func countSubscribers(r *registry, channels [][]byte) redis.Reply {
+	result := make([]redis.Reply, 0, len(channels)*2)
+	for _, channel := range channels {
+		result = append(result,
+			protocol.MakeBulkReply(channel),
+			protocol.MakeIntReply(int64(r.count(string(channel)))))
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+func toStrings(args [][]byte) []string {
+	result := make([]string, len(args))
+	for i, arg := range args {
+		result[i] = string(arg)
+	}
+	return result
+}
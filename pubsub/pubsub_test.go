@@ -0,0 +1,84 @@
+package pubsub
+
+import (
+	"goredis/lib/utils"
+	"goredis/redis/conn"
+	"goredis/redis/protocol"
+	"testing"
+)
+
+func TestPublish(t *testing.T) {
+	hub := MakeHub()
+	c1 := conn.NewFakeConn()
+	c2 := conn.NewFakeConn()
+	Subscribe(hub, c1, utils.ToCmdLine("news", "sports"))
+	if actual := string(c1.Bytes()); actual != "*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n*3\r\n$9\r\nsubscribe\r\n$6\r\nsports\r\n:2\r\n" {
+		t.Errorf("wrong subscribe reply %q", actual)
+	}
+	c2.SetProtocol(protocol.RESP3)
+	PSubscribe(hub, c2, utils.ToCmdLine("n*"))
+	c1.Clean()
+	c2.Clean()
+
+	reply := Publish(hub, utils.ToCmdLine("news", "hello"))
+	if intReply, ok := reply.(*protocol.IntReply); !ok || intReply.Code != 2 {
+		t.Errorf("expected 2 receivers, actual %q", reply.ToBytes())
+	}
+	if actual := string(c1.Bytes()); actual != "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n" {
+		t.Errorf("wrong message %q", actual)
+	}
+	if actual := string(c2.Bytes()); actual != ">4\r\n$8\r\npmessage\r\n$2\r\nn*\r\n$4\r\nnews\r\n$5\r\nhello\r\n" {
+		t.Errorf("wrong pattern message %q", actual)
+	}
+
+	reply = PubSub(hub, utils.ToCmdLine("numsub", "news", "none"))
+	if actual := string(reply.ToBytes()); actual != "*4\r\n$4\r\nnews\r\n:1\r\n$4\r\nnone\r\n:0\r\n" {
+		t.Errorf("wrong numsub %q", actual)
+	}
+	reply = PubSub(hub, utils.ToCmdLine("channels", "s*"))
+	if actual := string(reply.ToBytes()); actual != "*1\r\n$6\r\nsports\r\n" {
+		t.Errorf("wrong channels %q", actual)
+	}
+
+	c1.Clean()
+	UnSubscribe(hub, c1, nil)
+	if c1.SubsCount() != 0 || len(c1.Bytes()) == 0 {
+		t.Error("all channels should be unsubscribed")
+	}
+	hub.UnsubscribeAll(c2)
+	if reply := PubSub(hub, utils.ToCmdLine("numpat")); string(reply.ToBytes()) != ":0\r\n" {
+		t.Errorf("pattern should be removed after client closed, actual %q", reply.ToBytes())
+	}
+	if reply := Publish(hub, utils.ToCmdLine("news", "bye")); string(reply.ToBytes()) != ":0\r\n" {
+		t.Errorf("expected no receivers, actual %q", reply.ToBytes())
+	}
+}
+
+func TestShardChannel(t *testing.T) {
+	hub := MakeHub()
+	c := conn.NewFakeConn()
+	SSubscribe(hub, c, utils.ToCmdLine("orders"))
+	PSubscribe(hub, c, utils.ToCmdLine("*"))
+	c.Clean()
+	// 分片频道的消息不会发送给模式订阅者, 普通频道的消息也不会发送给分片频道的订阅者
+	if reply := SPublish(hub, utils.ToCmdLine("orders", "1")); string(reply.ToBytes()) != ":1\r\n" {
+		t.Errorf("expected 1 receiver, actual %q", reply.ToBytes())
+	}
+	if reply := Publish(hub, utils.ToCmdLine("orders", "2")); string(reply.ToBytes()) != ":1\r\n" {
+		t.Errorf("expected 1 receiver, actual %q", reply.ToBytes())
+	}
+	expected := "*3\r\n$8\r\nsmessage\r\n$6\r\norders\r\n$1\r\n1\r\n" +
+		"*4\r\n$8\r\npmessage\r\n$1\r\n*\r\n$6\r\norders\r\n$1\r\n2\r\n"
+	if actual := string(c.Bytes()); actual != expected {
+		t.Errorf("wrong messages %q", actual)
+	}
+
+	if errReply := CheckSubscribeMode(c, "get"); errReply == nil {
+		t.Error("get should not be allowed in subscribe mode")
+	}
+	SUnSubscribe(hub, c, nil)
+	PUnSubscribe(hub, c, nil)
+	if errReply := CheckSubscribeMode(c, "get"); errReply != nil {
+		t.Errorf("unexpected error %q", errReply.ToBytes())
+	}
+}
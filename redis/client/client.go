@@ -1,12 +1,15 @@
 package client
 
 import (
+	"errors"
 	"goredis/interface/redis"
 	"goredis/lib/logger"
 	"goredis/lib/sync/wait"
 	"goredis/redis/parser"
+	"goredis/redis/protocol"
 	"net"
 	"runtime/debug"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,6 +22,8 @@ const (
 )
 
 type Client struct {
+	// mu 保护conn, 并保证写入请求和放入等待队列这两步不会与重连交错
+	mu          sync.Mutex
 	conn        net.Conn
 	pendingReqs chan *request
 	waitingReqs chan *request
@@ -27,6 +32,11 @@ type Client struct {
 	status int32
 	// 代表还未发送的响应
 	working *sync.WaitGroup
+	ticker  *time.Ticker
+	// done 关闭后写协程和心跳协程退出, 请求队列不会被关闭, 避免向已关闭的channel发送
+	done      chan struct{}
+	workers   sync.WaitGroup
+	closeOnce sync.Once
 }
 
 type request struct {
@@ -40,10 +50,13 @@ type request struct {
 }
 
 const (
-	chanSize = 256
-	maxWait  = 3 * time.Second
+	chanSize          = 256
+	maxWait           = 3 * time.Second
+	heartbeatInterval = 10 * time.Second
 )
 
+var errClosed = errors.New("client closed")
+
 func NewClient(addr string) (*Client, error) {
 	conn, err := net.Dial("tcp", addr)
 	if err != nil {
@@ -55,23 +68,186 @@ func NewClient(addr string) (*Client, error) {
 		waitingReqs: make(chan *request, chanSize),
 		addr:        addr,
 		working:     &sync.WaitGroup{},
+		done:        make(chan struct{}),
 	}, nil
 }
 
+// Start 启动读写和心跳协程
 func (c *Client) Start() {
-
+	c.ticker = time.NewTicker(heartbeatInterval)
+	c.workers.Add(2)
+	go c.handleWrite()
+	go c.heartbeat()
+	go c.handleRead(c.conn)
+	atomic.StoreInt32(&c.status, running)
 }
 
+// Close 等待正在处理的请求完成, 停止写协程和心跳协程后关闭连接
 func (c *Client) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		atomic.StoreInt32(&c.status, closed)
+		c.working.Wait()
+		close(c.done)
+		c.workers.Wait()
+		if c.ticker != nil {
+			c.ticker.Stop()
+		}
+		c.mu.Lock()
+		err = c.conn.Close()
+		c.mu.Unlock()
+		// 让没有机会发送或者等待响应的请求立即失败
+		failRequests(c.pendingReqs, errClosed)
+		failRequests(c.waitingReqs, errClosed)
+	})
+	return err
+}
+
+// failRequests 取出队列中的所有请求并让它们失败
+func failRequests(reqs chan *request, err error) {
+	for {
+		select {
+		case req := <-reqs:
+			req.err = err
+			req.waiting.Done()
+		default:
+			return
+		}
+	}
+}
+
+// reconnect 重新建立连接, 并让所有等待响应的请求失败
+func (c *Client) reconnect(oldConn net.Conn) {
+	logger.Info("reconnect with: " + c.addr)
+	_ = oldConn.Close()
+
+	var conn net.Conn
+	for i := 0; i < 3; i++ {
+		var err error
+		conn, err = net.Dial("tcp", c.addr)
+		if err != nil {
+			logger.Error("reconnect error: " + err.Error())
+			time.Sleep(time.Second)
+			continue
+		}
+		break
+	}
+	if conn == nil {
+		_ = c.Close()
+		return
+	}
 
+	connClosed := errors.New("connection closed")
+	// 写协程可能持有锁并阻塞在已满的等待队列上, 获取锁之前持续清空队列
+	for !c.mu.TryLock() {
+		failRequests(c.waitingReqs, connClosed)
+		time.Sleep(time.Millisecond)
+	}
+	c.conn = conn
+	// 持有锁时队列中的请求都是写入旧连接的, 不会再收到响应
+	failRequests(c.waitingReqs, connClosed)
+	c.mu.Unlock()
+	go c.handleRead(conn)
 }
 
-func (c *Client) reconnect() {}
+// Send 发送命令并等待响应
+func (c *Client) Send(args [][]byte) redis.Reply {
+	if atomic.LoadInt32(&c.status) != running {
+		return protocol.MakeErrReply("client closed")
+	}
+	req := &request{
+		args:    args,
+		waiting: &wait.Wait{},
+	}
+	req.waiting.Add(1)
+	c.working.Add(1)
+	defer c.working.Done()
+	select {
+	case c.pendingReqs <- req:
+	case <-c.done:
+		return protocol.MakeErrReply("client closed")
+	}
+	timeout := req.waiting.WaitWithTimeout(maxWait)
+	if timeout {
+		return protocol.MakeErrReply("server time out")
+	}
+	if req.err != nil {
+		return protocol.MakeErrReply("request failed " + req.err.Error())
+	}
+	return req.reply
+}
 
-func (c Client) doRequest(req *request) {
+// heartbeat 定时发送PING保持连接
+func (c *Client) heartbeat() {
+	defer c.workers.Done()
+	for {
+		select {
+		case <-c.ticker.C:
+			c.doHeartbeat()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *Client) doHeartbeat() {
+	req := &request{
+		args:      [][]byte{[]byte("PING")},
+		heartbeat: true,
+		waiting:   &wait.Wait{},
+	}
+	req.waiting.Add(1)
+	c.working.Add(1)
+	defer c.working.Done()
+	select {
+	case c.pendingReqs <- req:
+	case <-c.done:
+		return
+	}
+	req.waiting.WaitWithTimeout(maxWait)
+}
+
+// handleWrite 按顺序将请求写入连接
+func (c *Client) handleWrite() {
+	defer c.workers.Done()
+	for {
+		select {
+		case req := <-c.pendingReqs:
+			c.doRequest(req)
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// doRequest 写入请求, 写入成功后放入等待响应的队列
+func (c *Client) doRequest(req *request) {
 	if req == nil || len(req.args) == 0 {
 		return
 	}
+	data := protocol.MakeMultiBulkReply(req.args).ToBytes()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	for i := 0; i < 3; i++ {
+		_, err = c.conn.Write(data)
+		if err == nil ||
+			(!strings.Contains(err.Error(), "timeout") && // only retry timeout
+				!strings.Contains(err.Error(), "deadline exceeded")) {
+			break
+		}
+	}
+	if err != nil {
+		req.err = err
+		req.waiting.Done()
+		return
+	}
+	select {
+	case c.waitingReqs <- req:
+	case <-c.done:
+		req.err = errClosed
+		req.waiting.Done()
+	}
 }
 
 // finishRequest 处理客户端的请求结束逻辑，确保请求被正确处理并通知等待的goroutine
@@ -83,8 +259,10 @@ func (c *Client) finishRequest(reply redis.Reply) {
 		}
 	}()
 
-	request := <-c.waitingReqs
-	if request == nil {
+	var request *request
+	select {
+	case request = <-c.waitingReqs:
+	case <-c.done:
 		return
 	}
 	request.reply = reply
@@ -93,16 +271,16 @@ func (c *Client) finishRequest(reply redis.Reply) {
 	}
 }
 
-// handleRead 开一个协程读取并解析网络连接中数据，使用chan传递
-func (c *Client) handleRead() {
-	ch := parser.ParseStream(c.conn)
+// handleRead 读取并解析conn中的回复, 连接出错时重连并在新的协程中读取新连接
+func (c *Client) handleRead(conn net.Conn) {
+	ch := parser.ParseStream(conn)
 	for payload := range ch {
 		if payload.Err != nil {
 			status := atomic.LoadInt32(&c.status)
 			if status == closed {
 				return
 			}
-			c.reconnect()
+			c.reconnect(conn)
 			return
 		}
 		c.finishRequest(payload.Data)
@@ -1,10 +1,12 @@
 package conn
 
 import (
+	"goredis/interface/redis"
 	"goredis/lib/logger"
-	"goredis/lib/sync/wait"
+	"goredis/redis/protocol"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,24 +18,40 @@ const (
 
 type Conn struct {
 	conn net.Conn
+	// closed is set by Close, and reset when the Conn is taken from the pool
+	closed atomic.Bool
 
-	sendingData wait.Wait
+	// id is unique among all connections, assigned when the connection is created
+	id int64
+
+	// writeMu serializes writes, so replies and pushed messages never interleave,
+	// and Close can wait for the write in progress
+	writeMu sync.Mutex
 
 	mu    sync.Mutex
 	flags uint64
 
-	subs map[string]bool
+	// subscribed channels, patterns and shard channels
+	subs  map[string]bool
+	psubs map[string]bool
+	ssubs map[string]bool
 
 	password string
 
+	// RESP protocol version, switched by HELLO
+	protocol int
+
 	// queued cmd for `multi`
 	queue    [][][]byte
-	watching map[string]uint32
+	watching map[redis.WatchKey]uint32
 	txErrors []error
 
 	selectedDB int
 }
 
+// connIDGenerator generates ids of connections, starting from 1
+var connIDGenerator atomic.Int64
+
 var connPool = sync.Pool{
 	New: func() interface{} { return &Conn{} },
 }
@@ -42,27 +60,51 @@ func (c *Conn) RemoteAddr() net.Addr {
 	return c.conn.RemoteAddr()
 }
 
+// Close closes the underlying connection, it is safe to call Close multiple times and concurrently.
+// The Conn itself is not reset, the goroutine that serves the connection should call Release
+// after it stops using the connection.
 func (c *Conn) Close() error {
-	// 等待10秒或者数据处理完成关闭连接
-	c.sendingData.WaitWithTimeout(10 * time.Second)
+	if !c.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	// 正在发送的数据最多再等待10秒, 然后关闭连接
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
 	_ = c.conn.Close()
+	return nil
+}
+
+// Release resets all per-connection state and puts the Conn back to the pool.
+// Only the goroutine that serves the connection may call it, after Close and after
+// the connection has been removed from pub/sub and tracking
+func (c *Conn) Release() {
+	_ = c.Close()
+	c.conn = nil
+	c.id = 0
+	c.flags = 0
 	c.subs = nil
+	c.psubs = nil
+	c.ssubs = nil
 	c.password = ""
 	c.queue = nil
 	c.watching = nil
 	c.txErrors = nil
 	c.selectedDB = 0
+	c.protocol = 0
 	connPool.Put(c)
-	return nil
 }
 
 func NewConn(conn net.Conn) *Conn {
 	c, ok := connPool.Get().(*Conn)
 	if !ok {
 		logger.Error("connection pool make wrong type")
-		return &Conn{conn: conn}
+		return &Conn{conn: conn, id: connIDGenerator.Add(1), protocol: protocol.RESP2}
 	}
 	c.conn = conn
+	c.closed.Store(false)
+	c.id = connIDGenerator.Add(1)
+	c.protocol = protocol.RESP2
 	return c
 }
 
@@ -70,9 +112,11 @@ func (c *Conn) Write(b []byte) (int, error) {
 	if len(b) == 0 {
 		return 0, nil
 	}
-	c.sendingData.Add(1)
-	// 为什么godis要写个闭包呢
-	defer c.sendingData.Done()
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed.Load() {
+		return 0, net.ErrClosed
+	}
 	return c.conn.Write(b)
 }
 
@@ -83,6 +127,11 @@ func (c *Conn) Name() string {
 	return ""
 }
 
+// ID returns the unique id of the connection
+func (c *Conn) ID() int64 {
+	return c.id
+}
+
 func (c *Conn) Subscribe(channel string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -102,19 +151,80 @@ func (c *Conn) UnSubsribe(channel string) {
 	delete(c.subs, channel)
 }
 
+// SubsCount returns the number of subscribed channels and patterns
 func (c *Conn) SubsCount() int {
-	return len(c.subs)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.subs) + len(c.psubs)
 }
 
 func (c *Conn) GetChannels() []string {
-	if c.subs == nil {
-		return make([]string, 0)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return mapKeys(c.subs)
+}
+
+// PSubscribe records a subscribed pattern
+func (c *Conn) PSubscribe(pattern string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.psubs == nil {
+		c.psubs = make(map[string]bool)
 	}
-	channels := make([]string, 0, len(c.subs))
-	for channel := range c.subs {
-		channels = append(channels, channel)
+	c.psubs[pattern] = true
+}
+
+// PUnSubscribe removes a subscribed pattern
+func (c *Conn) PUnSubscribe(pattern string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.psubs, pattern)
+}
+
+// GetPatterns returns subscribed patterns
+func (c *Conn) GetPatterns() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return mapKeys(c.psubs)
+}
+
+// SSubscribe records a subscribed shard channel
+func (c *Conn) SSubscribe(channel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ssubs == nil {
+		c.ssubs = make(map[string]bool)
 	}
-	return channels
+	c.ssubs[channel] = true
+}
+
+// SUnSubscribe removes a subscribed shard channel
+func (c *Conn) SUnSubscribe(channel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.ssubs, channel)
+}
+
+// SSubsCount returns the number of subscribed shard channels
+func (c *Conn) SSubsCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.ssubs)
+}
+
+// GetShardChannels returns subscribed shard channels
+func (c *Conn) GetShardChannels() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return mapKeys(c.ssubs)
+}
+
+func mapKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
 }
 
 func (c *Conn) SetPassword(password string) {
@@ -125,6 +235,16 @@ func (c *Conn) GetPassword() string {
 	return c.password
 }
 
+// GetProtocol returns RESP protocol version of the connection
+func (c *Conn) GetProtocol() int {
+	return c.protocol
+}
+
+// SetProtocol switches RESP protocol version of the connection
+func (c *Conn) SetProtocol(version int) {
+	c.protocol = version
+}
+
 func (c *Conn) InMultiState() bool {
 	return c.flags&flagMulti > 0
 }
@@ -133,6 +253,7 @@ func (c *Conn) SetMultiState(state bool) {
 	if !state {
 		c.watching = nil
 		c.queue = nil
+		c.txErrors = nil
 		c.flags &= ^flagMulti
 		return
 	}
@@ -143,7 +264,7 @@ func (c *Conn) GetQueuedCmdLine() [][][]byte {
 	return c.queue
 }
 
-func (c *Conn) EnqueueCMd(cmdLine [][]byte) {
+func (c *Conn) EnqueueCmd(cmdLine [][]byte) {
 	c.queue = append(c.queue, cmdLine)
 }
 
@@ -161,9 +282,9 @@ func (c *Conn) ClearQueuedCmds() {
 }
 
 // GetWatching returns watching keys and their version code when started watching
-func (c *Conn) GetWatching() map[string]uint32 {
+func (c *Conn) GetWatching() map[redis.WatchKey]uint32 {
 	if c.watching == nil {
-		c.watching = make(map[string]uint32)
+		c.watching = make(map[redis.WatchKey]uint32)
 	}
 	return c.watching
 }
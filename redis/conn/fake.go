@@ -0,0 +1,53 @@
+package conn
+
+import (
+	"bytes"
+	"goredis/redis/protocol"
+	"sync"
+)
+
+// FakeConn is a connection for tests, written data is kept in memory
+type FakeConn struct {
+	Conn
+	buf bytes.Buffer
+	wmu sync.Mutex
+}
+
+// NewFakeConn creates FakeConn
+func NewFakeConn() *FakeConn {
+	c := &FakeConn{}
+	c.id = connIDGenerator.Add(1)
+	c.protocol = protocol.RESP2
+	return c
+}
+
+// Write writes data to buffer
+func (c *FakeConn) Write(b []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.buf.Write(b)
+}
+
+// Bytes returns written data
+func (c *FakeConn) Bytes() []byte {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.buf.Bytes()
+}
+
+// Clean resets the buffer
+func (c *FakeConn) Clean() {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.buf.Reset()
+}
+
+// Close does nothing, fake connection is never put back into the pool
+func (c *FakeConn) Close() error {
+	return nil
+}
+
+// Name returns a fixed name
+func (c *FakeConn) Name() string {
+	return "fake"
+}
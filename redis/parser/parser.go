@@ -0,0 +1,232 @@
+// Package parser
+//
+// 实现了RESP2协议的流式解析, 服务端用它解析客户端发来的命令, 客户端用它解析服务端的回复
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"goredis/interface/redis"
+	"goredis/lib/logger"
+	"goredis/redis/protocol"
+	"io"
+	"math"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxBulkLen 与redis的proto-max-bulk-len默认值保持一致
+	maxBulkLen = 512 << 20
+	// maxArrayLen 与redis的multibulk长度上限保持一致
+	maxArrayLen = math.MaxInt32
+	// arrayPreallocLimit 数组预先分配的最大长度, 避免客户端声明很大的长度却不发送数据时占用大量内存
+	arrayPreallocLimit = 1024
+	// maxLineLen 内联命令和类型首行的最大长度, 与redis的PROTO_INLINE_MAX_SIZE保持一致
+	maxLineLen = 64 << 10
+	// maxNestingDepth 数组嵌套的最大深度, 避免恶意的深层嵌套耗尽栈空间
+	maxNestingDepth = 128
+)
+
+// Payload 存储解析得到的redis.Reply或者错误
+type Payload struct {
+	Data redis.Reply
+	Err  error
+}
+
+// ParseStream 从reader中持续读取数据, 通过channel返回解析结果
+// 遇到协议错误或者io错误时发送错误并关闭channel. 协议错误后无法确定下一个命令的起始位置, 与redis相同不再继续解析
+func ParseStream(reader io.Reader) <-chan *Payload {
+	ch := make(chan *Payload)
+	go parse0(reader, ch)
+	return ch
+}
+
+// ParseBytes 解析data中的所有回复
+func ParseBytes(data []byte) ([]redis.Reply, error) {
+	ch := make(chan *Payload)
+	go parse0(bytes.NewReader(data), ch)
+	var results []redis.Reply
+	for payload := range ch {
+		if payload.Err != nil {
+			if payload.Err == io.EOF {
+				break
+			}
+			return nil, payload.Err
+		}
+		results = append(results, payload.Data)
+	}
+	return results, nil
+}
+
+// ParseOne 解析data中的第一个回复
+func ParseOne(data []byte) (redis.Reply, error) {
+	ch := make(chan *Payload)
+	go parse0(bytes.NewReader(data), ch)
+	payload := <-ch
+	// 读完剩余的数据, 避免解析协程阻塞泄漏
+	go func() {
+		for range ch {
+		}
+	}()
+	return payload.Data, payload.Err
+}
+
+// parse0 在任何情况下退出时都会关闭ch, 调用方不会一直阻塞
+func parse0(rawReader io.Reader, ch chan<- *Payload) {
+	defer close(ch)
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error(err, string(debug.Stack()))
+		}
+	}()
+	reader := bufio.NewReader(rawReader)
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			ch <- &Payload{Err: err}
+			return
+		}
+		// 忽略空行, 例如telnet中直接回车
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		reply, err := parseLine(reader, line, 0)
+		if err != nil {
+			ch <- &Payload{Err: err}
+			return
+		}
+		ch <- &Payload{Data: reply}
+	}
+}
+
+// readLine 读取一行并去掉结尾的\r\n, 兼容只以\n结尾的内联命令. 超过maxLineLen时返回协议错误
+func readLine(reader *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLineLen {
+			return nil, protocolError("too big inline request")
+		}
+		line = append(line, chunk...)
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			return nil, err
+		}
+	}
+	line = line[:len(line)-1]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line, nil
+}
+
+// parseReply 读取并解析下一个完整的回复, 用于解析数组中的元素, depth为所在数组的嵌套深度
+func parseReply(reader *bufio.Reader, depth int) (redis.Reply, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, protocolError("empty line")
+	}
+	return parseLine(reader, line, depth)
+}
+
+// parseLine 根据首行的类型前缀解析回复, 数组和多行字符串会继续从reader读取
+func parseLine(reader *bufio.Reader, line []byte, depth int) (redis.Reply, error) {
+	switch line[0] {
+	case '+':
+		return protocol.MakeStatusReply(string(line[1:])), nil
+	case '-':
+		return protocol.MakeErrReply(string(line[1:])), nil
+	case ':':
+		value, err := strconv.ParseInt(string(line[1:]), 10, 64)
+		if err != nil {
+			return nil, protocolError("illegal number " + string(line[1:]))
+		}
+		return protocol.MakeIntReply(value), nil
+	case '$':
+		return parseBulkString(reader, line)
+	case '*':
+		return parseArray(reader, line, depth+1)
+	default:
+		return parseInline(line), nil
+	}
+}
+
+func parseBulkString(reader *bufio.Reader, header []byte) (redis.Reply, error) {
+	strLen, err := strconv.ParseInt(string(header[1:]), 10, 64)
+	if err != nil || strLen < -1 || strLen > maxBulkLen {
+		return nil, protocolError("illegal bulk string header: " + string(header))
+	}
+	if strLen == -1 {
+		return protocol.MakeNullBulkReply(), nil
+	}
+	body := make([]byte, strLen+2)
+	if _, err = io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+	if body[strLen] != '\r' || body[strLen+1] != '\n' {
+		return nil, protocolError("bulk string is not terminated by CRLF")
+	}
+	return protocol.MakeBulkReply(body[:strLen]), nil
+}
+
+// parseArray 解析数组, 元素全是字符串时返回MultiBulkReply, 否则返回MultiRawReply
+func parseArray(reader *bufio.Reader, header []byte, depth int) (redis.Reply, error) {
+	if depth > maxNestingDepth {
+		return nil, protocolError("too deep nested array")
+	}
+	n, err := strconv.ParseInt(string(header[1:]), 10, 64)
+	if err != nil || n < -1 || n > maxArrayLen {
+		return nil, protocolError("illegal array header: " + string(header))
+	}
+	if n == -1 {
+		return protocol.MakeNullMultiBulkReply(), nil
+	}
+	if n == 0 {
+		return protocol.MakeEmptyMultiBulkReply(), nil
+	}
+	replies := make([]redis.Reply, 0, min(n, arrayPreallocLimit))
+	allBulk := true
+	for i := int64(0); i < n; i++ {
+		reply, err := parseReply(reader, depth)
+		if err != nil {
+			return nil, err
+		}
+		switch reply.(type) {
+		case *protocol.BulkReply, *protocol.NullBulkReply:
+		default:
+			allBulk = false
+		}
+		replies = append(replies, reply)
+	}
+	if !allBulk {
+		return protocol.MakeMultiRawReply(replies), nil
+	}
+	args := make([][]byte, len(replies))
+	for i, reply := range replies {
+		if bulk, ok := reply.(*protocol.BulkReply); ok {
+			args[i] = bulk.Arg
+		}
+	}
+	return protocol.MakeMultiBulkReply(args), nil
+}
+
+// parseInline 解析以空格分隔的内联命令, 例如telnet中输入的 "PING"
+func parseInline(line []byte) redis.Reply {
+	fields := strings.Fields(string(line))
+	args := make([][]byte, len(fields))
+	for i, field := range fields {
+		args[i] = []byte(field)
+	}
+	return protocol.MakeMultiBulkReply(args)
+}
+
+func protocolError(msg string) error {
+	return &protocol.ProtocolErrReply{Msg: msg}
+}
@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"bytes"
+	"goredis/interface/redis"
+	"goredis/lib/utils"
+	"goredis/redis/protocol"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseStream(t *testing.T) {
+	replies := []redis.Reply{
+		protocol.MakeIntReply(1),
+		protocol.MakeStatusReply("OK"),
+		protocol.MakeErrReply("ERR unknown"),
+		protocol.MakeBulkReply([]byte("a\r\nb")), // test binary safe
+		protocol.MakeNullBulkReply(),
+		protocol.MakeMultiBulkReply([][]byte{
+			[]byte("a"),
+			[]byte("\r\n"),
+		}),
+		protocol.MakeEmptyMultiBulkReply(),
+		protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeBulkReply([]byte("0")),
+			protocol.MakeMultiBulkReply([][]byte{[]byte("k1"), []byte("k2")}),
+			protocol.MakeIntReply(-1),
+		}),
+	}
+	reqs := bytes.Buffer{}
+	for _, re := range replies {
+		reqs.Write(re.ToBytes())
+	}
+	reqs.Write([]byte("set a a" + protocol.CRLF)) // test text protocol
+	expected := make([]redis.Reply, len(replies))
+	copy(expected, replies)
+	expected = append(expected, protocol.MakeMultiBulkReply(utils.ToCmdLine("set", "a", "a")))
+
+	ch := ParseStream(bytes.NewReader(reqs.Bytes()))
+	i := 0
+	for payload := range ch {
+		if payload.Err != nil {
+			if payload.Err == io.EOF {
+				break
+			}
+			t.Error(payload.Err)
+			return
+		}
+		if payload.Data == nil {
+			t.Error("empty data")
+			return
+		}
+		exp := expected[i]
+		i++
+		if !utils.BytesEquals(exp.ToBytes(), payload.Data.ToBytes()) {
+			t.Errorf("parse failed: expected %q, actual %q", exp.ToBytes(), payload.Data.ToBytes())
+		}
+	}
+	if i != len(expected) {
+		t.Errorf("expected %d replies, actual %d", len(expected), i)
+	}
+}
+
+func TestParseProtocolError(t *testing.T) {
+	inputs := []string{
+		"*2\r\n$abc\r\nFLUSHALL\r\n",
+		"*99999999999999999\r\n",
+		"*-2\r\n",
+		strings.Repeat("*1\r\n", maxNestingDepth+1),
+		strings.Repeat("a", maxLineLen+1) + "\r\n",
+		"*" + strings.Repeat("1", maxLineLen),
+	}
+	for _, input := range inputs {
+		ch := ParseStream(bytes.NewReader([]byte(input)))
+		payload := <-ch
+		if _, ok := payload.Err.(*protocol.ProtocolErrReply); !ok {
+			t.Errorf("expected protocol error for %q, actual %v", input, payload.Err)
+		}
+		// 协议错误后不再解析剩余的数据, 避免把它们当作内联命令执行
+		if payload, ok := <-ch; ok {
+			t.Errorf("expected closed channel after protocol error, actual %v", payload)
+		}
+	}
+}
+
+func TestParseNullArray(t *testing.T) {
+	reply, err := ParseOne([]byte("*-1\r\n"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := reply.(*protocol.NullMultiBulkReply); !ok {
+		t.Errorf("expected null array, actual %q", reply.ToBytes())
+	}
+}
+
+func TestParseOne(t *testing.T) {
+	replies := []redis.Reply{
+		protocol.MakeIntReply(1),
+		protocol.MakeStatusReply("OK"),
+		protocol.MakeErrReply("ERR unknown"),
+		protocol.MakeBulkReply([]byte("a\r\nb")), // test binary safe
+		protocol.MakeNullBulkReply(),
+		protocol.MakeMultiBulkReply([][]byte{
+			[]byte("a"),
+			[]byte("\r\n"),
+		}),
+		protocol.MakeEmptyMultiBulkReply(),
+	}
+	for _, re := range replies {
+		result, err := ParseOne(re.ToBytes())
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !utils.BytesEquals(result.ToBytes(), re.ToBytes()) {
+			t.Errorf("parse failed: expected %q, actual %q", re.ToBytes(), result.ToBytes())
+		}
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	data := []byte("*2\r\n$3\r\nGET\r\n$1\r\na\r\n\r\nPING\r\n")
+	replies, err := ParseBytes(data)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(replies) != 2 {
+		t.Errorf("expected 2 replies, actual %d", len(replies))
+		return
+	}
+	if string(replies[1].ToBytes()) != "*1\r\n$4\r\nPING\r\n" {
+		t.Errorf("parse inline command failed: %q", replies[1].ToBytes())
+	}
+}
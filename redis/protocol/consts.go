@@ -29,6 +29,8 @@ func MakeOkReply() *OkReply {
 
 var nullBulkBytes = []byte("$-1\r\n")
 
+var nullBytes = []byte("_\r\n")
+
 // NullBulkReply is empty string
 type NullBulkReply struct{}
 
@@ -37,11 +39,42 @@ func (r *NullBulkReply) ToBytes() []byte {
 	return nullBulkBytes
 }
 
+// ToProtocolBytes marshal redis.Reply, RESP3 has a dedicated null type
+func (r *NullBulkReply) ToProtocolBytes(version int) []byte {
+	if version == RESP3 {
+		return nullBytes
+	}
+	return nullBulkBytes
+}
+
 // MakeNullBulkReply creates a new NullBulkReply
 func MakeNullBulkReply() *NullBulkReply {
 	return &NullBulkReply{}
 }
 
+var nullMultiBulkBytes = []byte("*-1\r\n")
+
+// NullMultiBulkReply is a null array, for example the reply of a timed out BLPOP
+type NullMultiBulkReply struct{}
+
+// ToBytes marshal redis.Reply
+func (r *NullMultiBulkReply) ToBytes() []byte {
+	return nullMultiBulkBytes
+}
+
+// ToProtocolBytes marshal redis.Reply, RESP3 has a dedicated null type
+func (r *NullMultiBulkReply) ToProtocolBytes(version int) []byte {
+	if version == RESP3 {
+		return nullBytes
+	}
+	return nullMultiBulkBytes
+}
+
+// MakeNullMultiBulkReply creates a new NullMultiBulkReply
+func MakeNullMultiBulkReply() *NullMultiBulkReply {
+	return &NullMultiBulkReply{}
+}
+
 var emptyMultiBulkBytes = []byte("*0\r\n")
 
 // EmptyMultiBulkReply is a empty list
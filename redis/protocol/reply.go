@@ -34,6 +34,14 @@ func (r *BulkReply) ToBytes() []byte {
 	return []byte("$" + strconv.Itoa(len(r.Arg)) + CRLF + string(r.Arg) + CRLF)
 }
 
+// ToProtocolBytes marshal redis.Reply, nil string is encoded as RESP3 null
+func (r *BulkReply) ToProtocolBytes(version int) []byte {
+	if r.Arg == nil && version == RESP3 {
+		return nullBytes
+	}
+	return r.ToBytes()
+}
+
 /* ---- Multi Bulk Reply ---- */
 
 // MultiBulkReply stores a list of string
@@ -63,6 +71,23 @@ func (r *MultiBulkReply) ToBytes() []byte {
 	return buf.Bytes()
 }
 
+// ToProtocolBytes marshal redis.Reply with given protocol version
+func (r *MultiBulkReply) ToProtocolBytes(version int) []byte {
+	if version != RESP3 {
+		return r.ToBytes()
+	}
+	var buf bytes.Buffer
+	buf.WriteString("*" + strconv.Itoa(len(r.Args)) + CRLF)
+	for _, arg := range r.Args {
+		if arg == nil {
+			buf.Write(nullBytes)
+		} else {
+			buf.WriteString("$" + strconv.Itoa(len(arg)) + CRLF + string(arg) + CRLF)
+		}
+	}
+	return buf.Bytes()
+}
+
 /* ---- Multi Raw Reply ---- */
 
 // MultiRawReply store complex list structure, for example GeoPos command
@@ -88,6 +113,13 @@ func (r *MultiRawReply) ToBytes() []byte {
 	return buf.Bytes()
 }
 
+// ToProtocolBytes marshal redis.Reply, nested replies use the same protocol version
+func (r *MultiRawReply) ToProtocolBytes(version int) []byte {
+	var buf bytes.Buffer
+	writeAggregate(&buf, '*', len(r.Replies), r.Replies, version)
+	return buf.Bytes()
+}
+
 /* ---- Status Reply ---- */
 
 // StatusReply stores a simple status string
@@ -153,7 +185,8 @@ func MakeErrReply(status string) *StandardErrReply {
 
 // IsErrorReply returns true if the given protocol is error
 func IsErrorReply(reply redis.Reply) bool {
-	return reply.ToBytes()[0] == '-'
+	b := reply.ToBytes()
+	return len(b) > 0 && b[0] == '-'
 }
 
 // ToBytes marshal redis.Reply
@@ -0,0 +1,279 @@
+package protocol
+
+import (
+	"bytes"
+	"goredis/interface/redis"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+const (
+	// RESP2 是连接默认使用的协议版本
+	RESP2 = 2
+	// RESP3 通过 HELLO 3 切换
+	RESP3 = 3
+)
+
+// Marshal 按照协议版本序列化回复, 未实现redis.ProtocolReply的回复在两个版本下编码相同
+func Marshal(reply redis.Reply, version int) []byte {
+	if r, ok := reply.(redis.ProtocolReply); ok {
+		return r.ToProtocolBytes(version)
+	}
+	return reply.ToBytes()
+}
+
+// writeAggregate 写入聚合类型的头部和所有元素, 元素按相同的协议版本序列化
+func writeAggregate(buf *bytes.Buffer, prefix byte, n int, replies []redis.Reply, version int) {
+	buf.WriteByte(prefix)
+	buf.WriteString(strconv.Itoa(n) + CRLF)
+	for _, reply := range replies {
+		buf.Write(Marshal(reply, version))
+	}
+}
+
+/* ---- Map Reply ---- */
+
+// MapReply 存储键值对, 如HGETALL的结果. RESP2下降级为键值交替的数组
+type MapReply struct {
+	// Pairs 按 key1, value1, key2, value2... 的顺序存储
+	Pairs []redis.Reply
+}
+
+// MakeMapReply creates MapReply
+func MakeMapReply(pairs []redis.Reply) *MapReply {
+	return &MapReply{Pairs: pairs}
+}
+
+// ToBytes marshal redis.Reply
+func (r *MapReply) ToBytes() []byte {
+	return r.ToProtocolBytes(RESP2)
+}
+
+// ToProtocolBytes marshal redis.Reply with given protocol version
+func (r *MapReply) ToProtocolBytes(version int) []byte {
+	var buf bytes.Buffer
+	if version == RESP3 {
+		writeAggregate(&buf, '%', len(r.Pairs)/2, r.Pairs, version)
+	} else {
+		writeAggregate(&buf, '*', len(r.Pairs), r.Pairs, version)
+	}
+	return buf.Bytes()
+}
+
+/* ---- Set Reply ---- */
+
+// SetReply 存储无序且不重复的元素, RESP2下降级为数组
+type SetReply struct {
+	Members []redis.Reply
+}
+
+// MakeSetReply creates SetReply
+func MakeSetReply(members []redis.Reply) *SetReply {
+	return &SetReply{Members: members}
+}
+
+// ToBytes marshal redis.Reply
+func (r *SetReply) ToBytes() []byte {
+	return r.ToProtocolBytes(RESP2)
+}
+
+// ToProtocolBytes marshal redis.Reply with given protocol version
+func (r *SetReply) ToProtocolBytes(version int) []byte {
+	var buf bytes.Buffer
+	prefix := byte('*')
+	if version == RESP3 {
+		prefix = '~'
+	}
+	writeAggregate(&buf, prefix, len(r.Members), r.Members, version)
+	return buf.Bytes()
+}
+
+/* ---- Push Reply ---- */
+
+// PushReply 是服务端主动推送的消息, 如pub/sub消息. RESP2下降级为数组
+type PushReply struct {
+	Items []redis.Reply
+}
+
+// MakePushReply creates PushReply
+func MakePushReply(items []redis.Reply) *PushReply {
+	return &PushReply{Items: items}
+}
+
+// ToBytes marshal redis.Reply
+func (r *PushReply) ToBytes() []byte {
+	return r.ToProtocolBytes(RESP2)
+}
+
+// ToProtocolBytes marshal redis.Reply with given protocol version
+func (r *PushReply) ToProtocolBytes(version int) []byte {
+	var buf bytes.Buffer
+	prefix := byte('*')
+	if version == RESP3 {
+		prefix = '>'
+	}
+	writeAggregate(&buf, prefix, len(r.Items), r.Items, version)
+	return buf.Bytes()
+}
+
+/* ---- Attribute Reply ---- */
+
+// AttributeReply 在回复之前附加一组属性, RESP2下属性会被丢弃
+type AttributeReply struct {
+	// Attrs 按 key1, value1, key2, value2... 的顺序存储
+	Attrs []redis.Reply
+	Reply redis.Reply
+}
+
+// MakeAttributeReply creates AttributeReply
+func MakeAttributeReply(attrs []redis.Reply, reply redis.Reply) *AttributeReply {
+	return &AttributeReply{Attrs: attrs, Reply: reply}
+}
+
+// ToBytes marshal redis.Reply
+func (r *AttributeReply) ToBytes() []byte {
+	return r.ToProtocolBytes(RESP2)
+}
+
+// ToProtocolBytes marshal redis.Reply with given protocol version
+func (r *AttributeReply) ToProtocolBytes(version int) []byte {
+	if version != RESP3 {
+		return Marshal(r.Reply, version)
+	}
+	var buf bytes.Buffer
+	writeAggregate(&buf, '|', len(r.Attrs)/2, r.Attrs, version)
+	buf.Write(Marshal(r.Reply, version))
+	return buf.Bytes()
+}
+
+/* ---- Double Reply ---- */
+
+// DoubleReply 存储浮点数, 如ZSCORE的结果. RESP2下降级为字符串
+type DoubleReply struct {
+	Value float64
+}
+
+// MakeDoubleReply creates DoubleReply
+func MakeDoubleReply(value float64) *DoubleReply {
+	return &DoubleReply{Value: value}
+}
+
+// ToBytes marshal redis.Reply
+func (r *DoubleReply) ToBytes() []byte {
+	return r.ToProtocolBytes(RESP2)
+}
+
+// ToProtocolBytes marshal redis.Reply with given protocol version
+func (r *DoubleReply) ToProtocolBytes(version int) []byte {
+	str := FormatFloat(r.Value)
+	if version == RESP3 {
+		return []byte("," + str + CRLF)
+	}
+	return MakeBulkReply([]byte(str)).ToBytes()
+}
+
+// FormatFloat 按redis的格式输出浮点数, 无穷大输出为 inf 和 -inf
+func FormatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "inf"
+	case math.IsInf(value, -1):
+		return "-inf"
+	case math.IsNaN(value):
+		return "nan"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+/* ---- Boolean Reply ---- */
+
+// BoolReply 存储布尔值, RESP2下降级为整数1或0
+type BoolReply struct {
+	Value bool
+}
+
+var (
+	trueReply  = &BoolReply{Value: true}
+	falseReply = &BoolReply{Value: false}
+)
+
+// MakeBoolReply returns BoolReply
+func MakeBoolReply(value bool) *BoolReply {
+	if value {
+		return trueReply
+	}
+	return falseReply
+}
+
+// ToBytes marshal redis.Reply
+func (r *BoolReply) ToBytes() []byte {
+	return r.ToProtocolBytes(RESP2)
+}
+
+// ToProtocolBytes marshal redis.Reply with given protocol version
+func (r *BoolReply) ToProtocolBytes(version int) []byte {
+	if version == RESP3 {
+		if r.Value {
+			return []byte("#t" + CRLF)
+		}
+		return []byte("#f" + CRLF)
+	}
+	if r.Value {
+		return []byte(":1" + CRLF)
+	}
+	return []byte(":0" + CRLF)
+}
+
+/* ---- Big Number Reply ---- */
+
+// BigNumberReply 存储超出int64范围的整数, RESP2下降级为字符串
+type BigNumberReply struct {
+	Value *big.Int
+}
+
+// MakeBigNumberReply creates BigNumberReply
+func MakeBigNumberReply(value *big.Int) *BigNumberReply {
+	return &BigNumberReply{Value: value}
+}
+
+// ToBytes marshal redis.Reply
+func (r *BigNumberReply) ToBytes() []byte {
+	return r.ToProtocolBytes(RESP2)
+}
+
+// ToProtocolBytes marshal redis.Reply with given protocol version
+func (r *BigNumberReply) ToProtocolBytes(version int) []byte {
+	str := r.Value.String()
+	if version == RESP3 {
+		return []byte("(" + str + CRLF)
+	}
+	return MakeBulkReply([]byte(str)).ToBytes()
+}
+
+/* ---- Verbatim String Reply ---- */
+
+// VerbatimReply 存储带格式的文本, 如INFO的结果. RESP2下降级为普通字符串
+type VerbatimReply struct {
+	// Format 是三个字符的格式, 如 txt 或 mkd
+	Format string
+	Text   []byte
+}
+
+// MakeVerbatimReply creates VerbatimReply
+func MakeVerbatimReply(format string, text []byte) *VerbatimReply {
+	return &VerbatimReply{Format: format, Text: text}
+}
+
+// ToBytes marshal redis.Reply
+func (r *VerbatimReply) ToBytes() []byte {
+	return r.ToProtocolBytes(RESP2)
+}
+
+// ToProtocolBytes marshal redis.Reply with given protocol version
+func (r *VerbatimReply) ToProtocolBytes(version int) []byte {
+	if version == RESP3 {
+		return []byte("=" + strconv.Itoa(len(r.Text)+4) + CRLF + r.Format + ":" + string(r.Text) + CRLF)
+	}
+	return MakeBulkReply(r.Text).ToBytes()
+}
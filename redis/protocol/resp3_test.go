@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"goredis/interface/redis"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		reply redis.Reply
+		resp2 string
+		resp3 string
+	}{
+		{
+			reply: MakeMapReply([]redis.Reply{MakeBulkReply([]byte("f")), MakeBulkReply([]byte("v"))}),
+			resp2: "*2\r\n$1\r\nf\r\n$1\r\nv\r\n",
+			resp3: "%1\r\n$1\r\nf\r\n$1\r\nv\r\n",
+		},
+		{
+			reply: MakeSetReply([]redis.Reply{MakeBulkReply([]byte("a"))}),
+			resp2: "*1\r\n$1\r\na\r\n",
+			resp3: "~1\r\n$1\r\na\r\n",
+		},
+		{
+			reply: MakePushReply([]redis.Reply{MakeBulkReply([]byte("message")), MakeDoubleReply(1.5)}),
+			resp2: "*2\r\n$7\r\nmessage\r\n$3\r\n1.5\r\n",
+			resp3: ">2\r\n$7\r\nmessage\r\n,1.5\r\n",
+		},
+		{
+			reply: MakeDoubleReply(math.Inf(-1)),
+			resp2: "$4\r\n-inf\r\n",
+			resp3: ",-inf\r\n",
+		},
+		{
+			reply: MakeBoolReply(true),
+			resp2: ":1\r\n",
+			resp3: "#t\r\n",
+		},
+		{
+			reply: MakeBigNumberReply(new(big.Int).Lsh(big.NewInt(1), 64)),
+			resp2: "$20\r\n18446744073709551616\r\n",
+			resp3: "(18446744073709551616\r\n",
+		},
+		{
+			reply: MakeVerbatimReply("txt", []byte("hi")),
+			resp2: "$2\r\nhi\r\n",
+			resp3: "=6\r\ntxt:hi\r\n",
+		},
+		{
+			reply: MakeAttributeReply([]redis.Reply{MakeBulkReply([]byte("ttl")), MakeIntReply(3)}, MakeIntReply(1)),
+			resp2: ":1\r\n",
+			resp3: "|1\r\n$3\r\nttl\r\n:3\r\n:1\r\n",
+		},
+		{
+			reply: MakeMultiRawReply([]redis.Reply{MakeNullBulkReply(), MakeBoolReply(false)}),
+			resp2: "*2\r\n$-1\r\n:0\r\n",
+			resp3: "*2\r\n_\r\n#f\r\n",
+		},
+		{
+			reply: MakeMultiBulkReply([][]byte{[]byte("a"), nil}),
+			resp2: "*2\r\n$1\r\na\r\n$-1\r\n",
+			resp3: "*2\r\n$1\r\na\r\n_\r\n",
+		},
+		{
+			reply: MakeIntReply(1),
+			resp2: ":1\r\n",
+			resp3: ":1\r\n",
+		},
+	}
+	for _, tt := range tests {
+		if actual := string(Marshal(tt.reply, RESP2)); actual != tt.resp2 {
+			t.Errorf("resp2: expected %q, actual %q", tt.resp2, actual)
+		}
+		if actual := string(tt.reply.ToBytes()); actual != tt.resp2 {
+			t.Errorf("ToBytes: expected %q, actual %q", tt.resp2, actual)
+		}
+		if actual := string(Marshal(tt.reply, RESP3)); actual != tt.resp3 {
+			t.Errorf("resp3: expected %q, actual %q", tt.resp3, actual)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Package server
+//
+// 实现了tcp.Handler, 负责解析客户端的命令并交给存储引擎执行
+package server
+
+import (
+	"context"
+	"errors"
+	"goredis/database"
+	databaseface "goredis/interface/database"
+	"goredis/interface/redis"
+	"goredis/lib/logger"
+	"goredis/lib/sync/atomic"
+	"goredis/redis/conn"
+	"goredis/redis/parser"
+	"goredis/redis/protocol"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// closeRetryInterval 连接断开后重复清理的间隔
+const closeRetryInterval = time.Second
+
+var unknownErrReplyBytes = []byte("-ERR unknown\r\n")
+
+var protocolErrReplyBytes = []byte("-ERR Protocol error: expected a multibulk request\r\n")
+
+// Handler 实现了tcp.Handler, 作为redis服务端处理客户端连接
+type Handler struct {
+	activeConn sync.Map // *conn.Conn -> placeholder
+	db         databaseface.DB
+	closing    atomic.Boolean
+}
+
+// MakeHandler 创建使用单机存储引擎的Handler
+func MakeHandler() *Handler {
+	return &Handler{
+		db: database.NewStandaloneServer(),
+	}
+}
+
+// closeClient 先清理存储引擎中连接相关的状态, 再关闭连接并放回连接池.
+// 只能由处理该连接的Handle协程在退出前调用, 其他协程只能关闭连接
+func (h *Handler) closeClient(client *conn.Conn) {
+	h.db.AfterClientClose(client)
+	h.activeConn.Delete(client)
+	client.Release()
+}
+
+// Handle 读取并执行客户端发送的命令, 直到连接关闭
+func (h *Handler) Handle(ctx context.Context, rawConn net.Conn) {
+	if h.closing.Get() {
+		// 正在关闭, 拒绝新连接
+		_ = rawConn.Close()
+		return
+	}
+
+	client := conn.NewConn(rawConn)
+	h.activeConn.Store(client, struct{}{})
+
+	ch := h.watchClose(client, parser.ParseStream(rawConn))
+	for payload := range ch {
+		if payload.Err != nil {
+			if isClosedErr(payload.Err) {
+				// 客户端关闭了连接
+				logger.Info("connection closed: " + client.RemoteAddr().String())
+				h.closeClient(client)
+				return
+			}
+			// 协议错误, 回复错误后关闭连接, 剩余的数据无法可靠地解析
+			var errReply redis.Reply = protocol.MakeErrReply(payload.Err.Error())
+			if r, ok := payload.Err.(redis.Reply); ok {
+				errReply = r
+			}
+			_, _ = client.Write(errReply.ToBytes())
+			logger.Info("protocol error, close connection: " + client.RemoteAddr().String())
+			h.closeClient(client)
+			return
+		}
+		// 请求只能是字符串数组或者内联命令, 整个回复已经被解析完, 回复错误后可以继续处理下一个请求
+		r, ok := payload.Data.(*protocol.MultiBulkReply)
+		if !ok {
+			_, _ = client.Write(protocolErrReplyBytes)
+			continue
+		}
+		result := h.db.Exec(client, r.Args)
+		if result != nil {
+			_, _ = client.Write(protocol.Marshal(result, client.GetProtocol()))
+		} else {
+			_, _ = client.Write(unknownErrReplyBytes)
+		}
+	}
+	// 解析协程异常退出时channel被关闭
+	h.closeClient(client)
+}
+
+func isClosedErr(err error) bool {
+	return err == io.EOF ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		strings.Contains(err.Error(), "use of closed network connection")
+}
+
+// watchClose 转发解析结果, 发现连接断开时立即调用AfterClientClose.
+// 此时主循环可能正阻塞在BLPOP等命令中, 在它取走断开事件之前会定期重试, 确保阻塞的命令能够返回
+func (h *Handler) watchClose(client *conn.Conn, ch <-chan *parser.Payload) <-chan *parser.Payload {
+	out := make(chan *parser.Payload)
+	go func() {
+		defer close(out)
+		for payload := range ch {
+			if payload.Err == nil || !isClosedErr(payload.Err) {
+				out <- payload
+				continue
+			}
+			for {
+				h.db.AfterClientClose(client)
+				select {
+				case out <- payload:
+					return
+				case <-time.After(closeRetryInterval):
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Close 停止接收新连接并关闭所有活跃的连接.
+// 只关闭底层连接, 各个Handle协程读到连接关闭后自己清理并放回连接池
+func (h *Handler) Close() error {
+	logger.Info("handler shutting down...")
+	h.closing.Set(true)
+	h.activeConn.Range(func(key, value any) bool {
+		client := key.(*conn.Conn)
+		_ = client.Close()
+		return true
+	})
+	h.db.Close()
+	return nil
+}
@@ -24,7 +24,7 @@ type Config struct {
 var ClientCounter int32
 
 func ListenAndServeWithSignal(cfg *Config, handler tcp.Handler) error {
-	closeChan, signalChan := make(chan struct{}), make(chan os.Signal)
+	closeChan, signalChan := make(chan struct{}), make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGHUP)
 	go func() {
 		sig := <-signalChan
@@ -76,7 +76,7 @@ func ListenAndServe(listen net.Listener, handler tcp.Handler, closeChan <-chan s
 
 		// handle
 		logger.Info("accept link")
-		ClientCounter++
+		atomic.AddInt32(&ClientCounter, 1)
 		waitDone.Add(1)
 		go func() {
 			defer func() {
@@ -85,6 +85,7 @@ func ListenAndServe(listen net.Listener, handler tcp.Handler, closeChan <-chan s
 			}()
 			handler.Handle(ctx, conn)
 		}()
-		waitDone.Wait()
 	}
+	// 等待所有连接处理完毕
+	waitDone.Wait()
 }